@@ -0,0 +1,79 @@
+// Command jobfit is the whole JobFit service in one binary, with the web app
+// built in. Its subcommands serve the API, run the worker and do one-off
+// maintenance; run it without arguments to list them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"aichatbot/internal/jobfit"
+)
+
+type command struct {
+	name, summary string
+	run           func(args []string)
+}
+
+var commands = []command{
+	{"serve", "serve the web app and HTTP API", noArgs("serve", jobfit.RunAPI)},
+	{"worker", "run queued analyses, digests, webhook deliveries and the Kafka sink", noArgs("worker", jobfit.RunWorker)},
+	{"migrate", "move keys written before namespacing into APP_ENV's namespace", noArgs("migrate", jobfit.RunMigrate)},
+	{"eval", "score labelled resumes and report how far off the model is", runEval},
+	{"check", "validate the configuration and connections, then exit", noArgs("check", jobfit.RunCheck)},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: jobfit <command> [flags]\n\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun jobfit <command> -h for a command's flags. Settings come from the environment or .env.")
+}
+
+// noArgs wraps a command that takes no flags or arguments.
+func noArgs(name string, run func()) func([]string) {
+	return func(args []string) {
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		fs.Parse(args)
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "jobfit %s takes no arguments\n", name)
+			os.Exit(2)
+		}
+		run()
+	}
+}
+
+func runEval(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	tolerance := fs.Int("tolerance", 10, "how many points a score may be off before the case fails")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jobfit eval [-tolerance n] cases.json\n\ncases.json is an array of {name, resume, jobDescription, expectedScore}.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	jobfit.RunEval(fs.Arg(0), *tolerance)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
+		}
+	}
+	if os.Args[1] != "-h" && os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "jobfit: unknown command %q\n\n", os.Args[1])
+	}
+	usage()
+	os.Exit(2)
+}
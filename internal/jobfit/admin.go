@@ -0,0 +1,30 @@
+package jobfit
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin only lets requests through that carry ADMIN_TOKEN as a bearer
+// token. When no token is configured the admin API doesn't exist at all.
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !app.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdmin reports whether the request carries ADMIN_TOKEN as a bearer token.
+func (app *application) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && app.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(app.adminToken)) == 1
+}
@@ -0,0 +1,130 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// How long a finished analysis can be fetched by ID (e.g. through a share link).
+const analysisRetention = 30 * 24 * time.Hour
+
+// analysisRecord is what gets persisted for every completed analysis.
+type analysisRecord struct {
+	AnalysisResponse
+	ResumeID  string    `json:"resumeId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Tags and Notes are the owner's own annotations, set with
+	// PATCH /history/{id}. They are private: analysisView leaves them out
+	// for anyone else holding the ID.
+	Tags  []string `json:"tags,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+	// CompanyBrief is the latest research brief attached with
+	// POST /company-brief.
+	CompanyBrief *companyBrief `json:"companyBrief,omitempty"`
+	// DeletedAt is set while the analysis is in its owner's trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+var errAnalysisNotFound = errors.New("analysis not found")
+
+func analysisKey(id string) string {
+	return rkey("analysis", id)
+}
+
+func (app *application) saveAnalysis(ctx context.Context, rec *analysisRecord) error {
+	data, err := app.marshalSealed(analysisKey(rec.ID), rec)
+	if err != nil {
+		return err
+	}
+	return app.rdb.Set(ctx, analysisKey(rec.ID), data, analysisRetention).Err()
+}
+
+// updateAnalysis overwrites an existing record without extending its retention.
+func (app *application) updateAnalysis(ctx context.Context, rec *analysisRecord) error {
+	data, err := app.marshalSealed(analysisKey(rec.ID), rec)
+	if err != nil {
+		return err
+	}
+	return app.rdb.Set(ctx, analysisKey(rec.ID), data, redis.KeepTTL).Err()
+}
+
+// loadAnalysis returns a stored analysis. Analyses in the trash are treated
+// as not found.
+func (app *application) loadAnalysis(ctx context.Context, id string) (*analysisRecord, error) {
+	rec, err := app.readAnalysis(ctx, id)
+	if err == nil && rec.DeletedAt != nil {
+		return nil, errAnalysisNotFound
+	}
+	return rec, err
+}
+
+// readAnalysis is loadAnalysis including analyses in the trash.
+func (app *application) readAnalysis(ctx context.Context, id string) (*analysisRecord, error) {
+	data, err := app.rdb.Get(ctx, analysisKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errAnalysisNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec analysisRecord
+	if err := app.unmarshalSealed(analysisKey(id), data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// sharedAnalysis is what anyone with an analysis's ID sees, as through a share
+// link: the analysis itself, without its owner's tags, notes, resume or
+// company research.
+type sharedAnalysis struct {
+	AnalysisResponse
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// analysisView returns rec as the caller may see it: the whole record for its
+// owner, otherwise only what a share link shows.
+func analysisView(rec *analysisRecord, owned bool) any {
+	if owned {
+		return rec
+	}
+	return sharedAnalysis{AnalysisResponse: rec.AnalysisResponse, CreatedAt: rec.CreatedAt}
+}
+
+// getAnalysisHandler serves a previously completed analysis by its ID. The ID
+// works as a share link, so only the owner gets the whole record.
+func (app *application) getAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+
+	rec, err := app.loadAnalysis(r.Context(), id)
+	if errors.Is(err, errAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not load analysis", http.StatusInternalServerError)
+		return
+	}
+
+	owned := false
+	if owner, ok := app.existingClientID(r); ok {
+		if owned, err = app.ownsAnalysis(r, owner, id); err != nil {
+			// Fall back to what anyone with the link would see.
+			app.logger.Error("failed to check analysis ownership", "analysisID", id, "error", err)
+			owned = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analysisView(rec, owned))
+}
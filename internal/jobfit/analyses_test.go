@@ -0,0 +1,50 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAnalysisViewHidesPrivateFields(t *testing.T) {
+	deleted := time.Now().UTC()
+	rec := &analysisRecord{
+		AnalysisResponse: AnalysisResponse{ID: "01J00000000000000000000000", JobTitle: "Engineer", MatchScore: 72},
+		ResumeID:         "resume-1",
+		CreatedAt:        time.Now().UTC(),
+		Tags:             []string{"dream job"},
+		Notes:            "needs rewrite; asking 150k",
+		CompanyBrief:     &companyBrief{},
+		DeletedAt:        &deleted,
+	}
+	private := []string{"tags", "notes", "resumeId", "companyBrief", "deletedAt"}
+
+	decode := func(owned bool) map[string]any {
+		data, err := json.Marshal(analysisView(rec, owned))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		return fields
+	}
+
+	shared := decode(false)
+	for _, name := range private {
+		if _, ok := shared[name]; ok {
+			t.Errorf("a non-owner sees %q", name)
+		}
+	}
+	if shared["jobTitle"] != "Engineer" || shared["matchScore"] != float64(72) || shared["createdAt"] == nil {
+		t.Errorf("the shared view is missing the analysis: %v", shared)
+	}
+
+	own := decode(true)
+	for _, name := range private {
+		if _, ok := own[name]; !ok {
+			t.Errorf("the owner doesn't see %q", name)
+		}
+	}
+}
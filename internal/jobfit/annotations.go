@@ -0,0 +1,117 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	maxTags        = 20
+	maxTagLength   = 32
+	maxNotesLength = 2000
+)
+
+// annotationUpdate is the body of PATCH /history/{id}. Omitted fields are left
+// unchanged; an empty list or string clears them.
+type annotationUpdate struct {
+	Tags  *[]string `json:"tags"`
+	Notes *string   `json:"notes"`
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags, keeping their order.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+		if t == "" || slices.Contains(out, t) {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return nil, errors.New("tags must be at most 32 characters")
+		}
+		out = append(out, t)
+	}
+	if len(out) > maxTags {
+		return nil, errors.New("an analysis can have at most 20 tags")
+	}
+	return out, nil
+}
+
+// ownsAnalysis reports whether id is in owner's history.
+func (app *application) ownsAnalysis(r *http.Request, owner, id string) (bool, error) {
+	err := app.rdb.ZScore(r.Context(), historyKey(owner), id).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// annotateHistoryHandler sets the tags and notes on one of the caller's analyses.
+func (app *application) annotateHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+
+	var upd annotationUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var tags []string
+	if upd.Tags != nil {
+		var err error
+		if tags, err = normalizeTags(*upd.Tags); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if upd.Notes != nil && len(*upd.Notes) > maxNotesLength {
+		http.Error(w, "notes must be at most 2000 characters", http.StatusBadRequest)
+		return
+	}
+
+	owned, err := app.ownsAnalysis(r, owner, id)
+	if err != nil {
+		app.logger.Error("failed to check analysis ownership", "analysisID", id, "error", err)
+		http.Error(w, "Could not update analysis", http.StatusInternalServerError)
+		return
+	}
+	if !owned {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+
+	rec, err := app.loadAnalysis(r.Context(), id)
+	if errors.Is(err, errAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not update analysis", http.StatusInternalServerError)
+		return
+	}
+
+	if upd.Tags != nil {
+		rec.Tags = tags
+	}
+	if upd.Notes != nil {
+		rec.Notes = strings.TrimSpace(*upd.Notes)
+	}
+	if err := app.updateAnalysis(r.Context(), rec); err != nil {
+		app.logger.Error("failed to store analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not update analysis", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
@@ -0,0 +1,144 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxAnnouncementLength    = 500
+	announcementLevelInfo    = "info"
+	announcementLevelWarning = "warning"
+)
+
+func announcementsKey() string { return rkey("announcements") }
+
+// An announcement is a service-wide banner message, e.g. planned maintenance.
+type announcement struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	Level     string    `json:"level"`
+	StartsAt  time.Time `json:"startsAt,omitzero"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (a *announcement) activeAt(t time.Time) bool {
+	return (a.StartsAt.IsZero() || !t.Before(a.StartsAt)) && (a.ExpiresAt.IsZero() || t.Before(a.ExpiresAt))
+}
+
+// loadAnnouncements returns every stored announcement, oldest first.
+func (app *application) loadAnnouncements(ctx context.Context) ([]*announcement, error) {
+	vals, err := app.rdb.HVals(ctx, announcementsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*announcement, 0, len(vals))
+	for _, v := range vals {
+		var a announcement
+		if err := json.Unmarshal([]byte(v), &a); err != nil {
+			app.logger.Warn("skipping unreadable announcement", "error", err)
+			continue
+		}
+		list = append(list, &a)
+	}
+	slices.SortFunc(list, func(a, b *announcement) int { return strings.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// announcementsHandler returns the announcements currently in effect. The
+// frontend polls it to show a banner.
+func (app *application) announcementsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.loadAnnouncements(r.Context())
+	if err != nil {
+		app.logger.Error("failed to load announcements", "error", err)
+		http.Error(w, "Could not load announcements", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	active := make([]*announcement, 0, len(list))
+	for _, a := range list {
+		if a.activeAt(now) {
+			active = append(active, a)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	json.NewEncoder(w).Encode(active)
+}
+
+// adminListAnnouncementsHandler returns all announcements, including scheduled
+// and expired ones.
+func (app *application) adminListAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := app.loadAnnouncements(r.Context())
+	if err != nil {
+		app.logger.Error("failed to load announcements", "error", err)
+		http.Error(w, "Could not load announcements", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (app *application) adminCreateAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	var a announcement
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	a.Message = strings.TrimSpace(a.Message)
+	if a.Message == "" || len(a.Message) > maxAnnouncementLength {
+		http.Error(w, "message must be between 1 and 500 characters", http.StatusBadRequest)
+		return
+	}
+	if a.Level == "" {
+		a.Level = announcementLevelInfo
+	}
+	if a.Level != announcementLevelInfo && a.Level != announcementLevelWarning {
+		http.Error(w, "level must be info or warning", http.StatusBadRequest)
+		return
+	}
+	if !a.ExpiresAt.IsZero() && !a.ExpiresAt.After(a.StartsAt) {
+		http.Error(w, "expiresAt must be after startsAt", http.StatusBadRequest)
+		return
+	}
+	a.CreatedAt = time.Now().UTC()
+	a.ID = newULID(a.CreatedAt)
+
+	data, err := json.Marshal(&a)
+	if err != nil {
+		http.Error(w, "Invalid announcement", http.StatusBadRequest)
+		return
+	}
+	if err := app.rdb.HSet(r.Context(), announcementsKey(), a.ID, data).Err(); err != nil {
+		app.logger.Error("failed to store announcement", "error", err)
+		http.Error(w, "Could not store announcement", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("announcement created", "announcementID", a.ID, "level", a.Level)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&a)
+}
+
+func (app *application) adminDeleteAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	n, err := app.rdb.HDel(r.Context(), announcementsKey(), id).Result()
+	if err != nil {
+		app.logger.Error("failed to delete announcement", "announcementID", id, "error", err)
+		http.Error(w, "Could not delete announcement", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return
+	}
+	app.logger.Info("announcement deleted", "announcementID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
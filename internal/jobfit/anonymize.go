@@ -0,0 +1,155 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	candidatePlaceholder   = "[Candidate]"
+	schoolPlaceholder      = "[School]"
+	redactedPlaceholder    = "[Redacted]"
+	maxAnonymizeResumeSize = 50000
+)
+
+var (
+	photoRegex  = regexp.MustCompile(`(?im)^.*(?:\bphoto(?:graph)?\b|\bheadshot\b|\S+\.(?:jpe?g|png|gif|webp|heic)\b).*$`)
+	honorificRe = regexp.MustCompile(`\b(?:Mr|Mrs|Ms|Miss|Mx)\.?\s+`)
+
+	// Pronoun phrases whose verb has to change with the pronoun come first,
+	// so "she is" becomes "they are" rather than "they is".
+	pronounRegex = regexp.MustCompile(`\b(?i:(he|she) (is|was|has|does)|he|she|him|his|hers|her|himself|herself)\b`)
+	pronounVerbs = map[string]string{"is": "are", "was": "were", "has": "have", "does": "do"}
+	pronounWords = map[string]string{
+		"he": "they", "she": "they", "him": "them", "his": "their", "her": "their",
+		"hers": "theirs", "himself": "themselves", "herself": "themselves",
+	}
+)
+
+type anonymizeRequest struct {
+	Resume string `json:"resume"`
+	// MaskSchools also hides institution names, which can reveal age,
+	// background or location.
+	MaskSchools bool `json:"maskSchools"`
+}
+
+type anonymizeResponse struct {
+	Resume string `json:"resume"`
+	// Redactions counts what was masked, keyed by kind.
+	Redactions map[string]int `json:"redactions"`
+}
+
+// matchCase returns repl with the same leading capitalization as orig.
+func matchCase(orig, repl string) string {
+	if orig != "" && orig[0] >= 'A' && orig[0] <= 'Z' {
+		return strings.ToUpper(repl[:1]) + repl[1:]
+	}
+	return repl
+}
+
+// replaceLiteral swaps every occurrence of old in s, counting them. Matches
+// must be whole words where old starts or ends with a letter or digit, and are
+// case-insensitive if fold is set.
+func replaceLiteral(s, old, repl string, fold bool, counts map[string]int, kind string) string {
+	if strings.TrimSpace(old) == "" {
+		return s
+	}
+	pattern := regexp.QuoteMeta(old)
+	if wordRegex.MatchString(old[:1]) {
+		pattern = `\b` + pattern
+	}
+	if wordRegex.MatchString(old[len(old)-1:]) {
+		pattern += `\b`
+	}
+	if fold {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.MustCompile(pattern).ReplaceAllStringFunc(s, func(string) string {
+		counts[kind]++
+		return repl
+	})
+}
+
+// anonymizeResume masks details that identify the candidate or hint at their
+// gender, for blind screening. Structure and everything job-related are kept.
+func anonymizeResume(text string, maskSchools bool) anonymizeResponse {
+	sr := parseResumeRules(text)
+	counts := map[string]int{}
+
+	for _, v := range []string{sr.Contact.Email, sr.Contact.Phone, sr.Contact.LinkedIn, sr.Contact.Website, sr.Contact.Location} {
+		text = replaceLiteral(text, v, redactedPlaceholder, true, counts, "contact")
+	}
+
+	if name := sr.Contact.Name; name != "" {
+		text = replaceLiteral(text, name, candidatePlaceholder, false, counts, "name")
+		// Also catch the first or last name on its own, e.g. in a summary
+		// written in the third person.
+		for _, part := range strings.Fields(name) {
+			if len(strings.Trim(part, ".")) > 1 {
+				text = replaceLiteral(text, part, candidatePlaceholder, false, counts, "name")
+			}
+		}
+	}
+
+	text = photoRegex.ReplaceAllStringFunc(text, func(string) string {
+		counts["photo"]++
+		return redactedPlaceholder
+	})
+
+	text = honorificRe.ReplaceAllStringFunc(text, func(string) string {
+		counts["gendered"]++
+		return ""
+	})
+	text = pronounRegex.ReplaceAllStringFunc(text, func(m string) string {
+		counts["gendered"]++
+		if pronoun, verb, ok := strings.Cut(m, " "); ok {
+			return matchCase(pronoun, "they") + " " + pronounVerbs[strings.ToLower(verb)]
+		}
+		return matchCase(m, pronounWords[strings.ToLower(m)])
+	})
+
+	if maskSchools {
+		for _, e := range sr.Education {
+			text = replaceLiteral(text, e.Institution, schoolPlaceholder, true, counts, "school")
+		}
+		// Catch schools the parser didn't attribute to an education entry.
+		text = schoolNameRegex.ReplaceAllStringFunc(text, func(string) string {
+			counts["school"]++
+			return schoolPlaceholder
+		})
+	}
+
+	return anonymizeResponse{Resume: text, Redactions: counts}
+}
+
+// schoolNameRegex matches names like "University of Michigan" or "Boston
+// College". A bare "School" isn't enough, so placeholders aren't re-matched.
+var schoolNameRegex = regexp.MustCompile(`(?:\b[A-Z][A-Za-z&.'-]*\s+){1,4}` + schoolWords + `(?:\s+of(?:\s+[A-Z][A-Za-z&.'-]*){1,4})?|\b` + schoolWords + `\s+of(?:\s+[A-Z][A-Za-z&.'-]*){1,4}`)
+
+const schoolWords = `(?:University|College|Institute|Academy|Polytechnic|School)`
+
+// anonymizeHandler returns a blind-screening copy of a resume. It's purely
+// rule-based, so it doesn't use the model or draw on the quota.
+func (app *application) anonymizeHandler(w http.ResponseWriter, r *http.Request) {
+	var req anonymizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" {
+		http.Error(w, "A resume is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resume) > maxAnonymizeResumeSize {
+		http.Error(w, "Resume is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp := anonymizeResume(req.Resume, req.MaskSchools)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		app.logger.Error("failed to encode response", "error", err)
+	}
+}
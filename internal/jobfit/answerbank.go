@@ -0,0 +1,179 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxAnswerBankInputSize = 50000
+	maxAnswerBankThemes    = 8
+	maxAnswerBankTheme     = 60
+)
+
+// defaultAnswerThemes are the behavioral questions nearly every interview
+// asks some version of.
+var defaultAnswerThemes = []string{"conflict", "failure", "leadership", "teamwork", "ambiguity", "tight deadline"}
+
+type answerBankRequest struct {
+	Resume string `json:"resume"`
+	// JobDescription, if given, steers the bank towards stories the job
+	// cares about.
+	JobDescription string   `json:"jobDescription,omitempty"`
+	Themes         []string `json:"themes,omitempty"`
+}
+
+// evidenceLine is a resume line a story is drawn from. Line is 1-based in
+// the resume as sent.
+type evidenceLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// behavioralAnswer is a STAR answer to a likely interview question, with the
+// resume lines it rests on so the candidate can check it's theirs.
+type behavioralAnswer struct {
+	Theme     string `json:"theme"`
+	Question  string `json:"question"`
+	Situation string `json:"situation"`
+	Task      string `json:"task"`
+	Action    string `json:"action"`
+	Result    string `json:"result"`
+	// Answer is the story told in the first person, about a minute long
+	// spoken.
+	Answer   string         `json:"answer"`
+	Evidence []evidenceLine `json:"evidence"`
+	// Prompts are details the resume doesn't give that the candidate should
+	// be ready to add, such as what they personally said in a conflict.
+	Prompts []string `json:"prompts"`
+}
+
+type answerBank struct {
+	ID      string             `json:"id"`
+	Answers []behavioralAnswer `json:"answers"`
+	// Uncovered are themes no story on the resume fits.
+	Uncovered []string `json:"uncovered"`
+}
+
+// answerBankHandler mines a resume for stories and writes a bank of
+// behavioral interview answers, one or two per theme, each tied to the
+// bullets it came from. Answers citing no real resume line are dropped, so
+// every story in the bank can be traced back to the resume.
+func (app *application) answerBankHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req answerBankRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" {
+		http.Error(w, "A resume is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resume) > maxAnswerBankInputSize || len(req.JobDescription) > maxAnswerBankInputSize {
+		http.Error(w, "Resume or job description is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(req.Themes) > maxAnswerBankThemes {
+		http.Error(w, fmt.Sprintf("At most %d themes can be given", maxAnswerBankThemes), http.StatusBadRequest)
+		return
+	}
+	themes := defaultAnswerThemes
+	if len(req.Themes) > 0 {
+		themes = nil
+		for _, t := range req.Themes {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t == "" || len(t) > maxAnswerBankTheme {
+				http.Error(w, fmt.Sprintf("Themes must be 1 to %d characters", maxAnswerBankTheme), http.StatusBadRequest)
+				return
+			}
+			themes = append(themes, t)
+		}
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionAnswers); !ok {
+		return
+	}
+
+	bank := answerBank{ID: newULID(time.Now())}
+	log := app.logger.With("answerBankID", bank.ID, "ip", ip)
+
+	lines := strings.Split(req.Resume, "\n")
+	var numbered strings.Builder
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			fmt.Fprintf(&numbered, "%d: %s\n", i+1, l)
+		}
+	}
+	job := ""
+	if req.JobDescription != "" {
+		job = fmt.Sprintf("Prefer the stories and question wordings most relevant to this job:\n\t\t---\n\t\t%s\n\t\t---", req.JobDescription)
+	}
+	prompt := fmt.Sprintf(`
+		Find the stories in the candidate's resume that could answer behavioral interview questions, and write an answer bank.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "answers": a JSON array with one or two objects for each theme (%s) a story on the resume fits, leaving out themes none does, each with "theme" (the theme exactly as given), "question" (a likely interview question for the theme), "situation", "task", "action" and "result" (one or two sentences each), "answer" (a first-person spoken answer of 120 to 180 words following the STAR structure), "evidence" (a JSON array of the line numbers, as given, the story is drawn from) and "prompts" (a JSON array of up to three short questions about details the resume doesn't give that the candidate should be ready to add).
+		Build each story only from what the resume says; where a theme like conflict or failure needs details the resume can't give, keep them general and ask for them in "prompts" rather than inventing them. A resume line may support more than one story, but give each theme a different story where the resume allows.
+		%s
+
+		**Resume (numbered lines):**
+		---
+		%s
+		---
+	`, strings.Join(themes, ", "), job, numbered.String())
+
+	var out struct {
+		Answers []struct {
+			behavioralAnswer
+			Evidence []int `json:"evidence"`
+		} `json:"answers"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+
+	bank.Answers = []behavioralAnswer{}
+	for _, a := range out.Answers {
+		answer := a.behavioralAnswer
+		answer.Evidence = []evidenceLine{}
+		for _, n := range a.Evidence {
+			if n >= 1 && n <= len(lines) && strings.TrimSpace(lines[n-1]) != "" {
+				answer.Evidence = append(answer.Evidence, evidenceLine{Line: n, Text: strings.TrimSpace(lines[n-1])})
+			}
+		}
+		if len(answer.Evidence) == 0 {
+			continue
+		}
+		if answer.Prompts == nil {
+			answer.Prompts = []string{}
+		}
+		bank.Answers = append(bank.Answers, answer)
+	}
+	bank.Uncovered = []string{}
+	for _, t := range themes {
+		covered := false
+		for _, a := range bank.Answers {
+			covered = covered || strings.EqualFold(a.Theme, t)
+		}
+		if !covered {
+			bank.Uncovered = append(bank.Uncovered, t)
+		}
+	}
+	log.Info("built answer bank", "answers", len(bank.Answers), "uncovered", len(bank.Uncovered))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(bank); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,142 @@
+package jobfit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion        = "2023-06-01"
+	// anthropicMaxTokens bounds a reply; the longest, a full analysis with a
+	// target plan, is well under it.
+	anthropicMaxTokens = 8192
+)
+
+// anthropicTiers are the default Claude models for each of modelTiers.
+// ANTHROPIC_MODEL and ANTHROPIC_MODEL_LITE override them.
+var anthropicTiers = map[string]string{
+	"standard": "claude-sonnet-4-5",
+	"lite":     "claude-haiku-4-5",
+}
+
+// anthropicSystemPrompt holds Claude to the reply format every prompt asks
+// for, since the messages API has no JSON mode.
+const anthropicSystemPrompt = "You are a careful career advisor. Reply with a single JSON object exactly as the user asks, with no text, explanation or markdown before or after it."
+
+// anthropicModel calls Claude through Anthropic's messages API.
+type anthropicModel struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	models  map[string]string // by tier
+}
+
+func newAnthropicModel() (*anthropicModel, error) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("LLM_PROVIDER=anthropic needs ANTHROPIC_API_KEY")
+	}
+	return &anthropicModel{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(cmp.Or(os.Getenv("ANTHROPIC_BASE_URL"), defaultAnthropicBaseURL), "/"),
+		apiKey:  apiKey,
+		models: map[string]string{
+			"standard": cmp.Or(os.Getenv("ANTHROPIC_MODEL"), anthropicTiers["standard"]),
+			"lite":     cmp.Or(os.Getenv("ANTHROPIC_MODEL_LITE"), anthropicTiers["lite"]),
+		},
+	}, nil
+}
+
+// anthropicResponse is the part of a messages API reply the app reads.
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+// jsonObject returns s from its first "{" to its last "}", dropping any
+// sentence the model put around the object despite being told not to.
+func jsonObject(s string) string {
+	start, end := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
+
+// generate implements languageModel.
+func (m *anthropicModel) generate(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":      m.models[modelTier(ctx)],
+		"max_tokens": anthropicMaxTokens,
+		"system":     anthropicSystemPrompt,
+		"messages": []any{
+			map[string]string{"role": "user", "content": prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", m.apiKey)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &e)
+		return "", fmt.Errorf("anthropic: %s: %s", res.Status, e.Error.Message)
+	}
+
+	var ar anthropicResponse
+	if err := json.Unmarshal(data, &ar); err != nil {
+		return "", fmt.Errorf("anthropic: %w", err)
+	}
+	if ar.StopReason == "refusal" {
+		return "", errModelBlocked
+	}
+	var text strings.Builder
+	for _, c := range ar.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return "", errModelEmpty
+	}
+	return jsonObject(cleanModelJSON(text.String())), nil
+}
+
+func (m *anthropicModel) provider() string {
+	return "anthropic/" + m.models[defaultModelTier]
+}
+
+func (m *anthropicModel) close() {
+	m.client.CloseIdleConnections()
+}
@@ -0,0 +1,504 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+
+	// "errors" // No longer needed
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+
+	// "os/signal" // No longer needed
+	"strings"
+	// "syscall" // No longer needed
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A custom type that can unmarshal a JSON string OR a JSON array of strings
+type FlexibleStringSlice []string
+
+func (f *FlexibleStringSlice) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = []string{s}
+		return nil
+	}
+	var sl []string
+	if err := json.Unmarshal(data, &sl); err == nil {
+		*f = sl
+		return nil
+	}
+	return fmt.Errorf("could not unmarshal %q as either a string or a slice of strings", data)
+}
+
+// Structs for API communication
+type AnalysisRequest struct {
+	Resume         string `json:"resume"`
+	JobDescription string `json:"jobDescription"`
+	// JobURL is a job posting to fetch the description from when
+	// JobDescription is empty.
+	JobURL string `json:"jobUrl,omitempty"`
+	// CheckLinks asks for the URLs on the resume to be fetched and any dead
+	// ones reported. It's off by default because it adds a few seconds.
+	CheckLinks bool `json:"checkLinks,omitempty"`
+	// GitHubUsername overrides the profile linked on the resume, if any.
+	GitHubUsername string `json:"githubUsername,omitempty"`
+	// PortfolioURL is a personal site whose content is added to the prompt
+	// as supplemental evidence.
+	PortfolioURL string `json:"portfolioUrl,omitempty"`
+	// TargetScore asks for the fewest changes that would reach this score;
+	// 0 asks for none.
+	TargetScore int `json:"targetScore,omitempty"`
+	// CheckGeneratedText adds a heuristic estimate of how machine-written
+	// the resume looks.
+	CheckGeneratedText bool `json:"checkGeneratedText,omitempty"`
+	// Fields limits the response to these AnalysisResponse fields; see
+	// fields.go.
+	Fields []string `json:"fields,omitempty"`
+	AnalysisOptions
+}
+
+type AnalysisResponse struct {
+	ID             string              `json:"id"`
+	JobTitle       string              `json:"jobTitle,omitempty"`
+	Company        string              `json:"company,omitempty"`
+	MatchScore     int                 `json:"matchScore"`
+	Improvements   FlexibleStringSlice `json:"improvements"`
+	NextSteps      FlexibleStringSlice `json:"nextSteps"`
+	RequiredSkills []string            `json:"requiredSkills,omitempty"`
+	Timeline       *employmentTimeline `json:"timeline,omitempty"`
+	ContactIssues  []contactIssue      `json:"contactIssues,omitempty"`
+	DeadLinks      []deadLink          `json:"deadLinks,omitempty"`
+	// GitHubUsername is set when the candidate's public repositories were
+	// used as evidence, in which case UnevidencedSkills lists resume skills
+	// none of them demonstrate.
+	GitHubUsername    string   `json:"githubUsername,omitempty"`
+	UnevidencedSkills []string `json:"unevidencedSkills,omitempty"`
+	// PortfolioURL is set when the portfolio could be fetched and was used.
+	PortfolioURL    string               `json:"portfolioUrl,omitempty"`
+	TargetPlan      *targetPlan          `json:"targetPlan,omitempty"`
+	ScoreConfidence *scoreConfidence     `json:"scoreConfidence,omitempty"`
+	StuffingWarning *stuffingWarning     `json:"stuffingWarning,omitempty"`
+	CopiedFromJob   *jobCopyReport       `json:"copiedFromJob,omitempty"`
+	GeneratedText   *generatedTextSignal `json:"generatedText,omitempty"`
+	// Phrasing lists weak verbs and clichés found by suggestPhrasing.
+	Phrasing []phrasingSuggestion `json:"phrasing,omitempty"`
+	// Consistency lists places the resume mixes tenses, date formats or
+	// bullet styles.
+	Consistency []consistencyIssue `json:"consistency,omitempty"`
+	// Jargon lists acronyms and in-house names with whether to keep, spell
+	// out or cut each.
+	Jargon []jargonTerm `json:"jargon,omitempty"`
+	// Grammar is set when LANGUAGETOOL_URL is.
+	Grammar *grammarReport `json:"grammar,omitempty"`
+}
+
+// A struct to hold application-wide dependencies.
+type application struct {
+	logger       *slog.Logger
+	models       languageModel
+	rdb          *redis.Client
+	channels     map[string]notificationChannel
+	adminToken   string
+	clientSecret []byte
+	quota        quotaConfig
+	metrics      routeMetrics
+	baseURL      string
+	scoreSamples int
+	keys         *keyring
+	webhooks     *webhookDispatcher
+	caches       hotCaches
+	frontend     *frontend
+	geo          *geoPolicy
+	proxies      trustedProxies
+	grammar      *grammarChecker
+	terms        termsConfig
+	residency    string
+	stripe       *stripeConfig
+}
+
+// getIPAddress returns the client's IP address as withClientIP worked it out
+// from the trusted proxies, or the connection's address for a request that
+// didn't go through it. Proxies sometimes include the client's port
+// ("203.0.113.7:51234", "[2001:db8::1]:443"), which is dropped, and IPv4
+// addresses written as IPv6 are converted back, so one client always comes
+// out as the same string.
+func getIPAddress(r *http.Request) string {
+	if origin, ok := r.Context().Value(clientOriginKey{}).(clientOrigin); ok {
+		return origin.ip
+	}
+	if ip, ok := parseClientIP(r.RemoteAddr); ok {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// parseClientIP parses an IP address with or without a port.
+func parseClientIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	ip, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		ap, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		ip = ap.Addr()
+	}
+	return ip.Unmap().WithZone(""), true
+}
+
+// analysisPrompt takes the optional key descriptions, the resume, the job
+// description and the computed employment history notes. Use
+// buildAnalysisPrompt rather than formatting it directly.
+const analysisPrompt = `
+		Analyze the following resume against the job description.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "jobTitle": a string with the job title taken from the job description.
+		- "company": a string with the hiring company's name, or an empty string if it is not mentioned.
+		- "matchScore": an integer between 0 and 100 representing the match percentage.
+		- "scoreMargin": an integer, how many points above or below matchScore the true match could reasonably be given how clear-cut the fit is.
+%s		- "requiredSkills": a JSON array of strings naming the skills, tools and technologies the job description asks for, each as a short common name such as "Kubernetes" or "SQL".
+
+		Here is the data:
+		**Resume:**
+		---
+		%s
+		---
+		**Job Description:**
+		---
+		%s
+		---
+		**Employment history facts (computed from the resume's dates; treat them as accurate):**
+		%s
+	`
+
+// analysisPromptOptional describes the keys the model writes most of its
+// output for, which are only asked for when the client wants them.
+var analysisPromptOptional = []struct{ field, line string }{
+	{"improvements", `		- "improvements": a JSON array of strings, where each string is a bullet point that begins with a dash (using **word** for bolding) on how to improve the resume.` + "\n"},
+	{"nextSteps", `		- "nextSteps": a JSON array of strings, where each string is a bullet point that begins with a dash (using **word** for bolding) on actionable next steps.` + "\n"},
+}
+
+// buildAnalysisPrompt fills in analysisPrompt. jobText is normally the job
+// description, or a summary of its requirements.
+func buildAnalysisPrompt(req *AnalysisRequest, jobText, historyNotes string) string {
+	var optional strings.Builder
+	for _, k := range analysisPromptOptional {
+		if req.wants(k.field) {
+			optional.WriteString(k.line)
+		}
+	}
+	return fmt.Sprintf(analysisPrompt, optional.String(), req.Resume, jobText, historyNotes) + req.promptNotes()
+}
+
+// checkAnalysisRequest validates an analysis request, returning the message
+// and status to reply with if it's unusable.
+func checkAnalysisRequest(req *AnalysisRequest) (string, int) {
+	if f, ok := req.checkFields(); !ok {
+		return fmt.Sprintf("Unknown field %q", f), http.StatusBadRequest
+	}
+	if req.GitHubUsername != "" && !githubUsernameRegex.MatchString(req.GitHubUsername) {
+		return "Invalid GitHub username", http.StatusBadRequest
+	}
+	// A targetScore of 0 is the same as leaving it out.
+	if req.TargetScore < 0 || req.TargetScore > 100 {
+		return "targetScore must be between 1 and 100, or 0 for no target", http.StatusBadRequest
+	}
+	if req.PortfolioURL != "" {
+		if _, err := parsePortfolioURL(req.PortfolioURL); err != nil {
+			return "Invalid portfolio URL", http.StatusBadRequest
+		}
+	}
+	if req.JobURL != "" {
+		if _, err := parseJobURL(req.JobURL); err != nil {
+			return "Invalid job URL", http.StatusBadRequest
+		}
+	}
+	if msg := req.AnalysisOptions.check(); msg != "" {
+		return msg, http.StatusBadRequest
+	}
+	return "", 0
+}
+
+// chatHandler is now a method on the 'application' struct.
+func (app *application) chatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := context.Background()
+	ip := getIPAddress(r)
+	owner := app.clientID(w, r)
+	if !app.requireConsent(w, r, owner) {
+		return
+	}
+
+	// Credits are only charged for requests that can run: a bad body,
+	// upload or job link costs nothing.
+	var req AnalysisRequest
+	if msg, status := app.decodeAnalysisRequest(w, r, &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	req.readFields(r)
+	app.applyPreferences(r.Context(), owner, &req)
+	if msg, status := checkAnalysisRequest(&req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	if msg, status := resolveJobURL(r.Context(), &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	usage, ok := app.chargeQuota(w, r, actionAnalyze)
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	analysisID := newULID(start)
+	region := app.region(r)
+	app.publishEvent(ctx, eventAnalysisRequested, analysisEvent{AnalysisID: analysisID, Source: sourceWeb, ClientID: owner, Region: region})
+	app.logger.Info("received analysis request", "analysisID", analysisID, "ip", ip, "region", region, "usage", fmt.Sprintf("%d/%d", usage.used, usage.limit), "bonus", usage.bonus)
+	log := app.logger.With("analysisID", analysisID, "ip", ip, "region", region)
+
+	resp, err := app.analyze(ctx, log, analysisID, owner, &req, nil)
+	if err != nil {
+		app.publishEvent(ctx, eventAnalysisFailed, analysisEvent{AnalysisID: analysisID, Source: sourceWeb, ClientID: owner, Region: region, Reason: failureReason(err)})
+		modelError(w, log, err)
+		return
+	}
+	if err := app.rewardReferral(ctx, r, owner); err != nil {
+		log.Error("failed to reward referral", "error", err)
+	}
+	completed := completedEvent(sourceWeb, owner, resp, start)
+	completed.Region = region
+	app.publishEvent(ctx, eventAnalysisCompleted, completed)
+
+	out, err := selectFields(resp, req.Fields)
+	if err != nil {
+		app.logger.Error("failed to select response fields", "analysisID", analysisID, "error", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		app.logger.Error("failed to encode response", "analysisID", analysisID, "error", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// analyze runs a full analysis of a validated request and saves it to owner's
+// history. It's shared by chatHandler and the analysis queue worker; the only
+// errors it returns come from the model. known holds scores already taken of
+// the same resume and job, such as a quick score, which count towards
+// SCORE_SAMPLES and are averaged into the final score.
+func (app *application) analyze(ctx context.Context, log *slog.Logger, analysisID, owner string, req *AnalysisRequest, known []int) (*AnalysisResponse, error) {
+	ctx = withModelTier(ctx, req.ModelTier)
+	var portfolioURL *url.URL
+	if req.PortfolioURL != "" {
+		portfolioURL, _ = parsePortfolioURL(req.PortfolioURL)
+	}
+
+	// Gaps, overlaps and contact details are checked here rather than left to
+	// the model, which notices them inconsistently.
+	parsed := parseResumeRules(req.Resume)
+	timeline := buildTimeline(parsed, time.Now())
+	contactIssues := checkContact(parsed.Contact, resumeHeader(req.Resume))
+	stuffing := detectStuffing(req.Resume, req.JobDescription)
+	copied := detectCopiedText(req.Resume, req.JobDescription)
+
+	prompt := buildAnalysisPrompt(req, req.JobDescription, timeline.promptNotes())
+	schema := analysisSchema(req)
+
+	githubUser := cmp.Or(req.GitHubUsername, githubUsername(req.Resume))
+	var github *githubProfile
+	if githubUser != "" {
+		var err error
+		if github, err = app.loadGitHubProfile(ctx, githubUser); err != nil {
+			// The analysis is still useful without it.
+			log.Warn("failed to load github profile", "username", githubUser, "error", err)
+		} else {
+			prompt += fmt.Sprintf(`
+		**GitHub projects:**
+		---
+		%s
+		---
+		Treat these projects as evidence when scoring technical skills.
+		Also include the key "unevidencedSkills": a JSON array of technical skills the resume claims that none of these projects demonstrate.
+	`, github.promptNotes())
+			addRequired(schema, "unevidencedSkills", stringArraySchema())
+		}
+	}
+
+	var portfolioUsed bool
+	if portfolioURL != nil {
+		if portfolio, err := app.portfolioContext(ctx, log, portfolioURL); err != nil {
+			log.Warn("failed to load portfolio", "url", portfolioURL.String(), "error", err)
+		} else {
+			portfolioUsed = true
+			prompt += fmt.Sprintf(`
+		**Portfolio (from the candidate's website, supplemental to the resume):**
+		---
+		%s
+		---
+		Credit relevant work shown in the portfolio even where the resume undersells it.
+	`, portfolio)
+		}
+	}
+
+	// Links are checked while the model is working so they add little latency.
+	var deadLinks chan []deadLink
+	if req.CheckLinks {
+		deadLinks = make(chan []deadLink, 1)
+		go func() { deadLinks <- checkLinks(ctx, resumeLinks(req.Resume)) }()
+	}
+	var grammar chan *grammarReport
+	if app.grammar != nil && req.wants("grammar") {
+		grammar = make(chan *grammarReport, 1)
+		go func() {
+			report, err := app.grammar.check(ctx, req.Resume, req.JobDescription)
+			if err != nil {
+				log.Warn("grammar check failed", "error", err)
+			}
+			grammar <- report
+		}()
+	}
+
+	if req.TargetScore > 0 {
+		prompt += targetPlanPrompt(req.TargetScore)
+		addRequired(schema, "targetChanges", targetChangesSchema)
+	}
+
+	// Extra score samples, if configured, also run alongside the main call.
+	var samples chan []int
+	if n := app.scoreSamples - 1 - len(known); n > 0 {
+		samples = make(chan []int, 1)
+		go func() { samples <- app.sampleScores(ctx, log, req.Resume, req.JobDescription, n) }()
+	}
+
+	var modelResp struct {
+		AnalysisResponse
+		TargetChanges []plannedChange `json:"targetChanges"`
+		ScoreMargin   int             `json:"scoreMargin"`
+	}
+	if err := app.generateJSON(withResponseSchema(ctx, schema), log, prompt, &modelResp); err != nil {
+		return nil, err
+	}
+	analysisResp := modelResp.AnalysisResponse
+	analysisResp.MatchScore = min(max(analysisResp.MatchScore, 0), 100)
+	scores := append([]int{analysisResp.MatchScore}, known...)
+	if samples != nil {
+		scores = append(scores, <-samples...)
+	}
+	if len(scores) > 1 {
+		sum := 0
+		for _, s := range scores {
+			sum += s
+		}
+		analysisResp.MatchScore = int(math.Round(float64(sum) / float64(len(scores))))
+	}
+	analysisResp.ScoreConfidence = newScoreConfidence(analysisResp.MatchScore, modelResp.ScoreMargin, scores)
+	if req.TargetScore > 0 {
+		analysisResp.TargetPlan = buildTargetPlan(analysisResp.MatchScore, req.TargetScore, modelResp.TargetChanges)
+	}
+
+	analysisResp.ID = analysisID
+	analysisResp.StuffingWarning = stuffing
+	if req.CheckGeneratedText {
+		analysisResp.GeneratedText = detectGeneratedText(req.Resume)
+	}
+	if copied != nil {
+		analysisResp.CopiedFromJob = copied
+		analysisResp.Improvements = append(FlexibleStringSlice{fmt.Sprintf(
+			"- **Copied text:** %d%% of the resume is pasted from the job description. Recruiters notice this; describe your own experience in your own words instead.",
+			copied.Share)}, analysisResp.Improvements...)
+	}
+	if phrasing := suggestPhrasing(req.Resume); len(phrasing) > 0 {
+		analysisResp.Phrasing = phrasing
+		analysisResp.Improvements = append(analysisResp.Improvements, phrasingImprovement(phrasing))
+	}
+	if consistency := checkConsistency(req.Resume); len(consistency) > 0 {
+		analysisResp.Consistency = consistency
+		analysisResp.Improvements = append(analysisResp.Improvements, consistencyImprovement(consistency))
+	}
+	if jargon := auditJargon(req.Resume, req.JobDescription); len(jargon) > 0 {
+		analysisResp.Jargon = jargon
+		if bullet := jargonImprovement(jargon); bullet != "" {
+			analysisResp.Improvements = append(analysisResp.Improvements, bullet)
+		}
+	}
+	if github != nil {
+		analysisResp.GitHubUsername = github.Username
+	} else {
+		analysisResp.UnevidencedSkills = nil
+	}
+	if portfolioUsed {
+		analysisResp.PortfolioURL = portfolioURL.String()
+	}
+	if len(timeline.Roles) > 0 {
+		analysisResp.Timeline = timeline
+	}
+	if len(contactIssues) > 0 {
+		analysisResp.ContactIssues = contactIssues
+		bullets := make([]string, 0, len(contactIssues)+len(analysisResp.Improvements))
+		for _, issue := range contactIssues {
+			bullets = append(bullets, "- **Contact details:** "+issue.Message)
+		}
+		analysisResp.Improvements = append(bullets, analysisResp.Improvements...)
+	}
+	if deadLinks != nil {
+		analysisResp.DeadLinks = <-deadLinks
+		for _, d := range analysisResp.DeadLinks {
+			analysisResp.Improvements = append(analysisResp.Improvements, "- **Broken link:** "+d.message())
+		}
+	}
+	if grammar != nil {
+		if analysisResp.Grammar = <-grammar; analysisResp.Grammar != nil {
+			if bullet := grammarImprovement(analysisResp.Grammar); bullet != "" {
+				analysisResp.Improvements = append(FlexibleStringSlice{bullet}, analysisResp.Improvements...)
+			}
+		}
+	}
+	log.Info("successfully parsed analysis", "matchScore", analysisResp.MatchScore)
+
+	rec := &analysisRecord{AnalysisResponse: analysisResp, CreatedAt: time.Now().UTC()}
+	resumeID, err := app.saveResumeVersion(ctx, owner, req.Resume)
+	if err != nil {
+		log.Error("failed to store resume version", "error", err)
+	}
+	rec.ResumeID = resumeID
+	if err := app.saveAnalysis(ctx, rec); err != nil {
+		// The user still gets their result; only the share link won't resolve.
+		log.Error("failed to store analysis", "error", err)
+	} else if err := app.addToHistory(ctx, owner, rec); err != nil {
+		log.Error("failed to add analysis to history", "error", err)
+	}
+	if err := app.recordStats(ctx, &analysisResp, rec.CreatedAt); err != nil {
+		log.Error("failed to record stats", "error", err)
+	}
+	return &analysisResp, nil
+}
+
+// Health check handler
+func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{
+		"status":        "available",
+		"environment":   os.Getenv("APP_ENV"),
+		"version":       "1.0.0",
+		"dataResidency": cmp.Or(app.residency, "none"),
+		"modelProvider": app.models.provider(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,213 @@
+package jobfit
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+type applicationEmailRequest struct {
+	// Kind is "application" unless set to "thank_you", for after an
+	// interview, or "follow_up", to ask after an application or interview
+	// that's gone quiet.
+	Kind         string `json:"kind,omitempty"`
+	ContactName  string `json:"contactName"`
+	ContactEmail string `json:"contactEmail"`
+	SenderName   string `json:"senderName,omitempty"`
+	// CoverLetterURL is referenced as an attachment if given. Only
+	// application emails have attachments.
+	CoverLetterURL string `json:"coverLetterUrl,omitempty"`
+	// Interviewers, Topics and InterviewDate describe the interview a
+	// thank-you or follow-up refers to, as the user remembers it.
+	Interviewers  []string `json:"interviewers,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	InterviewDate string   `json:"interviewDate,omitempty"`
+}
+
+const (
+	maxInterviewers    = 5
+	maxInterviewTopics = 8
+)
+
+// emailKinds are the emails applicationEmailHandler can write.
+var emailKinds = []string{"application", "thank_you", "follow_up"}
+
+// emailAttachment points at a document to attach; the email itself only
+// references it.
+type emailAttachment struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type applicationEmail struct {
+	To          string            `json:"to"`
+	Subject     string            `json:"subject"`
+	Body        string            `json:"body"`
+	Attachments []emailAttachment `json:"attachments"`
+	// Mailto opens the draft in the user's mail client.
+	Mailto string `json:"mailto"`
+}
+
+func mailtoEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// applicationEmailHandler drafts the email to send with an application, based
+// on one of the caller's analyses and the resume it was run against. The same
+// context drives the thank-you note after an interview and the follow-up when
+// an application goes quiet, so those only need what the analysis can't know:
+// who was met and what was discussed.
+func (app *application) applicationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+
+	var req applicationEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	to, err := mail.ParseAddress(req.ContactEmail)
+	if err != nil {
+		http.Error(w, "Invalid contact email", http.StatusBadRequest)
+		return
+	}
+	to.Name = strings.TrimSpace(req.ContactName)
+	req.Kind = cmp.Or(req.Kind, "application")
+	if !slices.Contains(emailKinds, req.Kind) {
+		http.Error(w, `kind must be "application", "thank_you" or "follow_up"`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Interviewers) > maxInterviewers || len(req.Topics) > maxInterviewTopics {
+		http.Error(w, fmt.Sprintf("At most %d interviewers and %d topics are allowed", maxInterviewers, maxInterviewTopics), http.StatusBadRequest)
+		return
+	}
+	if req.Kind != "application" {
+		req.CoverLetterURL = ""
+	}
+	if req.CoverLetterURL != "" {
+		if u, err := url.Parse(req.CoverLetterURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			http.Error(w, "Invalid cover letter URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	owned, err := app.ownsAnalysis(r, owner, id)
+	if err != nil {
+		app.logger.Error("failed to check analysis ownership", "analysisID", id, "error", err)
+		http.Error(w, "Could not build email", http.StatusInternalServerError)
+		return
+	}
+	if !owned {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	rec, err := app.loadAnalysis(ctx, id)
+	if errors.Is(err, errAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not build email", http.StatusInternalServerError)
+		return
+	}
+	if rec.ResumeID == "" {
+		http.Error(w, "The resume for this analysis is no longer stored", http.StatusConflict)
+		return
+	}
+	resume, err := app.loadResumeVersion(ctx, owner, rec.ResumeID)
+	if errors.Is(err, errResumeNotFound) {
+		http.Error(w, "The resume for this analysis is no longer stored", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load resume version", "analysisID", id, "resumeID", rec.ResumeID, "error", err)
+		http.Error(w, "Could not build email", http.StatusInternalServerError)
+		return
+	}
+
+	if _, ok := app.chargeQuota(w, r, actionEmail); !ok {
+		return
+	}
+
+	log := app.logger.With("analysisID", id, "ip", getIPAddress(r), "kind", req.Kind)
+	role, company := cmp.Or(rec.JobTitle, "advertised"), cmp.Or(rec.Company, "the company")
+	greeting := "the hiring manager"
+	if to.Name != "" {
+		greeting = to.Name
+	}
+	var task, body string
+	switch req.Kind {
+	case "application":
+		attached := "the resume is"
+		if req.CoverLetterURL != "" {
+			attached = "the resume and cover letter are"
+		}
+		task = fmt.Sprintf("Write a short email applying for the %s role at %s, addressed to %s.", role, company, greeting)
+		body = fmt.Sprintf("under 150 words: a greeting, two short paragraphs that name the company and connect the candidate's most relevant experience to the role, a line saying %s attached", attached)
+	case "thank_you":
+		task = fmt.Sprintf("Write a short thank-you email to send within a day of an interview for the %s role at %s, addressed to %s.", role, company, greeting)
+		body = "under 150 words: a greeting, thanks for the interviewer's time, one or two sentences picking up a topic that was discussed and tying it to the candidate's experience, and a line reaffirming interest in the role"
+	case "follow_up":
+		task = fmt.Sprintf("Write a short, polite follow-up email asking about the status of the candidate's application for the %s role at %s, addressed to %s.", role, company, greeting)
+		body = "under 100 words: a greeting, a reminder of the application or interview, one sentence on why the candidate is still a strong fit, and a courteous question about next steps or timing, without sounding impatient"
+	}
+	var interview []string
+	if len(req.Interviewers) > 0 {
+		interview = append(interview, "**Interviewers:** "+strings.Join(req.Interviewers, ", "))
+	}
+	if req.InterviewDate != "" {
+		interview = append(interview, "**Interview date:** "+req.InterviewDate)
+	}
+	if len(req.Topics) > 0 {
+		interview = append(interview, "**Topics discussed:** "+strings.Join(req.Topics, "; "))
+	}
+	prompt := fmt.Sprintf(`
+		%s
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "subject": a string, a specific subject line naming the role.
+		- "body": a string of plain text, %s, and a sign-off with the candidate's name (%s).
+		Do not invent experience that isn't on the resume or details of the interview that aren't given below, and do not use placeholders in square brackets.
+
+		%s
+
+		**Resume:**
+		---
+		%s
+		---
+	`, task, body, cmp.Or(req.SenderName, "taken from the resume"), strings.Join(interview, "\n\t\t"), resume.Text)
+
+	var email applicationEmail
+	if err := app.generateJSON(ctx, log, prompt, &email); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	email.To = to.String()
+	email.Attachments = []emailAttachment{}
+	if req.Kind == "application" {
+		email.Attachments = append(email.Attachments, emailAttachment{Kind: "resume", Name: "Resume", URL: app.publicURL(r, "/resumes/"+rec.ResumeID)})
+	}
+	if req.CoverLetterURL != "" {
+		email.Attachments = append(email.Attachments, emailAttachment{Kind: "cover_letter", Name: "Cover letter", URL: req.CoverLetterURL})
+	}
+	email.Mailto = fmt.Sprintf("mailto:%s?subject=%s&body=%s", to.Address, mailtoEscape(email.Subject), mailtoEscape(email.Body))
+	log.Info("built email")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(email); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,39 @@
+package jobfit
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultAzureOpenAIAPIVersion = "2024-10-21"
+
+// newAzureOpenAIModel calls OpenAI models deployed in the operator's own
+// Azure OpenAI resource. Each tier names a deployment rather than a model;
+// the lite tier uses the standard deployment unless it has its own.
+func newAzureOpenAIModel() (*openAIModel, error) {
+	endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
+	apiKey := os.Getenv("AZURE_OPENAI_API_KEY")
+	deployment := os.Getenv("AZURE_OPENAI_DEPLOYMENT")
+	if endpoint == "" || apiKey == "" || deployment == "" {
+		return nil, errors.New("LLM_PROVIDER=azure-openai needs AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY and AZURE_OPENAI_DEPLOYMENT")
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT must be an https URL such as https://my-resource.openai.azure.com, got %q", endpoint)
+	}
+	return &openAIModel{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		apiKey:  apiKey,
+		models: map[string]string{
+			"standard": deployment,
+			"lite":     cmp.Or(os.Getenv("AZURE_OPENAI_DEPLOYMENT_LITE"), deployment),
+		},
+		apiVersion: cmp.Or(os.Getenv("AZURE_OPENAI_API_VERSION"), defaultAzureOpenAIAPIVersion),
+	}, nil
+}
@@ -0,0 +1,166 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// maxPurchases is how many of a bucket's purchases are kept.
+	maxPurchases = 50
+	// Purchase records outlive the credits they bought so receipts stay
+	// available for the tax year.
+	purchaseRetention = 400 * 24 * time.Hour
+	// receiptLookups bounds the concurrent Stripe calls for missing receipts.
+	receiptLookups = 4
+)
+
+func purchasesKey(bucket string) string { return rkey("purchases", bucket) }
+
+// purchase is a fulfilled credit pack checkout. Amount is in the currency's
+// smallest unit, as Stripe reports it.
+type purchase struct {
+	ID            string    `json:"id"`
+	Pack          string    `json:"pack"`
+	Credits       int64     `json:"credits"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	PaymentIntent string    `json:"paymentIntent,omitempty"`
+	ReceiptURL    string    `json:"receiptUrl,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// receiptURL returns the Stripe-hosted receipt for a payment.
+func (sc *stripeConfig) receiptURL(ctx context.Context, paymentIntent string) (string, error) {
+	endpoint := fmt.Sprintf("%s/payment_intents/%s?expand[]=latest_charge", stripeAPI, url.PathEscape(paymentIntent))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(sc.secretKey, "")
+	res, err := sc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxStripeBody))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		LatestCharge struct {
+			ReceiptURL string `json:"receipt_url"`
+		} `json:"latest_charge"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(data, &out)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("stripe: %s: %s", res.Status, out.Error.Message)
+	}
+	return out.LatestCharge.ReceiptURL, nil
+}
+
+// savePurchaseScript replaces a purchase record in a bucket's list, wherever
+// later purchases have moved it to.
+var savePurchaseScript = redis.NewScript(`
+local items = redis.call('LRANGE', KEYS[1], 0, -1)
+for i, v in ipairs(items) do
+	if v == ARGV[1] then
+		redis.call('LSET', KEYS[1], i - 1, ARGV[2])
+		return 1
+	end
+end
+return 0
+`)
+
+// billingHandler shows the caller's billing state: the daily allowance, the
+// bonus credits left and the credit packs bought, newest first, each with
+// its Stripe receipt. Like checkout it's routed through signedClientOnly,
+// since receipts carry payment details.
+func (app *application) billingHandler(w http.ResponseWriter, r *http.Request) {
+	if app.stripe == nil {
+		http.Error(w, "Billing is not available", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+	b := app.quotaBuckets(r)[0]
+
+	pipe := app.rdb.Pipeline()
+	bonusCmd := pipe.Get(ctx, bonusKey(b.key))
+	listCmd := pipe.LRange(ctx, purchasesKey(b.key), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Error("failed to load billing", "bucket", b.key, "error", err)
+		http.Error(w, "Could not load billing", http.StatusInternalServerError)
+		return
+	}
+	bonus, _ := bonusCmd.Int64()
+	purchases := make([]purchase, 0, len(listCmd.Val()))
+	raws := make([]string, 0, len(listCmd.Val()))
+	for _, raw := range listCmd.Val() {
+		var p purchase
+		if err := json.Unmarshal([]byte(raw), &p); err != nil {
+			app.logger.Error("failed to decode purchase", "bucket", b.key, "error", err)
+			continue
+		}
+		purchases = append(purchases, p)
+		raws = append(raws, raw)
+	}
+
+	// Receipts that weren't ready when the purchase was fulfilled are looked
+	// up now, and kept so Stripe is only asked once.
+	sem := make(chan struct{}, receiptLookups)
+	var wg sync.WaitGroup
+	for i := range purchases {
+		if purchases[i].ReceiptURL != "" || purchases[i].PaymentIntent == "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			u, err := app.stripe.receiptURL(ctx, purchases[i].PaymentIntent)
+			if err != nil {
+				app.logger.Warn("failed to look up receipt", "sessionID", purchases[i].ID, "error", err)
+				return
+			}
+			if u == "" {
+				return
+			}
+			purchases[i].ReceiptURL = u
+			data, err := json.Marshal(purchases[i])
+			if err != nil {
+				return
+			}
+			if err := savePurchaseScript.Run(ctx, app.rdb, []string{purchasesKey(b.key)}, raws[i], data).Err(); err != nil {
+				app.logger.Warn("failed to save receipt", "sessionID", purchases[i].ID, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp := struct {
+		DailyCredits int64      `json:"dailyCredits"`
+		BonusCredits int64      `json:"bonusCredits"`
+		Purchases    []purchase `json:"purchases"`
+	}{
+		DailyCredits: b.limit,
+		BonusCredits: bonus,
+		Purchases:    purchases,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,123 @@
+package jobfit
+
+import (
+	"archive/zip"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeMarkdownList writes items as a bulleted list under a heading, or
+// nothing if there are none.
+func writeMarkdownList(w io.Writer, heading string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## %s\n\n", heading)
+	for _, it := range items {
+		fmt.Fprintf(w, "- %s\n", plainBullet(it))
+	}
+	fmt.Fprintln(w)
+}
+
+// writeAnalysisReport renders an analysis as Markdown.
+func writeAnalysisReport(w io.Writer, rec *analysisRecord) {
+	fmt.Fprintf(w, "# %s at %s\n\n", cmp.Or(rec.JobTitle, "Analysis"), cmp.Or(rec.Company, "an unnamed company"))
+	fmt.Fprintf(w, "Analyzed %s. Match score: **%d/100**.\n\n", rec.CreatedAt.Format(time.DateOnly), rec.MatchScore)
+	if len(rec.Tags) > 0 {
+		fmt.Fprintf(w, "Tags: %s\n\n", strings.Join(rec.Tags, ", "))
+	}
+	writeMarkdownList(w, "Required skills", rec.RequiredSkills)
+	writeMarkdownList(w, "Improvements", rec.Improvements)
+	writeMarkdownList(w, "Next steps", rec.NextSteps)
+	if rec.Notes != "" {
+		fmt.Fprintf(w, "## Notes\n\n%s\n", rec.Notes)
+	}
+}
+
+// writeCompanyBrief renders a company brief as Markdown.
+func writeCompanyBrief(w io.Writer, b *companyBrief) {
+	fmt.Fprintf(w, "# %s\n\n%s\n\n", b.Company, b.Overview)
+	writeMarkdownList(w, "Products and services", b.Products)
+	writeMarkdownList(w, "Values", b.Values)
+	writeMarkdownList(w, "Recent news to look up", b.NewsToCheck)
+	writeMarkdownList(w, "Likely interview themes", b.InterviewThemes)
+	writeMarkdownList(w, "Questions to ask", b.QuestionsToAsk)
+	if b.Source != "" {
+		fmt.Fprintf(w, "Based in part on %s.\n", b.Source)
+	}
+}
+
+// bundleHandler downloads everything kept for one application as a ZIP: the
+// analysis report, the resume it was run against and, if one was attached,
+// the company brief. Nothing in it is generated on the fly, so it's built
+// while streaming rather than through the job queue.
+func (app *application) bundleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	owned, err := app.ownsAnalysis(r, owner, id)
+	if err != nil {
+		app.logger.Error("failed to check analysis ownership", "analysisID", id, "error", err)
+		http.Error(w, "Could not build bundle", http.StatusInternalServerError)
+		return
+	}
+	if !owned {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	rec, err := app.loadAnalysis(ctx, id)
+	if errors.Is(err, errAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not build bundle", http.StatusInternalServerError)
+		return
+	}
+	// The resume may have been deleted since; the rest is still worth having.
+	var resume *resumeVersion
+	if rec.ResumeID != "" {
+		resume, err = app.loadResumeVersion(ctx, owner, rec.ResumeID)
+		if err != nil && !errors.Is(err, errResumeNotFound) {
+			app.logger.Error("failed to load resume version", "analysisID", id, "resumeID", rec.ResumeID, "error", err)
+			http.Error(w, "Could not build bundle", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="application-%s.zip"`, rec.ID))
+
+	zw := zip.NewWriter(w)
+	add := func(name string, write func(io.Writer)) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: rec.CreatedAt})
+		if err != nil {
+			return err
+		}
+		write(f)
+		return nil
+	}
+	err = add("analysis.md", func(f io.Writer) { writeAnalysisReport(f, rec) })
+	if err == nil && resume != nil {
+		err = add("resume.txt", func(f io.Writer) { io.WriteString(f, resume.Text) })
+	}
+	if err == nil && rec.CompanyBrief != nil {
+		err = add("company-brief.md", func(f io.Writer) { writeCompanyBrief(f, rec.CompanyBrief) })
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		app.logger.Error("failed to write bundle", "analysisID", id, "error", err)
+	}
+}
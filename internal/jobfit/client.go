@@ -0,0 +1,149 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	clientCookieName = "jobfit_client"
+	clientTokenTTL   = 365 * 24 * time.Hour
+)
+
+// clientIssuedKey records when a signed token was first handed out for a
+// client ID.
+func clientIssuedKey(id string) string {
+	return rkey("client-token", "issued", id)
+}
+
+// The client cookie holds a random ID, followed by ".<signature>" when
+// CLIENT_TOKEN_SECRET is configured. Only signed tokens are trusted as a quota
+// key; unsigned ones still identify the browser's history.
+
+func (app *application) signClientID(id string) string {
+	mac := hmac.New(sha256.New, app.clientSecret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// readClientCookie returns the client ID the request carries, if any, and
+// whether it came with a valid signature. A token whose signature doesn't match
+// is ignored entirely.
+func (app *application) readClientCookie(r *http.Request) (id string, verified bool) {
+	c, err := r.Cookie(clientCookieName)
+	if err != nil {
+		return "", false
+	}
+	id, sig, signed := strings.Cut(c.Value, ".")
+	if !isClientID(id) {
+		return "", false
+	}
+	if !signed || len(app.clientSecret) == 0 {
+		return id, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(app.signClientID(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// clientID returns the anonymous identifier the browser presented, issuing a new
+// one as a long-lived cookie if there isn't one yet. It must be called before the
+// handler starts writing the response body.
+func (app *application) clientID(w http.ResponseWriter, r *http.Request) string {
+	id, verified := app.readClientCookie(r)
+	if id != "" && (verified || len(app.clientSecret) == 0) {
+		return id
+	}
+
+	// Either there is no cookie, or it predates signing. In the latter case we
+	// keep the ID so the browser doesn't lose its history.
+	if id == "" {
+		var b [16]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	value := id
+	if len(app.clientSecret) > 0 {
+		value += "." + app.signClientID(id)
+		if err := app.rdb.SetNX(r.Context(), clientIssuedKey(id), time.Now().Unix(), clientTokenTTL).Err(); err != nil {
+			app.logger.Error("failed to record client token", "error", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     clientCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(clientTokenTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// clientIssuedAt returns when client id's signed token was handed out.
+// Tokens handed out before this was recorded count as issued at the Unix
+// epoch, which is older than any age that is checked.
+func (app *application) clientIssuedAt(ctx context.Context, id string) (time.Time, error) {
+	issued, err := app.rdb.Get(ctx, clientIssuedKey(id)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Unix(0, 0), nil
+	}
+	return time.Unix(issued, 0), err
+}
+
+// carriesSignedToken reports whether r came with owner's signed token.
+func (app *application) carriesSignedToken(r *http.Request, owner string) bool {
+	id, verified := app.readClientCookie(r)
+	return verified && id == owner
+}
+
+// existingClientID is like clientID but never issues a new identifier.
+func (app *application) existingClientID(r *http.Request) (string, bool) {
+	id, _ := app.readClientCookie(r)
+	return id, id != ""
+}
+
+// verifiedClientID returns the caller's client ID for actions that must stay
+// with one browser, such as paying for credits. Only a signed token will
+// do: an IP address can be shared or forged. Otherwise it writes a 403 and
+// returns false.
+func (app *application) verifiedClientID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, verified := app.readClientCookie(r)
+	if !verified {
+		http.Error(w, "This needs a client token. Reload the page and try again.", http.StatusForbidden)
+		return "", false
+	}
+	return id, true
+}
+
+// withClientID hands out a client token on the first page load, so that the
+// visitor's first analysis is already counted against their own quota rather
+// than their IP's.
+func (app *application) withClientID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && (r.URL.Path == "/" || r.URL.Path == "/index.html") {
+			app.clientID(w, r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isClientID(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,103 @@
+package jobfit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+// trustedProxies are the addresses allowed to say who a request came from.
+// X-Forwarded-For is only believed when the connection comes from one of
+// them, and then only as far back as the last hop that isn't one, since
+// anything before that is whatever the client chose to send.
+type trustedProxies []netip.Prefix
+
+// loadTrustedProxies reads TRUSTED_PROXIES, a comma-separated list of
+// addresses and CIDR ranges such as "10.0.0.0/8,192.0.2.7".
+func loadTrustedProxies() (trustedProxies, error) {
+	var tp trustedProxies
+	for entry := range strings.SplitSeq(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an address or CIDR range", entry)
+			}
+			ip = ip.Unmap()
+			entry = netip.PrefixFrom(ip, ip.BitLen()).String()
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entry %q is not an address or CIDR range", entry)
+		}
+		tp = append(tp, prefix.Masked())
+	}
+	return tp, nil
+}
+
+func (tp trustedProxies) trusts(ip netip.Addr) bool {
+	return slices.ContainsFunc(tp, func(p netip.Prefix) bool { return p.Contains(ip) })
+}
+
+// clientIP works out the address r came from. Without trusted proxies it's
+// the connection's address. Behind them, X-Forwarded-For is walked from the
+// right, past the trusted proxies, to the first address that isn't one;
+// X-Real-IP is used if a trusted proxy sent no X-Forwarded-For. Connections
+// over a Unix socket come from a local proxy and count as trusted.
+func (tp trustedProxies) clientIP(r *http.Request) string {
+	remote, ok := parseClientIP(r.RemoteAddr)
+	if ok && !tp.trusts(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if ip, ok := parseClientIP(r.Header.Get("X-Real-IP")); ok {
+			return ip.String()
+		}
+	}
+	client := remote
+	for _, hop := range slices.Backward(hops) {
+		ip, ok := parseClientIP(hop)
+		if !ok {
+			// The hop before a garbled entry is the furthest one to trust.
+			break
+		}
+		client = ip
+		if !tp.trusts(ip) {
+			break
+		}
+	}
+	if !client.IsValid() {
+		return r.RemoteAddr
+	}
+	return client.String()
+}
+
+type clientOriginKey struct{}
+
+// clientOrigin is where a request came from.
+type clientOrigin struct {
+	ip     string
+	region string
+}
+
+// withClientIP works out the request's client address, and the country it's
+// in, once for getIPAddress and region.
+func (app *application) withClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := app.proxies.clientIP(r)
+		origin := clientOrigin{ip: ip, region: app.geo.country(ip)}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientOriginKey{}, origin)))
+	})
+}
@@ -0,0 +1,81 @@
+package jobfit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.7, 2001:db8:ffff::/48")
+	proxies, err := loadTrustedProxies()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		proxies trustedProxies
+		remote  string
+		xff     []string
+		realIP  string
+		want    string
+	}{
+		{"no proxies ignores the header", nil, "203.0.113.9:4000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.9"},
+		{"untrusted peer ignores the header", proxies, "203.0.113.9:4000", []string{"198.51.100.1"}, "", "203.0.113.9"},
+		{"one proxy", proxies, "10.1.2.3:4000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed entries on the left are skipped", proxies, "10.1.2.3:4000", []string{"1.1.1.1, 2.2.2.2, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of proxies", proxies, "10.1.2.3:4000", []string{"198.51.100.1, 192.0.2.7", "10.9.9.9"}, "", "198.51.100.1"},
+		{"port and IPv4-mapped IPv6", proxies, "10.1.2.3:4000", []string{"[::ffff:198.51.100.1]:51234"}, "", "198.51.100.1"},
+		{"IPv6 peer and client", proxies, "[2001:db8:ffff::1]:443", []string{"2001:db8:1:2::5"}, "", "2001:db8:1:2::5"},
+		{"garbled entry stops the walk", proxies, "10.1.2.3:4000", []string{"198.51.100.1, junk, 10.9.9.9"}, "", "10.9.9.9"},
+		{"every hop is a proxy", proxies, "10.1.2.3:4000", []string{"10.7.7.7, 10.8.8.8"}, "", "10.7.7.7"},
+		{"X-Real-IP from a proxy", proxies, "10.1.2.3:4000", nil, "198.51.100.3", "198.51.100.3"},
+		{"proxy without headers", proxies, "10.1.2.3:4000", nil, "", "10.1.2.3"},
+		{"unix socket peer is trusted", nil, "@", []string{"1.1.1.1, 198.51.100.1"}, "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := tt.proxies.clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTrustedProxiesRejectsGarbage(t *testing.T) {
+	for _, v := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0.1:80"} {
+		t.Setenv("TRUSTED_PROXIES", v)
+		if _, err := loadTrustedProxies(); err == nil {
+			t.Errorf("TRUSTED_PROXIES=%q was accepted", v)
+		}
+	}
+}
+
+func TestIPBucket(t *testing.T) {
+	qc := quotaConfig{ipv6Prefix: 64}
+	tests := []struct {
+		ip, want string
+	}{
+		{"203.0.113.9", "203.0.113.9"},
+		{"2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:ffff:ffff:ffff:ffff", "2001:db8:1:2::/64"},
+		{"2001:db8:1:3::1", "2001:db8:1:3::/64"},
+		{"not-an-ip", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := qc.ipBucket(tt.ip); got != tt.want {
+			t.Errorf("ipBucket(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+	if got := (quotaConfig{ipv6Prefix: 128}).ipBucket("2001:db8::1"); got != "2001:db8::1" {
+		t.Errorf("ipBucket with a /128 prefix = %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	maxCompanyName    = 100
+	companyPageBudget = 6000
+)
+
+var companyPageClient = newGuardedClient(portfolioTimeout)
+
+type companyBriefRequest struct {
+	Company string `json:"company"`
+	// URL is the company's careers or about page, read for what the company
+	// says about itself.
+	URL string `json:"url,omitempty"`
+	// AnalysisID attaches the brief to one of the caller's analyses, which
+	// also supplies the company and role when they aren't given.
+	AnalysisID string `json:"analysisId,omitempty"`
+}
+
+// companyBrief is a one-page summary to read before applying or
+// interviewing. The model's knowledge of a company is dated, so recent news
+// is left as things to look up rather than stated.
+type companyBrief struct {
+	ID        string    `json:"id"`
+	Company   string    `json:"company"`
+	CreatedAt time.Time `json:"createdAt"`
+	Overview  string    `json:"overview"`
+	Products  []string  `json:"products"`
+	Values    []string  `json:"values"`
+	// NewsToCheck are topics to search for recent news on.
+	NewsToCheck     []string `json:"newsToCheck"`
+	InterviewThemes []string `json:"interviewThemes"`
+	// QuestionsToAsk are questions for the candidate to ask the interviewer.
+	QuestionsToAsk []string `json:"questionsToAsk"`
+	// Source is set when the page at the request's URL could be fetched and
+	// was used.
+	Source string `json:"source,omitempty"`
+}
+
+// parseCompanyURL accepts an http(s) URL, adding https:// if the scheme was
+// left off, like parsePortfolioURL.
+func parseCompanyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFetchURL(u); err != nil {
+		return nil, fmt.Errorf("unsupported company URL %q: %w", raw, err)
+	}
+	return u, nil
+}
+
+// fetchCompanyPage downloads a company page and returns its visible text,
+// cut to companyPageBudget characters.
+func fetchCompanyPage(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "JobFit/1.0")
+	resp, err := companyPageClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("company page returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("company page is %s, not HTML", ct)
+	}
+	text := visibleText(io.LimitReader(resp.Body, maxPortfolioBytes))
+	if len(text) > companyPageBudget {
+		text = strings.ToValidUTF8(text[:companyPageBudget], "") + "…"
+	}
+	return text, nil
+}
+
+// companyBriefHandler writes a research brief on a company: what it does and
+// sells, what it says it values, what to look up before the interview and
+// the themes interviewers there are likely to probe. With an analysisId the
+// brief is tailored to that role and saved on the analysis.
+func (app *application) companyBriefHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ip := getIPAddress(r)
+
+	var req companyBriefRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Company = strings.Join(strings.Fields(req.Company), " ")
+	if len(req.Company) > maxCompanyName {
+		http.Error(w, fmt.Sprintf("company must be at most %d characters", maxCompanyName), http.StatusBadRequest)
+		return
+	}
+	var pageURL *url.URL
+	if req.URL != "" {
+		var err error
+		if pageURL, err = parseCompanyURL(req.URL); err != nil {
+			http.Error(w, "Invalid company URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var rec *analysisRecord
+	if req.AnalysisID != "" {
+		owner, ok := app.existingClientID(r)
+		if !ok || !isULID(req.AnalysisID) {
+			http.Error(w, "Analysis not found", http.StatusNotFound)
+			return
+		}
+		owned, err := app.ownsAnalysis(r, owner, req.AnalysisID)
+		if err != nil {
+			app.logger.Error("failed to check analysis ownership", "analysisID", req.AnalysisID, "error", err)
+			http.Error(w, "Could not build company brief", http.StatusInternalServerError)
+			return
+		}
+		if !owned {
+			http.Error(w, "Analysis not found", http.StatusNotFound)
+			return
+		}
+		rec, err = app.loadAnalysis(ctx, req.AnalysisID)
+		if errors.Is(err, errAnalysisNotFound) {
+			http.Error(w, "Analysis not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			app.logger.Error("failed to load analysis", "analysisID", req.AnalysisID, "error", err)
+			http.Error(w, "Could not build company brief", http.StatusInternalServerError)
+			return
+		}
+		req.Company = cmp.Or(req.Company, rec.Company)
+	} else if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if req.Company == "" {
+		http.Error(w, "A company name is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := app.chargeQuota(w, r, actionBrief); !ok {
+		return
+	}
+
+	brief := companyBrief{ID: newULID(time.Now()), Company: req.Company, CreatedAt: time.Now().UTC()}
+	log := app.logger.With("briefID", brief.ID, "ip", ip)
+
+	var sections []string
+	if pageURL != nil {
+		text, err := fetchCompanyPage(ctx, pageURL)
+		if err != nil {
+			log.Warn("company page fetch failed, continuing without it", "url", pageURL.String(), "error", err)
+		} else if text != "" {
+			brief.Source = pageURL.String()
+			sections = append(sections, fmt.Sprintf("**The company's own page (%s):**\n\t\t---\n\t\t%s\n\t\t---", brief.Source, text))
+		}
+	}
+	if rec != nil {
+		role := fmt.Sprintf("**The role being applied for:** %s", cmp.Or(rec.JobTitle, "not given"))
+		if len(rec.RequiredSkills) > 0 {
+			role += fmt.Sprintf("\n\t\t**Skills it requires:** %s", strings.Join(rec.RequiredSkills, ", "))
+		}
+		sections = append(sections, role)
+	}
+	prompt := fmt.Sprintf(`
+		Write a one-page research brief on the company %q for a candidate preparing to apply or interview there.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "overview": a string of two or three sentences on what the company does, for whom, and roughly how large it is.
+		- "products": a JSON array of up to five strings, its main products or services, each with a few words on what it is.
+		- "values": a JSON array of up to five strings, the values or culture it is known for or says it has.
+		- "newsToCheck": a JSON array of three to five strings, specific topics to search for recent news on before the interview, such as funding, launches, leadership changes or earnings. Do not state recent events as fact; your knowledge may be out of date.
+		- "interviewThemes": a JSON array of three to six strings, themes interviewers at this company are likely to probe, each with a short reason.
+		- "questionsToAsk": a JSON array of three to five strings, thoughtful questions the candidate could ask their interviewers.
+		Prefer what the company's own page says over general knowledge. If you don't recognize the company and no page is given, keep the brief general and say so in "overview" rather than inventing details.
+
+		%s
+	`, req.Company, strings.Join(sections, "\n\n\t\t"))
+
+	var out companyBrief
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	out.ID, out.Company, out.CreatedAt, out.Source = brief.ID, brief.Company, brief.CreatedAt, brief.Source
+	brief = out
+	for _, list := range []*[]string{&brief.Products, &brief.Values, &brief.NewsToCheck, &brief.InterviewThemes, &brief.QuestionsToAsk} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+
+	if rec != nil {
+		rec.CompanyBrief = &brief
+		if err := app.updateAnalysis(ctx, rec); err != nil {
+			log.Error("failed to store analysis", "analysisID", rec.ID, "error", err)
+			http.Error(w, "Could not save company brief", http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Info("built company brief", "fetched", brief.Source != "", "attached", rec != nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(brief); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,151 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	minComparedJobs = 2
+	maxComparedJobs = 5
+)
+
+// compareFactors are the columns of the comparison matrix, in display order.
+var compareFactors = []string{"skills", "experience", "education", "seniority", "domain"}
+
+type compareRequest struct {
+	Resume          string   `json:"resume"`
+	JobDescriptions []string `json:"jobDescriptions"`
+}
+
+type jobComparison struct {
+	JobTitle   string         `json:"jobTitle"`
+	Company    string         `json:"company,omitempty"`
+	MatchScore int            `json:"matchScore"`
+	Factors    map[string]int `json:"factors"`
+	Strengths  []string       `json:"strengths"`
+	Gaps       []string       `json:"gaps"`
+}
+
+type jobRecommendation struct {
+	// Job is the index into the request's jobDescriptions.
+	Job    int    `json:"job"`
+	Reason string `json:"reason"`
+}
+
+type compareResponse struct {
+	ID             string            `json:"id"`
+	Factors        []string          `json:"factors"`
+	Jobs           []jobComparison   `json:"jobs"`
+	Recommendation jobRecommendation `json:"recommendation"`
+}
+
+// validate checks the model returned one well-formed entry per job and clamps
+// scores into range.
+func (cr *compareResponse) validate(jobs int) error {
+	if len(cr.Jobs) != jobs {
+		return fmt.Errorf("expected %d jobs, got %d", jobs, len(cr.Jobs))
+	}
+	if cr.Recommendation.Job < 0 || cr.Recommendation.Job >= jobs {
+		return fmt.Errorf("recommended job %d out of range", cr.Recommendation.Job)
+	}
+	for i := range cr.Jobs {
+		j := &cr.Jobs[i]
+		j.MatchScore = min(max(j.MatchScore, 0), 100)
+		scores := make(map[string]int, len(compareFactors))
+		for _, f := range compareFactors {
+			score, ok := j.Factors[f]
+			if !ok {
+				return fmt.Errorf("job %d is missing the %q factor", i, f)
+			}
+			scores[f] = min(max(score, 0), 100)
+		}
+		j.Factors = scores
+	}
+	return nil
+}
+
+// compareJobsHandler scores one resume against several job descriptions in a
+// single model call so the scores are consistent with each other.
+func (app *application) compareJobsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req compareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" {
+		http.Error(w, "A resume is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.JobDescriptions) < minComparedJobs || len(req.JobDescriptions) > maxComparedJobs {
+		http.Error(w, fmt.Sprintf("Provide between %d and %d job descriptions", minComparedJobs, maxComparedJobs), http.StatusBadRequest)
+		return
+	}
+	for _, jd := range req.JobDescriptions {
+		if strings.TrimSpace(jd) == "" {
+			http.Error(w, "Job descriptions cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionCompare); !ok {
+		return
+	}
+
+	comparisonID := newULID(time.Now())
+	log := app.logger.With("comparisonID", comparisonID, "ip", ip)
+	log.Info("received job comparison request", "jobs", len(req.JobDescriptions))
+
+	var jobs strings.Builder
+	for i, jd := range req.JobDescriptions {
+		fmt.Fprintf(&jobs, "**Job %d:**\n---\n%s\n---\n", i, jd)
+	}
+	prompt := fmt.Sprintf(`
+		Compare the following resume against each of the %d job descriptions.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "jobs": a JSON array with exactly one object per job, in the same order as the jobs are given. Each object has:
+		  - "jobTitle": a string with the job title taken from the job description.
+		  - "company": a string with the hiring company's name, or an empty string if it is not mentioned.
+		  - "matchScore": an integer between 0 and 100 representing the overall match percentage.
+		  - "factors": an object with the integer keys %s, each between 0 and 100, scoring how well the resume meets that part of the job.
+		  - "strengths": a JSON array of short strings naming where the resume fits this job best.
+		  - "gaps": a JSON array of short strings naming what this job needs that the resume lacks.
+		- "recommendation": an object with "job" (the number of the job to prioritize, counting from 0) and "reason" (a string of one or two sentences explaining why).
+		Score every job on the same scale so the scores can be compared side by side.
+
+		**Resume:**
+		---
+		%s
+		---
+		%s
+	`, len(req.JobDescriptions), strings.Join(compareFactors, ", "), req.Resume, jobs.String())
+
+	resp := compareResponse{ID: comparisonID, Factors: compareFactors}
+	if err := app.generateJSON(ctx, log, prompt, &resp); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	if err := resp.validate(len(req.JobDescriptions)); err != nil {
+		log.Error("invalid job comparison from the model", "error", err)
+		http.Error(w, "Failed to parse AI model response", http.StatusInternalServerError)
+		return
+	}
+	resp.ID, resp.Factors = comparisonID, compareFactors
+	log.Info("successfully compared jobs", "recommended", resp.Recommendation.Job)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
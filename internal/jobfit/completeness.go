@@ -0,0 +1,183 @@
+package jobfit
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	maxCompletenessBodySize = 100000
+
+	// A summary outside this many words reads as either an afterthought or
+	// a cover letter.
+	minSummaryWords = 20
+	maxSummaryWords = 120
+	// Fewer bullets than this, or bullets shorter than this, rarely say
+	// enough about a role.
+	minRoleBullets = 2
+	minBulletWords = 5
+	minSkills      = 5
+)
+
+// completenessGap is a missing or weak part of a resume, with the question
+// the guided builder asks to fill it.
+type completenessGap struct {
+	Section string `json:"section"`
+	// Index is the experience or education entry the gap is in.
+	Index *int   `json:"index,omitempty"`
+	Field string `json:"field"`
+	// Problem is "missing" or "weak".
+	Problem  string `json:"problem"`
+	Question string `json:"question"`
+}
+
+// completenessResponse lists the gaps in the order to ask about them, section
+// by section from the top of the resume. Score is the percentage of checks
+// the resume passes.
+type completenessResponse struct {
+	Score    int               `json:"score"`
+	Complete bool              `json:"complete"`
+	Gaps     []completenessGap `json:"gaps"`
+}
+
+// completenessCheck accumulates the checks run against a resume.
+type completenessCheck struct {
+	checked int
+	gaps    []completenessGap
+}
+
+// require counts a check, recording gap if ok is false.
+func (c *completenessCheck) require(ok bool, gap completenessGap) {
+	c.checked++
+	if !ok {
+		c.gaps = append(c.gaps, gap)
+	}
+}
+
+func wordCount(s string) int { return len(strings.Fields(s)) }
+
+// roleName describes an experience entry in a question.
+func roleName(e resumeExperience) string {
+	switch {
+	case e.Title != "" && e.Company != "":
+		return fmt.Sprintf("your %s role at %s", e.Title, e.Company)
+	case e.Company != "":
+		return "your role at " + e.Company
+	case e.Title != "":
+		return fmt.Sprintf("your %s role", e.Title)
+	}
+	return "this role"
+}
+
+// checkCompleteness reports what a partly built resume is missing and what
+// is there but weak. It's rule-based, so the builder can call it after every
+// answer.
+func checkCompleteness(res *structuredResume, now time.Time) completenessResponse {
+	var c completenessCheck
+	contact := res.Contact
+
+	c.require(contact.Name != "", completenessGap{Section: "contact", Field: "name", Problem: "missing", Question: "What's your full name as you'd like it to appear on your resume?"})
+	if contact.Email == "" {
+		c.require(false, completenessGap{Section: "contact", Field: "email", Problem: "missing", Question: "What email address should recruiters use to reach you?"})
+	} else {
+		_, err := mail.ParseAddress(contact.Email)
+		c.require(err == nil, completenessGap{Section: "contact", Field: "email", Problem: "weak", Question: fmt.Sprintf("%q doesn't look like a valid email address. What's the right one?", contact.Email)})
+	}
+	if contact.Phone == "" {
+		c.require(false, completenessGap{Section: "contact", Field: "phone", Problem: "missing", Question: "What phone number can recruiters call you on?"})
+	} else {
+		digits := countDigits(contact.Phone)
+		c.require(digits >= 10 && digits <= 15, completenessGap{Section: "contact", Field: "phone", Problem: "weak", Question: fmt.Sprintf("%q has the wrong number of digits for a phone number. Could you include the area code?", contact.Phone)})
+	}
+	c.require(contact.Location != "", completenessGap{Section: "contact", Field: "location", Problem: "missing", Question: `Where are you based? A city and state or country such as "Austin, TX" is enough.`})
+
+	if words := wordCount(res.Summary); words == 0 {
+		c.require(false, completenessGap{Section: "summary", Field: "summary", Problem: "missing", Question: "In two or three sentences, what do you do, what are you best at and what role are you looking for next?"})
+	} else {
+		question := "Your summary is very short. What's one achievement or strength you'd add to it?"
+		if words > maxSummaryWords {
+			question = "Your summary is long for the top of a resume. Which two or three points matter most?"
+		}
+		c.require(words >= minSummaryWords && words <= maxSummaryWords, completenessGap{Section: "summary", Field: "summary", Problem: "weak", Question: question})
+	}
+
+	c.require(len(res.Experience) > 0, completenessGap{Section: "experience", Field: "experience", Problem: "missing", Question: "What's your most recent job, internship or volunteer role? Tell us the title, the organization and when you started."})
+	for i, e := range res.Experience {
+		at := func(field, problem, question string) completenessGap {
+			return completenessGap{Section: "experience", Index: &i, Field: field, Problem: problem, Question: question}
+		}
+		c.require(e.Title != "", at("title", "missing", fmt.Sprintf("What was your job title in %s?", roleName(e))))
+		c.require(e.Company != "", at("company", "missing", fmt.Sprintf("Which company or organization was %s with?", roleName(e))))
+		if e.StartDate == "" {
+			c.require(false, at("startDate", "missing", fmt.Sprintf("When did you start %s? A month and year is enough.", roleName(e))))
+		} else {
+			_, ok := normalizeResumeDate(e.StartDate, false, now)
+			c.require(ok, at("startDate", "weak", fmt.Sprintf("We couldn't read %q as a date. When did you start %s?", e.StartDate, roleName(e))))
+		}
+		if e.EndDate == "" {
+			c.require(false, at("endDate", "missing", fmt.Sprintf("When did %s end? Answer \"Present\" if you're still there.", roleName(e))))
+		} else {
+			_, ok := normalizeResumeDate(e.EndDate, true, now)
+			c.require(ok, at("endDate", "weak", fmt.Sprintf("We couldn't read %q as a date. When did %s end?", e.EndDate, roleName(e))))
+		}
+
+		if len(e.Bullets) == 0 {
+			c.require(false, at("bullets", "missing", fmt.Sprintf("What were your main responsibilities and achievements in %s?", roleName(e))))
+			continue
+		}
+		c.require(len(e.Bullets) >= minRoleBullets, at("bullets", "weak", fmt.Sprintf("What else did you accomplish in %s? Projects you led or problems you solved make good bullets.", roleName(e))))
+		short, quantified := false, false
+		for _, b := range e.Bullets {
+			short = short || wordCount(b) < minBulletWords
+			quantified = quantified || countDigits(b) > 0
+		}
+		c.require(!short, at("bullets", "weak", fmt.Sprintf("Some bullets for %s are very brief. What did you do in each, and what came of it?", roleName(e))))
+		c.require(quantified, at("bullets", "weak", fmt.Sprintf("Can you put a number on any result from %s, such as money saved, time cut, users served or the size of your team?", roleName(e))))
+	}
+
+	if len(res.Skills) == 0 {
+		c.require(false, completenessGap{Section: "skills", Field: "skills", Problem: "missing", Question: "Which tools, technologies and skills do you use in your work?"})
+	} else {
+		c.require(len(res.Skills) >= minSkills, completenessGap{Section: "skills", Field: "skills", Problem: "weak", Question: "Which other tools or skills would you list? Include the ones the jobs you want ask for."})
+	}
+
+	c.require(len(res.Education) > 0, completenessGap{Section: "education", Field: "education", Problem: "missing", Question: "Where did you study, and what degree, diploma or certification did you earn?"})
+	for i, e := range res.Education {
+		c.require(e.Institution != "", completenessGap{Section: "education", Index: &i, Field: "institution", Problem: "missing", Question: "Which school, university or provider was this qualification from?"})
+		c.require(e.Degree != "", completenessGap{Section: "education", Index: &i, Field: "degree", Problem: "missing", Question: fmt.Sprintf("What did you study at %s, and what qualification did you earn?", cmp.Or(e.Institution, "this school"))})
+	}
+
+	resp := completenessResponse{Gaps: c.gaps, Complete: len(c.gaps) == 0}
+	if resp.Gaps == nil {
+		resp.Gaps = []completenessGap{}
+	}
+	resp.Score = 100 * (c.checked - len(c.gaps)) / c.checked
+	return resp
+}
+
+// completenessHandler powers the guided resume builder: it takes the
+// structured resume built so far and returns the gaps to ask about next. Like
+// anonymizeHandler it doesn't use the model or draw on the quota.
+func (app *application) completenessHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Resume *structuredResume `json:"resume"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCompletenessBodySize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Resume == nil {
+		req.Resume = &structuredResume{}
+	}
+
+	resp := checkCompleteness(req.Resume, time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		app.logger.Error("failed to encode response", "error", err)
+	}
+}
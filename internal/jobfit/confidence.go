@@ -0,0 +1,119 @@
+package jobfit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	maxScoreSamples = 5
+	// defaultScoreMargin is used when the model doesn't say how sure it is.
+	defaultScoreMargin = 10
+)
+
+// scoreConfidence is the range the match score is likely to fall in, so the
+// UI can show 72±6 rather than implying a precise number.
+type scoreConfidence struct {
+	Margin int `json:"margin"`
+	Low    int `json:"low"`
+	High   int `json:"high"`
+	// Method is "sampled" when the margin comes from the spread of several
+	// independent scores and "self-reported" when the model estimated it.
+	Method  string `json:"method"`
+	Samples int    `json:"samples"`
+}
+
+// loadScoreSamples reads SCORE_SAMPLES, the number of times each resume is
+// scored to measure how much the model's score varies. Every sample past the
+// first costs an extra model call.
+func loadScoreSamples() (int, error) {
+	v := os.Getenv("SCORE_SAMPLES")
+	if v == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxScoreSamples {
+		return 0, fmt.Errorf("SCORE_SAMPLES must be between 1 and %d, got %q", maxScoreSamples, v)
+	}
+	return n, nil
+}
+
+// quickScore asks the model for nothing but the match score, which it answers
+// far faster than a full analysis.
+func (app *application) quickScore(ctx context.Context, log *slog.Logger, resume, jobDescription string) (int, error) {
+	prompt := fmt.Sprintf(`
+		Score how well the following resume matches the job description.
+		Your response MUST be a valid JSON object with a single key "matchScore", an integer between 0 and 100.
+
+		**Resume:**
+		---
+		%s
+		---
+		**Job Description:**
+		---
+		%s
+		---
+	`, resume, jobDescription)
+
+	var score struct {
+		MatchScore int `json:"matchScore"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &score); err != nil {
+		return 0, err
+	}
+	return min(max(score.MatchScore, 0), 100), nil
+}
+
+// sampleScores asks the model for n more scores of the same resume and job in
+// parallel. Failed samples are dropped.
+func (app *application) sampleScores(ctx context.Context, log *slog.Logger, resume, jobDescription string, n int) []int {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	scores := make([]int, 0, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			score, err := app.quickScore(ctx, log, resume, jobDescription)
+			if err != nil {
+				log.Warn("score sample failed", "error", err)
+				return
+			}
+			mu.Lock()
+			scores = append(scores, score)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return scores
+}
+
+// newScoreConfidence builds the band around score. With at least two scores
+// the margin is a 95% interval from their spread; otherwise it falls back to
+// the margin the model reported.
+func newScoreConfidence(score, reportedMargin int, scores []int) *scoreConfidence {
+	c := &scoreConfidence{Method: "self-reported", Samples: len(scores), Margin: reportedMargin}
+	if len(scores) >= 2 {
+		var mean, variance float64
+		for _, s := range scores {
+			mean += float64(s)
+		}
+		mean /= float64(len(scores))
+		for _, s := range scores {
+			variance += (float64(s) - mean) * (float64(s) - mean)
+		}
+		variance /= float64(len(scores) - 1)
+		c.Method = "sampled"
+		c.Margin = int(math.Ceil(1.96 * math.Sqrt(variance)))
+	}
+	if c.Method == "self-reported" && (c.Margin <= 0 || c.Margin > 50) {
+		c.Margin = defaultScoreMargin
+	}
+	c.Low, c.High = max(score-c.Margin, 0), min(score+c.Margin, 100)
+	return c
+}
@@ -0,0 +1,171 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Email addresses and Slack member IDs are only delivered to once the client
+// has shown they can read what's sent there, by posting back a code sent to
+// the address. Until then the address only ever receives that code.
+const (
+	confirmationCodeTTL = 24 * time.Hour
+	// maxConfirmationsPerDay bounds the codes a client can have sent, so
+	// confirmations can't be used to message strangers either.
+	maxConfirmationsPerDay = 5
+	// A code is thrown away after this many wrong guesses.
+	maxConfirmationAttempts = 5
+)
+
+func confirmationKey(owner, channel string) string {
+	return rkey("notify", "confirm", owner, channel)
+}
+
+func confirmationsSentKey(owner string) string {
+	return rkey("notify", "codes-sent", owner)
+}
+
+// A pendingConfirmation is a code sent to an address. It's stored sealed,
+// since it holds the address.
+type pendingConfirmation struct {
+	Address  string `json:"address"`
+	Code     string `json:"code"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// sendConfirmationCode sends a new code to address on channel, replacing any
+// earlier one. It returns the message and status to reply with if it
+// couldn't.
+func (app *application) sendConfirmationCode(ctx context.Context, owner, channel, address string) (string, int) {
+	log := app.logger.With("clientID", owner, "channel", channel)
+	sent, err := app.rdb.Incr(ctx, confirmationsSentKey(owner)).Result()
+	if err == nil && sent == 1 {
+		err = app.rdb.Expire(ctx, confirmationsSentKey(owner), 24*time.Hour).Err()
+	}
+	if err != nil {
+		log.Error("failed to count confirmation codes", "error", err)
+		return "Could not save notification preferences", http.StatusInternalServerError
+	}
+	if sent > maxConfirmationsPerDay {
+		return "Too many confirmation codes have been sent today. Please try again tomorrow.", http.StatusTooManyRequests
+	}
+
+	c := pendingConfirmation{Address: address, Code: randomCouponCode()}
+	data, err := app.marshalSealed(confirmationKey(owner, channel), c)
+	if err == nil {
+		err = app.rdb.Set(ctx, confirmationKey(owner, channel), data, confirmationCodeTTL).Err()
+	}
+	if err != nil {
+		log.Error("failed to store confirmation code", "error", err)
+		return "Could not save notification preferences", http.StatusInternalServerError
+	}
+
+	var to notificationPrefs
+	for _, a := range to.confirmable() {
+		if a.channel == channel {
+			*a.address = address
+		}
+	}
+	n := notification{
+		Event:   "confirmation",
+		Subject: "Confirm your JobFit.ai notifications",
+		Body: fmt.Sprintf("Your confirmation code is %s. Enter it in your JobFit.ai notification settings within 24 hours to start receiving notifications here.\n\n"+
+			"If you didn't ask for this, you can ignore this message: nothing else will be sent to you.\n", c.Code),
+	}
+	if err := app.channels[channel].send(ctx, to, n); err != nil {
+		log.Error("failed to send confirmation code", "error", err)
+		return "Could not send the confirmation code to " + channel, http.StatusBadGateway
+	}
+	log.Info("confirmation code sent")
+	return "", 0
+}
+
+// confirmAddressHandler confirms the email address or Slack member ID a code
+// was sent to, which then receives the notifications the caller chose for it.
+func (app *application) confirmAddressHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Channel string `json:"channel"`
+		Code    string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Channel != "email" && req.Channel != "slack" {
+		http.Error(w, `channel must be "email" or "slack"`, http.StatusBadRequest)
+		return
+	}
+	const invalid = "Invalid or expired confirmation code"
+	owner, ok := app.existingClientID(r)
+	if !ok {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	key := confirmationKey(owner, req.Channel)
+
+	data, err := app.rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	var c pendingConfirmation
+	if err == nil {
+		err = app.unmarshalSealed(key, data, &c)
+	}
+	if err != nil {
+		app.logger.Error("failed to load confirmation code", "error", err)
+		http.Error(w, "Could not confirm the address", http.StatusInternalServerError)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if subtle.ConstantTimeCompare([]byte(code), []byte(c.Code)) != 1 {
+		c.Attempts++
+		if c.Attempts >= maxConfirmationAttempts {
+			app.rdb.Del(ctx, key)
+		} else if data, err := app.marshalSealed(key, c); err == nil {
+			app.rdb.Set(ctx, key, data, redis.KeepTTL)
+		}
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+
+	p, err := app.loadNotificationPrefs(ctx, owner)
+	if err != nil {
+		app.logger.Error("failed to load notification preferences", "error", err)
+		http.Error(w, "Could not confirm the address", http.StatusInternalServerError)
+		return
+	}
+	confirmed := false
+	for _, a := range p.confirmable() {
+		if a.channel == req.Channel && *a.pending == c.Address {
+			*a.address, *a.pending = c.Address, ""
+			confirmed = true
+		}
+	}
+	app.rdb.Del(ctx, key)
+	if !confirmed {
+		// The address was changed or removed since the code was sent.
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	if err := app.saveNotificationPrefs(ctx, owner, p); err != nil {
+		app.logger.Error("failed to save notification preferences", "error", err)
+		http.Error(w, "Could not confirm the address", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("notification address confirmed", "clientID", owner, "channel", req.Channel)
+
+	p.WebhookSecret = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
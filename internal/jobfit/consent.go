@@ -0,0 +1,192 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// When TERMS_VERSION is set, visitors must accept that version of the terms
+// of service and privacy policy before any resume is analyzed. Each
+// acceptance is kept as evidence of consent: the version, when it was given
+// and the IP address it came from. Publishing a new TERMS_VERSION asks
+// everyone to accept again. Widget and integration analyses are covered by
+// the embedding site's own terms and aren't checked.
+const maxConsentRecords = 20
+
+// consentKey lists a client's acceptances, newest first.
+func consentKey(clientID string) string { return rkey("consent", clientID) }
+
+// termsConfig is the current version of the terms and where to read them.
+type termsConfig struct {
+	version string
+	url     string
+}
+
+// loadTerms reads TERMS_VERSION and TERMS_URL.
+func loadTerms() (termsConfig, error) {
+	tc := termsConfig{version: os.Getenv("TERMS_VERSION"), url: os.Getenv("TERMS_URL")}
+	if tc.url != "" {
+		if tc.version == "" {
+			return tc, errors.New("TERMS_URL needs TERMS_VERSION")
+		}
+		if u, err := url.Parse(tc.url); err != nil || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "") {
+			return tc, fmt.Errorf("TERMS_URL must be a web address, got %q", tc.url)
+		}
+	}
+	return tc, nil
+}
+
+// A consentRecord is one acceptance of the terms.
+type consentRecord struct {
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+	IP         string    `json:"ip"`
+}
+
+// latestConsent returns the client's most recent acceptance, or nil if they
+// have never accepted.
+func (app *application) latestConsent(ctx context.Context, clientID string) (*consentRecord, error) {
+	data, err := app.rdb.LIndex(ctx, consentKey(clientID), 0).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c consentRecord
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// requireConsent writes a 428 response and returns false if owner hasn't
+// accepted the current terms.
+func (app *application) requireConsent(w http.ResponseWriter, r *http.Request, owner string) bool {
+	if app.terms.version == "" {
+		return true
+	}
+	c, err := app.latestConsent(r.Context(), owner)
+	if err != nil {
+		app.logger.Error("failed to load consent", "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return false
+	}
+	if c == nil || c.Version != app.terms.version {
+		http.Error(w, "Please accept the terms of service and privacy policy to continue.", http.StatusPreconditionRequired)
+		return false
+	}
+	return true
+}
+
+// consentResponse is the caller's consent status.
+type consentResponse struct {
+	Required        bool       `json:"required"`
+	CurrentVersion  string     `json:"currentVersion,omitempty"`
+	TermsURL        string     `json:"termsUrl,omitempty"`
+	Accepted        bool       `json:"accepted"`
+	AcceptedVersion string     `json:"acceptedVersion,omitempty"`
+	AcceptedAt      *time.Time `json:"acceptedAt,omitempty"`
+}
+
+func (app *application) consentStatus(c *consentRecord) consentResponse {
+	resp := consentResponse{Required: app.terms.version != "", CurrentVersion: app.terms.version, TermsURL: app.terms.url}
+	if c != nil {
+		resp.Accepted = c.Version == app.terms.version
+		resp.AcceptedVersion = c.Version
+		resp.AcceptedAt = &c.AcceptedAt
+	}
+	return resp
+}
+
+// consentHandler reports which version of the terms the caller last
+// accepted and whether it's the current one.
+func (app *application) consentHandler(w http.ResponseWriter, r *http.Request) {
+	var c *consentRecord
+	if owner, ok := app.existingClientID(r); ok {
+		var err error
+		if c, err = app.latestConsent(r.Context(), owner); err != nil {
+			app.logger.Error("failed to load consent", "error", err)
+			http.Error(w, "Could not load consent", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(app.consentStatus(c))
+}
+
+// acceptConsentHandler records that the caller accepted the terms. The
+// request names the version they were shown, so someone who read an older
+// version isn't recorded as accepting the new one.
+func (app *application) acceptConsentHandler(w http.ResponseWriter, r *http.Request) {
+	owner := app.clientID(w, r)
+
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if app.terms.version == "" {
+		http.Error(w, "No terms need accepting", http.StatusNotFound)
+		return
+	}
+	if req.Version != app.terms.version {
+		http.Error(w, "The terms have changed; please review the current version", http.StatusConflict)
+		return
+	}
+
+	c := consentRecord{Version: req.Version, AcceptedAt: time.Now().UTC().Truncate(time.Second), IP: getIPAddress(r)}
+	data, err := json.Marshal(c)
+	if err != nil {
+		http.Error(w, "Could not record consent", http.StatusInternalServerError)
+		return
+	}
+	pipe := app.rdb.TxPipeline()
+	pipe.LPush(r.Context(), consentKey(owner), data)
+	pipe.LTrim(r.Context(), consentKey(owner), 0, maxConsentRecords-1)
+	if _, err := pipe.Exec(r.Context()); err != nil {
+		app.logger.Error("failed to record consent", "error", err)
+		http.Error(w, "Could not record consent", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("terms accepted", "version", c.Version, "ip", c.IP)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app.consentStatus(&c))
+}
+
+// adminConsentHandler lists every acceptance recorded for a client, newest
+// first, for answering data protection requests.
+func (app *application) adminConsentHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("clientId")
+	if !isClientID(id) {
+		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+		return
+	}
+	items, err := app.rdb.LRange(r.Context(), consentKey(id), 0, -1).Result()
+	if err != nil {
+		app.logger.Error("failed to load consent", "clientID", id, "error", err)
+		http.Error(w, "Could not load consent", http.StatusInternalServerError)
+		return
+	}
+	records := make([]consentRecord, 0, len(items))
+	for _, item := range items {
+		var c consentRecord
+		if json.Unmarshal([]byte(item), &c) == nil {
+			records = append(records, c)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,265 @@
+package jobfit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxConsistencyIssues = 30
+
+// pastIrregularVerbs are common resume verbs whose past tense doesn't end in
+// "-ed", keyed by their present form.
+var pastIrregularVerbs = map[string]string{
+	"lead": "led", "build": "built", "run": "ran", "make": "made", "win": "won", "grow": "grew",
+	"drive": "drove", "write": "wrote", "teach": "taught", "begin": "began", "bring": "brought",
+	"sell": "sold", "set": "set", "cut": "cut", "spend": "spent", "keep": "kept", "hold": "held",
+	"oversee": "oversaw", "take": "took", "give": "gave", "choose": "chose", "meet": "met",
+	"send": "sent", "find": "found", "become": "became", "speak": "spoke", "think": "thought",
+}
+
+// pastIrregularForms is the set of pastIrregularVerbs' past forms.
+var pastIrregularForms = func() map[string]bool {
+	forms := make(map[string]bool, len(pastIrregularVerbs))
+	for _, past := range pastIrregularVerbs {
+		forms[past] = true
+	}
+	return forms
+}()
+
+// presentActionVerbs are the base forms of common resume verbs with regular
+// past tenses, used to recognize present-tense bullets such as "Manage the
+// team" or "Develops APIs".
+var presentActionVerbs = []string{
+	"manage", "develop", "design", "implement", "create", "coordinate", "maintain", "support",
+	"analyze", "analyse", "improve", "deliver", "collaborate", "work", "help", "handle", "ensure",
+	"provide", "mentor", "train", "monitor", "plan", "prepare", "review", "test", "direct",
+	"establish", "launch", "reduce", "increase", "optimize", "automate", "migrate", "deploy",
+	"own", "partner", "present", "research", "architect", "engineer", "streamline", "organize",
+	"assist", "conduct", "perform", "serve", "negotiate", "produce", "resolve", "track",
+}
+
+var (
+	presentDateRegex = regexp.MustCompile(`(?i)^(present|current|now|today)$`)
+	leadingWordRegex = regexp.MustCompile(`^[A-Za-z]+`)
+)
+
+// consistencyIssue is a place where the resume breaks its own style. Line is
+// 1-based and Column counts characters from 1, pointing at the word, date or
+// character at fault.
+type consistencyIssue struct {
+	// Kind is "tense", "date_format", "bullet_punctuation" or
+	// "bullet_marker".
+	Kind    string `json:"kind"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Text    string `json:"text"`
+	Message string `json:"message"`
+}
+
+// verbTense reports whether word reads as a "past" or "present" tense verb,
+// or "" if it can't tell.
+func verbTense(word string) string {
+	w := strings.ToLower(word)
+	switch {
+	case strings.HasSuffix(w, "ed") || pastIrregularForms[w]:
+		return "past"
+	case strings.HasSuffix(w, "ing"):
+		return "present"
+	}
+	for _, stem := range []string{w, strings.TrimSuffix(w, "s"), strings.TrimSuffix(w, "es")} {
+		if _, ok := pastIrregularVerbs[stem]; ok || slices.Contains(presentActionVerbs, stem) {
+			return "present"
+		}
+	}
+	return ""
+}
+
+// dateFormat names how a resume date is written, or "" for "Present" and
+// anything unrecognized.
+func dateFormat(date string) string {
+	date = strings.TrimSpace(date)
+	switch {
+	case numericDateRegex.MatchString(date):
+		return "MM/YYYY"
+	case yearOnlyRegex.MatchString(date):
+		return "YYYY"
+	}
+	m := monthYearRegex.FindStringSubmatch(date)
+	if m == nil {
+		return ""
+	}
+	switch month := strings.ToLower(m[1]); {
+	case month == "may":
+		// Written the same either way.
+		return ""
+	case len(month) == 3 || month == "sept":
+		if strings.Contains(date, ".") {
+			return "Mon. YYYY"
+		}
+		return "Mon YYYY"
+	}
+	return "Month YYYY"
+}
+
+// column is the 1-based character position of byte offset i in line.
+func column(line string, i int) int {
+	return utf8.RuneCountInString(line[:i]) + 1
+}
+
+// consistencyLine is a line of an experience section.
+type consistencyLine struct {
+	number int
+	raw    string
+	// text is the line without its bullet marker, starting at offset in raw.
+	text   string
+	offset int
+	marker rune
+	// inRole is whether the line comes after a role's dates, and current
+	// whether that role is ongoing.
+	inRole  bool
+	current bool
+	dated   bool
+}
+
+// minority returns the items whose key isn't the most common one, so the
+// odd ones out can be flagged. Ties keep the first key seen.
+func minority[T any](items []T, key func(T) string) []T {
+	counts := map[string]int{}
+	var order []string
+	for _, it := range items {
+		k := key(it)
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+	if len(order) < 2 {
+		return nil
+	}
+	major := order[0]
+	for _, k := range order {
+		if counts[k] > counts[major] {
+			major = k
+		}
+	}
+	var out []T
+	for _, it := range items {
+		if key(it) != major {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// checkConsistency looks for mixed style in the resume's experience
+// sections: present tense in past roles, dates written several ways, and
+// bullets that differ in end punctuation or marker. It's rule-based, so it
+// costs no model calls. Issues are in line order.
+func checkConsistency(resume string) []consistencyIssue {
+	var lines []consistencyLine
+	inExperience, inRole, current := false, false, false
+	for i, raw := range strings.Split(resume, "\n") {
+		if isSectionHeading(raw) {
+			name := strings.ToLower(strings.TrimRight(strings.TrimSpace(raw), ": "))
+			inExperience = slices.Contains(experienceSections, name)
+			continue
+		}
+		trimmed := strings.TrimSpace(raw)
+		if !inExperience || trimmed == "" {
+			continue
+		}
+		l := consistencyLine{number: i + 1, raw: raw}
+		text := strings.TrimSpace(strings.TrimLeft(trimmed, bulletMarkers))
+		if text != trimmed {
+			l.marker, _ = utf8.DecodeRuneInString(trimmed)
+		}
+		l.text = text
+		l.offset = strings.Index(raw, text)
+		if m := dateRangeRegex.FindStringSubmatch(text); m != nil {
+			inRole, current = true, presentDateRegex.MatchString(strings.TrimSpace(m[2]))
+			l.dated = true
+		}
+		l.inRole, l.current = inRole, current
+		lines = append(lines, l)
+	}
+
+	var issues []consistencyIssue
+	var bullets []consistencyLine
+	var dates []consistencyIssue
+	for _, l := range lines {
+		if l.dated {
+			for _, loc := range dateRangeRegex.FindAllStringSubmatchIndex(l.text, -1) {
+				for _, g := range [][2]int{{loc[2], loc[3]}, {loc[4], loc[5]}} {
+					date := l.text[g[0]:g[1]]
+					if f := dateFormat(date); f != "" {
+						dates = append(dates, consistencyIssue{Kind: "date_format", Line: l.number, Column: column(l.raw, l.offset+g[0]), Text: date, Message: f})
+					}
+				}
+			}
+			continue
+		}
+		if l.marker == 0 {
+			continue
+		}
+		bullets = append(bullets, l)
+		word := leadingWordRegex.FindString(l.text)
+		if l.inRole && !l.current && verbTense(word) == "present" {
+			issues = append(issues, consistencyIssue{Kind: "tense", Line: l.number, Column: column(l.raw, l.offset), Text: word,
+				Message: fmt.Sprintf("%q is present tense in a role that has ended; describe past roles in the past tense.", word)})
+		}
+	}
+
+	// Current roles may use either tense, but not both.
+	var currentVerbs []consistencyIssue
+	for _, l := range bullets {
+		if word := leadingWordRegex.FindString(l.text); l.current && verbTense(word) != "" {
+			currentVerbs = append(currentVerbs, consistencyIssue{Kind: "tense", Line: l.number, Column: column(l.raw, l.offset), Text: word, Message: verbTense(word)})
+		}
+	}
+	for _, v := range minority(currentVerbs, func(i consistencyIssue) string { return i.Message }) {
+		v.Message = fmt.Sprintf("%q is %s tense while most bullets in your current role are not; pick one tense for the role.", v.Text, v.Message)
+		issues = append(issues, v)
+	}
+
+	for _, d := range minority(dates, func(i consistencyIssue) string { return i.Message }) {
+		d.Message = fmt.Sprintf("%q is written as %s, unlike most dates on the resume; write every date the same way.", d.Text, d.Message)
+		issues = append(issues, d)
+	}
+
+	endsWithPeriod := func(l consistencyLine) string { return fmt.Sprint(strings.HasSuffix(l.text, ".")) }
+	for _, l := range minority(bullets, endsWithPeriod) {
+		msg := "This bullet ends with a period while most don't; end every bullet the same way."
+		if endsWithPeriod(l) == "false" {
+			msg = "This bullet has no closing period while most do; end every bullet the same way."
+		}
+		end := len(strings.TrimRight(l.raw, " \t\r"))
+		issues = append(issues, consistencyIssue{Kind: "bullet_punctuation", Line: l.number, Column: column(l.raw, end-1), Text: l.text, Message: msg})
+	}
+	for _, l := range minority(bullets, func(l consistencyLine) string { return string(l.marker) }) {
+		i := strings.IndexRune(l.raw, l.marker)
+		issues = append(issues, consistencyIssue{Kind: "bullet_marker", Line: l.number, Column: column(l.raw, i), Text: string(l.marker),
+			Message: fmt.Sprintf("This bullet starts with %q, unlike most; use the same bullet marker throughout.", string(l.marker))})
+	}
+
+	slices.SortStableFunc(issues, func(a, b consistencyIssue) int { return a.Line - b.Line })
+	return issues[:min(len(issues), maxConsistencyIssues)]
+}
+
+// consistencyImprovement summarizes issues as one improvement bullet.
+func consistencyImprovement(issues []consistencyIssue) string {
+	var kinds []string
+	for _, k := range []struct{ kind, name string }{
+		{"tense", "verb tense"},
+		{"date_format", "date formats"},
+		{"bullet_punctuation", "bullet punctuation"},
+		{"bullet_marker", "bullet markers"},
+	} {
+		if slices.ContainsFunc(issues, func(i consistencyIssue) bool { return i.Kind == k.kind }) {
+			kinds = append(kinds, k.name)
+		}
+	}
+	return fmt.Sprintf("- **Consistency:** Make %s consistent (%d places, starting at line %d).", strings.Join(kinds, ", "), len(issues), issues[0].Line)
+}
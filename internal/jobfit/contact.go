@@ -0,0 +1,88 @@
+package jobfit
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Looser patterns than the parser's, used to spot contact details that are
+// present but malformed.
+var (
+	emailLikeRegex       = regexp.MustCompile(`\S+@\S+`)
+	linkedInMentionRegex = regexp.MustCompile(`(?i)\blinked\s?in\b`)
+	linkedInURLRegex     = regexp.MustCompile(`(?i)\S*linkedin\.com\S*`)
+)
+
+// contactIssue is a problem with the contact details at the top of a resume.
+type contactIssue struct {
+	Field string `json:"field"`
+	// Problem is "missing" or "invalid".
+	Problem string `json:"problem"`
+	Message string `json:"message"`
+}
+
+// resumeHeader returns the lines above the first section heading, plus any
+// explicit contact section, which is where recruiters look for contact details.
+func resumeHeader(text string) string {
+	var b strings.Builder
+	for _, s := range splitSections(text) {
+		if s.Name != "header" && s.Name != "contact" {
+			continue
+		}
+		for _, l := range s.Lines {
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// checkContact flags missing or malformed email, phone, LinkedIn and location
+// details. c is the contact block from parseResumeRules and header the text
+// it was parsed from.
+func checkContact(c resumeContact, header string) []contactIssue {
+	issues := []contactIssue{}
+
+	switch {
+	case c.Email != "":
+		if _, err := mail.ParseAddress(c.Email); err != nil || strings.Contains(c.Email, "..") {
+			issues = append(issues, contactIssue{"email", "invalid", "The email address " + c.Email + " doesn't look valid."})
+		}
+	case emailLikeRegex.MatchString(header):
+		issues = append(issues, contactIssue{"email", "invalid", "The email address " + emailLikeRegex.FindString(header) + " is incomplete."})
+	default:
+		issues = append(issues, contactIssue{"email", "missing", "Add an email address to the top of the resume."})
+	}
+
+	if c.Phone == "" {
+		issues = append(issues, contactIssue{"phone", "missing", "Add a phone number to the top of the resume."})
+	} else if digits := countDigits(c.Phone); digits < 10 || digits > 15 {
+		issues = append(issues, contactIssue{"phone", "invalid", "The phone number " + c.Phone + " has the wrong number of digits."})
+	}
+
+	switch {
+	case c.LinkedIn != "":
+	case linkedInURLRegex.MatchString(header):
+		issues = append(issues, contactIssue{"linkedin", "invalid", "The LinkedIn link should point to a profile (linkedin.com/in/your-name)."})
+	case linkedInMentionRegex.MatchString(header):
+		issues = append(issues, contactIssue{"linkedin", "invalid", "LinkedIn is mentioned but there's no profile URL."})
+	default:
+		issues = append(issues, contactIssue{"linkedin", "missing", "Add a LinkedIn profile URL."})
+	}
+
+	if c.Location == "" {
+		issues = append(issues, contactIssue{"location", "missing", `Add a location such as "City, State" so recruiters can check eligibility.`})
+	}
+	return issues
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package jobfit
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// shingleSize is the number of consecutive words compared at a time.
+	// Eight words is long enough that a match is almost never a coincidence.
+	shingleSize = 8
+	// Copied runs shorter than this are ignored; short stock phrases like
+	// "cross-functional teams to deliver" are fine to share.
+	minCopiedWords = 12
+)
+
+var wordRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+type copiedSpan struct {
+	Text  string `json:"text"`
+	Words int    `json:"words"`
+}
+
+// jobCopyReport lists passages of the resume copied from the job description.
+type jobCopyReport struct {
+	Spans []copiedSpan `json:"spans"`
+	// Share is the percentage of the resume's words inside copied passages.
+	Share int `json:"share"`
+}
+
+func shingle(words []string) string {
+	return strings.Join(words, " ")
+}
+
+// detectCopiedText finds passages pasted from the job description into the
+// resume by comparing overlapping runs of words. It returns nil if none are
+// long enough to matter.
+func detectCopiedText(resume, jobDescription string) *jobCopyReport {
+	jobWords := searchTerms(jobDescription)
+	if len(jobWords) < shingleSize {
+		return nil
+	}
+	jobShingles := make(map[string]bool, len(jobWords))
+	for i := 0; i+shingleSize <= len(jobWords); i++ {
+		jobShingles[shingle(jobWords[i:i+shingleSize])] = true
+	}
+
+	// Keep each word's position so spans can be quoted from the original text.
+	locs := wordRegex.FindAllStringIndex(resume, -1)
+	words := make([]string, len(locs))
+	for i, loc := range locs {
+		words[i] = strings.ToLower(resume[loc[0]:loc[1]])
+	}
+
+	// Mark every word covered by a shingle that also appears in the job.
+	copied := make([]bool, len(words))
+	for i := 0; i+shingleSize <= len(words); i++ {
+		if jobShingles[shingle(words[i:i+shingleSize])] {
+			for j := i; j < i+shingleSize; j++ {
+				copied[j] = true
+			}
+		}
+	}
+
+	report := &jobCopyReport{Spans: []copiedSpan{}}
+	copiedWords := 0
+	for start := 0; start < len(words); {
+		if !copied[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(words) && copied[end] {
+			end++
+		}
+		if n := end - start; n >= minCopiedWords {
+			report.Spans = append(report.Spans, copiedSpan{Text: resume[locs[start][0]:locs[end-1][1]], Words: n})
+			copiedWords += n
+		}
+		start = end
+	}
+	if len(report.Spans) == 0 {
+		return nil
+	}
+	report.Share = copiedWords * 100 / len(words)
+	return report
+}
@@ -0,0 +1,234 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Bonus credits that go unused for this long are dropped.
+	bonusCreditRetention = 90 * 24 * time.Hour
+)
+
+func couponIndexKey() string { return rkey("coupons") }
+
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9-]{4,32}$`)
+
+// A coupon grants extra credits to whoever redeems it, once per client.
+// MaxRedemptions of zero means unlimited.
+type coupon struct {
+	Code           string    `json:"code"`
+	Credits        int64     `json:"credits"`
+	MaxRedemptions int64     `json:"maxRedemptions"`
+	Redemptions    int64     `json:"redemptions"`
+	ExpiresAt      time.Time `json:"expiresAt,omitzero"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func couponKey(code string) string          { return rkey("coupon", code) }
+func couponRedeemersKey(code string) string { return rkey("coupon", code, "redeemers") }
+func bonusKey(bucket string) string         { return rkey("bonus", bucket) }
+
+// redeemCouponScript checks and applies a redemption atomically. It returns the
+// new bonus balance, or a negative status: -1 unknown or expired, -2 used up,
+// -3 already redeemed by this client.
+var redeemCouponScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return -1 end
+local max = tonumber(redis.call('HGET', KEYS[1], 'maxRedemptions'))
+local used = tonumber(redis.call('HGET', KEYS[1], 'redemptions'))
+if max > 0 and used >= max then return -2 end
+if redis.call('SADD', KEYS[2], ARGV[1]) == 0 then return -3 end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then redis.call('PEXPIRE', KEYS[2], ttl) end
+redis.call('HINCRBY', KEYS[1], 'redemptions', 1)
+local balance = redis.call('INCRBY', KEYS[3], redis.call('HGET', KEYS[1], 'credits'))
+redis.call('EXPIRE', KEYS[3], ARGV[2])
+return balance
+`)
+
+func randomCouponCode() string {
+	var b [8]byte
+	rand.Read(b[:])
+	for i := range b {
+		b[i] = ulidAlphabet[b[i]&0x1f]
+	}
+	return string(b[:])
+}
+
+// redeemCouponHandler applies a coupon code to the caller's quota.
+func (app *application) redeemCouponHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if !couponCodePattern.MatchString(code) {
+		http.Error(w, "That code is not valid", http.StatusNotFound)
+		return
+	}
+
+	bucket := app.quotaBuckets(r)[0].key
+	keys := []string{couponKey(code), couponRedeemersKey(code), bonusKey(bucket)}
+	balance, err := redeemCouponScript.Run(r.Context(), app.rdb, keys, bucket, int(bonusCreditRetention.Seconds())).Int64()
+	if err != nil {
+		app.logger.Error("failed to redeem coupon", "code", code, "error", err)
+		http.Error(w, "Could not redeem code", http.StatusInternalServerError)
+		return
+	}
+	switch balance {
+	case -1:
+		http.Error(w, "That code is not valid or has expired", http.StatusNotFound)
+		return
+	case -2:
+		http.Error(w, "That code has already been fully redeemed", http.StatusGone)
+		return
+	case -3:
+		http.Error(w, "You have already redeemed that code", http.StatusConflict)
+		return
+	}
+	app.forgetQuota(bucket)
+	app.logger.Info("coupon redeemed", "code", code, "bucket", bucket)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"bonusCredits": balance})
+}
+
+func (app *application) adminCreateCouponHandler(w http.ResponseWriter, r *http.Request) {
+	c := coupon{MaxRedemptions: 1}
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+	if c.Code == "" {
+		c.Code = randomCouponCode()
+	}
+	if !couponCodePattern.MatchString(c.Code) {
+		http.Error(w, "code must be 4-32 letters, digits or dashes", http.StatusBadRequest)
+		return
+	}
+	if c.Credits < 1 || c.MaxRedemptions < 0 {
+		http.Error(w, "credits must be positive and maxRedemptions must not be negative", http.StatusBadRequest)
+		return
+	}
+	c.CreatedAt = time.Now().UTC()
+	if !c.ExpiresAt.IsZero() && !c.ExpiresAt.After(c.CreatedAt) {
+		http.Error(w, "expiresAt must be in the future", http.StatusBadRequest)
+		return
+	}
+	c.Redemptions = 0
+
+	ctx := r.Context()
+	created, err := app.rdb.HSetNX(ctx, couponKey(c.Code), "credits", c.Credits).Result()
+	if err != nil {
+		app.logger.Error("failed to create coupon", "code", c.Code, "error", err)
+		http.Error(w, "Could not create coupon", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		http.Error(w, "A coupon with that code already exists", http.StatusConflict)
+		return
+	}
+	pipe := app.rdb.TxPipeline()
+	pipe.HSet(ctx, couponKey(c.Code),
+		"maxRedemptions", c.MaxRedemptions,
+		"redemptions", 0,
+		"createdAt", c.CreatedAt.Unix(),
+	)
+	if !c.ExpiresAt.IsZero() {
+		pipe.HSet(ctx, couponKey(c.Code), "expiresAt", c.ExpiresAt.Unix())
+		pipe.ExpireAt(ctx, couponKey(c.Code), c.ExpiresAt)
+	}
+	pipe.SAdd(ctx, couponIndexKey(), c.Code)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.rdb.Del(ctx, couponKey(c.Code))
+		app.logger.Error("failed to create coupon", "code", c.Code, "error", err)
+		http.Error(w, "Could not create coupon", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("coupon created", "code", c.Code, "credits", c.Credits, "maxRedemptions", c.MaxRedemptions)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&c)
+}
+
+// loadCoupon reads a coupon, returning nil if it doesn't exist or has expired.
+func (app *application) loadCoupon(ctx context.Context, code string) (*coupon, error) {
+	fields, err := app.rdb.HGetAll(ctx, couponKey(code)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	num := func(name string) int64 {
+		n, _ := strconv.ParseInt(fields[name], 10, 64)
+		return n
+	}
+	c := &coupon{
+		Code:           code,
+		Credits:        num("credits"),
+		MaxRedemptions: num("maxRedemptions"),
+		Redemptions:    num("redemptions"),
+		CreatedAt:      time.Unix(num("createdAt"), 0).UTC(),
+	}
+	if exp := num("expiresAt"); exp > 0 {
+		c.ExpiresAt = time.Unix(exp, 0).UTC()
+	}
+	return c, nil
+}
+
+func (app *application) adminListCouponsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	codes, err := app.rdb.SMembers(ctx, couponIndexKey()).Result()
+	if err != nil {
+		app.logger.Error("failed to list coupons", "error", err)
+		http.Error(w, "Could not list coupons", http.StatusInternalServerError)
+		return
+	}
+	list := make([]*coupon, 0, len(codes))
+	for _, code := range codes {
+		c, err := app.loadCoupon(ctx, code)
+		if err != nil {
+			app.logger.Error("failed to load coupon", "code", code, "error", err)
+			http.Error(w, "Could not list coupons", http.StatusInternalServerError)
+			return
+		}
+		if c == nil {
+			// Expired; tidy up the index while we're here.
+			app.rdb.SRem(ctx, couponIndexKey(), code)
+			continue
+		}
+		list = append(list, c)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (app *application) adminDeleteCouponHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(r.PathValue("code"))
+	n, err := app.rdb.Del(r.Context(), couponKey(code), couponRedeemersKey(code)).Result()
+	if err == nil {
+		err = app.rdb.SRem(r.Context(), couponIndexKey(), code).Err()
+	}
+	if err != nil {
+		app.logger.Error("failed to delete coupon", "code", code, "error", err)
+		http.Error(w, "Could not delete coupon", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Coupon not found", http.StatusNotFound)
+		return
+	}
+	app.logger.Info("coupon deleted", "code", code)
+	w.WriteHeader(http.StatusNoContent)
+}
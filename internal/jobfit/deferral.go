@@ -0,0 +1,199 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A client with a signed token who runs out of credits can still post to
+// /analyses/async: the analysis is deferred, kept sealed like any queued job,
+// and queued for a worker once their credits reset, paid for from the new
+// day's allowance. Status is polled at /analyses/async/{id} as usual.
+const (
+	defaultMaxDeferred = 3
+	maxDeferredLimit   = 100
+	// A deferred analysis that still can't be paid for this long after it
+	// was posted fails rather than wait on.
+	maxAnalysisDeferral = 48 * time.Hour
+	deferralInterval    = time.Minute
+	deferralBatchSize   = 100
+)
+
+// deferredDueKey is a sorted set of deferred job IDs scored by when they are
+// due, in Unix milliseconds.
+func deferredDueKey() string { return rkey("queue", "deferred") }
+
+// deferredClientKey is the set of a client's deferred job IDs, which bounds
+// how many they can have.
+func deferredClientKey(owner string) string { return rkey("deferred", owner) }
+
+// deferralTime returns when an analysis that went over quota can run instead,
+// or false if it can't be deferred: deferral is off, the client has no
+// signed token, or it was their network's quota that ran out rather than
+// their own.
+func (app *application) deferralTime(r *http.Request, usage quotaUsage) (time.Time, bool) {
+	if app.quota.maxDeferred == 0 {
+		return time.Time{}, false
+	}
+	id, verified := app.readClientCookie(r)
+	if !verified || usage.exceeded.key != "client:"+id {
+		return time.Time{}, false
+	}
+	return app.quotaResetTime(r.Context(), usage.exceeded.key), true
+}
+
+// quotaResetTime is when bucket's daily counter runs out.
+func (app *application) quotaResetTime(ctx context.Context, bucket string) time.Time {
+	ttl, err := app.rdb.TTL(ctx, quotaKey(bucket)).Result()
+	if err != nil || ttl <= 0 {
+		// Try again shortly; the counter gets its expiry back on the next
+		// charge.
+		ttl = deferralInterval
+	}
+	return time.Now().Add(ttl)
+}
+
+// deferAnalysisJob stores job to be queued at runAfter, unless the client
+// already has as many deferred analyses as they may, and replies with where
+// to poll for it. Nothing has run yet, so a pending referral is left for the
+// worker to pay out once the analysis completes.
+//
+// Only clients with a signed token get here. There are no user accounts, so
+// the signed token stands in for an authenticated user: it is what credits
+// are counted against, and unlike an IP address it can't be shared or
+// forged. Unsigned clients and IP quotas still get a 429. Once accounts
+// exist, deferral should key on them.
+func (app *application) deferAnalysisJob(w http.ResponseWriter, r *http.Request, job *analysisJob, runAfter time.Time, usage quotaUsage) {
+	ctx := r.Context()
+	log := app.logger.With("analysisID", job.ID, "clientID", job.Owner)
+
+	pipe := app.rdb.TxPipeline()
+	pipe.SAdd(ctx, deferredClientKey(job.Owner), job.ID)
+	count := pipe.SCard(ctx, deferredClientKey(job.Owner))
+	pipe.Expire(ctx, deferredClientKey(job.Owner), maxAnalysisDeferral+analysisJobRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("failed to defer analysis", "error", err)
+		http.Error(w, "Failed to queue analysis", http.StatusInternalServerError)
+		return
+	}
+	if count.Val() > app.quota.maxDeferred {
+		app.rdb.SRem(ctx, deferredClientKey(job.Owner), job.ID)
+		app.rejectOverQuota(w, r, actionAnalyze, usage)
+		return
+	}
+
+	job.Status = jobDeferred
+	due := runAfter.UTC().Truncate(time.Second)
+	job.RunAfter = &due
+	if err := app.saveAnalysisJob(ctx, job); err != nil {
+		app.rdb.SRem(ctx, deferredClientKey(job.Owner), job.ID)
+		log.Error("failed to store analysis job", "error", err)
+		http.Error(w, "Failed to queue analysis", http.StatusInternalServerError)
+		return
+	}
+	if err := app.rdb.ZAdd(ctx, deferredDueKey(), redis.Z{Score: float64(due.UnixMilli()), Member: job.ID}).Err(); err != nil {
+		app.rdb.SRem(ctx, deferredClientKey(job.Owner), job.ID)
+		app.rdb.Del(ctx, analysisJobKey(job.ID))
+		log.Error("failed to defer analysis", "error", err)
+		http.Error(w, "Failed to queue analysis", http.StatusInternalServerError)
+		return
+	}
+	log.Info("deferred analysis request until credits reset", "runAfter", due)
+
+	w.Header().Set("Location", "/analyses/async/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(analysisJobResponse{ID: job.ID, Status: job.Status, QueuedAt: job.QueuedAt, RunAfter: job.RunAfter})
+}
+
+// runDeferredAnalyses queues deferred analyses once they are due. It runs on
+// the worker's leader.
+func (app *application) runDeferredAnalyses(ctx context.Context) {
+	ticker := time.NewTicker(deferralInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.queueDueAnalyses(ctx)
+		}
+	}
+}
+
+func (app *application) queueDueAnalyses(ctx context.Context) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	due, err := app.rdb.ZRangeByScore(ctx, deferredDueKey(), &redis.ZRangeBy{Min: "-inf", Max: now, Count: deferralBatchSize}).Result()
+	if err != nil {
+		app.logger.Error("failed to list deferred analyses", "error", err)
+		return
+	}
+	for _, id := range due {
+		if err := app.queueDeferredAnalysis(ctx, id); err != nil {
+			app.logger.Error("failed to queue deferred analysis", "analysisID", id, "error", err)
+		}
+	}
+}
+
+// queueDeferredAnalysis charges a due analysis to its client and queues it.
+// If their credits have already gone again it waits for the next reset, up
+// to maxAnalysisDeferral.
+func (app *application) queueDeferredAnalysis(ctx context.Context, id string) error {
+	job, err := app.loadAnalysisJob(ctx, id)
+	if errors.Is(err, errAnalysisJobNotFound) {
+		return app.rdb.ZRem(ctx, deferredDueKey(), id).Err()
+	}
+	if err != nil {
+		return err
+	}
+	done := func() error {
+		pipe := app.rdb.Pipeline()
+		pipe.ZRem(ctx, deferredDueKey(), id)
+		pipe.SRem(ctx, deferredClientKey(job.Owner), id)
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+	if job.Status != jobDeferred {
+		return done()
+	}
+	log := app.logger.With("analysisID", id, "clientID", job.Owner)
+
+	usage, err := app.consumeBuckets(ctx, app.clientQuotaBuckets(job.Owner, job.IP, app.geo.country(job.IP)), app.quota.costs[actionAnalyze])
+	if err != nil {
+		return err
+	}
+	if usage.exceeded != nil {
+		if time.Since(job.QueuedAt) > maxAnalysisDeferral {
+			log.Warn("deferred analysis expired before credits freed up")
+			finished := time.Now().UTC()
+			job.Status = jobFailed
+			job.Error = "Your credits didn't free up in time to run this analysis."
+			job.RunAfter = nil
+			job.Request = nil
+			job.FinishedAt = &finished
+			if err := app.saveAnalysisJob(ctx, job); err != nil {
+				return err
+			}
+			return done()
+		}
+		next := app.quotaResetTime(ctx, usage.exceeded.key).UTC().Truncate(time.Second)
+		job.RunAfter = &next
+		if err := app.saveAnalysisJob(ctx, job); err != nil {
+			return err
+		}
+		log.Info("deferred analysis still over quota", "bucket", usage.exceeded.key, "runAfter", next)
+		return app.rdb.ZAdd(ctx, deferredDueKey(), redis.Z{Score: float64(next.UnixMilli()), Member: id}).Err()
+	}
+
+	if err := app.submitAnalysisJob(ctx, job); err != nil {
+		return err
+	}
+	log.Info("queued deferred analysis", "used", usage.used, "limit", usage.limit, "bonus", usage.bonus)
+	return done()
+}
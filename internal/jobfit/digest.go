@@ -0,0 +1,189 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	digestInterval      = 7 * 24 * time.Hour
+	digestCheckInterval = time.Hour
+	digestRecentLimit   = 5
+	digestSendTimeout   = 5 * time.Minute
+	// Every digest carries its own unsubscribe link, which keeps working
+	// this long.
+	digestUnsubscribeTTL = 90 * 24 * time.Hour
+)
+
+func digestLastSentKey(owner string) string {
+	return rkey("digest", "lastsent", owner)
+}
+
+// digestUnsubscribeKey maps an unsubscribe token to the client whose digest
+// it was sent in.
+func digestUnsubscribeKey(token string) string {
+	return rkey("digest", "unsubscribe", token)
+}
+
+// runDigests periodically emails every subscriber whose last digest is at least
+// a week old. It blocks until ctx is cancelled, and is meant to run on the
+// elected leader only. Digests link back to the site to unsubscribe, so they
+// need PUBLIC_BASE_URL.
+func (app *application) runDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.sendDueDigests(ctx)
+		}
+	}
+}
+
+func (app *application) sendDueDigests(ctx context.Context) {
+	owners, err := app.rdb.SMembers(ctx, subscribersKey(eventWeeklyDigest)).Result()
+	if err != nil {
+		app.logger.Error("failed to list digest subscribers", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, owner := range owners {
+		if ctx.Err() != nil {
+			return
+		}
+		// The lock covers a leader that lost its lease part way through a
+		// send, so the next one can't mail the same subscriber twice.
+		err := app.withLock(ctx, "digest:"+owner, digestSendTimeout, func() error {
+			return app.sendDigest(ctx, owner, now)
+		})
+		if err != nil && !errors.Is(err, errLockHeld) {
+			app.logger.Error("failed to send digest", "error", err)
+		}
+	}
+}
+
+// sendDigest sends owner's digest if one is due.
+func (app *application) sendDigest(ctx context.Context, owner string, now time.Time) error {
+	lastSent, err := app.rdb.Get(ctx, digestLastSentKey(owner)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("loading digest state: %w", err)
+	}
+	if now.Sub(time.Unix(lastSent, 0)) < digestInterval {
+		return nil
+	}
+
+	// Nothing is sent until the client has confirmed an address to send it
+	// to, and the week isn't counted as handled, so the first digest goes out
+	// soon after they do.
+	p, err := app.loadNotificationPrefs(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("loading notification preferences: %w", err)
+	}
+	if !app.deliverable(p, eventWeeklyDigest) {
+		return nil
+	}
+
+	recs, err := app.loadHistory(ctx, owner, now.Add(-digestInterval), now)
+	if err != nil {
+		return fmt.Errorf("loading history: %w", err)
+	}
+	// Quiet weeks don't get a digest, but still count as handled.
+	if len(recs) > 0 {
+		token := randomHex(16)
+		if err := app.rdb.Set(ctx, digestUnsubscribeKey(token), owner, digestUnsubscribeTTL).Err(); err != nil {
+			return fmt.Errorf("saving unsubscribe token: %w", err)
+		}
+		unsubscribe := strings.TrimSuffix(app.baseURL, "/") + "/unsubscribe/" + token
+		n := notification{Event: eventWeeklyDigest, Subject: "Your weekly JobFit.ai summary", Body: digestBody(recs, unsubscribe)}
+		if err := app.deliver(ctx, p, n); err != nil {
+			return fmt.Errorf("delivering digest: %w", err)
+		}
+	}
+	if err := app.rdb.Set(ctx, digestLastSentKey(owner), now.Unix(), 0).Err(); err != nil {
+		return fmt.Errorf("saving digest state: %w", err)
+	}
+	return nil
+}
+
+// digestBody summarizes a week of analyses, which loadHistory returns oldest
+// first, ending with the link that turns the digest off.
+func digestBody(recs []*analysisRecord, unsubscribeURL string) string {
+	best, total := recs[0], 0
+	for _, rec := range recs {
+		total += rec.MatchScore
+		if rec.MatchScore > best.MatchScore {
+			best = rec
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Here is what you did on JobFit.ai this week.\n\n")
+	fmt.Fprintf(&b, "Analyses run: %d\n", len(recs))
+	fmt.Fprintf(&b, "Average match score: %d%%\n", total/len(recs))
+	fmt.Fprintf(&b, "Best match: %d%% for %s\n\n", best.MatchScore, describeJob(best))
+
+	b.WriteString("Most recent analyses:\n")
+	for i := len(recs) - 1; i >= max(0, len(recs)-digestRecentLimit); i-- {
+		rec := recs[i]
+		fmt.Fprintf(&b, "  %3d%%  %s (%s)\n", rec.MatchScore, describeJob(rec), rec.CreatedAt.Format("Jan 2"))
+	}
+
+	b.WriteString("\nYou are receiving this because you turned on the weekly digest. To stop receiving it, open this link:\n")
+	b.WriteString(unsubscribeURL + "\n")
+	return b.String()
+}
+
+func describeJob(rec *analysisRecord) string {
+	switch {
+	case rec.JobTitle != "" && rec.Company != "":
+		return rec.JobTitle + " at " + rec.Company
+	case rec.JobTitle != "":
+		return rec.JobTitle
+	default:
+		return "an untitled role"
+	}
+}
+
+// unsubscribeDigestHandler turns off the weekly digest for the client whose
+// digest carried the token. It needs no cookie, since the link is opened from
+// the digest, and accepts POST as well for mail clients' one-click
+// unsubscribe.
+func (app *application) unsubscribeDigestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := r.PathValue("token")
+	owner, err := app.rdb.Get(ctx, digestUnsubscribeKey(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		http.Error(w, "This unsubscribe link has expired. Turn the digest off in your notification settings instead.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load unsubscribe token", "error", err)
+		http.Error(w, "Could not unsubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	p, err := app.loadNotificationPrefs(ctx, owner)
+	if err == nil && len(p.Events[eventWeeklyDigest]) > 0 {
+		delete(p.Events, eventWeeklyDigest)
+		err = app.saveNotificationPrefs(ctx, owner, p)
+	}
+	if err != nil {
+		app.logger.Error("failed to unsubscribe from digest", "clientID", owner, "error", err)
+		http.Error(w, "Could not unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("unsubscribed from digest", "clientID", owner)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, "You won't receive the weekly JobFit.ai digest any more.\n")
+}
@@ -0,0 +1,42 @@
+package jobfit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestBodyLinksToUnsubscribe(t *testing.T) {
+	recs := []*analysisRecord{
+		{AnalysisResponse: AnalysisResponse{JobTitle: "Engineer", Company: "Acme", MatchScore: 60}, CreatedAt: time.Now()},
+		{AnalysisResponse: AnalysisResponse{JobTitle: "Lead", MatchScore: 80}, CreatedAt: time.Now()},
+	}
+	body := digestBody(recs, "https://jobfit.example/unsubscribe/abc123")
+	if !strings.HasSuffix(body, "\nhttps://jobfit.example/unsubscribe/abc123\n") {
+		t.Errorf("digest doesn't end with its unsubscribe link:\n%s", body)
+	}
+	if !strings.Contains(body, "Best match: 80% for Lead") {
+		t.Errorf("digest is missing the best match:\n%s", body)
+	}
+}
+
+func TestDigestNeedsConfirmedAddress(t *testing.T) {
+	var sent []string
+	app := &application{channels: map[string]notificationChannel{"email": recordingChannel{&sent}}}
+	events := map[string][]string{eventWeeklyDigest: {"email"}}
+	tests := []struct {
+		name string
+		p    notificationPrefs
+		want bool
+	}{
+		{"confirmed", notificationPrefs{Email: "me@example.com", Events: events}, true},
+		{"pending", notificationPrefs{PendingEmail: "me@example.com", Events: events}, false},
+		{"not subscribed", notificationPrefs{Email: "me@example.com"}, false},
+		{"channel not offered", notificationPrefs{Email: "me@example.com", Events: map[string][]string{eventWeeklyDigest: {"slack"}}}, false},
+	}
+	for _, tt := range tests {
+		if got := app.deliverable(tt.p, eventWeeklyDigest); got != tt.want {
+			t.Errorf("%s: deliverable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
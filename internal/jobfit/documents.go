@@ -0,0 +1,226 @@
+package jobfit
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDocumentXMLSize caps how much of a document's XML is decompressed, so a
+// small upload can't expand into gigabytes.
+const maxDocumentXMLSize = 20 << 20
+
+const (
+	wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	odtTextNS     = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odtMimetype   = "application/vnd.oasis.opendocument.text"
+)
+
+var errUnsupportedDocument = errors.New("unsupported document type")
+
+// A documentError is a problem with an uploaded document that its sender can
+// fix, worded for them. Any other error reading a document is only reported
+// to them as unreadable, since it comes from the parsers.
+type documentError string
+
+func (e documentError) Error() string { return string(e) }
+
+var errEncryptedPDF = documentError("The PDF is encrypted or password-protected. Save a copy without the password and upload that instead.")
+
+// extractResumeText returns the text of an uploaded resume, telling PDF,
+// DOCX, ODT and plain text apart by their content rather than the file name.
+func extractResumeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return extractPDFText(data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return extractZippedDocumentText(data)
+	case utf8.Valid(data) && strings.HasPrefix(http.DetectContentType(data), "text/plain"):
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", errUnsupportedDocument
+}
+
+// extractZippedDocumentText reads the DOCX or ODT document in a zip file.
+func extractZippedDocumentText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("read document: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var text string
+	switch {
+	case files["word/document.xml"] != nil:
+		text, err = readZippedXML(files["word/document.xml"], docxText)
+	case files["content.xml"] != nil && files["mimetype"] != nil && isODT(files["mimetype"]):
+		text, err = readZippedXML(files["content.xml"], odtText)
+	default:
+		return "", errUnsupportedDocument
+	}
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", documentError("The document has no text.")
+	}
+	return text, nil
+}
+
+// isODT reports whether an OpenDocument mimetype file names a text document
+// rather than, say, a spreadsheet.
+func isODT(f *zip.File) bool {
+	rc, err := f.Open()
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	mt, err := io.ReadAll(io.LimitReader(rc, 256))
+	return err == nil && strings.TrimSpace(string(mt)) == odtMimetype
+}
+
+func readZippedXML(f *zip.File, extract func(*xml.Decoder) (string, error)) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("read document: %w", err)
+	}
+	defer rc.Close()
+	text, err := extract(xml.NewDecoder(io.LimitReader(rc, maxDocumentXMLSize)))
+	if err != nil {
+		return "", fmt.Errorf("read document: %w", err)
+	}
+	return text, nil
+}
+
+// paragraphWriter collects a document's text a paragraph per line, starting
+// list items with "- " so bullets survive for the resume parsers.
+type paragraphWriter struct {
+	out    strings.Builder
+	line   strings.Builder
+	bullet bool
+}
+
+func (p *paragraphWriter) endParagraph() {
+	if l := strings.TrimSpace(p.line.String()); l != "" {
+		if p.bullet {
+			l = "- " + l
+		}
+		p.out.WriteString(l + "\n")
+	}
+	p.line.Reset()
+	p.bullet = false
+}
+
+func (p *paragraphWriter) String() string {
+	return strings.TrimSpace(p.out.String())
+}
+
+// docxText reads a DOCX main document. Paragraphs with numbering properties
+// are list items, which is how Word stores bullets.
+func docxText(d *xml.Decoder) (string, error) {
+	var p paragraphWriter
+	inText := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				p.line.WriteByte('\t')
+			case "br", "cr":
+				p.line.WriteByte(' ')
+			case "numPr":
+				p.bullet = true
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				p.endParagraph()
+			}
+		case xml.CharData:
+			if inText {
+				p.line.Write(t)
+			}
+		}
+	}
+	p.endParagraph()
+	return p.String(), nil
+}
+
+// odtText reads an ODT content.xml. Paragraphs and headings inside list items
+// are bullets; <text:s> is a run of spaces.
+func odtText(d *xml.Decoder) (string, error) {
+	var p paragraphWriter
+	depth, listDepth := 0, 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odtTextNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				depth++
+				p.bullet = listDepth > 0
+			case "list-item":
+				listDepth++
+			case "s":
+				p.line.WriteByte(' ')
+			case "tab":
+				p.line.WriteByte('\t')
+			case "line-break":
+				p.line.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if t.Name.Space != odtTextNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				depth--
+				if depth == 0 {
+					p.endParagraph()
+				}
+			case "list-item":
+				listDepth--
+			}
+		case xml.CharData:
+			if depth > 0 {
+				p.line.Write(t)
+			}
+		}
+	}
+	p.endParagraph()
+	return p.String(), nil
+}
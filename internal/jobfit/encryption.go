@@ -0,0 +1,233 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Encrypted values are stored as sealedPrefix, the key ID, a colon, then the
+// nonce and ciphertext. Anything else is a plaintext value written before
+// encryption was turned on.
+const sealedPrefix = "enc1:"
+
+var errUnknownEncryptionKey = errors.New("value is encrypted with a key that isn't configured")
+
+// A keyring encrypts stored resumes and analyses with AES-256-GCM. New values
+// are sealed with the first key; the others are kept so values sealed before a
+// rotation can still be read until they're re-encrypted or expire.
+//
+// A nil keyring stores values as they are.
+type keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// loadKeyring reads ENCRYPTION_KEYS, a comma-separated list of id:key pairs
+// where each key is 32 bytes of base64. Encryption is off when it's unset.
+func loadKeyring() (*keyring, error) {
+	v := os.Getenv("ENCRYPTION_KEYS")
+	if v == "" {
+		return nil, nil
+	}
+	k := &keyring{keys: map[string]cipher.AEAD{}}
+	for _, pair := range strings.Split(v, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS entries must look like id:base64key, got %q", pair)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("ENCRYPTION_KEYS has key %q twice", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes of base64", id)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	return k, nil
+}
+
+// seal encrypts a value to be stored under the Redis key name. The name,
+// without its namespace, is bound in as additional data, so a value copied to
+// another key won't open but one moved into the namespace still does.
+func (k *keyring) seal(name string, plaintext []byte) []byte {
+	if k == nil {
+		return plaintext
+	}
+	aead := k.keys[k.current]
+	out := make([]byte, 0, len(sealedPrefix)+len(k.current)+1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	out = append(out, sealedPrefix+k.current+":"...)
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(unnamespaced(name)))
+}
+
+// open reverses seal. Plaintext values pass through, so records written before
+// encryption was enabled stay readable.
+func (k *keyring) open(name string, data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(data, []byte(sealedPrefix))
+	if !ok {
+		return data, nil
+	}
+	id, sealed, ok := bytes.Cut(rest, []byte(":"))
+	if !ok {
+		return nil, errors.New("malformed encrypted value")
+	}
+	if k == nil {
+		return nil, errUnknownEncryptionKey
+	}
+	aead, ok := k.keys[string(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownEncryptionKey, id)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(unnamespaced(name)))
+}
+
+// isCurrent reports whether data is already stored the way seal would store
+// it now.
+func (k *keyring) isCurrent(data []byte) bool {
+	if k == nil {
+		return !bytes.HasPrefix(data, []byte(sealedPrefix))
+	}
+	return bytes.HasPrefix(data, []byte(sealedPrefix+k.current+":"))
+}
+
+// marshalSealed encodes a value to be stored under the Redis key name,
+// encrypting it when a keyring is configured. unmarshalSealed reads it back.
+func (app *application) marshalSealed(name string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return app.keys.seal(name, data), nil
+}
+
+func (app *application) unmarshalSealed(name string, data []byte, v any) error {
+	data, err := app.keys.open(name, data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// sealedKeyPatterns are the keys whose values go through the keyring.
+var sealedKeyPatterns = []string{analysisKey("*"), resumeKey("*"), notificationPrefsKey("*"), confirmationKey("*", "*"), webhookDeliveryKey("*")}
+
+// reencryptLockTTL bounds how long a re-encryption run that died keeps others
+// from starting.
+const reencryptLockTTL = 30 * time.Minute
+
+// adminReencryptHandler rewrites every sealed value with the current key, for
+// use after a rotation. Values already using it are left alone, and expiry
+// times are kept. Once it has run, retired keys can be removed from
+// ENCRYPTION_KEYS.
+func (app *application) adminReencryptHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	// Rewrites are safe to repeat, but two runs at once would only double the
+	// load for nothing.
+	l, err := app.acquireLock(ctx, "reencrypt", reencryptLockTTL)
+	if errors.Is(err, errLockHeld) {
+		http.Error(w, "Re-encryption is already running", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to take re-encryption lock", "error", err)
+		http.Error(w, "Could not re-encrypt stored data", http.StatusInternalServerError)
+		return
+	}
+	defer l.release(context.WithoutCancel(ctx))
+
+	var result struct {
+		Scanned   int `json:"scanned"`
+		Rewritten int `json:"rewritten"`
+		Failed    int `json:"failed"`
+	}
+	for _, pattern := range sealedKeyPatterns {
+		iter := app.rdb.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			name := iter.Val()
+			result.Scanned++
+			rewritten, err := app.reencrypt(ctx, name)
+			if err != nil {
+				app.logger.Error("failed to re-encrypt value", "key", name, "error", err)
+				result.Failed++
+			} else if rewritten {
+				result.Rewritten++
+			}
+		}
+		if err := iter.Err(); err != nil {
+			app.logger.Error("failed to scan for re-encryption", "pattern", pattern, "error", err)
+			http.Error(w, "Could not re-encrypt stored data", http.StatusInternalServerError)
+			return
+		}
+	}
+	app.logger.Info("re-encrypted stored data", "scanned", result.Scanned, "rewritten", result.Rewritten, "failed", result.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// reencrypt reseals one stored value with the current key. The write is
+// dropped and retried if the value changes underneath it.
+func (app *application) reencrypt(ctx context.Context, name string) (bool, error) {
+	var rewritten bool
+	txf := func(tx *redis.Tx) error {
+		rewritten = false
+		data, err := tx.Get(ctx, name).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil // Expired since the scan.
+		}
+		if err != nil {
+			return err
+		}
+		if app.keys.isCurrent(data) {
+			return nil
+		}
+		plaintext, err := app.keys.open(name, data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, name, app.keys.seal(name, plaintext), redis.KeepTTL)
+			return nil
+		})
+		rewritten = err == nil
+		return err
+	}
+	for range 3 {
+		err := app.rdb.Watch(ctx, txf, name)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return rewritten, err
+		}
+	}
+	return false, redis.TxFailedErr
+}
@@ -0,0 +1,109 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// An evalCase is a resume and job whose match score a person has judged. A
+// set of them shows whether a prompt or model change moves scores away from
+// what a recruiter would say.
+type evalCase struct {
+	Name           string `json:"name"`
+	Resume         string `json:"resume"`
+	JobDescription string `json:"jobDescription"`
+	ExpectedScore  int    `json:"expectedScore"`
+}
+
+func loadEvalCases(file string) ([]evalCase, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cases []evalCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for i, c := range cases {
+		if c.Resume == "" || c.JobDescription == "" {
+			return nil, fmt.Errorf("%s: case %d needs a resume and a jobDescription", file, i+1)
+		}
+		if c.ExpectedScore < 0 || c.ExpectedScore > 100 {
+			return nil, fmt.Errorf("%s: case %d expectedScore must be between 0 and 100", file, i+1)
+		}
+	}
+	return cases, nil
+}
+
+// evalScore scores a case with the same prompt as a real analysis. Nothing is
+// saved or counted in the stats.
+func (app *application) evalScore(ctx context.Context, log *slog.Logger, c evalCase) (int, error) {
+	req := &AnalysisRequest{Resume: c.Resume, JobDescription: c.JobDescription}
+	timeline := buildTimeline(parseResumeRules(c.Resume), time.Now())
+	var resp AnalysisResponse
+	if err := app.generateJSON(withResponseSchema(ctx, analysisSchema(req)), log, buildAnalysisPrompt(req, req.JobDescription, timeline.promptNotes()), &resp); err != nil {
+		return 0, err
+	}
+	return min(max(resp.MatchScore, 0), 100), nil
+}
+
+// RunEval scores each case in file (a JSON array of evalCase) and prints how
+// far the model is from the expected scores. It exits non-zero if any case
+// is off by more than tolerance or couldn't be scored.
+func RunEval(file string, tolerance int) {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})).With("process", "eval")
+	cases, err := loadEvalCases(file)
+	if err != nil {
+		logger.Error("failed to load eval cases", "error", err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	app := newApplication(ctx, logger)
+	defer app.models.close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASE\tEXPECTED\tSCORE\tDIFF\t")
+	var totalDiff, scored, outside int
+	for i, c := range cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		// Cases run one at a time so a large set doesn't trip rate limits.
+		score, err := app.evalScore(ctx, logger.With("case", name), c)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t%d\terror: %v\t\t\n", name, c.ExpectedScore, err)
+			outside++
+			continue
+		}
+		diff := score - c.ExpectedScore
+		mark := ""
+		if abs(diff) > tolerance {
+			mark = " !"
+			outside++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d%s\t\n", name, c.ExpectedScore, score, diff, mark)
+		totalDiff += abs(diff)
+		scored++
+	}
+	tw.Flush()
+
+	if scored > 0 {
+		fmt.Printf("\nmean absolute error %.1f over %d cases; %d outside ±%d\n", float64(totalDiff)/float64(scored), scored, outside, tolerance)
+	}
+	if outside > 0 {
+		os.Exit(1)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lifecycle events are appended to the Redis stream arm:{env}:events so
+// other systems (analytics, CRM sync) can follow along without polling the
+// API. Read it with XREAD, or XREADGROUP to share the work between consumers.
+// The stream is trimmed to roughly eventStreamMaxLen entries.
+//
+// Every entry has four fields:
+//
+//	type  the event type, below
+//	id    a ULID, unique per event
+//	time  when it happened, RFC 3339 in UTC
+//	data  a JSON object whose shape depends on the type
+//
+// analysis.requested, analysis.completed and analysis.failed share these data
+// keys:
+//
+//	analysisId  the analysis ULID
+//	source      "web", "widget" or "api"
+//	clientId    the anonymous client, widget key or integration that asked
+//
+// analysis.completed adds matchScore, jobTitle, company, roleFamily and
+// durationMs. analysis.failed adds reason, one of blocked, empty_response,
+// invalid_response, timeout or model_error. quota.exceeded has action, scope
+// ("client" or "ip"), limit and, when known, clientId. With GeoIP set up,
+// quota.exceeded and the events of web analyses also carry region, the
+// requester's country code. IP addresses and resume text are never
+// published.
+const (
+	eventStreamMaxLen = 100000
+
+	eventAnalysisRequested = "analysis.requested"
+	eventAnalysisCompleted = "analysis.completed"
+	eventAnalysisFailed    = "analysis.failed"
+	eventQuotaExceeded     = "quota.exceeded"
+)
+
+func eventStreamKey() string { return rkey("events") }
+
+const (
+	sourceWeb    = "web"
+	sourceWidget = "widget"
+	sourceAPI    = "api"
+)
+
+type analysisEvent struct {
+	AnalysisID string `json:"analysisId"`
+	Source     string `json:"source"`
+	ClientID   string `json:"clientId,omitempty"`
+	MatchScore *int   `json:"matchScore,omitempty"`
+	JobTitle   string `json:"jobTitle,omitempty"`
+	Company    string `json:"company,omitempty"`
+	RoleFamily string `json:"roleFamily,omitempty"`
+	DurationMS int64  `json:"durationMs,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Region     string `json:"region,omitempty"`
+}
+
+type quotaEvent struct {
+	Action   string `json:"action"`
+	Scope    string `json:"scope"`
+	Limit    int64  `json:"limit"`
+	ClientID string `json:"clientId,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// completedEvent describes a finished analysis that started at start.
+func completedEvent(source, clientID string, resp *AnalysisResponse, start time.Time) analysisEvent {
+	return analysisEvent{
+		AnalysisID: resp.ID,
+		Source:     source,
+		ClientID:   clientID,
+		MatchScore: &resp.MatchScore,
+		JobTitle:   resp.JobTitle,
+		Company:    resp.Company,
+		RoleFamily: roleFamily(resp.JobTitle),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+}
+
+// publishEvent appends an event to the stream. Publishing is best effort: a
+// failure is logged and never fails the request that caused it.
+func (app *application) publishEvent(ctx context.Context, typ string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		app.logger.Error("failed to encode event", "type", typ, "error", err)
+		return
+	}
+	now := time.Now().UTC()
+	err = app.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: eventStreamKey(),
+		MaxLen: eventStreamMaxLen,
+		Approx: true,
+		Values: []any{"type", typ, "id", newULID(now), "time", now.Format(time.RFC3339Nano), "data", payload},
+	}).Err()
+	if err != nil {
+		app.logger.Error("failed to publish event", "type", typ, "error", err)
+	}
+}
+
+// quotaScope names the kind of bucket that ran out without exposing its key,
+// which may be an IP address.
+func quotaScope(b *quotaBucket) string {
+	if strings.HasPrefix(b.key, "client:") {
+		return "client"
+	}
+	return "ip"
+}
+
+// failureReason classifies a generateJSON error for analysis.failed.
+func failureReason(err error) string {
+	var jsonErr *modelJSONError
+	switch {
+	case errors.Is(err, errModelBlocked):
+		return "blocked"
+	case errors.Is(err, errModelEmpty):
+		return "empty_response"
+	case errors.As(err, &jsonErr):
+		return "invalid_response"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "model_error"
+	}
+}
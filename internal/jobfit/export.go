@@ -0,0 +1,83 @@
+package jobfit
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many improvements make it into the "top gaps" column.
+const exportTopGaps = 3
+
+// plainBullet strips the leading dash and **bold** markers the model puts on
+// each bullet point.
+func plainBullet(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, "-•* ")
+	return strings.ReplaceAll(s, "**", "")
+}
+
+// csvSafe neutralizes cells that spreadsheet applications would otherwise
+// evaluate as formulas.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportHistoryHandler streams the caller's history as a CSV file. It accepts
+// the same filters and sort order as GET /history but ignores pagination.
+func (app *application) exportHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	hq, problem := parseHistoryQuery(r)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	var recs []*analysisRecord
+	if owner, ok := app.existingClientID(r); ok {
+		var err error
+		recs, err = app.loadHistory(r.Context(), owner, hq.from, hq.to)
+		if err != nil {
+			app.logger.Error("failed to load history", "error", err)
+			http.Error(w, "Could not export history", http.StatusInternalServerError)
+			return
+		}
+	}
+	matched := make([]*analysisRecord, 0, len(recs))
+	for _, rec := range recs {
+		if hq.matches(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	hq.cursor, hq.limit = nil, len(matched)
+	matched = hq.paginate(matched).Items
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="jobfit-history.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "date", "score", "job_title", "company", "tags", "top_gaps"})
+	for _, rec := range matched {
+		gaps := make([]string, 0, exportTopGaps)
+		for _, imp := range rec.Improvements[:min(exportTopGaps, len(rec.Improvements))] {
+			gaps = append(gaps, plainBullet(imp))
+		}
+		cw.Write([]string{
+			rec.ID,
+			rec.CreatedAt.Format(time.DateOnly),
+			strconv.Itoa(rec.MatchScore),
+			csvSafe(rec.JobTitle),
+			csvSafe(rec.Company),
+			csvSafe(strings.Join(rec.Tags, "; ")),
+			csvSafe(strings.Join(gaps, " | ")),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.logger.Error("failed to write history export", "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Clients that only need part of an analysis, such as the browser extension's
+// quick match, name the fields they want in "fields", either in the request
+// body or as a comma-separated query parameter. Fields nobody asked for are
+// left out of the response, and the costlier ones aren't generated at all.
+
+// analysisFields are the names a request can select: the JSON names of
+// AnalysisResponse. The ID is always returned.
+var analysisFields = func() []string {
+	t := reflect.TypeFor[AnalysisResponse]()
+	names := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+// readFields adds the fields named in r's query string to those in the body.
+func (req *AnalysisRequest) readFields(r *http.Request) {
+	for _, f := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" && !slices.Contains(req.Fields, f) {
+			req.Fields = append(req.Fields, f)
+		}
+	}
+}
+
+// checkFields returns the first field the request names that doesn't exist.
+func (req *AnalysisRequest) checkFields() (string, bool) {
+	for _, f := range req.Fields {
+		if !slices.Contains(analysisFields, f) {
+			return f, false
+		}
+	}
+	return "", true
+}
+
+// wants reports whether the response should include field. Requests that
+// don't select fields get all of them.
+func (req *AnalysisRequest) wants(field string) bool {
+	return len(req.Fields) == 0 || slices.Contains(req.Fields, field)
+}
+
+// selectFields returns resp trimmed to the ID and fields, or resp itself when
+// no fields are selected.
+func selectFields(resp *AnalysisResponse, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return resp, nil
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := map[string]json.RawMessage{"id": all["id"]}
+	for _, f := range fields {
+		if v, ok := all[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,247 @@
+package jobfit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"aichatbot/web"
+)
+
+// The web app's page is rendered from web/templates/index.html rather than
+// served as a static file, so its strings can be translated, the API address
+// and feature flags can be set per deployment, and a site served under
+// another host name can carry its own name, logo and colour. The script and
+// stylesheet are still served from web/static/.
+const (
+	frontendTemplate = "templates/index.html"
+	localesDir       = "locales"
+	defaultLocale    = "en"
+	localeCookie     = "lang"
+)
+
+// staticFiles are served as they are, at the root of the site.
+var staticFiles, _ = fs.Sub(web.Files, "static")
+
+// frontendFeatures are the flags FEATURE_FLAGS may turn on in the web app.
+var frontendFeatures = []string{
+	// quickAnalysis shows a quick score first and the report once it's
+	// ready, using /analyses/quick. It needs a worker running.
+	"quickAnalysis",
+}
+
+var (
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+	colorPattern  = regexp.MustCompile(`^#[0-9a-fA-F]{3}([0-9a-fA-F]{3})?$`)
+)
+
+// A brand is how the web app presents itself on one host.
+type brand struct {
+	Name        string `json:"name"`
+	LogoURL     string `json:"logoUrl,omitempty"`
+	AccentColor string `json:"accentColor,omitempty"`
+	// Locale is the language used when the visitor hasn't chosen one and
+	// their browser asks for none we have.
+	Locale string `json:"locale,omitempty"`
+}
+
+var defaultBrand = brand{Name: "JobFit.ai"}
+
+// frontendConfig is handed to script.js.
+type frontendConfig struct {
+	APIBase  string            `json:"apiBase"`
+	Features map[string]bool   `json:"features"`
+	Messages map[string]string `json:"messages"`
+	// Terms is set when visitors must accept the terms before analyzing.
+	Terms *frontendTerms `json:"terms,omitempty"`
+}
+
+type frontendTerms struct {
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"`
+}
+
+type frontend struct {
+	tmpl     *template.Template
+	locales  map[string]map[string]string
+	brands   map[string]brand
+	apiBase  string
+	features map[string]bool
+}
+
+type indexPage struct {
+	Locale  string
+	Locales []string
+	Brand   brand
+	T       map[string]string
+	Config  frontendConfig
+}
+
+// loadFrontend reads the page template, the translations in locales/ and the
+// API_BASE_URL, FEATURE_FLAGS and BRANDING_FILE settings.
+func loadFrontend() (*frontend, error) {
+	tmpl, err := template.ParseFS(web.Files, frontendTemplate)
+	if err != nil {
+		return nil, err
+	}
+	f := &frontend{
+		tmpl:     tmpl,
+		locales:  map[string]map[string]string{},
+		brands:   map[string]brand{},
+		apiBase:  strings.TrimSuffix(os.Getenv("API_BASE_URL"), "/"),
+		features: map[string]bool{},
+	}
+
+	files, err := fs.Glob(web.Files, path.Join(localesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		locale := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		if !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("%s is not named after a locale such as en or pt-br", file)
+		}
+		data, err := fs.ReadFile(web.Files, file)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		f.locales[locale] = messages
+	}
+	base, ok := f.locales[defaultLocale]
+	if !ok {
+		return nil, fmt.Errorf("%s/%s.json is missing", localesDir, defaultLocale)
+	}
+	// Strings a translation lacks are shown in English.
+	for locale, messages := range f.locales {
+		merged := maps.Clone(base)
+		maps.Copy(merged, messages)
+		f.locales[locale] = merged
+	}
+
+	for _, flag := range strings.Split(os.Getenv("FEATURE_FLAGS"), ",") {
+		if flag = strings.TrimSpace(flag); flag == "" {
+			continue
+		}
+		if !slices.Contains(frontendFeatures, flag) {
+			return nil, fmt.Errorf("FEATURE_FLAGS names unknown feature %q", flag)
+		}
+		f.features[flag] = true
+	}
+
+	if file := os.Getenv("BRANDING_FILE"); file != "" {
+		if f.brands, err = f.loadBrands(file); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// loadBrands reads a JSON object mapping host names to their brand.
+func (f *frontend) loadBrands(file string) (map[string]brand, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var brands map[string]brand
+	if err := json.Unmarshal(data, &brands); err != nil {
+		return nil, fmt.Errorf("BRANDING_FILE: %w", err)
+	}
+	lowered := make(map[string]brand, len(brands))
+	for host, b := range brands {
+		if b.Name == "" {
+			return nil, fmt.Errorf("BRANDING_FILE: %s has no name", host)
+		}
+		if b.AccentColor != "" && !colorPattern.MatchString(b.AccentColor) {
+			return nil, fmt.Errorf("BRANDING_FILE: %s accentColor must look like #38bdf8", host)
+		}
+		if b.Locale = strings.ToLower(b.Locale); b.Locale != "" && f.locales[b.Locale] == nil {
+			return nil, fmt.Errorf("BRANDING_FILE: %s uses locale %q, which has no translation", host, b.Locale)
+		}
+		lowered[strings.ToLower(host)] = b
+	}
+	return lowered, nil
+}
+
+// brandFor returns the brand for the host r was sent to.
+func (f *frontend) brandFor(r *http.Request) brand {
+	host := strings.ToLower(r.Host)
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	if b, ok := f.brands[host]; ok {
+		return b
+	}
+	return defaultBrand
+}
+
+// locale picks the page's language: ?lang= if given (and remembered in a
+// cookie), then the cookie, then the browser's preferences, then the brand's.
+func (f *frontend) locale(w http.ResponseWriter, r *http.Request, b brand) string {
+	if l := strings.ToLower(r.URL.Query().Get("lang")); f.locales[l] != nil {
+		http.SetCookie(w, &http.Cookie{
+			Name: localeCookie, Value: l, Path: "/", MaxAge: int((365 * 24 * time.Hour).Seconds()),
+			SameSite: http.SameSiteLaxMode,
+		})
+		return l
+	}
+	if c, err := r.Cookie(localeCookie); err == nil && f.locales[c.Value] != nil {
+		return c.Value
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(tag)
+		if f.locales[tag] != nil {
+			return tag
+		}
+		if primary, _, _ := strings.Cut(tag, "-"); f.locales[primary] != nil {
+			return primary
+		}
+	}
+	if b.Locale != "" {
+		return b.Locale
+	}
+	return defaultLocale
+}
+
+// indexHandler renders the web app's page.
+func (app *application) indexHandler(w http.ResponseWriter, r *http.Request) {
+	f := app.frontend
+	b := f.brandFor(r)
+	locale := f.locale(w, r, b)
+	page := indexPage{
+		Locale:  locale,
+		Locales: slices.Sorted(maps.Keys(f.locales)),
+		Brand:   b,
+		T:       f.locales[locale],
+		Config:  frontendConfig{APIBase: f.apiBase, Features: f.features, Messages: f.locales[locale]},
+	}
+	if app.terms.version != "" {
+		page.Config.Terms = &frontendTerms{Version: app.terms.version, URL: app.terms.url}
+	}
+
+	var buf bytes.Buffer
+	if err := f.tmpl.Execute(&buf, page); err != nil {
+		app.logger.Error("failed to render page", "locale", locale, "error", err)
+		http.Error(w, "Could not load page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", locale)
+	w.Header().Set("Vary", "Accept-Language, Cookie")
+	w.Header().Set("Cache-Control", "no-cache")
+	buf.WriteTo(w)
+}
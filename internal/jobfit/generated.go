@@ -0,0 +1,86 @@
+package jobfit
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Phrases that language models reach for far more often than people do.
+var generatedPhrases = []string{
+	"spearheaded", "leveraged", "leveraging", "delve", "results-driven", "proven track record",
+	"seamlessly", "cutting-edge", "fostering", "fast-paced environment", "passionate about",
+	"dynamic", "synergy", "synergies", "meticulous", "robust", "pivotal", "orchestrated",
+	"adept at", "in today's", "a testament to", "navigating", "holistic", "streamlined",
+}
+
+var sentenceEndRegex = regexp.MustCompile(`[.!?]+\s+|\n+`)
+
+// generatedTextSignal estimates how heavily a resume appears machine-written.
+// It is a probabilistic hint from writing-style heuristics, not proof: people
+// write like this too, and edited model output won't show it.
+type generatedTextSignal struct {
+	// Likelihood is 0–100; higher means more of the telltale patterns.
+	Likelihood int      `json:"likelihood"`
+	Indicators []string `json:"indicators"`
+	Disclaimer string   `json:"disclaimer"`
+}
+
+const generatedTextDisclaimer = "Probabilistic estimate from writing-style heuristics. It cannot prove how a resume was written and should not be the basis of a hiring decision on its own."
+
+// detectGeneratedText combines three signals: stock phrases, heavy em-dash
+// use, and unusually uniform sentence lengths (human writing is "burstier").
+func detectGeneratedText(resume string) *generatedTextSignal {
+	sig := &generatedTextSignal{Indicators: []string{}, Disclaimer: generatedTextDisclaimer}
+	lower := strings.ToLower(resume)
+	words := len(searchTerms(resume))
+	if words == 0 {
+		return sig
+	}
+
+	var found []string
+	hits := 0
+	for _, p := range generatedPhrases {
+		if n := strings.Count(lower, p); n > 0 {
+			found = append(found, p)
+			hits += n
+		}
+	}
+	// Per 100 words; two or more stock phrases per 100 words is a lot.
+	phraseScore := min(float64(hits)*100/float64(words)/2, 1)
+	if len(found) > 0 {
+		sig.Indicators = append(sig.Indicators, "stock phrases: "+strings.Join(found, ", "))
+	}
+
+	dashScore := min(float64(strings.Count(resume, "—"))*100/float64(words), 1)
+	if dashScore >= 0.5 {
+		sig.Indicators = append(sig.Indicators, "frequent em dashes")
+	}
+
+	var lengths []float64
+	for _, s := range sentenceEndRegex.Split(resume, -1) {
+		if n := len(strings.Fields(s)); n >= 4 {
+			lengths = append(lengths, float64(n))
+		}
+	}
+	uniformScore := 0.0
+	if len(lengths) >= 5 {
+		var mean, variance float64
+		for _, l := range lengths {
+			mean += l
+		}
+		mean /= float64(len(lengths))
+		for _, l := range lengths {
+			variance += (l - mean) * (l - mean)
+		}
+		variance /= float64(len(lengths))
+		// A coefficient of variation below about 0.5 is unusually even.
+		if cv := math.Sqrt(variance) / mean; cv < 0.5 {
+			uniformScore = (0.5 - cv) / 0.5
+			sig.Indicators = append(sig.Indicators, "uniform sentence lengths")
+		}
+	}
+
+	sig.Likelihood = int(math.Round(100 * (0.5*phraseScore + 0.2*dashScore + 0.3*uniformScore)))
+	return sig
+}
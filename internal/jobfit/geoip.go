@@ -0,0 +1,448 @@
+package jobfit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// With GEOIP_DB pointing at a MaxMind country or city database (such as the
+// free GeoLite2-Country.mmdb), each request's country is looked up from its
+// IP address. Countries that abuse the service can then be blocked outright
+// (GEO_BLOCKED_COUNTRIES) or given a smaller daily quota (GEO_DAILY_CREDITS),
+// and the country is logged and published with events as "region".
+//
+// The database is read with the small reader below, which implements as much
+// of the MaxMind DB format (https://maxmind.github.io/MaxMind-DB/) as a
+// country lookup needs.
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// geoPolicy is the GeoIP database and what to do about each country. A nil
+// *geoPolicy means GeoIP is off: every lookup finds nothing and nothing is
+// blocked.
+type geoPolicy struct {
+	db      *mmdbReader
+	blocked map[string]bool
+	credits map[string]int64
+}
+
+// loadGeoPolicy reads GEOIP_DB, GEO_BLOCKED_COUNTRIES and GEO_DAILY_CREDITS.
+// Countries are ISO 3166 codes such as "DE"; GEO_DAILY_CREDITS is a
+// comma-separated list of country=credits pairs, e.g. "XX=1,YY=2".
+func loadGeoPolicy() (*geoPolicy, error) {
+	path := os.Getenv("GEOIP_DB")
+	blocked := os.Getenv("GEO_BLOCKED_COUNTRIES")
+	credits := os.Getenv("GEO_DAILY_CREDITS")
+	if path == "" {
+		if blocked != "" || credits != "" {
+			return nil, errors.New("GEO_BLOCKED_COUNTRIES and GEO_DAILY_CREDITS need GEOIP_DB")
+		}
+		return nil, nil
+	}
+
+	db, err := openMMDB(path)
+	if err != nil {
+		return nil, err
+	}
+	g := &geoPolicy{db: db, blocked: map[string]bool{}, credits: map[string]int64{}}
+	for _, c := range strings.Split(blocked, ",") {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c == "" {
+			continue
+		}
+		if !countryCodePattern.MatchString(c) {
+			return nil, fmt.Errorf("GEO_BLOCKED_COUNTRIES entry %q must be a two-letter country code", c)
+		}
+		g.blocked[c] = true
+	}
+	for _, pair := range strings.Split(credits, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		c, v, ok := strings.Cut(pair, "=")
+		c = strings.ToUpper(strings.TrimSpace(c))
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if !ok || err != nil || n < 1 || !countryCodePattern.MatchString(c) {
+			return nil, fmt.Errorf("GEO_DAILY_CREDITS entry %q must look like DE=3", pair)
+		}
+		g.credits[c] = n
+	}
+	return g, nil
+}
+
+// country returns the ISO code of the country ip is in, or "" if it isn't
+// known.
+func (g *geoPolicy) country(ip string) string {
+	if g == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	record, err := g.db.lookup(addr)
+	if err != nil {
+		return ""
+	}
+	for _, field := range []string{"country", "registered_country"} {
+		if c, ok := mmdbPath(record, field, "iso_code").(string); ok {
+			return c
+		}
+	}
+	return ""
+}
+
+// dailyCredits returns the daily credits of clients in country, or def if
+// the country has no limit of its own.
+func (g *geoPolicy) dailyCredits(country string, def int64) int64 {
+	if g == nil {
+		return def
+	}
+	if n, ok := g.credits[country]; ok {
+		return n
+	}
+	return def
+}
+
+func (g *geoPolicy) blocks(country string) bool {
+	return g != nil && g.blocked[country]
+}
+
+// region is the country r came from, or "" if it isn't known. It's looked
+// up once, by withClientIP.
+func (app *application) region(r *http.Request) string {
+	if origin, ok := r.Context().Value(clientOriginKey{}).(clientOrigin); ok {
+		return origin.region
+	}
+	return app.geo.country(getIPAddress(r))
+}
+
+// allowRegion writes a 403 response and returns false if r comes from a
+// blocked country.
+func (app *application) allowRegion(w http.ResponseWriter, r *http.Request) bool {
+	country := app.region(r)
+	if !app.geo.blocks(country) {
+		return true
+	}
+	app.logger.Warn("request from blocked region", "ip", getIPAddress(r), "region", country, "path", r.URL.Path)
+	http.Error(w, "This service is not available in your region.", http.StatusForbidden)
+	return false
+}
+
+// mmdbPath follows keys through nested maps of a decoded record, returning
+// nil if any is missing.
+func mmdbPath(v any, keys ...string) any {
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+var (
+	mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+	errMMDBCorrupt     = errors.New("corrupt MaxMind DB data")
+)
+
+// mmdbMaxDepth bounds how deeply maps and arrays may nest, so a pointer
+// back into an enclosing map can't recurse forever.
+const mmdbMaxDepth = 32
+
+// MaxMind DB data field types.
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// mmdbReader looks addresses up in a MaxMind DB file held in memory. The
+// file is a binary search tree over address bits whose leaves point into a
+// data section of records.
+type mmdbReader struct {
+	tree       []byte
+	data       mmdbDecoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 lookups start at in an IPv6 tree, where
+	// IPv4 addresses live under ::/96.
+	ipv4Start uint
+}
+
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMMDBReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// newMMDBReader reads the MaxMind DB file in buf.
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	i := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file")
+	}
+	meta, _, err := mmdbDecoder(buf[i+len(mmdbMetadataMarker):]).decode(0)
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) uint {
+		n, _ := mmdbPath(meta, name).(uint64)
+		return uint(n)
+	}
+	r := &mmdbReader{nodeCount: field("node_count"), recordSize: field("record_size"), ipVersion: field("ip_version")}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+	// Each node holds two records, and the tree is followed by 16 zero bytes.
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, errMMDBCorrupt
+	}
+	r.tree = buf[:treeSize]
+	r.data = mmdbDecoder(buf[treeSize+16 : i])
+
+	if r.ipVersion == 6 {
+		for range 96 {
+			if r.ipv4Start >= r.nodeCount {
+				break
+			}
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *mmdbReader) record(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		// The middle byte holds the top four bits of both records.
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+// lookup returns the record for addr, or nil if the database has none.
+func (r *mmdbReader) lookup(addr netip.Addr) (any, error) {
+	addr = addr.Unmap()
+	node := uint(0)
+	var bits []byte
+	if addr.Is4() {
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+		b := addr.As4()
+		bits = b[:]
+	} else {
+		if r.ipVersion == 4 {
+			return nil, nil
+		}
+		b := addr.As16()
+		bits = b[:]
+	}
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, uint(bits[i/8]>>(7-i%8)&1))
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errMMDBCorrupt
+	}
+	// Data pointers count from the start of the tree's 16-byte separator.
+	v, _, err := r.data.decode(node - r.nodeCount - 16)
+	return v, err
+}
+
+// mmdbDecoder decodes fields of a data or metadata section. Maps decode to
+// map[string]any, arrays to []any, unsigned integers to uint64 (uint128 is
+// left as bytes), int32 to int64 and floats to float64.
+type mmdbDecoder []byte
+
+// decode decodes the field at offset, returning it and the offset after it.
+func (d mmdbDecoder) decode(offset uint) (any, uint, error) {
+	return d.decodeNested(offset, 0)
+}
+
+// decodeNested decodes a field depth maps and arrays down.
+func (d mmdbDecoder) decodeNested(offset uint, depth int) (any, uint, error) {
+	if offset >= uint(len(d)) || depth > mmdbMaxDepth {
+		return nil, 0, errMMDBCorrupt
+	}
+	ctrl := d[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if target >= uint(len(d)) || d[target]>>5 == mmdbPointer {
+			return nil, 0, errMMDBCorrupt
+		}
+		v, _, err := d.decodeNested(target, depth)
+		return v, next, err
+	}
+	if typ == mmdbExtended {
+		if offset >= uint(len(d)) {
+			return nil, 0, errMMDBCorrupt
+		}
+		typ = 7 + uint(d[offset])
+		offset++
+	}
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Every entry of a map or array takes at least a byte, so a size beyond
+	// the section's end is corrupt, not just a big allocation.
+	if (typ == mmdbMap || typ == mmdbArray) && size > uint(len(d))-offset {
+		return nil, 0, errMMDBCorrupt
+	}
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]any, size)
+		for range size {
+			var k, v any
+			if k, offset, err = d.decodeNested(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errMMDBCorrupt
+			}
+			if v, offset, err = d.decodeNested(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]any, 0, size)
+		for range size {
+			var v any
+			if v, offset, err = d.decodeNested(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d)) {
+		return nil, 0, errMMDBCorrupt
+	}
+	b := d[offset : offset+size]
+	offset += size
+	switch typ {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbBytes, mmdbUint128:
+		return []byte(b), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errMMDBCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errMMDBCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		if size > 8 {
+			return nil, 0, errMMDBCorrupt
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if typ == mmdbInt32 {
+			return int64(int32(n)), offset, nil
+		}
+		return n, offset, nil
+	}
+	return nil, 0, errMMDBCorrupt
+}
+
+// size reads a field's length, which may continue into the bytes after the
+// control byte.
+func (d mmdbDecoder) size(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+	n := size - 28
+	if offset+n > uint(len(d)) {
+		return 0, 0, errMMDBCorrupt
+	}
+	var v uint
+	for _, c := range d[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	v += [...]uint{29, 285, 65821}[n-1]
+	return v, offset + n, nil
+}
+
+// pointer reads a pointer field's target, an offset into the section.
+func (d mmdbDecoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 3
+	n := ss + 1
+	if offset+n > uint(len(d)) {
+		return 0, 0, errMMDBCorrupt
+	}
+	var p uint
+	if ss < 3 {
+		p = uint(ctrl & 7)
+	}
+	for _, c := range d[offset : offset+n] {
+		p = p<<8 | uint(c)
+	}
+	p += [...]uint{0, 2048, 526336, 0}[ss]
+	return p, offset + n, nil
+}
@@ -0,0 +1,309 @@
+package jobfit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mmdbField encodes one MaxMind DB field of type typ with payload, which for
+// maps and arrays is the already-encoded entries and for bools is empty.
+func mmdbField(typ int, size int, payload []byte) []byte {
+	var out []byte
+	ctrlType := typ
+	if typ > 7 {
+		ctrlType = mmdbExtended
+	}
+	ctrl := byte(ctrlType << 5)
+	var ext []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		ext = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		ext = binary.BigEndian.AppendUint16(nil, uint16(size-285))
+	default:
+		ctrl |= 31
+		n := size - 65821
+		ext = []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	out = append(out, ctrl)
+	if typ > 7 {
+		out = append(out, byte(typ-7))
+	}
+	out = append(out, ext...)
+	return append(out, payload...)
+}
+
+func mmdbText(s string) []byte { return mmdbField(mmdbString, len(s), []byte(s)) }
+
+func mmdbUint(typ int, n uint64, width int) []byte {
+	b := binary.BigEndian.AppendUint64(nil, n)
+	return mmdbField(typ, width, b[8-width:])
+}
+
+// mmdbMapOf encodes a map from alternating keys and encoded values.
+func mmdbMapOf(kv ...any) []byte {
+	var payload []byte
+	for i := 0; i < len(kv); i += 2 {
+		payload = append(payload, mmdbText(kv[i].(string))...)
+		payload = append(payload, kv[i+1].([]byte)...)
+	}
+	return mmdbField(mmdbMap, len(kv)/2, payload)
+}
+
+// mmdbPointerTo encodes a pointer to offset in the data section.
+func mmdbPointerTo(offset uint) []byte {
+	switch {
+	case offset < 2048:
+		return []byte{byte(mmdbPointer<<5 | offset>>8), byte(offset)}
+	case offset < 526336:
+		p := offset - 2048
+		return []byte{byte(mmdbPointer<<5 | 1<<3 | p>>16), byte(p >> 8), byte(p)}
+	default:
+		p := offset - 526336
+		return []byte{byte(mmdbPointer<<5 | 2<<3 | p>>24), byte(p >> 16), byte(p >> 8), byte(p)}
+	}
+}
+
+// mmdbFixture builds a database whose tree maps each prefix to the record at
+// the given data section offset.
+type mmdbFixture struct {
+	ipVersion  int
+	recordSize int
+	prefixes   map[string]uint
+	data       []byte
+}
+
+func (f mmdbFixture) build(t *testing.T) []byte {
+	t.Helper()
+	type rec struct {
+		node int // -1 if the record isn't a node
+		data int // -1 if it isn't data either
+	}
+	nodes := [][2]rec{{{-1, -1}, {-1, -1}}}
+	for p, offset := range f.prefixes {
+		prefix := netip.MustParsePrefix(p)
+		bits := prefix.Addr().AsSlice()
+		if f.ipVersion == 6 && prefix.Addr().Is4() {
+			a := prefix.Addr().As4()
+			bits = append(make([]byte, 12), a[:]...)
+			prefix = netip.PrefixFrom(netip.AddrFrom16([16]byte(bits)), prefix.Bits()+96)
+		}
+		node := 0
+		for i := range prefix.Bits() {
+			bit := bits[i/8] >> (7 - i%8) & 1
+			if i == prefix.Bits()-1 {
+				nodes[node][bit] = rec{-1, int(offset)}
+				break
+			}
+			if nodes[node][bit].node < 0 {
+				nodes = append(nodes, [2]rec{{-1, -1}, {-1, -1}})
+				nodes[node][bit] = rec{len(nodes) - 1, -1}
+			}
+			node = nodes[node][bit].node
+		}
+	}
+
+	count := uint(len(nodes))
+	value := func(r rec) uint {
+		switch {
+		case r.node >= 0:
+			return uint(r.node)
+		case r.data >= 0:
+			return count + 16 + uint(r.data)
+		}
+		return count
+	}
+	var buf []byte
+	for _, n := range nodes {
+		l, r := value(n[0]), value(n[1])
+		switch f.recordSize {
+		case 24:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(l>>24)<<4|byte(r>>24)&0x0f, byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			buf = binary.BigEndian.AppendUint32(buf, uint32(l))
+			buf = binary.BigEndian.AppendUint32(buf, uint32(r))
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, f.data...)
+	buf = append(buf, mmdbMetadataMarker...)
+	buf = append(buf, mmdbMapOf(
+		"node_count", mmdbUint(mmdbUint32, uint64(count), 4),
+		"record_size", mmdbUint(mmdbUint16, uint64(f.recordSize), 2),
+		"ip_version", mmdbUint(mmdbUint16, uint64(f.ipVersion), 2),
+		"database_type", mmdbText("Test-Country"),
+	)...)
+	return buf
+}
+
+// countryData is a data section with German and French records, the French
+// one reached through pointers: a short one to the shared country map and,
+// past 2 KiB of padding, a long one.
+func countryData() (data []byte, de, fr uint) {
+	data = mmdbMapOf("country", mmdbMapOf("iso_code", mmdbText("DE")))
+	shared := uint(len(data))
+	data = append(data, mmdbMapOf("iso_code", mmdbText("FR"))...)
+	data = append(data, mmdbText(strings.Repeat("x", 3000))...)
+	far := uint(len(data))
+	data = append(data, mmdbMapOf("names", mmdbText("France"), "iso", mmdbPointerTo(shared))...)
+	fr = uint(len(data))
+	data = append(data, mmdbMapOf("registered_country", mmdbPointerTo(shared), "extra", mmdbPointerTo(far))...)
+	return data, 0, fr
+}
+
+func TestMMDBLookup(t *testing.T) {
+	data, de, fr := countryData()
+	for _, tt := range []struct {
+		ipVersion, recordSize int
+	}{{4, 24}, {4, 28}, {4, 32}, {6, 24}, {6, 28}, {6, 32}} {
+		prefixes := map[string]uint{"1.0.0.0/8": de, "2.2.0.0/16": fr}
+		if tt.ipVersion == 6 {
+			prefixes["2001:db8::/32"] = fr
+		}
+		buf := mmdbFixture{ipVersion: tt.ipVersion, recordSize: tt.recordSize, prefixes: prefixes, data: data}.build(t)
+		db, err := newMMDBReader(buf)
+		if err != nil {
+			t.Fatalf("v%d/%d: %v", tt.ipVersion, tt.recordSize, err)
+		}
+		g := &geoPolicy{db: db}
+		want := map[string]string{
+			"1.2.3.4":        "DE",
+			"::ffff:1.2.3.4": "DE",
+			"2.2.9.9":        "FR",
+			"2.3.0.1":        "",
+			"9.9.9.9":        "",
+			"2001:db8::1":    "",
+			"2001:db9::1":    "",
+			"not an ip":      "",
+		}
+		if tt.ipVersion == 6 {
+			want["2001:db8::1"] = "FR"
+		}
+		for ip, country := range want {
+			if got := g.country(ip); got != country {
+				t.Errorf("v%d/%d: country(%q) = %q, want %q", tt.ipVersion, tt.recordSize, ip, got, country)
+			}
+		}
+	}
+
+	// The far pointer needs the two-byte form.
+	db, _ := newMMDBReader(mmdbFixture{ipVersion: 4, recordSize: 24, prefixes: map[string]uint{"2.0.0.0/8": fr}, data: data}.build(t))
+	rec, err := db.lookup(netip.MustParseAddr("2.0.0.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mmdbPath(rec, "extra", "iso", "iso_code"); got != "FR" {
+		t.Errorf("extra.iso.iso_code = %v, want FR", got)
+	}
+}
+
+func TestMMDBDecodeTypes(t *testing.T) {
+	field := mmdbMapOf(
+		"u16", mmdbUint(mmdbUint16, 513, 2),
+		"u32", mmdbUint(mmdbUint32, 1<<31, 4),
+		"u64", mmdbUint(mmdbUint64, 1<<40, 8),
+		"u128", mmdbField(mmdbUint128, 3, []byte{1, 2, 3}),
+		"i32", mmdbField(mmdbInt32, 4, binary.BigEndian.AppendUint32(nil, uint32(0xfffffffe))),
+		"yes", mmdbField(mmdbBool, 1, nil),
+		"no", mmdbField(mmdbBool, 0, nil),
+		"double", mmdbField(mmdbDouble, 8, binary.BigEndian.AppendUint64(nil, math.Float64bits(1.5))),
+		"float", mmdbField(mmdbFloat, 4, binary.BigEndian.AppendUint32(nil, math.Float32bits(0.25))),
+		"bytes", mmdbField(mmdbBytes, 2, []byte{0xde, 0xad}),
+		"array", mmdbField(mmdbArray, 2, append(mmdbText("a"), mmdbUint(mmdbUint16, 7, 1)...)),
+		"long", mmdbText(strings.Repeat("y", 300)),
+		"empty", mmdbField(mmdbMap, 0, nil),
+	)
+	got, next, err := mmdbDecoder(field).decode(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != uint(len(field)) {
+		t.Errorf("decode stopped at %d of %d bytes", next, len(field))
+	}
+	want := map[string]any{
+		"u16":    uint64(513),
+		"u32":    uint64(1 << 31),
+		"u64":    uint64(1 << 40),
+		"u128":   []byte{1, 2, 3},
+		"i32":    int64(-2),
+		"yes":    true,
+		"no":     false,
+		"double": 1.5,
+		"float":  0.25,
+		"bytes":  []byte{0xde, 0xad},
+		"array":  []any{"a", uint64(7)},
+		"long":   strings.Repeat("y", 300),
+		"empty":  map[string]any{},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestMMDBRejectsCorruptData(t *testing.T) {
+	data, _, fr := countryData()
+	valid := mmdbFixture{ipVersion: 6, recordSize: 28, prefixes: map[string]uint{"2.0.0.0/8": fr}, data: data}.build(t)
+
+	// Every truncation of the file fails to open, and every truncation of
+	// the data section fails the lookup, without panicking.
+	for n := range len(valid) {
+		if _, err := newMMDBReader(valid[:n]); err == nil {
+			t.Errorf("file truncated to %d bytes was accepted", n)
+		}
+	}
+	for n := range len(data) {
+		buf := mmdbFixture{ipVersion: 6, recordSize: 28, prefixes: map[string]uint{"2.0.0.0/8": fr}, data: data[:n]}.build(t)
+		db, err := newMMDBReader(buf)
+		if err != nil {
+			t.Fatalf("data truncated to %d bytes: %v", n, err)
+		}
+		if _, err := db.lookup(netip.MustParseAddr("2.0.0.1")); !errors.Is(err, errMMDBCorrupt) {
+			t.Errorf("data truncated to %d bytes: err = %v, want errMMDBCorrupt", n, err)
+		}
+	}
+
+	bad := map[string][]byte{
+		"truncated string":         mmdbText("hello")[:4],
+		"truncated size":           {mmdbString<<5 | 30, 1},
+		"truncated pointer":        {mmdbPointer<<5 | 2<<3, 0},
+		"truncated extended type":  {0},
+		"pointer past the end":     mmdbPointerTo(500),
+		"pointer to pointer":       append(mmdbPointerTo(2), mmdbPointerTo(0)...),
+		"map with non-string key":  mmdbField(mmdbMap, 1, append(mmdbUint(mmdbUint16, 1, 1), mmdbText("v")...)),
+		"map bigger than the data": mmdbField(mmdbMap, 60000, nil),
+		"array bigger than data":   mmdbField(mmdbArray, 1000, mmdbText("a")),
+		"double of wrong size":     mmdbField(mmdbDouble, 4, make([]byte, 4)),
+		"integer too wide":         mmdbField(mmdbUint64, 9, make([]byte, 9)),
+		"map that contains itself": mmdbField(mmdbMap, 1, append(mmdbText("k"), mmdbPointerTo(0)...)),
+	}
+	for name, field := range bad {
+		if _, _, err := mmdbDecoder(field).decode(0); !errors.Is(err, errMMDBCorrupt) {
+			t.Errorf("%s: err = %v, want errMMDBCorrupt", name, err)
+		}
+	}
+
+	if _, err := newMMDBReader([]byte("not a database")); err == nil {
+		t.Error("a file without metadata was accepted")
+	}
+	huge := append([]byte{}, valid[:bytes.LastIndex(valid, mmdbMetadataMarker)+len(mmdbMetadataMarker)]...)
+	huge = append(huge, mmdbMapOf(
+		"node_count", mmdbUint(mmdbUint32, 1<<30, 4),
+		"record_size", mmdbUint(mmdbUint16, 24, 2),
+		"ip_version", mmdbUint(mmdbUint16, 4, 2),
+	)...)
+	if _, err := newMMDBReader(huge); !errors.Is(err, errMMDBCorrupt) {
+		t.Errorf("node count beyond the file: err = %v", err)
+	}
+}
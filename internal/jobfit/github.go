@@ -0,0 +1,222 @@
+package jobfit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	githubAPI          = "https://api.github.com"
+	githubTimeout      = 5 * time.Second
+	githubCacheTTL     = 6 * time.Hour
+	githubPromptRepos  = 10
+	githubReposFetched = 50
+)
+
+var (
+	githubProfileRegex  = regexp.MustCompile(`(?i)github\.com/([A-Za-z0-9](?:[A-Za-z0-9-]{0,38}))`)
+	githubUsernameRegex = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+	// Paths on github.com that aren't user profiles.
+	githubReservedPaths = []string{"about", "features", "orgs", "settings", "topics", "marketplace", "sponsors", "collections", "explore"}
+
+	githubClient = &http.Client{Timeout: githubTimeout}
+)
+
+var errGitHubUserNotFound = errors.New("github user not found")
+
+type githubRepo struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Topics      []string  `json:"topics,omitempty"`
+	Stars       int       `json:"stargazers_count"`
+	Fork        bool      `json:"fork"`
+	PushedAt    time.Time `json:"pushed_at"`
+}
+
+// githubProfile is the public evidence of a candidate's work on GitHub.
+type githubProfile struct {
+	Username string       `json:"username"`
+	Repos    []githubRepo `json:"repos"`
+	// Pinned is only available when GITHUB_TOKEN is set, since the GraphQL
+	// API requires authentication.
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+// githubUsername finds the candidate's GitHub profile link on the resume.
+func githubUsername(text string) string {
+	for _, m := range githubProfileRegex.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(githubReservedPaths, strings.ToLower(m[1])) {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func githubProfileKey(username string) string {
+	return rkey("github", strings.ToLower(username))
+}
+
+func githubRequest(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return githubClient.Do(req)
+}
+
+// loadGitHubProfile fetches a user's public repositories, caching them so
+// repeated analyses don't eat into GitHub's unauthenticated rate limit.
+func (app *application) loadGitHubProfile(ctx context.Context, username string) (*githubProfile, error) {
+	if p, ok := app.caches.github.get(strings.ToLower(username)); ok {
+		return p, nil
+	}
+	if data, err := app.rdb.Get(ctx, githubProfileKey(username)).Bytes(); err == nil {
+		var p githubProfile
+		if err := json.Unmarshal(data, &p); err == nil {
+			app.caches.github.set(strings.ToLower(username), &p)
+			return &p, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	resp, err := githubRequest(ctx, http.MethodGet, fmt.Sprintf("%s/users/%s/repos?sort=pushed&per_page=%d", githubAPI, username, githubReposFetched), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGitHubUserNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github returned %s", resp.Status)
+	}
+
+	p := &githubProfile{Username: username}
+	if err := json.NewDecoder(resp.Body).Decode(&p.Repos); err != nil {
+		return nil, err
+	}
+	// Forks mostly show what someone looked at, not what they built.
+	p.Repos = slices.DeleteFunc(p.Repos, func(r githubRepo) bool { return r.Fork })
+	if os.Getenv("GITHUB_TOKEN") != "" {
+		if p.Pinned, err = fetchPinnedRepos(ctx, username); err != nil {
+			app.logger.Warn("failed to fetch pinned github repos", "username", username, "error", err)
+		}
+	}
+
+	if data, err := json.Marshal(p); err == nil {
+		app.rdb.Set(ctx, githubProfileKey(username), data, githubCacheTTL)
+	}
+	app.caches.github.set(strings.ToLower(username), p)
+	return p, nil
+}
+
+func fetchPinnedRepos(ctx context.Context, username string) ([]string, error) {
+	query, _ := json.Marshal(map[string]any{
+		"query":     `query($login: String!) { user(login: $login) { pinnedItems(first: 6, types: REPOSITORY) { nodes { ... on Repository { name } } } } }`,
+		"variables": map[string]string{"login": username},
+	})
+	resp, err := githubRequest(ctx, http.MethodPost, githubAPI+"/graphql", query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github returned %s", resp.Status)
+	}
+
+	var result struct {
+		Data struct {
+			User struct {
+				PinnedItems struct {
+					Nodes []struct {
+						Name string `json:"name"`
+					} `json:"nodes"`
+				} `json:"pinnedItems"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var pinned []string
+	for _, n := range result.Data.User.PinnedItems.Nodes {
+		pinned = append(pinned, n.Name)
+	}
+	return pinned, nil
+}
+
+// languages counts the primary language of each repository, most used first.
+func (p *githubProfile) languages() []string {
+	counts := map[string]int{}
+	for _, r := range p.Repos {
+		if r.Language != "" {
+			counts[r.Language]++
+		}
+	}
+	langs := make([]string, 0, len(counts))
+	for lang := range counts {
+		langs = append(langs, lang)
+	}
+	slices.SortFunc(langs, func(a, b string) int {
+		return cmp.Or(counts[b]-counts[a], strings.Compare(a, b))
+	})
+	return langs
+}
+
+// promptNotes describes the profile for the model, pinned repositories first
+// and then the most recently active ones.
+func (p *githubProfile) promptNotes() string {
+	if len(p.Repos) == 0 {
+		return fmt.Sprintf("GitHub user %s has no public repositories of their own.", p.Username)
+	}
+	repos := slices.Clone(p.Repos)
+	slices.SortStableFunc(repos, func(a, b githubRepo) int {
+		aPinned, bPinned := slices.Contains(p.Pinned, a.Name), slices.Contains(p.Pinned, b.Name)
+		switch {
+		case aPinned && !bPinned:
+			return -1
+		case bPinned && !aPinned:
+			return 1
+		}
+		return b.PushedAt.Compare(a.PushedAt)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "GitHub user %s has %d public repositories. Languages used: %s.\n", p.Username, len(p.Repos), strings.Join(p.languages(), ", "))
+	for _, r := range repos[:min(len(repos), githubPromptRepos)] {
+		fmt.Fprintf(&b, "- %s", r.Name)
+		if r.Language != "" {
+			fmt.Fprintf(&b, " (%s)", r.Language)
+		}
+		if slices.Contains(p.Pinned, r.Name) {
+			b.WriteString(" [pinned]")
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&b, ": %s", r.Description)
+		}
+		if len(r.Topics) > 0 {
+			fmt.Fprintf(&b, " Topics: %s.", strings.Join(r.Topics, ", "))
+		}
+		fmt.Fprintf(&b, " Last updated %s.\n", r.PushedAt.Format("Jan 2006"))
+	}
+	return strings.TrimSpace(b.String())
+}
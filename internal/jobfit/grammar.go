@@ -0,0 +1,179 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	grammarTimeout   = 10 * time.Second
+	maxGrammarIssues = 50
+	maxSuggestions   = 5
+)
+
+// Resumes are written in fragments, so LanguageTool's checks for sentence
+// style, capitalization and spacing mostly flag things that are fine there.
+const (
+	grammarDisabledCategories = "TYPOGRAPHY,STYLE,REDUNDANCY,CASING"
+	grammarDisabledRules      = "WHITESPACE_RULE,UPPERCASE_SENTENCE_START,SENTENCE_FRAGMENT,EN_QUOTES,DASH_RULE"
+)
+
+// A grammarChecker spell- and grammar-checks resumes with a LanguageTool
+// server, usually a self-hosted one so resumes stay in-house.
+type grammarChecker struct {
+	client   *http.Client
+	endpoint string
+	language string
+}
+
+// newGrammarCheckerFromEnv configures the checker from LANGUAGETOOL_URL, the
+// server's base address, and LANGUAGETOOL_LANGUAGE, which is "auto" unless
+// set. It returns nil if LANGUAGETOOL_URL is unset.
+func newGrammarCheckerFromEnv() (*grammarChecker, error) {
+	base := os.Getenv("LANGUAGETOOL_URL")
+	if base == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("LANGUAGETOOL_URL must be an http(s) URL")
+	}
+	return &grammarChecker{
+		client:   &http.Client{Timeout: grammarTimeout},
+		endpoint: strings.TrimSuffix(u.String(), "/") + "/v2/check",
+		language: cmp.Or(os.Getenv("LANGUAGETOOL_LANGUAGE"), "auto"),
+	}, nil
+}
+
+// grammarIssue is a spelling or grammar mistake. Offset and Length count
+// UTF-16 code units, as JavaScript strings do, so the web app can highlight
+// the text directly.
+type grammarIssue struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Text   string `json:"text"`
+	// Kind is "spelling", "grammar" or "punctuation".
+	Kind        string   `json:"kind"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// grammarReport is the grammar section of an analysis.
+type grammarReport struct {
+	Language string         `json:"language"`
+	Issues   []grammarIssue `json:"issues"`
+}
+
+// languageToolResponse is the part of LanguageTool's /v2/check reply the app
+// reads.
+type languageToolResponse struct {
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	Matches []struct {
+		Message      string `json:"message"`
+		Offset       int    `json:"offset"`
+		Length       int    `json:"length"`
+		Replacements []struct {
+			Value string `json:"value"`
+		} `json:"replacements"`
+		Rule struct {
+			IssueType string `json:"issueType"`
+			Category  struct {
+				ID string `json:"id"`
+			} `json:"category"`
+		} `json:"rule"`
+	} `json:"matches"`
+}
+
+// grammarKind sorts LanguageTool's categories into the kinds the app reports.
+func grammarKind(category, issueType string) string {
+	switch {
+	case category == "TYPOS" || issueType == "misspelling":
+		return "spelling"
+	case category == "PUNCTUATION":
+		return "punctuation"
+	}
+	return "grammar"
+}
+
+// check returns the mistakes in resume. Spelling "mistakes" that appear in
+// the job description are left out, since they're usually product names and
+// jargon the dictionary doesn't know.
+func (g *grammarChecker) check(ctx context.Context, resume, jobDescription string) (*grammarReport, error) {
+	form := url.Values{
+		"text":               {resume},
+		"language":           {g.language},
+		"disabledCategories": {grammarDisabledCategories},
+		"disabledRules":      {grammarDisabledRules},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("languagetool: %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	var lt languageToolResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 4<<20)).Decode(&lt); err != nil {
+		return nil, fmt.Errorf("languagetool: %w", err)
+	}
+
+	units := utf16.Encode([]rune(resume))
+	jobLower := strings.ToLower(jobDescription)
+	report := &grammarReport{Language: lt.Language.Code, Issues: []grammarIssue{}}
+	for _, m := range lt.Matches {
+		if m.Offset < 0 || m.Length <= 0 || m.Offset+m.Length > len(units) {
+			continue
+		}
+		text := string(utf16.Decode(units[m.Offset : m.Offset+m.Length]))
+		kind := grammarKind(m.Rule.Category.ID, m.Rule.IssueType)
+		if kind == "spelling" && strings.Contains(jobLower, strings.ToLower(text)) {
+			continue
+		}
+		issue := grammarIssue{Offset: m.Offset, Length: m.Length, Text: text, Kind: kind, Message: m.Message, Suggestions: []string{}}
+		for _, r := range m.Replacements[:min(len(m.Replacements), maxSuggestions)] {
+			issue.Suggestions = append(issue.Suggestions, r.Value)
+		}
+		report.Issues = append(report.Issues, issue)
+		if len(report.Issues) == maxGrammarIssues {
+			break
+		}
+	}
+	return report, nil
+}
+
+// grammarImprovement summarizes report as an improvement bullet, or returns
+// "" if there's nothing to fix.
+func grammarImprovement(report *grammarReport) string {
+	if len(report.Issues) == 0 {
+		return ""
+	}
+	var examples []string
+	for _, issue := range report.Issues[:min(len(report.Issues), 3)] {
+		if len(issue.Suggestions) > 0 {
+			examples = append(examples, fmt.Sprintf("%q → %q", issue.Text, issue.Suggestions[0]))
+		} else {
+			examples = append(examples, fmt.Sprintf("%q", issue.Text))
+		}
+	}
+	return fmt.Sprintf("- **Spelling and grammar:** Fix %d mistake(s), such as %s. Typos are one of the quickest ways to be rejected.",
+		len(report.Issues), strings.Join(examples, ", "))
+}
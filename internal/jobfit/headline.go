@@ -0,0 +1,222 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxHeadlineInputSize = 50000
+	// maxLinkedInHeadline is LinkedIn's limit on headline length.
+	maxLinkedInHeadline = 220
+	// Below this share of the target title's words, the headline is
+	// reported as misaligned.
+	minTitleAlignment = 60
+)
+
+// titleAbbreviations are expanded before titles are compared, so "Sr. Eng"
+// matches "Senior Engineer".
+var titleAbbreviations = map[string]string{
+	"sr": "senior", "snr": "senior", "jr": "junior", "eng": "engineer", "engr": "engineer",
+	"mgr": "manager", "dev": "developer", "swe": "software engineer", "pm": "product manager",
+	"vp": "vice president", "dir": "director", "assoc": "associate", "admin": "administrator",
+}
+
+// seniorityWords mark a title's level. A headline at a different level from
+// the job's is filtered out by many ATS title searches.
+var seniorityWords = []string{"intern", "junior", "associate", "senior", "staff", "principal", "lead", "head", "director", "chief"}
+
+// titleWords normalizes a job title into lowercase words with abbreviations
+// expanded and filler such as "of" and "and" dropped.
+func titleWords(title string) []string {
+	var words []string
+	for _, w := range searchTerms(title) {
+		if exp, ok := titleAbbreviations[w]; ok {
+			words = append(words, strings.Fields(exp)...)
+		} else if !slices.Contains(functionWords, w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// titleSeniority returns the first seniority word in words, or "".
+func titleSeniority(words []string) string {
+	for _, w := range words {
+		if slices.Contains(seniorityWords, w) {
+			return w
+		}
+	}
+	return ""
+}
+
+// resumeHeadline finds the title the candidate presents themselves with: a
+// headline line under their name, or failing that their latest job title.
+// source says which it is, and is "" if there's neither.
+func resumeHeadline(resume string) (headline, source string) {
+	for _, s := range splitSections(resume) {
+		if s.Name != "header" {
+			continue
+		}
+		// The first line is the name.
+		for _, l := range s.Lines[min(1, len(s.Lines)):] {
+			if strings.ContainsAny(l.Text, "@|/:0123456789") || locationRegex.MatchString(l.Text) || len(strings.Fields(l.Text)) > 10 {
+				continue
+			}
+			return l.Text, "headline"
+		}
+	}
+	for _, e := range parseResumeRules(resume).Experience {
+		if e.Title != "" {
+			return e.Title, "latest_title"
+		}
+	}
+	return "", ""
+}
+
+// headlineAdvice compares the candidate's headline with the job's title and
+// suggests aligned versions for the resume and LinkedIn.
+type headlineAdvice struct {
+	ID              string `json:"id"`
+	CurrentHeadline string `json:"currentHeadline"`
+	// Source is "headline" when the resume has a headline under the name,
+	// "latest_title" when the latest job title stands in for one, or "".
+	Source      string `json:"source"`
+	TargetTitle string `json:"targetTitle"`
+	// Alignment is the percentage of the target title's words the current
+	// headline contains.
+	Alignment         int      `json:"alignment"`
+	Aligned           bool     `json:"aligned"`
+	Issues            []string `json:"issues"`
+	SuggestedHeadline string   `json:"suggestedHeadline"`
+	LinkedInHeadline  string   `json:"linkedinHeadline"`
+	Reason            string   `json:"reason"`
+}
+
+// checkTitleAlignment fills in how well advice.CurrentHeadline matches
+// advice.TargetTitle.
+func checkTitleAlignment(advice *headlineAdvice) {
+	advice.Issues = []string{}
+	current, target := titleWords(advice.CurrentHeadline), titleWords(advice.TargetTitle)
+	if len(target) == 0 {
+		return
+	}
+	if len(current) == 0 {
+		advice.Issues = append(advice.Issues, fmt.Sprintf("The resume has no headline; add one matching %q under your name.", advice.TargetTitle))
+		return
+	}
+	var missing []string
+	matched := 0
+	for _, w := range target {
+		switch {
+		case slices.Contains(current, w):
+			matched++
+		case !slices.Contains(seniorityWords, w):
+			missing = append(missing, w)
+		}
+	}
+	advice.Alignment = 100 * matched / len(target)
+	if len(missing) > 0 {
+		advice.Issues = append(advice.Issues, fmt.Sprintf("Your headline doesn't include %q from the job title.", strings.Join(missing, " ")))
+	}
+	switch have, want := titleSeniority(current), titleSeniority(target); {
+	case have == want:
+	case have == "":
+		advice.Issues = append(advice.Issues, fmt.Sprintf("The job is a %s role but your headline doesn't give a level.", want))
+	case want == "":
+		advice.Issues = append(advice.Issues, fmt.Sprintf("Your headline says %s but the job title doesn't; it may read as over- or under-qualified.", have))
+	default:
+		advice.Issues = append(advice.Issues, fmt.Sprintf("Your headline says %s but the job is a %s role.", have, want))
+	}
+	advice.Aligned = advice.Alignment >= minTitleAlignment && len(advice.Issues) == 0
+}
+
+// headlineHandler checks whether the candidate's headline uses the job's
+// title and suggests one that does, since ATS searches and recruiters filter
+// on titles before reading anything else. The suggestion stays truthful to
+// the resume rather than copying a title the candidate hasn't held.
+func (app *application) headlineHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req struct {
+		Resume         string `json:"resume"`
+		JobDescription string `json:"jobDescription"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		http.Error(w, "A resume and a job description are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resume) > maxHeadlineInputSize || len(req.JobDescription) > maxHeadlineInputSize {
+		http.Error(w, "Resume or job description is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionHeadline); !ok {
+		return
+	}
+
+	advice := headlineAdvice{ID: newULID(time.Now())}
+	log := app.logger.With("headlineID", advice.ID, "ip", ip)
+	jr, err := app.loadJobRequirements(ctx, log, req.JobDescription)
+	if err != nil {
+		modelError(w, log, err)
+		return
+	}
+	advice.TargetTitle = jr.JobTitle
+	advice.CurrentHeadline, advice.Source = resumeHeadline(req.Resume)
+	checkTitleAlignment(&advice)
+
+	prompt := fmt.Sprintf(`
+		Suggest a headline for the candidate's resume that uses the target job title's wording, and a LinkedIn headline.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "suggestedHeadline": a string of at most 10 words to go under the candidate's name, using the target title's words where the resume supports them, such as "Senior Backend Engineer | Go, Kubernetes".
+		- "linkedinHeadline": a string of at most %d characters: the title, then two or three of the candidate's strongest skills or results relevant to the job, separated by " | ".
+		- "reason": a string, one sentence on what changed and why.
+		Only claim a title or seniority the resume supports; if the candidate hasn't held the target title, describe what they are in the target's terms instead.
+
+		**Target job title:** %s
+		**Seniority:** %s
+		**Skills the job asks for:** %s
+		**Current headline:** %s
+
+		**Resume:**
+		---
+		%s
+		---
+	`, maxLinkedInHeadline, jr.JobTitle, jr.Seniority, strings.Join(jr.RequiredSkills, ", "), advice.CurrentHeadline, req.Resume)
+
+	var out struct {
+		SuggestedHeadline string `json:"suggestedHeadline"`
+		LinkedInHeadline  string `json:"linkedinHeadline"`
+		Reason            string `json:"reason"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	advice.SuggestedHeadline, advice.Reason = out.SuggestedHeadline, out.Reason
+	advice.LinkedInHeadline = out.LinkedInHeadline
+	if r := []rune(advice.LinkedInHeadline); len(r) > maxLinkedInHeadline {
+		advice.LinkedInHeadline = strings.TrimSpace(string(r[:maxLinkedInHeadline]))
+	}
+	log.Info("suggested headline", "alignment", advice.Alignment, "source", advice.Source)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(advice); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
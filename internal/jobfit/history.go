@@ -0,0 +1,223 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The oldest entries are dropped once a client's history grows past this.
+const maxHistoryItems = 200
+
+func historyKey(owner string) string {
+	return rkey("history", owner)
+}
+
+// addToHistory indexes a stored analysis under its owner, newest last.
+func (app *application) addToHistory(ctx context.Context, owner string, rec *analysisRecord) error {
+	key := historyKey(owner)
+	pipe := app.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(rec.CreatedAt.UnixMilli()), Member: rec.ID})
+	pipe.ZRemRangeByRank(ctx, key, 0, -maxHistoryItems-1)
+	pipe.Expire(ctx, key, analysisRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// loadHistory returns the owner's analyses created within [from, to]. A zero
+// time leaves that end of the range open. Entries whose record has expired are
+// skipped.
+func (app *application) loadHistory(ctx context.Context, owner string, from, to time.Time) ([]*analysisRecord, error) {
+	rng := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if !from.IsZero() {
+		rng.Min = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		rng.Max = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	ids, err := app.rdb.ZRangeByScore(ctx, historyKey(owner), rng).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = analysisKey(id)
+	}
+	vals, err := app.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]*analysisRecord, 0, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var rec analysisRecord
+		if err := app.unmarshalSealed(keys[i], []byte(s), &rec); err != nil {
+			app.logger.Warn("skipping unreadable history entry", "error", err)
+			continue
+		}
+		recs = append(recs, &rec)
+	}
+	return recs, nil
+}
+
+// historySorts maps the accepted "sort" values to an ordering. Every ordering
+// falls back to the ID so cursors are unambiguous.
+var historySorts = map[string]func(a, b *analysisRecord) bool{
+	"newest": func(a, b *analysisRecord) bool { return a.ID > b.ID },
+	"oldest": func(a, b *analysisRecord) bool { return a.ID < b.ID },
+	"score": func(a, b *analysisRecord) bool {
+		if a.MatchScore != b.MatchScore {
+			return a.MatchScore > b.MatchScore
+		}
+		return a.ID > b.ID
+	},
+}
+
+type historyQuery struct {
+	from, to time.Time
+	minScore int
+	title    string
+	tags     []string
+	sort     string
+	limit    int
+	cursor   *pageCursor
+}
+
+// parseHistoryDate accepts either a full RFC 3339 timestamp or a plain date. A
+// plain date used as the end of a range covers that whole day.
+func parseHistoryDate(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Millisecond)
+	}
+	return t, nil
+}
+
+func parseHistoryQuery(r *http.Request) (historyQuery, string) {
+	q := r.URL.Query()
+	hq := historyQuery{sort: "newest", title: strings.ToLower(strings.TrimSpace(q.Get("title")))}
+
+	var err error
+	if hq.limit, hq.cursor, err = parsePageParams(r); err != nil {
+		return hq, err.Error()
+	}
+	if v := q.Get("from"); v != "" {
+		if hq.from, err = parseHistoryDate(v, false); err != nil {
+			return hq, "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if hq.to, err = parseHistoryDate(v, true); err != nil {
+			return hq, "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		}
+	}
+	if v := q.Get("minScore"); v != "" {
+		if hq.minScore, err = strconv.Atoi(v); err != nil || hq.minScore < 0 || hq.minScore > 100 {
+			return hq, "minScore must be an integer between 0 and 100"
+		}
+	}
+	if len(q["tag"]) > 0 {
+		if hq.tags, err = normalizeTags(q["tag"]); err != nil {
+			return hq, err.Error()
+		}
+	}
+	if v := q.Get("sort"); v != "" {
+		if _, ok := historySorts[v]; !ok {
+			return hq, "sort must be one of newest, oldest or score"
+		}
+		hq.sort = v
+	}
+	return hq, ""
+}
+
+func (hq historyQuery) matches(rec *analysisRecord) bool {
+	if rec.MatchScore < hq.minScore {
+		return false
+	}
+	if hq.title != "" && !strings.Contains(strings.ToLower(rec.JobTitle), hq.title) {
+		return false
+	}
+	for _, t := range hq.tags {
+		if !slices.Contains(rec.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// paginate orders recs and returns the page that follows the query's cursor.
+func (hq historyQuery) paginate(recs []*analysisRecord) page[*analysisRecord] {
+	less := historySorts[hq.sort]
+	slices.SortFunc(recs, func(a, b *analysisRecord) int {
+		if less(a, b) {
+			return -1
+		}
+		if less(b, a) {
+			return 1
+		}
+		return 0
+	})
+
+	start := 0
+	if hq.cursor != nil {
+		last := &analysisRecord{AnalysisResponse: AnalysisResponse{ID: hq.cursor.ID, MatchScore: hq.cursor.Score}}
+		for start < len(recs) && !less(last, recs[start]) {
+			start++
+		}
+	}
+	end := min(start+hq.limit, len(recs))
+
+	p := page[*analysisRecord]{Items: recs[start:end]}
+	if end < len(recs) {
+		tail := recs[end-1]
+		p.NextCursor = pageCursor{ID: tail.ID, Score: tail.MatchScore}.encode()
+	}
+	return p
+}
+
+// listHistoryHandler returns the caller's past analyses, one page at a time.
+func (app *application) listHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	hq, problem := parseHistoryQuery(r)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	var recs []*analysisRecord
+	if owner, ok := app.existingClientID(r); ok {
+		var err error
+		recs, err = app.loadHistory(r.Context(), owner, hq.from, hq.to)
+		if err != nil {
+			app.logger.Error("failed to load history", "error", err)
+			http.Error(w, "Could not load history", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	matched := make([]*analysisRecord, 0, len(recs))
+	for _, rec := range recs {
+		if hq.matches(rec) {
+			matched = append(matched, rec)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hq.paginate(matched))
+}
@@ -0,0 +1,161 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	auditLogSize = 10000
+	// maxImpersonationReason bounds the free-text reason kept with each entry.
+	maxImpersonationReason = 500
+)
+
+func auditLogKey() string { return rkey("audit", "log") }
+
+// auditEntry is one request an admin made while impersonating a client.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	ClientID string    `json:"clientId"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Write    bool      `json:"write"`
+	Reason   string    `json:"reason"`
+	IP       string    `json:"ip"`
+}
+
+// withImpersonation lets an admin see the app as a given client does, to
+// debug a reported problem with their history or quota. The request carries
+// the admin token and X-Impersonate-Client, and is served as if it came with
+// that client's cookie. Impersonation is read-only unless
+// X-Impersonate-Write is true, since writes can spend the client's credits
+// or change their data. Every impersonated request, allowed or not, is
+// written to the audit log with the X-Impersonate-Reason it gave.
+func (app *application) withImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Impersonate-Client")
+		if target == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !app.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !isClientID(target) {
+			http.Error(w, "Invalid X-Impersonate-Client", http.StatusBadRequest)
+			return
+		}
+		reason := strings.TrimSpace(r.Header.Get("X-Impersonate-Reason"))
+		if reason == "" {
+			http.Error(w, "X-Impersonate-Reason is required", http.StatusBadRequest)
+			return
+		}
+		if len(reason) > maxImpersonationReason {
+			reason = reason[:maxImpersonationReason]
+		}
+
+		write, _ := strconv.ParseBool(r.Header.Get("X-Impersonate-Write"))
+		entry := auditEntry{
+			Time:     time.Now().UTC(),
+			Action:   "impersonate",
+			ClientID: target,
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Write:    write,
+			Reason:   reason,
+			IP:       getIPAddress(r),
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			entry.Status = rec.code()
+			app.audit(entry)
+		}()
+
+		if !write && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(rec, "Impersonation is read-only; set X-Impersonate-Write: true to make changes", http.StatusForbidden)
+			return
+		}
+
+		// Swap the admin's own cookie, if any, for the client's. It's signed
+		// like one the client's browser holds, so every handler treats the
+		// request exactly as theirs and never issues a new cookie.
+		value := target
+		if len(app.clientSecret) > 0 {
+			value += "." + app.signClientID(target)
+		}
+		r = r.Clone(r.Context())
+		var cookies []string
+		for _, c := range r.Cookies() {
+			if c.Name != clientCookieName {
+				cookies = append(cookies, c.String())
+			}
+		}
+		cookies = append(cookies, (&http.Cookie{Name: clientCookieName, Value: value}).String())
+		r.Header.Set("Cookie", strings.Join(cookies, "; "))
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// audit records an entry in the audit log and the server log. It doesn't
+// use the request's context so a client hanging up can't drop the record.
+func (app *application) audit(e auditEntry) {
+	app.logger.Info("audit", "action", e.Action, "clientID", e.ClientID, "method", e.Method,
+		"path", e.Path, "status", e.Status, "write", e.Write, "reason", e.Reason, "ip", e.IP)
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pipe := app.rdb.Pipeline()
+	pipe.LPush(ctx, auditLogKey(), data)
+	pipe.LTrim(ctx, auditLogKey(), 0, auditLogSize-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to write audit log", "error", err)
+	}
+}
+
+// adminAuditLogHandler lists the most recent audit entries, newest first,
+// optionally only those for ?clientId=.
+func (app *application) adminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := r.URL.Query().Get("clientId")
+	if clientID != "" && !isClientID(clientID) {
+		http.Error(w, "Invalid clientId", http.StatusBadRequest)
+		return
+	}
+	// Filtering scans the whole log; otherwise only the latest page is read.
+	stop := int64(99)
+	if clientID != "" {
+		stop = -1
+	}
+	vals, err := app.rdb.LRange(r.Context(), auditLogKey(), 0, stop).Result()
+	if err != nil {
+		app.logger.Error("failed to load audit log", "error", err)
+		http.Error(w, "Could not load audit log", http.StatusInternalServerError)
+		return
+	}
+	list := make([]auditEntry, 0, min(len(vals), 100))
+	for _, v := range vals {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(v), &e); err != nil {
+			continue
+		}
+		if clientID != "" && e.ClientID != clientID {
+			continue
+		}
+		list = append(list, e)
+		if len(list) == 100 {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,283 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// A signed request must arrive within this long of its timestamp, and its
+	// signature can't be used twice in that window.
+	signatureMaxAge              = 5 * time.Minute
+	defaultIntegrationDailyLimit = 1000
+	maxIntegrationBodySize       = 2*maxWidgetInputSize + 1024
+)
+
+func integrationsKey() string { return rkey("integrations") }
+
+var errIntegrationNotFound = errors.New("integration not found")
+
+// An integration is a third-party backend allowed to call the server-to-server
+// API. It signs each request with its shared secret, so the secret never has
+// to reach a browser.
+//
+// Requests carry three headers:
+//
+//	X-JobFit-Integration: the integration ID
+//	X-JobFit-Timestamp:   the current Unix time in seconds
+//	X-JobFit-Signature:   hex(HMAC-SHA256(secret, timestamp + "." + body))
+type integration struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Secret     string    `json:"secret,omitempty"`
+	DailyLimit int64     `json:"dailyLimit"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func integrationUsageKey(id, day string) string { return rkey("integration", "usage", id, day) }
+
+func integrationSignatureKey(id, sig string) string { return rkey("integration", "sig", id, sig) }
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// signPayload is the HMAC-SHA256 signature of "<timestamp>.<body>", used both
+// for requests integrations send us and for webhooks we send out.
+func signPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (app *application) loadIntegration(ctx context.Context, id string) (*integration, error) {
+	if in, ok := app.caches.integrations.get(id); ok {
+		return &in, nil
+	}
+	data, err := app.rdb.HGet(ctx, integrationsKey(), id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errIntegrationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var in integration
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, err
+	}
+	app.caches.integrations.set(id, in)
+	return &in, nil
+}
+
+// verifySignedRequest checks the signature headers against the body and
+// returns the calling integration, writing the error response if they don't
+// hold up. Every failure gets the same message so callers can't probe which
+// part was wrong.
+func (app *application) verifySignedRequest(w http.ResponseWriter, r *http.Request, body []byte) (*integration, bool) {
+	ctx := r.Context()
+	id := r.Header.Get("X-JobFit-Integration")
+	timestamp := r.Header.Get("X-JobFit-Timestamp")
+	sig := strings.ToLower(r.Header.Get("X-JobFit-Signature"))
+	log := app.logger.With("integrationID", id, "ip", getIPAddress(r))
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if id == "" || sig == "" || err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		log.Warn("signed request outside the allowed window", "timestamp", timestamp)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	in, err := app.loadIntegration(ctx, id)
+	if errors.Is(err, errIntegrationNotFound) {
+		log.Warn("signed request from unknown integration")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err != nil {
+		log.Error("failed to load integration", "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(signPayload(in.Secret, timestamp, body))) {
+		log.Warn("signature mismatch")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	fresh, err := app.rdb.SetNX(ctx, integrationSignatureKey(id, sig), 1, 2*signatureMaxAge).Result()
+	if err != nil {
+		log.Error("failed to record signature", "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !fresh {
+		log.Warn("replayed signed request")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return nil, false
+	}
+	return in, true
+}
+
+// integrationAnalyzeHandler is the server-to-server analyze API. It takes the
+// same body as /chat and counts against the integration's daily limit.
+func (app *application) integrationAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIntegrationBodySize))
+	if err != nil {
+		http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	in, ok := app.verifySignedRequest(w, r, body)
+	if !ok {
+		return
+	}
+
+	var req AnalysisRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.readFields(r)
+	if msg, status := checkBasicAnalysisRequest(&req); status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+
+	key := integrationUsageKey(in.ID, time.Now().UTC().Format("2006-01-02"))
+	pipe := app.rdb.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, rateLimitDuration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("redis increment failed", "integrationID", in.ID, "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(in.DailyLimit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(max(in.DailyLimit-count.Val(), 0), 10))
+	if count.Val() > in.DailyLimit {
+		app.logger.Warn("integration limit exceeded", "integrationID", in.ID, "count", count.Val())
+		http.Error(w, "Daily limit reached for this integration", http.StatusTooManyRequests)
+		return
+	}
+
+	analysisID := newULID(time.Now())
+	log := app.logger.With("analysisID", analysisID, "integrationID", in.ID)
+	resp, err := app.basicAnalysis(ctx, log, analysisID, sourceAPI, in.ID, &req)
+	if err != nil {
+		modelError(w, log, err)
+		return
+	}
+
+	out, err := selectFields(resp, req.Fields)
+	if err != nil {
+		log.Error("failed to select response fields", "error", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
+
+// adminCreateIntegrationHandler registers an integration. The response is the
+// only time its secret is shown.
+func (app *application) adminCreateIntegrationHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		DailyLimit int64  `json:"dailyLimit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if req.DailyLimit < 0 {
+		http.Error(w, "dailyLimit must be positive", http.StatusBadRequest)
+		return
+	}
+	in := integration{
+		ID:         "int_" + randomHex(8),
+		Name:       strings.TrimSpace(req.Name),
+		Secret:     "sk_" + randomHex(32),
+		DailyLimit: cmp.Or(req.DailyLimit, defaultIntegrationDailyLimit),
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	data, err := json.Marshal(&in)
+	if err == nil {
+		err = app.rdb.HSet(r.Context(), integrationsKey(), in.ID, data).Err()
+	}
+	if err != nil {
+		app.logger.Error("failed to create integration", "name", in.Name, "error", err)
+		http.Error(w, "Could not create integration", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("integration created", "integrationID", in.ID, "name", in.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&in)
+}
+
+func (app *application) adminListIntegrationsHandler(w http.ResponseWriter, r *http.Request) {
+	vals, err := app.rdb.HVals(r.Context(), integrationsKey()).Result()
+	if err != nil {
+		app.logger.Error("failed to list integrations", "error", err)
+		http.Error(w, "Could not list integrations", http.StatusInternalServerError)
+		return
+	}
+	list := make([]integration, 0, len(vals))
+	for _, v := range vals {
+		var in integration
+		if err := json.Unmarshal([]byte(v), &in); err != nil {
+			app.logger.Warn("skipping unreadable integration", "error", err)
+			continue
+		}
+		in.Secret = ""
+		list = append(list, in)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (app *application) adminDeleteIntegrationHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	n, err := app.rdb.HDel(r.Context(), integrationsKey(), id).Result()
+	app.caches.integrations.remove(id)
+	if err != nil {
+		app.logger.Error("failed to delete integration", "integrationID", id, "error", err)
+		http.Error(w, "Could not delete integration", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Integration not found", http.StatusNotFound)
+		return
+	}
+	app.logger.Info("integration deleted", "integrationID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,197 @@
+package jobfit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const maxJargonTerms = 20
+
+var (
+	acronymRegex = regexp.MustCompile(`\b[A-Z][A-Z0-9&]{1,5}s?\b`)
+	// camelCaseRegex matches names like "DataHub" or "projectFalcon", which
+	// on a resume are usually internal tools.
+	camelCaseRegex = regexp.MustCompile(`\b[A-Za-z][a-z]+[A-Z][A-Za-z]*\b`)
+	// Surnames like McKinsey and MacArthur look like camel case too.
+	scottishNameRegex = regexp.MustCompile(`^Ma?c[A-Z]`)
+)
+
+// commonAcronyms are understood by any recruiter and never flagged.
+var commonAcronyms = []string{
+	"AI", "API", "APIs", "AWS", "BA", "BS", "BSc", "CEO", "CFO", "COO", "CSS", "CTO", "CV", "EU",
+	"GCP", "GPA", "HR", "HTML", "HTTP", "II", "III", "IT", "IV", "JSON", "MA", "MBA", "ML", "MS",
+	"MSc", "NGO", "PDF", "PHP", "PR", "QA", "REST", "SQL", "UI", "UK", "US", "USA", "UX", "VP", "XML",
+}
+
+// commonCamelCase are product and technology names that are written in camel
+// case and aren't jargon.
+var commonCamelCase = []string{
+	"JavaScript", "TypeScript", "GitHub", "GitLab", "PostgreSQL", "MySQL", "MongoDB", "DynamoDB",
+	"PowerPoint", "PowerBI", "LinkedIn", "YouTube", "WordPress", "iOS", "macOS", "iPhone", "iPad",
+	"BigQuery", "CloudFormation", "DevOps", "FastAPI", "GraphQL", "NoSQL", "OpenAI", "PyTorch",
+	"QuickBooks", "RabbitMQ", "SharePoint", "TensorFlow", "NetSuite", "HubSpot", "SalesForce",
+	"JetBrains", "WebSocket", "WebSockets", "OAuth", "NextJS", "NodeJS", "VueJS", "SciPy", "NumPy",
+	"PhD", "SaaS", "PaaS", "IaaS", "FinTech", "eCommerce",
+}
+
+// acronymExpansions are business and technical acronyms whose meaning can be
+// suggested without the resume spelling it out.
+var acronymExpansions = map[string]string{
+	"KPI": "key performance indicator", "OKR": "objectives and key results", "SLA": "service-level agreement",
+	"SLO": "service-level objective", "SRE": "site reliability engineering", "ROI": "return on investment",
+	"ARR": "annual recurring revenue", "MRR": "monthly recurring revenue", "CRM": "customer relationship management",
+	"ERP": "enterprise resource planning", "B2B": "business-to-business",
+	"B2C": "business-to-consumer", "P&L": "profit and loss", "SEO": "search engine optimization",
+	"CI": "continuous integration", "CD": "continuous delivery", "ETL": "extract, transform, load",
+	"SDK": "software development kit", "MVP": "minimum viable product", "PMO": "project management office",
+	"RFP": "request for proposal", "SOP": "standard operating procedure", "QBR": "quarterly business review",
+	"NPS": "Net Promoter Score", "CSAT": "customer satisfaction score", "GTM": "go-to-market",
+	"SMB": "small and medium-sized business", "EHR": "electronic health record", "HIPAA": "Health Insurance Portability and Accountability Act",
+	"GDPR": "General Data Protection Regulation", "SOC": "System and Organization Controls", "PII": "personally identifiable information",
+	"TAM": "total addressable market", "CAC": "customer acquisition cost", "LTV": "customer lifetime value",
+}
+
+// jargonTerm is an acronym or in-house name a recruiter may not understand.
+type jargonTerm struct {
+	Term string `json:"term"`
+	// Kind is "acronym" or "jargon".
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	// Explained is whether the resume spells the term out somewhere, and
+	// InJobDescription whether the job uses it too.
+	Explained        bool   `json:"explained"`
+	InJobDescription bool   `json:"inJobDescription"`
+	Expansion        string `json:"expansion,omitempty"`
+	// Suggestion is "keep", "expand" or "explain_or_remove".
+	Suggestion string `json:"suggestion"`
+	Message    string `json:"message"`
+}
+
+// initials returns the first letters of words, in upper case.
+func initials(words []string) string {
+	var b strings.Builder
+	for _, w := range words {
+		if r := []rune(w); len(r) > 0 && unicode.IsLetter(r[0]) {
+			b.WriteRune(unicode.ToUpper(r[0]))
+		}
+	}
+	return b.String()
+}
+
+// resumeExpansion finds where text spells acronym out, as in "key
+// performance indicators (KPIs)" or "KPI (key performance indicator)".
+func resumeExpansion(text, acronym string) string {
+	letters := strings.TrimSuffix(acronym, "s")
+	before := regexp.MustCompile(`((?:[A-Za-z&-]+\s+){` + fmt.Sprint(len(letters)-1) + `}[A-Za-z&-]+)\s*\(` + regexp.QuoteMeta(acronym) + `\)`)
+	for _, m := range before.FindAllStringSubmatch(text, -1) {
+		if words := strings.Fields(strings.ReplaceAll(m[1], "-", " ")); initials(words) == strings.ToUpper(letters) {
+			return m[1]
+		}
+	}
+	after := regexp.MustCompile(`\b` + regexp.QuoteMeta(acronym) + `\s*\(([^()]{3,80})\)`)
+	if m := after.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// auditJargon finds acronyms and in-house names in the resume body and
+// suggests, for each, whether to keep it, spell it out or cut it. A term the
+// job description also uses is one the hiring team knows, so it's kept; one
+// the job spells out instead should be written the job's way. It's rule-based
+// and costs no model calls. Terms are in the order they first appear.
+func auditJargon(resume, jobDescription string) []jargonTerm {
+	// Contact details are full of state and country codes, so only the
+	// sections below them are read.
+	var body strings.Builder
+	for _, s := range splitSections(resume) {
+		if s.Name == "header" || s.Name == "contact" {
+			continue
+		}
+		for _, l := range s.Lines {
+			body.WriteString(l.Text + "\n")
+		}
+	}
+	text := body.String()
+	jobLower := strings.ToLower(jobDescription)
+	jobWords := searchTerms(jobDescription)
+
+	var terms []jargonTerm
+	seen := map[string]int{}
+	add := func(term, kind string) {
+		if i, ok := seen[term]; ok {
+			terms[i].Count++
+			return
+		}
+		seen[term] = len(terms)
+		terms = append(terms, jargonTerm{Term: term, Kind: kind, Count: 1})
+	}
+	for _, a := range acronymRegex.FindAllString(text, -1) {
+		if !slices.Contains(commonAcronyms, a) && !slices.Contains(commonAcronyms, strings.TrimSuffix(a, "s")) {
+			add(a, "acronym")
+		}
+	}
+	for _, w := range camelCaseRegex.FindAllString(text, -1) {
+		if !scottishNameRegex.MatchString(w) && !slices.ContainsFunc(commonCamelCase, func(c string) bool { return strings.EqualFold(c, w) }) {
+			add(w, "jargon")
+		}
+	}
+
+	out := make([]jargonTerm, 0, min(len(terms), maxJargonTerms))
+	for _, t := range terms {
+		t.InJobDescription = slices.Contains(jobWords, strings.ToLower(t.Term)) ||
+			slices.Contains(jobWords, strings.ToLower(strings.TrimSuffix(t.Term, "s")))
+		if t.Kind == "acronym" {
+			t.Expansion = resumeExpansion(text, t.Term)
+			t.Explained = t.Expansion != ""
+		}
+		if t.Expansion == "" {
+			t.Expansion = acronymExpansions[strings.TrimSuffix(t.Term, "s")]
+		}
+		jobSpellsOut := t.Expansion != "" && strings.Contains(jobLower, strings.ToLower(t.Expansion))
+
+		switch {
+		case t.InJobDescription:
+			t.Suggestion = "keep"
+			t.Message = fmt.Sprintf("The job uses %q too, so keep it.", t.Term)
+		case t.Explained:
+			t.Suggestion = "keep"
+			t.Message = fmt.Sprintf("%q is spelled out on the resume.", t.Term)
+		case jobSpellsOut:
+			t.Suggestion = "expand"
+			t.Message = fmt.Sprintf("The job says %q rather than %q; use its wording so keyword searches match.", t.Expansion, t.Term)
+		case t.Expansion != "":
+			t.Suggestion = "expand"
+			t.Message = fmt.Sprintf("Spell out %q as %q the first time it's used.", t.Term, t.Expansion)
+		case t.Kind == "acronym":
+			t.Suggestion = "explain_or_remove"
+			t.Message = fmt.Sprintf("%q may only mean something inside your company; spell it out or cut it.", t.Term)
+		default:
+			t.Suggestion = "explain_or_remove"
+			t.Message = fmt.Sprintf("%q looks like an internal name; say what it is in plain words (for example \"an internal analytics dashboard\") or cut it.", t.Term)
+		}
+		out = append(out, t)
+		if len(out) == maxJargonTerms {
+			break
+		}
+	}
+	return out
+}
+
+// jargonImprovement summarizes terms as an improvement bullet, or returns ""
+// if none need changing.
+func jargonImprovement(terms []jargonTerm) string {
+	var flagged []string
+	for _, t := range terms {
+		if t.Suggestion != "keep" && len(flagged) < 5 {
+			flagged = append(flagged, fmt.Sprintf("%q", t.Term))
+		}
+	}
+	if len(flagged) == 0 {
+		return ""
+	}
+	return "- **Jargon:** Spell out or replace terms a recruiter may not know, such as " + strings.Join(flagged, ", ") + "."
+}
@@ -0,0 +1,299 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	jobPageTimeout  = 10 * time.Second
+	maxJobPageBytes = 4 << 20
+	// minJobDescriptionLength is the least text a page must yield to count
+	// as a job description rather than a login wall or an error page.
+	minJobDescriptionLength = 200
+)
+
+var jobPageClient = newGuardedClient(jobPageTimeout)
+
+var errNoJobDescription = errors.New("no job description found on the page")
+
+// parseJobURL accepts an http(s) URL, adding https:// if the scheme was left
+// off, like parsePortfolioURL.
+func parseJobURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFetchURL(u); err != nil {
+		return nil, fmt.Errorf("unsupported job URL %q: %w", raw, err)
+	}
+	return u, nil
+}
+
+// fetchJobDescription downloads a job posting and returns its description
+// without the page's navigation, related jobs and other boilerplate.
+func fetchJobDescription(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "JobFit/1.0")
+	req.Header.Set("Accept", "text/html")
+	resp, err := jobPageClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("job page returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return "", fmt.Errorf("job page is %s, not HTML", ct)
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxJobPageBytes))
+	if err != nil {
+		return "", err
+	}
+	text := jobPostingText(doc)
+	if len(text) < minJobDescriptionLength {
+		return "", errNoJobDescription
+	}
+	return text, nil
+}
+
+// jobPostingText extracts the description from a parsed job page. Most job
+// boards embed a schema.org JobPosting for search engines, which is the
+// cleanest source; otherwise the page's main content is found the way
+// readability tools do it.
+func jobPostingText(doc *html.Node) string {
+	if text := structuredJobPosting(doc); len(text) >= minJobDescriptionLength {
+		return text
+	}
+	if n := mainContent(doc); n != nil {
+		return nodeText(n)
+	}
+	return ""
+}
+
+// structuredJobPosting returns the title, company and description of a
+// JobPosting in the page's JSON-LD, or "".
+func structuredJobPosting(doc *html.Node) string {
+	type jobPosting struct {
+		Type               any    `json:"@type"`
+		Title              string `json:"title"`
+		Description        string `json:"description"`
+		HiringOrganization struct {
+			Name string `json:"name"`
+		} `json:"hiringOrganization"`
+		Graph []json.RawMessage `json:"@graph"`
+	}
+	isJobPosting := func(p jobPosting) bool {
+		switch t := p.Type.(type) {
+		case string:
+			return t == "JobPosting"
+		case []any:
+			for _, v := range t {
+				if v == "JobPosting" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var found *jobPosting
+	var visit func(raw []byte)
+	visit = func(raw []byte) {
+		raw = bytes.TrimSpace(raw)
+		if found != nil || len(raw) == 0 {
+			return
+		}
+		if raw[0] == '[' {
+			var items []json.RawMessage
+			if json.Unmarshal(raw, &items) == nil {
+				for _, it := range items {
+					visit(it)
+				}
+			}
+			return
+		}
+		var p jobPosting
+		if json.Unmarshal(raw, &p) != nil {
+			return
+		}
+		if isJobPosting(p) && p.Description != "" {
+			found = &p
+			return
+		}
+		for _, g := range p.Graph {
+			visit(g)
+		}
+	}
+	walkNodes(doc, func(n *html.Node) {
+		if n.DataAtom == atom.Script && attr(n, "type") == "application/ld+json" && n.FirstChild != nil {
+			visit([]byte(n.FirstChild.Data))
+		}
+	})
+	if found == nil {
+		return ""
+	}
+	// The description is HTML, often entity-escaped inside the JSON.
+	description := html.UnescapeString(found.Description)
+	text := visibleText(strings.NewReader(description))
+	var header []string
+	if found.Title != "" {
+		header = append(header, found.Title)
+	}
+	if found.HiringOrganization.Name != "" {
+		header = append(header, found.HiringOrganization.Name)
+	}
+	if len(header) > 0 {
+		text = strings.Join(header, "\n") + "\n\n" + text
+	}
+	return text
+}
+
+// walkNodes calls visit for n and everything below it, in document order.
+func walkNodes(n *html.Node, visit func(*html.Node)) {
+	visit(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkNodes(c, visit)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(strings.ToLower(a.Val))
+		}
+	}
+	return ""
+}
+
+// boilerplateAtoms are elements whose contents are never the posting.
+var boilerplateAtoms = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true, atom.Header: true,
+	atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Svg: true, atom.Iframe: true,
+}
+
+// textLength returns how much text n holds and how much of it is link text,
+// ignoring boilerplate elements.
+func textLength(n *html.Node) (total, linked int) {
+	if n.Type == html.ElementNode && boilerplateAtoms[n.DataAtom] {
+		return 0, 0
+	}
+	if n.Type == html.TextNode {
+		l := len(strings.Join(strings.Fields(n.Data), " "))
+		return l, 0
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		t, l := textLength(c)
+		total += t
+		if n.DataAtom == atom.A {
+			l = t
+		}
+		linked += l
+	}
+	return total, linked
+}
+
+// mainContent picks the element holding the page's main text. Each paragraph
+// scores its parent by its length and its grandparent by half,
+// so the winner is the container with the most prose rather than the one with
+// the most text overall, which would be <body>. Link-heavy containers such as
+// lists of related jobs are discounted.
+func mainContent(doc *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	walkNodes(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode || (n.DataAtom != atom.P && n.DataAtom != atom.Li && n.DataAtom != atom.Pre) {
+			return
+		}
+		total, _ := textLength(n)
+		if total < 25 || n.Parent == nil {
+			return
+		}
+		scores[n.Parent] += float64(total)
+		if gp := n.Parent.Parent; gp != nil {
+			// A list is only a wrapper for its items, so the container
+			// holding it takes their full weight.
+			if n.DataAtom == atom.Li {
+				scores[gp] += float64(total)
+			} else {
+				scores[gp] += float64(total) / 2
+			}
+		}
+	})
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		if skipped(n) {
+			continue
+		}
+		total, linked := textLength(n)
+		if total > 0 {
+			score *= 1 - float64(linked)/float64(total)
+		}
+		if n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+			score *= 1.25
+		}
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// skipped reports whether n is inside a boilerplate element.
+func skipped(n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Type == html.ElementNode && boilerplateAtoms[n.DataAtom] {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeText renders n and returns its visible text one block per line.
+func nodeText(n *html.Node) string {
+	var b bytes.Buffer
+	if err := html.Render(&b, n); err != nil {
+		return ""
+	}
+	return visibleText(&b)
+}
+
+// resolveJobURL fills in req.JobDescription from req.JobURL when no text was
+// pasted. It returns an error message and status like checkAnalysisRequest.
+func resolveJobURL(ctx context.Context, req *AnalysisRequest) (string, int) {
+	if req.JobURL == "" || strings.TrimSpace(req.JobDescription) != "" {
+		return "", 0
+	}
+	u, err := parseJobURL(req.JobURL)
+	if err != nil {
+		return "Invalid job URL", http.StatusBadRequest
+	}
+	text, err := fetchJobDescription(ctx, u)
+	if errors.Is(err, errNoJobDescription) {
+		return "No job description was found at that URL; paste the text instead", http.StatusUnprocessableEntity
+	}
+	if err != nil {
+		return "Could not fetch the job URL; paste the text instead", http.StatusBadGateway
+	}
+	req.JobDescription = text
+	return "", 0
+}
@@ -0,0 +1,137 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxRoleNotesSize = 5000
+
+type jobPostRequest struct {
+	// Notes are the hiring manager's rough bullets about the role.
+	Notes   string `json:"notes"`
+	Title   string `json:"title,omitempty"`
+	Company string `json:"company,omitempty"`
+}
+
+// jobPost is a structured job description. Text holds the same content as
+// plain text, ready to pass to /chat as the jobDescription.
+type jobPost struct {
+	ID               string   `json:"id"`
+	Title            string   `json:"title"`
+	Company          string   `json:"company,omitempty"`
+	Summary          string   `json:"summary"`
+	Responsibilities []string `json:"responsibilities"`
+	Requirements     []string `json:"requirements"`
+	NiceToHaves      []string `json:"niceToHaves"`
+	Text             string   `json:"text"`
+}
+
+func (jp *jobPost) render() string {
+	var b strings.Builder
+	b.WriteString(jp.Title)
+	if jp.Company != "" {
+		b.WriteString(" at " + jp.Company)
+	}
+	b.WriteString("\n\n" + jp.Summary + "\n")
+	for _, section := range []struct {
+		heading string
+		items   []string
+	}{
+		{"Responsibilities", jp.Responsibilities},
+		{"Requirements", jp.Requirements},
+		{"Nice to have", jp.NiceToHaves},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+		b.WriteString("\n" + section.heading + "\n")
+		for _, item := range section.items {
+			b.WriteString("- " + item + "\n")
+		}
+	}
+	return b.String()
+}
+
+// generateJobPostHandler turns a hiring manager's notes into a job
+// description that candidates can then be scored against.
+func (app *application) generateJobPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req jobPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Notes) == "" {
+		http.Error(w, "Role notes are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Notes) > maxRoleNotesSize {
+		http.Error(w, "Role notes are too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if _, ok := app.chargeQuota(w, r, actionJobPost); !ok {
+		return
+	}
+
+	postID := newULID(time.Now())
+	log := app.logger.With("jobPostID", postID, "ip", ip)
+
+	var known strings.Builder
+	if req.Title != "" {
+		fmt.Fprintf(&known, "The job title is %q.\n", req.Title)
+	}
+	if req.Company != "" {
+		fmt.Fprintf(&known, "The company is %q.\n", req.Company)
+	}
+	prompt := fmt.Sprintf(`
+		Write a job description from the hiring manager's notes below.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "title": a string with the job title.
+		- "company": a string with the company name, or an empty string if the notes don't say.
+		- "summary": a string of two or three sentences describing the role.
+		- "responsibilities": a JSON array of strings, one responsibility each.
+		- "requirements": a JSON array of strings, each a must-have skill or qualification.
+		- "niceToHaves": a JSON array of strings, each a skill or qualification that helps but isn't required.
+		Keep requirements to what the notes actually call for; put anything optional under niceToHaves.
+		Write plainly and inclusively, without inflated adjectives. Do not use markdown.
+		%s
+		**Notes:**
+		---
+		%s
+		---
+	`, known.String(), req.Notes)
+
+	var post jobPost
+	if err := app.generateJSON(ctx, log, prompt, &post); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	post.ID = postID
+	if req.Title != "" {
+		post.Title = req.Title
+	}
+	if req.Company != "" {
+		post.Company = req.Company
+	}
+	for _, list := range []*[]string{&post.Responsibilities, &post.Requirements, &post.NiceToHaves} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	post.Text = post.render()
+	log.Info("generated job post", "title", post.Title)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(post); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Widget sites and integrations score many candidates against the same job
+// posting. Rather than send the whole posting with every resume, its
+// requirements are extracted once, cached by the posting's content, and the
+// much shorter summary goes in each candidate's prompt. Every candidate is
+// then measured against the same requirements and the same skill list.
+const jobRequirementsTTL = 14 * 24 * time.Hour
+
+// jobRequirementsFlight makes concurrent analyses of a posting that isn't
+// cached yet wait for one extraction instead of each running their own.
+var jobRequirementsFlight singleflight.Group
+
+// jobRequirements is what the analysis needs to know about a job posting.
+type jobRequirements struct {
+	JobTitle         string   `json:"jobTitle"`
+	Company          string   `json:"company"`
+	Seniority        string   `json:"seniority"`
+	RequiredSkills   []string `json:"requiredSkills"`
+	MustHave         []string `json:"mustHave"`
+	NiceToHave       []string `json:"niceToHave"`
+	Responsibilities []string `json:"responsibilities"`
+}
+
+// jobRequirementsKey is keyed by a hash of the posting, so reposting the same
+// text with different spacing hits the same entry.
+func jobRequirementsKey(jobDescription string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(jobDescription), " ")))
+	return rkey("jobreqs", hex.EncodeToString(sum[:]))
+}
+
+const jobRequirementsPrompt = `
+		Extract the requirements from the following job description.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "jobTitle": a string with the job title.
+		- "company": a string with the hiring company's name, or an empty string if it is not mentioned.
+		- "seniority": a string such as "junior", "mid", "senior" or "lead", or an empty string if unclear.
+		- "requiredSkills": a JSON array of strings naming the skills, tools and technologies asked for, each as a short common name such as "Kubernetes" or "SQL".
+		- "mustHave": a JSON array of strings, each a requirement stated as essential, including years of experience, qualifications and domain knowledge.
+		- "niceToHave": a JSON array of strings, each a requirement stated as optional or preferred.
+		- "responsibilities": a JSON array of strings summarising the main duties of the role.
+
+		**Job Description:**
+		---
+		%s
+		---
+	`
+
+// loadJobRequirements returns the requirements of a job posting, extracting
+// and caching them if no analysis has seen the posting recently.
+func (app *application) loadJobRequirements(ctx context.Context, log *slog.Logger, jobDescription string) (*jobRequirements, error) {
+	key := jobRequirementsKey(jobDescription)
+	if data, err := app.rdb.Get(ctx, key).Bytes(); err == nil {
+		var jr jobRequirements
+		if err := json.Unmarshal(data, &jr); err == nil {
+			return &jr, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	v, err, _ := jobRequirementsFlight.Do(key, func() (any, error) {
+		// Others may be waiting on this, so it isn't cut short if the
+		// request that started it goes away.
+		ctx := context.WithoutCancel(ctx)
+		var jr jobRequirements
+		if err := app.generateJSON(ctx, log, fmt.Sprintf(jobRequirementsPrompt, jobDescription), &jr); err != nil {
+			return nil, err
+		}
+		if data, err := json.Marshal(&jr); err == nil {
+			app.rdb.Set(ctx, key, data, jobRequirementsTTL)
+		}
+		return &jr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*jobRequirements), nil
+}
+
+// promptText stands in for the job description in the analysis prompt.
+func (jr *jobRequirements) promptText() string {
+	var b strings.Builder
+	b.WriteString("(Summary of the job posting's requirements.)\n")
+	fmt.Fprintf(&b, "Job title: %s\n", jr.JobTitle)
+	if jr.Company != "" {
+		fmt.Fprintf(&b, "Company: %s\n", jr.Company)
+	}
+	if jr.Seniority != "" {
+		fmt.Fprintf(&b, "Seniority: %s\n", jr.Seniority)
+	}
+	fmt.Fprintf(&b, "Skills: %s\n", strings.Join(jr.RequiredSkills, ", "))
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"Must have", jr.MustHave},
+		{"Nice to have", jr.NiceToHave},
+		{"Responsibilities", jr.Responsibilities},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", section.title)
+		for _, item := range section.items {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,97 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// repairJSON fixes the mistakes models most often make in a JSON object: text
+// around it, trailing commas, raw line breaks inside strings and, when the
+// reply was cut off, unclosed strings, arrays and objects. It doesn't check
+// that the result is valid; anything it can't fix is left for the decoder to
+// report.
+func repairJSON(s string) string {
+	start := strings.Index(s, "{")
+	if start < 0 {
+		return s
+	}
+	var b strings.Builder
+	var closers []byte
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\n':
+				b.WriteString(`\n`)
+				continue
+			case c == '\r':
+				continue
+			case c == '\t':
+				b.WriteString(`\t`)
+				continue
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				continue // a stray closer
+			}
+			closers = closers[:len(closers)-1]
+			b.WriteByte(c)
+			if len(closers) == 0 {
+				return b.String() // drop whatever follows the object
+			}
+			continue
+		case ',':
+			if next := strings.TrimLeft(s[i+1:], " \t\r\n"); next == "" || next[0] == '}' || next[0] == ']' {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	// The reply was cut off: close what's open.
+	if inString {
+		if escaped {
+			b.WriteByte('\\')
+		}
+		b.WriteByte('"')
+	}
+	out := strings.TrimRight(b.String(), " \t\r\n,")
+	for i := len(closers) - 1; i >= 0; i-- {
+		out += string(closers[i])
+	}
+	return out
+}
+
+// correctionPrompt asks the model again for a reply it got wrong, saying
+// what was wrong with it, and the shape expected if there is a schema.
+func correctionPrompt(prompt string, err error, schema any) string {
+	p := prompt + fmt.Sprintf(`
+
+		**Your previous reply could not be used:** %v.
+		Return only valid JSON: a single object with exactly the keys and value types described above, with no text, comments or markdown around it.
+	`, err)
+	if schema != nil {
+		if data, err := json.Marshal(schema); err == nil {
+			p += fmt.Sprintf("\t\tThe object must match this JSON schema: %s\n", data)
+		}
+	}
+	return p
+}
@@ -0,0 +1,192 @@
+package jobfit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	kafkaSinkGroup   = "kafka-sink"
+	kafkaBatchSize   = 100
+	kafkaBlock       = 5 * time.Second
+	kafkaRetryDelay  = 10 * time.Second
+	kafkaClaimIdle   = time.Minute
+	kafkaSendTimeout = 15 * time.Second
+)
+
+// kafkaEventSchema is the Avro schema records are sent with in avro format.
+// data stays a JSON string since its shape depends on the event type.
+const kafkaEventSchema = `{
+	"type": "record",
+	"name": "LifecycleEvent",
+	"namespace": "ai.jobfit.events",
+	"fields": [
+		{"name": "type", "type": "string"},
+		{"name": "id", "type": "string"},
+		{"name": "time", "type": "string"},
+		{"name": "data", "type": "string"}
+	]
+}`
+
+// A kafkaSink forwards the event stream to a Kafka topic through a Confluent
+// REST Proxy. It reads the stream as a consumer group and only acknowledges
+// entries once the proxy has accepted them, so every event reaches Kafka at
+// least once, even across restarts.
+type kafkaSink struct {
+	rdb      *redis.Client
+	client   *http.Client
+	logger   *slog.Logger
+	endpoint string // the proxy's URL for the topic
+	format   string // "json" or "avro"
+	consumer string
+}
+
+// newKafkaSinkFromEnv configures the sink from KAFKA_REST_URL, KAFKA_TOPIC and
+// KAFKA_FORMAT. It returns nil if KAFKA_REST_URL is unset. Credentials for the
+// proxy, if it needs them, go in the URL as user:password.
+func newKafkaSinkFromEnv(rdb *redis.Client, logger *slog.Logger) (*kafkaSink, error) {
+	base := os.Getenv("KAFKA_REST_URL")
+	if base == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("KAFKA_REST_URL must be an http(s) URL")
+	}
+	format := cmp.Or(os.Getenv("KAFKA_FORMAT"), "json")
+	if format != "json" && format != "avro" {
+		return nil, fmt.Errorf("KAFKA_FORMAT must be json or avro, got %q", format)
+	}
+	topic := cmp.Or(os.Getenv("KAFKA_TOPIC"), "jobfit.events")
+	host, _ := os.Hostname()
+	return &kafkaSink{
+		rdb:      rdb,
+		client:   &http.Client{Timeout: kafkaSendTimeout},
+		logger:   logger.With("component", "kafka-sink", "topic", topic),
+		endpoint: strings.TrimSuffix(u.String(), "/") + "/topics/" + url.PathEscape(topic),
+		format:   format,
+		consumer: cmp.Or(host, "api"),
+	}, nil
+}
+
+// run forwards events until ctx is cancelled, starting with whatever the
+// stream still holds the first time it runs. Entries another replica read but
+// never acknowledged are claimed after a minute and sent again.
+func (s *kafkaSink) run(ctx context.Context) {
+	err := s.rdb.XGroupCreateMkStream(ctx, eventStreamKey(), kafkaSinkGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		s.logger.Error("failed to create consumer group", "error", err)
+		return
+	}
+	s.logger.Info("forwarding events to kafka", "format", s.format)
+
+	for ctx.Err() == nil {
+		msgs, err := s.next(ctx)
+		if err == nil && len(msgs) > 0 {
+			err = s.forward(ctx, msgs)
+		}
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("failed to forward events", "count", len(msgs), "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(kafkaRetryDelay):
+			}
+		}
+	}
+}
+
+// next returns stale entries abandoned by other consumers if there are any,
+// otherwise waits for new ones.
+func (s *kafkaSink) next(ctx context.Context) ([]redis.XMessage, error) {
+	claimed, _, err := s.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream: eventStreamKey(), Group: kafkaSinkGroup, Consumer: s.consumer,
+		MinIdle: kafkaClaimIdle, Start: "0-0", Count: kafkaBatchSize,
+	}).Result()
+	if err != nil || len(claimed) > 0 {
+		return claimed, err
+	}
+	streams, err := s.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: kafkaSinkGroup, Consumer: s.consumer,
+		Streams: []string{eventStreamKey(), ">"}, Count: kafkaBatchSize, Block: kafkaBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return streams[0].Messages, nil
+}
+
+// kafkaRecord is one record in a REST Proxy v2 produce request.
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func (s *kafkaSink) forward(ctx context.Context, msgs []redis.XMessage) error {
+	records := make([]kafkaRecord, 0, len(msgs))
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+		field := func(name string) string {
+			v, _ := m.Values[name].(string)
+			return v
+		}
+		data := field("data")
+		if !json.Valid([]byte(data)) {
+			s.logger.Warn("skipping malformed event", "streamID", m.ID)
+			continue
+		}
+		value := map[string]any{"type": field("type"), "id": field("id"), "time": field("time")}
+		if s.format == "avro" {
+			value["data"] = data
+		} else {
+			value["data"] = json.RawMessage(data)
+		}
+		records = append(records, kafkaRecord{Key: field("id"), Value: value})
+	}
+
+	if len(records) > 0 {
+		body := map[string]any{"records": records}
+		contentType := "application/vnd.kafka.json.v2+json"
+		if s.format == "avro" {
+			body["value_schema"] = kafkaEventSchema
+			body["key_schema"] = `"string"`
+			contentType = "application/vnd.kafka.avro.v2+json"
+		}
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("rest proxy returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+		}
+	}
+	return s.rdb.XAck(ctx, eventStreamKey(), kafkaSinkGroup, ids...).Err()
+}
@@ -0,0 +1,144 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Every Redis key is named arm:{env}:{purpose}:{id}, so several environments
+// (and anything else sharing the Redis) can't collide. keyNamespace holds the
+// arm:{env}: part; it's set once at startup, before any key is built.
+var keyNamespace = "arm:dev:"
+
+var appEnvPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// configureKeyNamespace sets the namespace from APP_ENV, "dev" by default.
+func configureKeyNamespace() error {
+	env := strings.ToLower(cmp.Or(os.Getenv("APP_ENV"), "dev"))
+	if !appEnvPattern.MatchString(env) {
+		return fmt.Errorf("APP_ENV must be lowercase letters, digits and dashes, got %q", env)
+	}
+	keyNamespace = "arm:" + env + ":"
+	return nil
+}
+
+// rkey builds the key for purpose, followed by any id parts.
+func rkey(purpose string, id ...string) string {
+	return keyNamespace + strings.Join(append([]string{purpose}, id...), ":")
+}
+
+// unnamespaced strips the namespace from a key, leaving {purpose}:{id}.
+func unnamespaced(key string) string {
+	return strings.TrimPrefix(key, keyNamespace)
+}
+
+// quotaKey is the daily counter for a quota bucket, which is an IPv4
+// address, an IPv6 network or "client:" and a client ID.
+func quotaKey(bucket string) string {
+	return rkey("quota", bucket)
+}
+
+// legacyKeyPurposes are the first segments of keys written before keys were
+// namespaced. Those keys move to the namespace unchanged; bare IP addresses
+// and client: keys were quota counters and move under quota:.
+var legacyKeyPurposes = []string{
+	"analysis", "announcements", "bonus", "coupon", "coupons", "digest", "events",
+	"github", "history", "integration", "integrations", "lock", "notify", "queue",
+	"referral", "resume", "resumes", "stats", "webhook", "widget",
+}
+
+// legacyKeyTarget returns the namespaced name for a key written before keys
+// were namespaced, or false if the key isn't one of ours.
+func legacyKeyTarget(key string) (string, bool) {
+	if strings.HasPrefix(key, "arm:") {
+		return "", false
+	}
+	if _, err := netip.ParseAddr(key); err == nil || strings.HasPrefix(key, "client:") {
+		return quotaKey(key), true
+	}
+	purpose, _, _ := strings.Cut(key, ":")
+	if !slices.Contains(legacyKeyPurposes, purpose) {
+		return "", false
+	}
+	return keyNamespace + key, true
+}
+
+// keyMigration counts what migrateLegacyKeys did.
+type keyMigration struct {
+	Scanned int `json:"scanned"`
+	Moved   int `json:"moved"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// migrateLegacyKeys moves keys written before namespacing into this
+// environment's namespace, keeping their expiry. Run it once, from the
+// environment that owns the existing data; it's safe to repeat. Keys whose
+// new name is already taken are left where they are and counted as skipped.
+// It returns errLockHeld if a migration is already running.
+func (app *application) migrateLegacyKeys(ctx context.Context) (keyMigration, error) {
+	var result keyMigration
+	l, err := app.acquireLock(ctx, "migrate-keys", 30*time.Minute)
+	if err != nil {
+		return result, err
+	}
+	defer l.release(context.WithoutCancel(ctx))
+
+	iter := app.rdb.Scan(ctx, 0, "*", 500).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		result.Scanned++
+		target, ok := legacyKeyTarget(key)
+		if !ok {
+			continue
+		}
+		moved, err := app.rdb.RenameNX(ctx, key, target).Result()
+		switch {
+		case errors.Is(err, redis.Nil) || (err != nil && strings.Contains(err.Error(), "no such key")):
+			// Expired since the scan.
+		case err != nil:
+			app.logger.Error("failed to migrate key", "key", key, "error", err)
+			result.Failed++
+		case moved:
+			result.Moved++
+		default:
+			app.logger.Warn("namespaced key already exists, leaving legacy key", "key", key, "target", target)
+			result.Skipped++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return result, err
+	}
+	app.logger.Info("migrated legacy keys", "scanned", result.Scanned, "moved", result.Moved, "skipped", result.Skipped, "failed", result.Failed)
+	return result, nil
+}
+
+// adminMigrateKeysHandler runs migrateLegacyKeys. The same migration can be
+// run from the command line with "jobfit migrate".
+func (app *application) adminMigrateKeysHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := app.migrateLegacyKeys(r.Context())
+	if errors.Is(err, errLockHeld) {
+		http.Error(w, "Key migration is already running", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to migrate keys", "error", err)
+		http.Error(w, "Could not migrate keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
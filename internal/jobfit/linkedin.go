@@ -0,0 +1,228 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxLinkedInInputSize = 50000
+	// maxLinkedInAbout is LinkedIn's limit on the About section, and
+	// linkedInAboutPreview roughly how much of it shows before "see more".
+	maxLinkedInAbout     = 2600
+	linkedInAboutPreview = 265
+	// LinkedIn features a profile's top skills; recruiters see those first.
+	linkedInTopSkills = 5
+)
+
+// linkedinRequest is a LinkedIn profile pasted section by section. Any
+// section may be left out, but not all of them.
+type linkedinRequest struct {
+	Headline       string   `json:"headline"`
+	About          string   `json:"about"`
+	Experience     string   `json:"experience"`
+	Skills         []string `json:"skills"`
+	JobDescription string   `json:"jobDescription"`
+}
+
+// linkedinReview is advice for making a profile turn up in recruiter searches
+// for the job, which rank on keywords across the headline, About, experience
+// and skills rather than reading the profile like a resume.
+type linkedinReview struct {
+	ID    string `json:"id"`
+	Score int    `json:"score"`
+	// Keywords are the job's skills found anywhere on the profile, and
+	// MissingKeywords those a recruiter searching for it wouldn't match.
+	Keywords        []string `json:"keywords"`
+	MissingKeywords []string `json:"missingKeywords"`
+	// SkillsOrder is the profile's skills reordered so the job's come first;
+	// the first few are the ones to feature.
+	SkillsOrder []string `json:"skillsOrder"`
+	// Issues are rule-based problems with lengths, the headline's title and
+	// the About preview.
+	Issues []string `json:"issues"`
+	// Headline and AboutOpening are suggested rewrites.
+	Headline     string   `json:"headline"`
+	AboutOpening string   `json:"aboutOpening"`
+	Advice       []string `json:"advice"`
+}
+
+// mentionsSkill reports whether every word of skill is among words, so "Go"
+// doesn't match "Google" but "Kubernetes" matches "Kubernetes (EKS)".
+func mentionsSkill(words []string, skill string) bool {
+	terms := searchTerms(skill)
+	return len(terms) > 0 && !slices.ContainsFunc(terms, func(t string) bool { return !slices.Contains(words, t) })
+}
+
+// orderSkills puts the job's must-haves first, then its other required and
+// nice-to-have skills, each group in the job's order, then the rest as the
+// profile had them.
+func orderSkills(skills []string, jr *jobRequirements) []string {
+	ordered := make([]string, 0, len(skills))
+	for _, group := range [][]string{jr.MustHave, jr.RequiredSkills, jr.NiceToHave} {
+		for _, want := range group {
+			for _, s := range skills {
+				if !slices.Contains(ordered, s) && mentionsSkill(searchTerms(s), want) {
+					ordered = append(ordered, s)
+				}
+			}
+		}
+	}
+	for _, s := range skills {
+		if !slices.Contains(ordered, s) {
+			ordered = append(ordered, s)
+		}
+	}
+	return ordered
+}
+
+// reviewLinkedInProfile fills in the rule-based parts of review: keyword
+// coverage, skill order and length and title problems.
+func reviewLinkedInProfile(review *linkedinReview, req *linkedinRequest, jr *jobRequirements) {
+	words := searchTerms(strings.Join([]string{req.Headline, req.About, req.Experience, strings.Join(req.Skills, " ")}, " "))
+	review.Keywords, review.MissingKeywords, review.Issues = []string{}, []string{}, []string{}
+	for _, skill := range append(slices.Clone(jr.MustHave), jr.RequiredSkills...) {
+		if slices.Contains(review.Keywords, skill) || slices.Contains(review.MissingKeywords, skill) {
+			continue
+		}
+		if mentionsSkill(words, skill) {
+			review.Keywords = append(review.Keywords, skill)
+		} else {
+			review.MissingKeywords = append(review.MissingKeywords, skill)
+		}
+	}
+	review.SkillsOrder = orderSkills(req.Skills, jr)
+
+	if n := utf8.RuneCountInString(req.Headline); n > maxLinkedInHeadline {
+		review.Issues = append(review.Issues, fmt.Sprintf("The headline is %d characters; LinkedIn cuts it off at %d.", n, maxLinkedInHeadline))
+	}
+	if req.Headline != "" {
+		title := headlineAdvice{CurrentHeadline: req.Headline, TargetTitle: jr.JobTitle}
+		checkTitleAlignment(&title)
+		review.Issues = append(review.Issues, title.Issues...)
+	} else {
+		review.Issues = append(review.Issues, "There's no headline; it's the most heavily weighted field in recruiter search.")
+	}
+	switch n := utf8.RuneCountInString(req.About); {
+	case n == 0:
+		review.Issues = append(review.Issues, "There's no About section; add one so the profile has room for the job's keywords.")
+	case n > maxLinkedInAbout:
+		review.Issues = append(review.Issues, fmt.Sprintf("The About section is %d characters; LinkedIn allows %d.", n, maxLinkedInAbout))
+	default:
+		preview := string([]rune(req.About)[:min(n, linkedInAboutPreview)])
+		if len(review.Keywords) > 0 && !slices.ContainsFunc(review.Keywords, func(k string) bool { return mentionsSkill(searchTerms(preview), k) }) {
+			review.Issues = append(review.Issues, "The first lines of the About section, shown before \"see more\", don't mention any of the job's skills.")
+		}
+	}
+	if len(req.Skills) > 0 {
+		for i, s := range review.SkillsOrder[:min(len(review.SkillsOrder), linkedInTopSkills)] {
+			if s != req.Skills[i] {
+				review.Issues = append(review.Issues, fmt.Sprintf("Reorder your skills so the job's come first and feature %s.", strings.Join(review.SkillsOrder[:min(len(review.SkillsOrder), linkedInTopSkills)], ", ")))
+				break
+			}
+		}
+	}
+}
+
+// linkedinReviewHandler reviews a LinkedIn profile against a job with
+// LinkedIn-specific advice. Resumes are read top to bottom by a person or an
+// ATS, but profiles are found through recruiter keyword search first, so the
+// advice is about where keywords sit and how skills are ordered.
+func (app *application) linkedinReviewHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req linkedinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	profile := strings.Join([]string{req.Headline, req.About, req.Experience, strings.Join(req.Skills, "\n")}, "\n")
+	if strings.TrimSpace(profile) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		http.Error(w, "A profile and a job description are required", http.StatusBadRequest)
+		return
+	}
+	if len(profile) > maxLinkedInInputSize || len(req.JobDescription) > maxLinkedInInputSize {
+		http.Error(w, "Profile or job description is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionLinkedIn); !ok {
+		return
+	}
+
+	review := linkedinReview{ID: newULID(time.Now())}
+	log := app.logger.With("linkedinID", review.ID, "ip", ip)
+	jr, err := app.loadJobRequirements(ctx, log, req.JobDescription)
+	if err != nil {
+		modelError(w, log, err)
+		return
+	}
+	reviewLinkedInProfile(&review, &req, jr)
+
+	prompt := fmt.Sprintf(`
+		Review the candidate's LinkedIn profile for the job below. Give LinkedIn-specific advice, not resume advice: recruiters find profiles by keyword search over the headline, About, experience and skills, and see only the headline and the first lines of About before clicking.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "score": an integer from 0 to 100 for how likely the profile is to come up in, and hold up to, a recruiter search for this job.
+		- "headline": a string, a rewritten headline of at most %d characters using the job title's wording and the candidate's strongest relevant skills.
+		- "aboutOpening": a string, a rewritten first two sentences of About (at most %d characters) that work before "see more".
+		- "advice": a JSON array of strings, up to six specific changes, such as which missing keywords to add to which section, experience entries to expand, and skills to add or endorse.
+		Only suggest keywords and claims the profile supports; don't invent experience.
+
+		**Target job title:** %s
+		**Job's keywords missing from the profile:** %s
+
+		**Headline:** %s
+		**About:**
+		---
+		%s
+		---
+		**Experience:**
+		---
+		%s
+		---
+		**Skills:** %s
+
+		**Job Description:**
+		---
+		%s
+		---
+	`, maxLinkedInHeadline, linkedInAboutPreview, jr.JobTitle, strings.Join(review.MissingKeywords, ", "),
+		req.Headline, req.About, req.Experience, strings.Join(req.Skills, ", "), req.JobDescription)
+
+	var out struct {
+		Score        int      `json:"score"`
+		Headline     string   `json:"headline"`
+		AboutOpening string   `json:"aboutOpening"`
+		Advice       []string `json:"advice"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	review.Score = max(0, min(out.Score, 100))
+	review.Headline, review.AboutOpening = out.Headline, out.AboutOpening
+	if r := []rune(review.Headline); len(r) > maxLinkedInHeadline {
+		review.Headline = strings.TrimSpace(string(r[:maxLinkedInHeadline]))
+	}
+	review.Advice = out.Advice
+	if review.Advice == nil {
+		review.Advice = []string{}
+	}
+	log.Info("reviewed linkedin profile", "score", review.Score, "missingKeywords", len(review.MissingKeywords))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,121 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxCheckedLinks  = 10
+	linkCheckWorkers = 4
+	linkCheckTimeout = 5 * time.Second
+)
+
+var linkClient = newGuardedClient(linkCheckTimeout)
+
+// deadLink is a URL on the resume that didn't resolve.
+type deadLink struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// resumeLinks returns the distinct URLs listed on a resume, with a scheme
+// added where the resume left it off.
+func resumeLinks(text string) []string {
+	var links []string
+	seen := map[string]bool{}
+	for _, match := range append(linkedInRegex.FindAllString(text, -1), urlRegex.FindAllString(text, -1)...) {
+		link := strings.TrimRight(match, ".)")
+		if !strings.HasPrefix(strings.ToLower(link), "http") {
+			link = "https://" + link
+		}
+		key := strings.TrimSuffix(strings.ToLower(link), "/")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		links = append(links, link)
+		if len(links) == maxCheckedLinks {
+			break
+		}
+	}
+	return links
+}
+
+// checkLink reports why url is dead, or returns nil if it's reachable. Sites
+// that refuse HEAD are retried with GET.
+func checkLink(ctx context.Context, url string) *deadLink {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return &deadLink{URL: url, Reason: "is not a valid URL"}
+		}
+		req.Header.Set("User-Agent", "JobFitLinkChecker/1.0")
+		resp, err := linkClient.Do(req)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return &deadLink{URL: url, Reason: "timed out"}
+			}
+			return &deadLink{URL: url, Reason: "could not be reached"}
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	switch {
+	case status < 400:
+		return nil
+	case status == 404 || status == 410:
+		return &deadLink{URL: url, Status: status, Reason: "page not found"}
+	case status >= 500:
+		return &deadLink{URL: url, Status: status, Reason: "server error"}
+	default:
+		// 401/403/429 and LinkedIn's 999 mean the site blocks automated
+		// requests, not that the link is broken.
+		return nil
+	}
+}
+
+// checkLinks checks the resume's links concurrently and returns the dead ones
+// in the order they appear.
+func checkLinks(ctx context.Context, links []string) []deadLink {
+	results := make([]*deadLink, len(links))
+	sem := make(chan struct{}, linkCheckWorkers)
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = checkLink(ctx, link)
+		}()
+	}
+	wg.Wait()
+
+	dead := []deadLink{}
+	for _, d := range results {
+		if d != nil {
+			dead = append(dead, *d)
+		}
+	}
+	return dead
+}
+
+func (d deadLink) message() string {
+	if d.Status != 0 {
+		return fmt.Sprintf("%s returned %d (%s).", d.URL, d.Status, d.Reason)
+	}
+	return fmt.Sprintf("%s %s.", d.URL, d.Reason)
+}
@@ -0,0 +1,109 @@
+package jobfit
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemd passes socket-activated listeners from file descriptor 3 on.
+const systemdFirstFD = 3
+
+const defaultSocketMode = 0o660
+
+// apiListener opens where the API server accepts connections, in order of
+// preference:
+//
+//   - a socket systemd passed in (LISTEN_PID and LISTEN_FDS, see
+//     sd_listen_fds(3)), so the unit's .socket owns the address;
+//   - LISTEN_ADDR, either a TCP address such as 127.0.0.1:8080 or
+//     unix:/path/to/socket for running behind nginx on a shared host;
+//   - TCP on PORT, 8080 by default.
+//
+// It also returns a description of the address for the logs.
+func apiListener() (net.Listener, string, error) {
+	if l, err := systemdListener(); l != nil || err != nil {
+		return l, "systemd socket", err
+	}
+
+	addr := os.Getenv("LISTEN_ADDR")
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		l, err := unixListener(path)
+		return l, addr, err
+	}
+	if addr == "" {
+		addr = ":" + cmp.Or(os.Getenv("PORT"), "8080")
+	}
+	l, err := net.Listen("tcp", addr)
+	return l, addr, err
+}
+
+// systemdListener returns the first socket systemd passed this process, or
+// nil if it wasn't socket activated.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("LISTEN_FDS must be a positive number, got %q", os.Getenv("LISTEN_FDS"))
+	}
+	if n > 1 {
+		return nil, fmt.Errorf("systemd passed %d sockets; only one is supported", n)
+	}
+	// Child processes mustn't think the socket was meant for them.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// unixListener listens on a Unix socket at path, readable and writable by
+// SOCKET_MODE (0660 by default) so a proxy in the same group can connect. A
+// socket file left behind by a previous run is replaced, but one that another
+// process is still serving on is not.
+func unixListener(path string) (net.Listener, error) {
+	mode := fs.FileMode(defaultSocketMode)
+	if v := os.Getenv("SOCKET_MODE"); v != "" {
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || m > 0o777 {
+			return nil, fmt.Errorf("SOCKET_MODE must be octal permissions such as 0660, got %q", v)
+		}
+		mode = fs.FileMode(m)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and isn't a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
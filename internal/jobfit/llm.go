@@ -0,0 +1,210 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const modelTimeout = 30 * time.Second
+
+var (
+	errModelBlocked = errors.New("response blocked by the safety filter")
+	errModelEmpty   = errors.New("empty response from the model")
+)
+
+// A languageModel answers a prompt with the model's raw reply. It's the only
+// thing handlers need from the model vendor, so they deal in strings and the
+// errors above rather than vendor types. The Gemini key pool in modelkeys.go,
+// openai.go, anthropic.go and ollama.go implement it, the chain in
+// modelchain.go combines them, and a canned fake can stand in for them.
+type languageModel interface {
+	// generate returns the reply text, errModelBlocked if the vendor's
+	// safety filter stopped it, or errModelEmpty if there was none.
+	generate(ctx context.Context, prompt string) (string, error)
+	// provider names where calls go, for logs and the health check.
+	provider() string
+	close()
+}
+
+// A slowModel needs longer than modelTimeout to reply.
+type slowModel interface {
+	timeout() time.Duration
+}
+
+// newLanguageModel connects to the vendor named by LLM_PROVIDER: "gemini",
+// the default, "vertex" for Gemini through Vertex AI only, "openai",
+// "azure-openai", "anthropic" or "ollama". A local Ollama server is
+// the one choice besides Vertex AI that EU residency allows, since resumes
+// stay wherever it runs. LLM_FALLBACK_CHAIN takes precedence and lists
+// several to try in turn.
+func newLanguageModel(ctx context.Context, logger *slog.Logger, residency string) (languageModel, error) {
+	if spec := os.Getenv("LLM_FALLBACK_CHAIN"); spec != "" {
+		return newModelChain(ctx, logger, residency, spec)
+	}
+	return newProviderModel(ctx, logger, residency, os.Getenv("LLM_PROVIDER"), "")
+}
+
+// newProviderModel connects to one vendor. A non-empty model is used for
+// every tier in place of the vendor's configured models.
+func newProviderModel(ctx context.Context, logger *slog.Logger, residency, provider, model string) (languageModel, error) {
+	tiers := modelTiers
+	var pinned map[string]string
+	if model != "" {
+		pinned = map[string]string{}
+		for tier := range modelTiers {
+			pinned[tier] = model
+		}
+		tiers = pinned
+	}
+	switch provider {
+	case "", "gemini", "vertex":
+		return newModelPool(ctx, logger, residency, provider == "vertex", tiers)
+	case "ollama":
+		m, err := newOllamaModel()
+		if err == nil && pinned != nil {
+			m.models = pinned
+		}
+		return m, err
+	case "openai", "azure-openai", "anthropic":
+		if residency == residencyEU {
+			return nil, fmt.Errorf("DATA_RESIDENCY=eu can't use LLM_PROVIDER=%s: only Vertex AI in an EU region is allowed", provider)
+		}
+	default:
+		return nil, fmt.Errorf("LLM_PROVIDER must be gemini, vertex, openai, azure-openai, anthropic or ollama, got %q", provider)
+	}
+	if provider == "anthropic" {
+		m, err := newAnthropicModel()
+		if err == nil && pinned != nil {
+			m.models = pinned
+		}
+		return m, err
+	}
+	newModel := newOpenAIModel
+	if provider == "azure-openai" {
+		newModel = newAzureOpenAIModel
+	}
+	m, err := newModel()
+	if err == nil && pinned != nil {
+		m.models = pinned
+	}
+	return m, err
+}
+
+// modelJSONError is returned when the model's reply isn't the JSON we asked for.
+type modelJSONError struct {
+	err error
+	// size is the reply's length in bytes. The reply itself isn't kept,
+	// since it's made from the resume.
+	size int
+}
+
+func (e *modelJSONError) Error() string { return "invalid json from model: " + e.err.Error() }
+func (e *modelJSONError) Unwrap() error { return e.err }
+
+// cleanModelJSON strips the markdown code fence models like to wrap JSON in.
+// Gemini is asked for JSON output and doesn't add one, but other providers'
+// models may.
+func cleanModelJSON(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
+
+// generateJSON sends prompt to the model and decodes its JSON reply into v.
+// A reply that doesn't decode is repaired if it can be, and otherwise asked
+// for again once, saying what was wrong with it.
+func (app *application) generateJSON(ctx context.Context, log *slog.Logger, prompt string, v any) error {
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("generateJSON needs a non-nil pointer to decode into, got %T", v)
+	}
+	err := app.tryGenerateJSON(ctx, log, prompt, v)
+	var jsonErr *modelJSONError
+	if !errors.As(err, &jsonErr) {
+		return err
+	}
+	log.Warn("model reply wasn't valid json, asking again", "error", jsonErr.err)
+	var schema any
+	if s := responseSchema(ctx); s != nil {
+		schema = vertexSchema(s)
+	}
+	reflect.ValueOf(v).Elem().SetZero()
+	return app.tryGenerateJSON(ctx, log, correctionPrompt(prompt, jsonErr.err, schema), v)
+}
+
+func (app *application) tryGenerateJSON(ctx context.Context, log *slog.Logger, prompt string, v any) error {
+	timeout := modelTimeout
+	if slow, ok := app.models.(slowModel); ok {
+		timeout = slow.timeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	reply, err := app.models.generate(ctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	// Replies are made from the resume, so only their size is logged.
+	cleaned := cleanModelJSON(reply)
+	log.Debug("model replied", "provider", app.models.provider(), "bytes", len(cleaned))
+
+	err = json.Unmarshal([]byte(cleaned), v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		if repaired := repairJSON(cleaned); json.Unmarshal([]byte(repaired), v) == nil {
+			log.Warn("repaired invalid json from the model", "error", err)
+			return nil
+		}
+	}
+	if err != nil {
+		return &modelJSONError{err: err, size: len(cleaned)}
+	}
+	return nil
+}
+
+// modelError logs a generateJSON failure and writes the matching HTTP error.
+func modelError(w http.ResponseWriter, log *slog.Logger, err error) {
+	logModelError(log, err)
+	msg, status := modelErrorMessage(err)
+	http.Error(w, msg, status)
+}
+
+func logModelError(log *slog.Logger, err error) {
+	var jsonErr *modelJSONError
+	switch {
+	case errors.Is(err, errModelBlocked):
+		log.Warn("model response blocked by safety filter")
+	case errors.Is(err, errModelEmpty):
+		log.Warn("received empty response from the model")
+	case errors.As(err, &jsonErr):
+		log.Error("failed to unmarshal json from the model", "error", jsonErr.err, "bytes", jsonErr.size)
+	default:
+		log.Error("model content generation failed", "error", err)
+	}
+}
+
+// modelErrorMessage returns what to tell the user about a generateJSON
+// failure, and the HTTP status that goes with it.
+func modelErrorMessage(err error) (string, int) {
+	var jsonErr *modelJSONError
+	switch {
+	case errors.Is(err, errModelBlocked):
+		return "The analysis was blocked by the content safety filter.", http.StatusBadRequest
+	case errors.Is(err, errModelEmpty):
+		return "Received an empty response from the AI model", http.StatusInternalServerError
+	case errors.As(err, &jsonErr):
+		return "Failed to parse AI model response", http.StatusInternalServerError
+	default:
+		return "Failed to get analysis from AI model", http.StatusInternalServerError
+	}
+}
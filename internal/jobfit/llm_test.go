@@ -0,0 +1,115 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// scriptedModel replies with each of its replies in turn and records the
+// prompts it was sent.
+type scriptedModel struct {
+	replies []string
+	prompts []string
+}
+
+func (m *scriptedModel) generate(_ context.Context, prompt string) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	if len(m.prompts) > len(m.replies) {
+		return "", errModelEmpty
+	}
+	return m.replies[len(m.prompts)-1], nil
+}
+
+func (m *scriptedModel) provider() string { return "scripted" }
+func (m *scriptedModel) close()           {}
+
+func TestGenerateJSON(t *testing.T) {
+	type reply struct {
+		Title  string   `json:"title"`
+		Score  int      `json:"score"`
+		Skills []string `json:"skills"`
+	}
+	valid := `{"title": "Engineer", "score": 80, "skills": ["Go", "SQL"]}`
+	want := reply{Title: "Engineer", Score: 80, Skills: []string{"Go", "SQL"}}
+
+	tests := []struct {
+		name        string
+		replies     []string
+		want        reply
+		corrections int
+		wantErr     bool
+	}{
+		{name: "valid", replies: []string{valid}, want: want},
+		{name: "markdown fence", replies: []string{"```json\n" + valid + "\n```"}, want: want},
+		{name: "prose around the object", replies: []string{"Here is the analysis:\n" + valid + "\nLet me know if you need more."}, want: want},
+		{name: "trailing commas", replies: []string{`{"title": "Engineer", "score": 80, "skills": ["Go", "SQL",],}`}, want: want},
+		{name: "line break inside a string", replies: []string{"{\"title\": \"Engi\nneer\", \"score\": 80, \"skills\": [\"Go\", \"SQL\"]}"}, want: reply{Title: "Engi\nneer", Score: 80, Skills: []string{"Go", "SQL"}}},
+		{name: "truncated", replies: []string{`{"title": "Engineer", "score": 80, "skills": ["Go", "SQL`}, want: want},
+		{name: "truncated after a comma", replies: []string{`{"title": "Engineer", "score": 80, "skills": ["Go", "SQL",`}, want: want},
+		{name: "no json at all", replies: []string{"I can't help with that.", valid}, want: want, corrections: 1},
+		{name: "wrong types", replies: []string{`{"title": "Engineer", "score": "high", "skills": "Go"}`, valid}, want: want, corrections: 1},
+		{name: "bad twice", replies: []string{"Sorry.", "Still no."}, corrections: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &scriptedModel{replies: tt.replies}
+			app := &application{models: m}
+			var got reply
+			err := app.generateJSON(context.Background(), slog.New(slog.DiscardHandler), "Describe the job.", &got)
+
+			var jsonErr *modelJSONError
+			if tt.wantErr != errors.As(err, &jsonErr) {
+				t.Fatalf("err = %v, want a modelJSONError: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if !tt.wantErr && (got.Title != tt.want.Title || got.Score != tt.want.Score || strings.Join(got.Skills, ",") != strings.Join(tt.want.Skills, ",")) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+
+			if len(m.prompts) != 1+tt.corrections {
+				t.Fatalf("model was called %d times, want %d", len(m.prompts), 1+tt.corrections)
+			}
+			for _, p := range m.prompts[1:] {
+				if !strings.HasPrefix(p, "Describe the job.") || !strings.Contains(p, "Your previous reply could not be used") {
+					t.Errorf("second prompt isn't a correction of the first:\n%s", p)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateJSONCorrectionStartsClean(t *testing.T) {
+	// The first reply decodes partly before failing; none of it may survive
+	// into the result of the retry.
+	m := &scriptedModel{replies: []string{`{"title": "Wrong", "score": "x"}`, `{"score": 70}`}}
+	app := &application{models: m}
+	var got struct {
+		Title string `json:"title"`
+		Score int    `json:"score"`
+	}
+	if err := app.generateJSON(context.Background(), slog.New(slog.DiscardHandler), "p", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "" || got.Score != 70 {
+		t.Errorf("decoded %+v, want only the retry's fields", got)
+	}
+}
+
+func TestGenerateJSONNeedsPointer(t *testing.T) {
+	m := &scriptedModel{replies: []string{`{}`}}
+	app := &application{models: m}
+	var nilPtr *struct{}
+	for _, v := range []any{struct{}{}, nilPtr, nil} {
+		if err := app.generateJSON(context.Background(), slog.New(slog.DiscardHandler), "p", v); err == nil {
+			t.Errorf("generateJSON(%T) succeeded", v)
+		}
+	}
+	if len(m.prompts) != 0 {
+		t.Errorf("model was called %d times for unusable targets", len(m.prompts))
+	}
+}
@@ -0,0 +1,137 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// A leader holds its lease this long without renewing it, so a replica
+	// that dies hands over within leaderLease.
+	leaderLease         = 30 * time.Second
+	leaderRenewInterval = 10 * time.Second
+)
+
+var errLockHeld = errors.New("lock is held elsewhere")
+
+func lockKey(name string) string {
+	return rkey("lock", name)
+}
+
+// A redisLock is a lease on a key, held by whoever knows its token. Leases
+// expire on their own, so a replica that dies holding one doesn't block the
+// others for longer than its TTL.
+type redisLock struct {
+	rdb   *redis.Client
+	key   string
+	token string
+}
+
+// releaseLockScript and extendLockScript only touch the lease if it is still
+// ours, never one another replica took after ours expired.
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0
+`)
+
+var extendLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 0
+`)
+
+// acquireLock takes the named lock for ttl, or returns errLockHeld if another
+// replica has it.
+func (app *application) acquireLock(ctx context.Context, name string, ttl time.Duration) (*redisLock, error) {
+	l := &redisLock{rdb: app.rdb, key: lockKey(name), token: randomHex(16)}
+	ok, err := app.rdb.SetNX(ctx, l.key, l.token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errLockHeld
+	}
+	return l, nil
+}
+
+// extend renews the lease for another ttl. It returns errLockHeld if the
+// lease had already lapsed and someone else took it.
+func (l *redisLock) extend(ctx context.Context, ttl time.Duration) error {
+	n, err := extendLockScript.Run(ctx, l.rdb, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errLockHeld
+	}
+	return nil
+}
+
+func (l *redisLock) release(ctx context.Context) error {
+	return releaseLockScript.Run(ctx, l.rdb, []string{l.key}, l.token).Err()
+}
+
+// withLock runs fn while holding the named lock, or returns errLockHeld
+// without running it. ttl must comfortably exceed how long fn can take.
+func (app *application) withLock(ctx context.Context, name string, ttl time.Duration, fn func() error) error {
+	l, err := app.acquireLock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	defer l.release(context.WithoutCancel(ctx))
+	return fn()
+}
+
+// runAsLeader runs job on exactly one replica at a time, until ctx is
+// cancelled. Every replica calls it; whichever holds the "leader:name" lease
+// runs job, and the rest wait to take over should it stop renewing. job is
+// given a context that is cancelled if leadership is lost, and must return
+// when it is.
+func (app *application) runAsLeader(ctx context.Context, name string, job func(context.Context)) {
+	log := app.logger.With("job", name)
+	for {
+		l, err := app.acquireLock(ctx, "leader:"+name, leaderLease)
+		if err == nil {
+			log.Info("became leader")
+			app.lead(ctx, l, job)
+			l.release(context.WithoutCancel(ctx))
+			log.Info("stopped leading")
+		} else if !errors.Is(err, errLockHeld) && ctx.Err() == nil {
+			log.Error("leader election failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderRenewInterval):
+		}
+	}
+}
+
+// lead runs job while renewing l, and returns once job has returned.
+func (app *application) lead(ctx context.Context, l *redisLock, job func(context.Context)) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		job(jobCtx)
+	}()
+
+	ticker := time.NewTicker(leaderRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := l.extend(ctx, leaderLease); err != nil {
+				app.logger.Warn("lost leadership", "lock", l.key, "error", err)
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package jobfit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// An lruCache keeps recently used values in memory for a short time, in front
+// of lookups whose source of truth is Redis, so bursts of requests for the
+// same thing don't each cost a round trip. Every replica has its own: a write
+// removes the local copy, and other replicas see it once their entry's ttl
+// runs out, so ttl is how stale a value can get.
+//
+// A nil *lruCache caches nothing.
+type lruCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // of *lruEntry, most recently used first
+	items map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func newLRUCache[K comparable, V any](size int, ttl time.Duration) *lruCache[K, V] {
+	return &lruCache[K, V]{size: size, ttl: ttl, order: list.New(), items: make(map[K]*list.Element, size)}
+}
+
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*lruEntry[K, V])
+	if time.Now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *lruCache[K, V]) set(key K, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *lruCache[K, V]) remove(key K) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// hotCaches are the in-memory caches in front of Redis. Cached values are
+// shared between requests and must not be modified.
+type hotCaches struct {
+	quota        *lruCache[string, quotaSnapshot]
+	github       *lruCache[string, *githubProfile]
+	widgetKeys   *lruCache[string, widgetKey]
+	integrations *lruCache[string, integration]
+}
+
+func newHotCaches() hotCaches {
+	return hotCaches{
+		// Quota only backs the read-only /quota endpoint; charging always
+		// goes to Redis.
+		quota:  newLRUCache[string, quotaSnapshot](10000, 2*time.Second),
+		github: newLRUCache[string, *githubProfile](500, 5*time.Minute),
+		// A revoked key or integration keeps working on other replicas for
+		// at most this long.
+		widgetKeys:   newLRUCache[string, widgetKey](1000, 30*time.Second),
+		integrations: newLRUCache[string, integration](1000, 30*time.Second),
+	}
+}
@@ -0,0 +1,55 @@
+package jobfit
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// mailer sends plain-text email through an SMTP relay.
+type mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// newMailerFromEnv configures a mailer from SMTP_* variables. It returns nil when
+// SMTP_HOST is unset, which disables every feature that sends email.
+func newMailerFromEnv() *mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	m := &mailer{addr: net.JoinHostPort(host, port), from: os.Getenv("SMTP_FROM")}
+	if m.from == "" {
+		m.from = "JobFit.ai <no-reply@job-fit-ai.com>"
+	}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		m.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return m
+}
+
+func (m *mailer) send(to, subject, body string) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	envelopeFrom := m.from
+	if i := strings.LastIndex(envelopeFrom, "<"); i >= 0 {
+		envelopeFrom = strings.Trim(envelopeFrom[i:], "<>")
+	}
+	return smtp.SendMail(m.addr, m.auth, envelopeFrom, []string{to}, []byte(msg.String()))
+}
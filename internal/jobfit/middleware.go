@@ -0,0 +1,258 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A middleware wraps a handler with behaviour shared by a group of routes.
+type middleware func(http.Handler) http.Handler
+
+// A routeGroup registers routes on a mux with the same middleware, the
+// first outermost.
+type routeGroup struct {
+	mux         *http.ServeMux
+	middlewares []middleware
+}
+
+func newRouteGroup(mux *http.ServeMux, mws ...middleware) routeGroup {
+	return routeGroup{mux: mux, middlewares: mws}
+}
+
+// with returns a group whose routes also go through mws, inside the
+// group's own middleware.
+func (g routeGroup) with(mws ...middleware) routeGroup {
+	return routeGroup{mux: g.mux, middlewares: append(slices.Clip(g.middlewares), mws...)}
+}
+
+func (g routeGroup) handle(pattern string, h http.Handler) {
+	for _, mw := range slices.Backward(g.middlewares) {
+		h = mw(h)
+	}
+	g.mux.Handle(pattern, h)
+}
+
+func (g routeGroup) handleFunc(pattern string, h http.HandlerFunc) {
+	g.handle(pattern, h)
+}
+
+// statusRecorder remembers the status code a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// code is the status sent, which is 200 if the handler wrote nothing.
+func (s *statusRecorder) code() int {
+	return cmp.Or(s.status, http.StatusOK)
+}
+
+type requestIDKey struct{}
+
+// validRequestID limits the request IDs taken from callers to something safe
+// to log and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withRequestID tags each request with an ID, the caller's X-Request-ID if it
+// sent a sensible one, and returns it in the response's X-Request-ID so a
+// user's report can be matched to the logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			var b [8]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID withRequestID gave the request ctx belongs to.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// recoverPanic turns a panicking handler into a logged 500 instead of a
+// dropped connection.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			app.logger.Error("panic serving request", "requestID", requestID(r.Context()),
+				"method", r.Method, "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+			if rec.status == 0 {
+				http.Error(rec, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// logRequests writes an access log line for each request.
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		app.logger.Info("request", "requestID", requestID(r.Context()), "method", r.Method,
+			"path", r.URL.Path, "route", r.Pattern, "status", rec.code(),
+			"duration", time.Since(start).Round(time.Millisecond), "ip", getIPAddress(r))
+	})
+}
+
+// adminOnly is requireAdmin as a middleware.
+func (app *application) adminOnly(next http.Handler) http.Handler {
+	return app.requireAdmin(next.ServeHTTP)
+}
+
+type signedClientKey struct{}
+
+// signedClientOnly lets through only requests with a signed client token,
+// for routes that must stay with one browser; see verifiedClientID.
+func (app *application) signedClientOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := app.verifiedClientID(w, r)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedClientKey{}, id)))
+	})
+}
+
+// signedClientID returns the client signedClientOnly let through.
+func signedClientID(ctx context.Context) string {
+	id, _ := ctx.Value(signedClientKey{}).(string)
+	return id
+}
+
+// limitRequestRate answers 429 once an IP has made the configured number of
+// requests in the current minute. If Redis can't be reached the request is
+// let through rather than refused.
+func (app *application) limitRequestRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.quota.perMinute == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		now := time.Now().Unix()
+		key := requestRateKey(app.quota.ipBucket(getIPAddress(r)), now/60)
+		pipe := app.rdb.Pipeline()
+		count := pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, 2*time.Minute)
+		if _, err := pipe.Exec(ctx); err != nil {
+			app.logger.Error("failed to count request", "ip", getIPAddress(r), "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if count.Val() > app.quota.perMinute {
+			w.Header().Set("Retry-After", strconv.FormatInt(60-now%60, 10))
+			http.Error(w, "Too many requests. Please slow down and try again in a minute.", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeStats are the counters kept for one route since the process started.
+type routeStats struct {
+	Requests     int64         `json:"requests"`
+	ClientErrors int64         `json:"clientErrors"`
+	ServerErrors int64         `json:"serverErrors"`
+	AverageMs    float64       `json:"averageMs"`
+	total        time.Duration // time spent serving Requests
+}
+
+// routeMetrics counts requests and their latency per route.
+type routeMetrics struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats // by route pattern
+}
+
+func (m *routeMetrics) record(route string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.routes == nil {
+		m.routes = map[string]*routeStats{}
+	}
+	s := m.routes[route]
+	if s == nil {
+		s = &routeStats{}
+		m.routes[route] = s
+	}
+	s.Requests++
+	s.total += d
+	switch {
+	case status >= 500:
+		s.ServerErrors++
+	case status >= 400:
+		s.ClientErrors++
+	}
+}
+
+func (m *routeMetrics) snapshot() map[string]routeStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]routeStats, len(m.routes))
+	for route, s := range m.routes {
+		c := *s
+		c.AverageMs = float64(s.total.Microseconds()) / 1000 / float64(s.Requests)
+		out[route] = c
+	}
+	return out
+}
+
+// countRequests records each request in the route's metrics.
+func (app *application) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		app.metrics.record(r.Pattern, rec.code(), time.Since(start))
+	})
+}
+
+// adminMetricsHandler reports this process's request counts and average
+// latency per route.
+func (app *application) adminMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(app.metrics.snapshot())
+}
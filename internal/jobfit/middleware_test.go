@@ -0,0 +1,49 @@
+package jobfit
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteGroupOrder(t *testing.T) {
+	var calls []string
+	mark := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	mux := http.NewServeMux()
+	g := newRouteGroup(mux, mark("a"), mark("b")).with(mark("c"))
+	g.handleFunc("GET /x", func(http.ResponseWriter, *http.Request) { calls = append(calls, "handler") })
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+	if got := strings.Join(calls, ","); got != "a,b,c,handler" {
+		t.Errorf("calls = %s, want a,b,c,handler", got)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	var logs bytes.Buffer
+	app := &application{logger: slog.New(slog.NewTextHandler(&logs, nil)), adminToken: "adm", clientSecret: []byte("s")}
+	mux := http.NewServeMux()
+	api := newRouteGroup(mux, withRequestID, app.withClientIP, app.logRequests, app.countRequests, app.recoverPanic)
+	api.handleFunc("GET /boom", func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if !strings.Contains(logs.String(), "panic serving request") || !strings.Contains(logs.String(), "status=500") {
+		t.Errorf("panic wasn't logged as a 500:\n%s", logs.String())
+	}
+	if got := app.metrics.snapshot()["GET /boom"].ServerErrors; got != 1 {
+		t.Errorf("server errors counted = %d, want 1", got)
+	}
+}
@@ -0,0 +1,94 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// A modelChain tries a list of models in order, moving on to the next when
+// one fails, times out or is rate limited, so users only see an error when
+// every one of them did. A reply the safety filter blocked is returned as is:
+// it's about the prompt, not the model.
+type modelChain struct {
+	logger *slog.Logger
+	links  []languageModel
+}
+
+// newModelChain builds the chain LLM_FALLBACK_CHAIN describes, a
+// comma-separated list of providers as LLM_PROVIDER names them, each
+// optionally followed by a slash and the model to use for every tier, e.g.
+// "gemini/gemini-1.5-flash,gemini/gemini-1.5-pro,openai". A provider without
+// a model uses its usual configuration.
+func newModelChain(ctx context.Context, logger *slog.Logger, residency, spec string) (*modelChain, error) {
+	c := &modelChain{logger: logger}
+	for link := range strings.SplitSeq(spec, ",") {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		provider, model, _ := strings.Cut(link, "/")
+		m, err := newProviderModel(ctx, logger, residency, provider, model)
+		if err != nil {
+			c.close()
+			return nil, fmt.Errorf("LLM_FALLBACK_CHAIN %q: %w", link, err)
+		}
+		c.links = append(c.links, m)
+	}
+	if len(c.links) == 0 {
+		return nil, errors.New("LLM_FALLBACK_CHAIN names no providers")
+	}
+	return c, nil
+}
+
+// linkTimeout is how long one model in the chain gets to reply.
+func linkTimeout(m languageModel) time.Duration {
+	if slow, ok := m.(slowModel); ok {
+		return slow.timeout()
+	}
+	return modelTimeout
+}
+
+// generate implements languageModel.
+func (c *modelChain) generate(ctx context.Context, prompt string) (string, error) {
+	var err error
+	for i, m := range c.links {
+		attempt, cancel := context.WithTimeout(ctx, linkTimeout(m))
+		var reply string
+		reply, err = m.generate(attempt, prompt)
+		cancel()
+		if err == nil || errors.Is(err, errModelBlocked) || ctx.Err() != nil {
+			return reply, err
+		}
+		if i < len(c.links)-1 {
+			c.logger.Warn("model failed, falling back", "provider", m.provider(), "next", c.links[i+1].provider(), "error", err)
+		}
+	}
+	return "", err
+}
+
+// timeout gives each model in the chain its own full timeout.
+func (c *modelChain) timeout() time.Duration {
+	var total time.Duration
+	for _, m := range c.links {
+		total += linkTimeout(m)
+	}
+	return total
+}
+
+func (c *modelChain) provider() string {
+	names := make([]string, len(c.links))
+	for i, m := range c.links {
+		names[i] = m.provider()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *modelChain) close() {
+	for _, m := range c.links {
+		m.close()
+	}
+}
@@ -0,0 +1,391 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// modelTiers are the models an analysis can ask for by tier.
+var modelTiers = map[string]string{
+	"standard": "gemini-2.0-flash",
+	// lite is faster and cheaper but notices less.
+	"lite": "gemini-2.0-flash-lite",
+}
+
+const (
+	defaultModelTier = "standard"
+	// A key that was rejected or rate limited isn't tried again for this long
+	// unless every other key is failing too.
+	modelKeyCooldown = time.Minute
+)
+
+// contentGenerator is a model reached through either the Gemini API or
+// Vertex AI.
+type contentGenerator interface {
+	GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error)
+}
+
+// A modelKey is one configured way of reaching the model.
+type modelKey struct {
+	name          string
+	hint          string // the last characters of the API key, or the Vertex AI region, for logs
+	provider      string // "gemini-api" or "vertex-ai/" and the region
+	client        *genai.Client
+	models        map[string]contentGenerator // by tier
+	cooldownUntil time.Time
+	failures      int
+}
+
+// A modelPool holds the primary and secondary API keys. Calls go to the
+// primary; when it is rejected or rate limited they fail over to the
+// secondary until the primary's cooldown ends.
+type modelPool struct {
+	logger    *slog.Logger
+	residency string
+	tiers     map[string]string // model names by tier
+	mu        sync.Mutex
+	keys      []*modelKey
+}
+
+func keyHint(apiKey string) string {
+	if len(apiKey) <= 4 {
+		return "****"
+	}
+	return "…" + apiKey[len(apiKey)-4:]
+}
+
+func newModelKey(ctx context.Context, name string, tiers map[string]string, opts ...option.ClientOption) (*modelKey, error) {
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k := &modelKey{name: name, provider: "gemini-api", client: client, models: map[string]contentGenerator{}}
+	for tier, model := range tiers {
+		m := client.GenerativeModel(model)
+		m.ResponseMIMEType = "application/json"
+		k.models[tier] = m
+	}
+	return k, nil
+}
+
+// newModelPool connects to Vertex AI if VERTEX_PROJECT is set or vertex is
+// true (LLM_PROVIDER=vertex); EU residency requires it. Otherwise it uses
+// GEMINI_API_KEY and, if set, GEMINI_API_KEY_SECONDARY, and without an API key
+// it falls back to GOOGLE_APPLICATION_CREDENTIALS, which has no failover.
+// tiers names the Gemini model for each tier.
+func newModelPool(ctx context.Context, logger *slog.Logger, residency string, vertex bool, tiers map[string]string) (*modelPool, error) {
+	p := &modelPool{logger: logger, residency: residency, tiers: tiers}
+	project := os.Getenv("VERTEX_PROJECT")
+	if vertex && project == "" {
+		var err error
+		if project, err = defaultVertexProject(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if residency == residencyEU {
+		if os.Getenv("GEMINI_API_KEY") != "" || os.Getenv("GEMINI_API_KEY_SECONDARY") != "" {
+			return nil, errors.New("DATA_RESIDENCY=eu can't use GEMINI_API_KEY: the Gemini API has no data residency guarantee; use VERTEX_PROJECT")
+		}
+		if project == "" {
+			return nil, errors.New("DATA_RESIDENCY=eu needs VERTEX_PROJECT")
+		}
+	}
+	if project != "" {
+		k, err := newVertexKey(ctx, residency, project, tiers)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = append(p.keys, k)
+		return p, nil
+	}
+
+	for _, env := range []struct{ name, variable string }{
+		{"primary", "GEMINI_API_KEY"},
+		{"secondary", "GEMINI_API_KEY_SECONDARY"},
+	} {
+		apiKey := os.Getenv(env.variable)
+		if apiKey == "" {
+			continue
+		}
+		k, err := newModelKey(ctx, env.name, tiers, option.WithAPIKey(apiKey))
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		k.hint = keyHint(apiKey)
+		p.keys = append(p.keys, k)
+	}
+	if len(p.keys) == 0 {
+		if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == "" {
+			return nil, errors.New("you must set either GEMINI_API_KEY or GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		k, err := newModelKey(ctx, "application-credentials", tiers)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = append(p.keys, k)
+	}
+	return p, nil
+}
+
+func (p *modelPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.client != nil {
+			k.client.Close()
+		}
+	}
+}
+
+// shouldFailOver reports whether err means the key itself is the problem, so
+// another key might succeed where this one didn't.
+func shouldFailOver(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	// An invalid key comes back as a 400 rather than a 401.
+	return apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "API key")
+}
+
+// order returns the keys to try, those not cooling down first.
+func (p *modelPool) order() []*modelKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var ready, cooling []*modelKey
+	for _, k := range p.keys {
+		if now.Before(k.cooldownUntil) {
+			cooling = append(cooling, k)
+		} else {
+			ready = append(ready, k)
+		}
+	}
+	return append(ready, cooling...)
+}
+
+func (p *modelPool) markFailed(k *modelKey, err error) {
+	p.mu.Lock()
+	k.failures++
+	k.cooldownUntil = time.Now().Add(modelKeyCooldown)
+	p.mu.Unlock()
+	p.logger.Warn("model key failed, failing over", "key", k.name, "hint", k.hint, "error", err)
+}
+
+type modelTierKey struct{}
+
+// withModelTier makes model calls made with ctx use tier's model rather than
+// the standard one.
+func withModelTier(ctx context.Context, tier string) context.Context {
+	if tier == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, modelTierKey{}, tier)
+}
+
+func modelTier(ctx context.Context) string {
+	if tier, ok := ctx.Value(modelTierKey{}).(string); ok {
+		return tier
+	}
+	return defaultModelTier
+}
+
+// generateContent calls the model with the first key that works.
+func (p *modelPool) generateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	tier := modelTier(ctx)
+	schema := responseSchema(ctx)
+	var err error
+	for _, k := range p.order() {
+		gen := k.models[tier]
+		if schema != nil {
+			gen = withSchema(gen, schema)
+		}
+		var resp *genai.GenerateContentResponse
+		resp, err = gen.GenerateContent(ctx, parts...)
+		if err == nil || !shouldFailOver(err) {
+			return resp, err
+		}
+		p.markFailed(k, err)
+	}
+	return nil, err
+}
+
+// generate implements languageModel.
+func (p *modelPool) generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := p.generateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonSafety {
+		return "", errModelBlocked
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", errModelEmpty
+	}
+	return fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0]), nil
+}
+
+var modelKeySlots = []string{"primary", "secondary"}
+
+// replace puts apiKey in the named slot, dropping the key that was there.
+// Replaced keys live in memory only: update the environment as well so a
+// restart doesn't bring the old key back.
+func (p *modelPool) replace(ctx context.Context, slot, apiKey string) error {
+	i := slices.Index(modelKeySlots, slot)
+	if i < 0 {
+		return fmt.Errorf("unknown key slot %q", slot)
+	}
+	if p.residency == residencyEU {
+		return errors.New("API keys reach the Gemini API, which EU data residency doesn't allow")
+	}
+	k, err := newModelKey(ctx, slot, p.tiers, option.WithAPIKey(apiKey))
+	if err != nil {
+		return err
+	}
+	k.hint = keyHint(apiKey)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i > len(p.keys) {
+		k.client.Close()
+		return errors.New("set a primary key before a secondary one")
+	}
+	if i == len(p.keys) {
+		p.keys = append(p.keys, k)
+		return nil
+	}
+	old := p.keys[i]
+	p.keys[i] = k
+	// Give in-flight calls on the old key time to finish.
+	if old.client != nil {
+		time.AfterFunc(modelTimeout, func() { old.client.Close() })
+	}
+	return nil
+}
+
+// provider names where calls go while the primary key is working.
+func (p *modelPool) provider() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) == 0 {
+		return ""
+	}
+	return p.keys[0].provider
+}
+
+// swap promotes the secondary key to primary.
+func (p *modelPool) swap() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) < 2 {
+		return errors.New("there is no secondary key to swap to")
+	}
+	p.keys[0], p.keys[1] = p.keys[1], p.keys[0]
+	for i, k := range p.keys {
+		k.name = modelKeySlots[i]
+		k.cooldownUntil = time.Time{}
+	}
+	return nil
+}
+
+type modelKeyStatus struct {
+	Name          string     `json:"name"`
+	Hint          string     `json:"hint,omitempty"`
+	Failures      int        `json:"failures"`
+	CoolingDown   bool       `json:"coolingDown"`
+	CooldownUntil *time.Time `json:"cooldownUntil,omitempty"`
+}
+
+func (p *modelPool) status() []modelKeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	list := make([]modelKeyStatus, 0, len(p.keys))
+	for _, k := range p.keys {
+		s := modelKeyStatus{Name: k.name, Hint: k.hint, Failures: k.failures, CoolingDown: now.Before(k.cooldownUntil)}
+		if s.CoolingDown {
+			until := k.cooldownUntil
+			s.CooldownUntil = &until
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// keyPool returns the Gemini key pool, the first one in a fallback chain, or
+// writes a 404 when there is none.
+func (app *application) keyPool(w http.ResponseWriter) (*modelPool, bool) {
+	models := []languageModel{app.models}
+	if chain, ok := app.models.(*modelChain); ok {
+		models = chain.links
+	}
+	for _, m := range models {
+		if pool, ok := m.(*modelPool); ok {
+			return pool, true
+		}
+	}
+	http.Error(w, "The model provider has no managed keys", http.StatusNotFound)
+	return nil, false
+}
+
+func (app *application) adminModelKeysHandler(w http.ResponseWriter, r *http.Request) {
+	pool, ok := app.keyPool(w)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pool.status())
+}
+
+// adminRotateModelKeyHandler swaps a new API key into the given slot, or
+// promotes the secondary key when no key is given.
+func (app *application) adminRotateModelKeyHandler(w http.ResponseWriter, r *http.Request) {
+	pool, ok := app.keyPool(w)
+	if !ok {
+		return
+	}
+	var req struct {
+		Slot   string `json:"slot"`
+		APIKey string `json:"apiKey"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	var err error
+	if apiKey := strings.TrimSpace(req.APIKey); apiKey != "" {
+		err = pool.replace(r.Context(), cmp.Or(req.Slot, "primary"), apiKey)
+	} else {
+		err = pool.swap()
+	}
+	if err != nil {
+		app.logger.Warn("model key rotation failed", "error", err)
+		http.Error(w, "Could not rotate key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	app.logger.Info("model keys rotated", "keys", pool.status())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pool.status())
+}
@@ -0,0 +1,381 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Events a client can subscribe to.
+const (
+	eventWeeklyDigest = "weekly_digest"
+	eventQuotaAlert   = "quota_alert"
+)
+
+var notificationEvents = []string{eventWeeklyDigest, eventQuotaAlert}
+
+// notificationPrefs holds a client's delivery addresses and, for each event, the
+// channels it should be delivered on.
+type notificationPrefs struct {
+	Email       string              `json:"email,omitempty"`
+	WebhookURL  string              `json:"webhookUrl,omitempty"`
+	SlackUserID string              `json:"slackUserId,omitempty"`
+	Events      map[string][]string `json:"events,omitempty"`
+	// QuotaAlertPercents are the shares of the daily credits whose use
+	// triggers a quota_alert, 80 and 100 by default.
+	QuotaAlertPercents []int `json:"quotaAlertPercents,omitempty"`
+	// WebhookSecret is what webhook payloads are signed with. Each
+	// WebhookURL gets a new random one, returned only in the response that
+	// set it.
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+	// Email and SlackUserID only ever hold addresses the client has
+	// confirmed. A new one waits here, receiving nothing but its
+	// confirmation code, until the code is posted to
+	// /me/notifications/confirm.
+	PendingEmail       string `json:"pendingEmail,omitempty"`
+	PendingSlackUserID string `json:"pendingSlackUserId,omitempty"`
+}
+
+// A confirmableAddress is an address field that must be confirmed before
+// anything is delivered to it, along with where it waits until then. An
+// email address or Slack member could be anyone's; a webhook's payloads are
+// signed with a secret only the client sees, so it needs no confirming.
+type confirmableAddress struct {
+	channel          string
+	address, pending *string
+}
+
+func (p *notificationPrefs) confirmable() []confirmableAddress {
+	return []confirmableAddress{
+		{"email", &p.Email, &p.PendingEmail},
+		{"slack", &p.SlackUserID, &p.PendingSlackUserID},
+	}
+}
+
+// requestAddresses turns the email and Slack fields of a PUT into the
+// addresses asked for. A pending address sent back unchanged, as a client
+// that reads and then writes its settings will, counts as asked for again;
+// any other pending field is ignored.
+func (p *notificationPrefs) requestAddresses(saved notificationPrefs) {
+	have := saved.confirmable()
+	for i, a := range p.confirmable() {
+		if *a.address == "" && *a.pending == *have[i].pending {
+			*a.address = *a.pending
+		}
+		*a.pending = ""
+	}
+}
+
+// holdUnconfirmed moves the addresses asked for into their pending fields
+// unless saved already has them confirmed, and returns the ones that need a
+// confirmation code sent. An address that was already pending keeps its
+// code; to get a new one, remove the address and set it again.
+func (p *notificationPrefs) holdUnconfirmed(saved notificationPrefs) []confirmableAddress {
+	var unconfirmed []confirmableAddress
+	have := saved.confirmable()
+	for i, a := range p.confirmable() {
+		requested := *a.address
+		if requested == "" || requested == *have[i].address {
+			continue
+		}
+		*a.address, *a.pending = "", requested
+		if requested != *have[i].pending {
+			unconfirmed = append(unconfirmed, a)
+		}
+	}
+	return unconfirmed
+}
+
+type notification struct {
+	Event   string `json:"event"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// A notificationChannel is one way of reaching a client.
+type notificationChannel interface {
+	// address returns where the channel delivers for p, or "" if p doesn't
+	// configure it.
+	address(p notificationPrefs) string
+	send(ctx context.Context, p notificationPrefs, n notification) error
+}
+
+// newNotificationChannels returns the channels this server can deliver on.
+// Email needs SMTP and Slack needs SLACK_BOT_TOKEN; webhooks are always on.
+func newNotificationChannels(m *mailer, webhooks *webhookDispatcher) map[string]notificationChannel {
+	client := &http.Client{Timeout: 10 * time.Second}
+	channels := map[string]notificationChannel{
+		"webhook": webhookChannel{d: webhooks},
+	}
+	if m != nil {
+		channels["email"] = emailChannel{m: m}
+	}
+	if token := os.Getenv("SLACK_BOT_TOKEN"); token != "" {
+		channels["slack"] = slackChannel{token: token, client: client}
+	}
+	return channels
+}
+
+type emailChannel struct{ m *mailer }
+
+func (emailChannel) address(p notificationPrefs) string { return p.Email }
+
+func (c emailChannel) send(_ context.Context, p notificationPrefs, n notification) error {
+	return c.m.send(p.Email, n.Subject, n.Body)
+}
+
+// webhookChannel queues notifications with the webhook dispatcher, which
+// signs and retries them.
+type webhookChannel struct{ d *webhookDispatcher }
+
+func (webhookChannel) address(p notificationPrefs) string { return p.WebhookURL }
+
+func (c webhookChannel) send(ctx context.Context, p notificationPrefs, n notification) error {
+	if p.WebhookSecret == "" {
+		return errors.New("webhook has no signing secret yet")
+	}
+	_, err := c.d.enqueue(ctx, p.WebhookURL, p.WebhookSecret, n.Event, struct {
+		notification
+		SentAt time.Time `json:"sentAt"`
+	}{n, time.Now().UTC()})
+	return err
+}
+
+// slackChannel sends direct messages through a Slack bot.
+type slackChannel struct {
+	token  string
+	client *http.Client
+}
+
+func (slackChannel) address(p notificationPrefs) string { return p.SlackUserID }
+
+func (c slackChannel) send(ctx context.Context, p notificationPrefs, n notification) error {
+	payload, err := json.Marshal(map[string]string{
+		"channel": p.SlackUserID,
+		"text":    "*" + n.Subject + "*\n" + n.Body,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://slack.com/api/chat.postMessage", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("slack: %s", result.Error)
+	}
+	return nil
+}
+
+func notificationPrefsKey(owner string) string {
+	return rkey("notify", "prefs", owner)
+}
+
+// subscribersKey is the set of clients subscribed to event on any channel.
+func subscribersKey(event string) string {
+	return rkey("notify", "subscribers", event)
+}
+
+func (app *application) loadNotificationPrefs(ctx context.Context, owner string) (notificationPrefs, error) {
+	var p notificationPrefs
+	data, err := app.rdb.Get(ctx, notificationPrefsKey(owner)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = app.unmarshalSealed(notificationPrefsKey(owner), data, &p)
+	return p, err
+}
+
+func (app *application) saveNotificationPrefs(ctx context.Context, owner string, p notificationPrefs) error {
+	data, err := app.marshalSealed(notificationPrefsKey(owner), p)
+	if err != nil {
+		return err
+	}
+	pipe := app.rdb.TxPipeline()
+	pipe.Set(ctx, notificationPrefsKey(owner), data, 0)
+	for _, event := range notificationEvents {
+		if len(p.Events[event]) > 0 {
+			pipe.SAdd(ctx, subscribersKey(event), owner)
+		} else {
+			pipe.SRem(ctx, subscribersKey(event), owner)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// notify delivers n to owner on every channel they chose for n.Event. Clients
+// who haven't subscribed to the event are silently skipped.
+func (app *application) notify(ctx context.Context, owner string, n notification) error {
+	p, err := app.loadNotificationPrefs(ctx, owner)
+	if err != nil {
+		return err
+	}
+	return app.deliver(ctx, p, n)
+}
+
+// deliverable reports whether p has a channel chosen for event that has a
+// confirmed address to deliver to.
+func (app *application) deliverable(p notificationPrefs, event string) bool {
+	return slices.ContainsFunc(p.Events[event], func(name string) bool {
+		ch, ok := app.channels[name]
+		return ok && ch.address(p) != ""
+	})
+}
+
+// deliver sends n on the channels p chose for n.Event. Channels without a
+// confirmed address are skipped: an address still waiting for confirmation
+// only ever gets its code.
+func (app *application) deliver(ctx context.Context, p notificationPrefs, n notification) error {
+	var errs []error
+	for _, name := range p.Events[n.Event] {
+		ch, ok := app.channels[name]
+		if !ok {
+			continue
+		}
+		if ch.address(p) == "" {
+			continue
+		}
+		if err := ch.send(ctx, p, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var slackUserIDPattern = regexp.MustCompile(`^[UW][A-Z0-9]{2,}$`)
+
+// validate checks p against the channels this server offers.
+func (p notificationPrefs) validate(channels map[string]notificationChannel) error {
+	if p.Email != "" {
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			return errors.New("email is not a valid address")
+		}
+		if channels["email"] == nil {
+			return errors.New("email notifications are not available")
+		}
+	}
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || checkFetchURL(u) != nil {
+			return errors.New("webhookUrl must be a public http(s) URL")
+		}
+	}
+	if p.SlackUserID != "" {
+		if !slackUserIDPattern.MatchString(p.SlackUserID) {
+			return errors.New("slackUserId must be a Slack member ID such as U012AB3CD")
+		}
+		if channels["slack"] == nil {
+			return errors.New("slack notifications are not available")
+		}
+	}
+	if err := validateQuotaAlertPercents(p.QuotaAlertPercents); err != nil {
+		return err
+	}
+	for event, names := range p.Events {
+		if !slices.Contains(notificationEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+		for _, name := range names {
+			ch, ok := channels[name]
+			if !ok {
+				return fmt.Errorf("channel %q is not available", name)
+			}
+			if ch.address(p) == "" {
+				return fmt.Errorf("channel %q needs an address", name)
+			}
+		}
+	}
+	return nil
+}
+
+// notificationPrefsHandler reads (GET) or replaces (PUT) the caller's
+// notification preferences. A webhook's signing secret is only shown in the
+// response that creates it: setting a new webhookUrl, or reading back one
+// that was saved before secrets were stored, issues a new secret. To get a
+// new one for the same URL, remove the URL and set it again.
+//
+// A new email address or Slack member ID is sent a confirmation code and
+// comes back as pendingEmail or pendingSlackUserId until the code is posted
+// to /me/notifications/confirm.
+func (app *application) notificationPrefsHandler(w http.ResponseWriter, r *http.Request) {
+	owner := app.clientID(w, r)
+	ctx := r.Context()
+
+	saved, err := app.loadNotificationPrefs(ctx, owner)
+	if err != nil {
+		app.logger.Error("failed to load notification preferences", "error", err)
+		http.Error(w, "Could not load notification preferences", http.StatusInternalServerError)
+		return
+	}
+	p := saved
+	if r.Method == http.MethodPut {
+		p = notificationPrefs{}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		p.WebhookSecret = ""
+		p.requestAddresses(saved)
+		if err := p.validate(app.channels); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, a := range p.holdUnconfirmed(saved) {
+			if msg, status := app.sendConfirmationCode(ctx, owner, a.channel, *a.pending); msg != "" {
+				http.Error(w, msg, status)
+				return
+			}
+		}
+		if p.WebhookURL != "" && p.WebhookURL == saved.WebhookURL {
+			p.WebhookSecret = saved.WebhookSecret
+		}
+	}
+
+	created := p.WebhookURL != "" && p.WebhookSecret == ""
+	if created {
+		p.WebhookSecret = newWebhookSecret()
+	}
+	if r.Method == http.MethodPut || created {
+		if err := app.saveNotificationPrefs(ctx, owner, p); err != nil {
+			app.logger.Error("failed to save notification preferences", "error", err)
+			http.Error(w, "Could not save notification preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !created {
+		p.WebhookSecret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
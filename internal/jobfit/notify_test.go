@@ -0,0 +1,120 @@
+package jobfit
+
+import (
+	"context"
+	"testing"
+)
+
+// recordingChannel is an email channel that records what it was asked to
+// send instead of sending it.
+type recordingChannel struct {
+	sent *[]string
+}
+
+func (recordingChannel) address(p notificationPrefs) string { return p.Email }
+
+func (c recordingChannel) send(_ context.Context, p notificationPrefs, n notification) error {
+	*c.sent = append(*c.sent, p.Email+": "+n.Subject)
+	return nil
+}
+
+func TestDeliverSkipsUnconfirmedAddresses(t *testing.T) {
+	var sent []string
+	app := &application{channels: map[string]notificationChannel{"email": recordingChannel{&sent}}}
+	n := notification{Event: eventWeeklyDigest, Subject: "Your weekly JobFit.ai summary"}
+	events := map[string][]string{eventWeeklyDigest: {"email"}, eventQuotaAlert: {"email"}}
+
+	pending := notificationPrefs{PendingEmail: "stranger@example.com", Events: events}
+	if err := app.deliver(context.Background(), pending, n); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("an unconfirmed address was sent %q", sent)
+	}
+
+	confirmed := notificationPrefs{Email: "me@example.com", PendingEmail: "stranger@example.com", Events: events}
+	if err := app.deliver(context.Background(), confirmed, n); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0] != "me@example.com: Your weekly JobFit.ai summary" {
+		t.Errorf("sent %q, want one message to the confirmed address", sent)
+	}
+}
+
+func TestHoldUnconfirmed(t *testing.T) {
+	tests := []struct {
+		name        string
+		saved, put  notificationPrefs
+		want        notificationPrefs
+		needingCode []string
+	}{
+		{
+			name:        "new address waits for a code",
+			put:         notificationPrefs{Email: "me@example.com", SlackUserID: "U012AB3CD"},
+			want:        notificationPrefs{PendingEmail: "me@example.com", PendingSlackUserID: "U012AB3CD"},
+			needingCode: []string{"email", "slack"},
+		},
+		{
+			name:  "confirmed address stays confirmed",
+			saved: notificationPrefs{Email: "me@example.com"},
+			put:   notificationPrefs{Email: "me@example.com"},
+			want:  notificationPrefs{Email: "me@example.com"},
+		},
+		{
+			name:        "changed address stops delivery until confirmed",
+			saved:       notificationPrefs{Email: "old@example.com"},
+			put:         notificationPrefs{Email: "new@example.com"},
+			want:        notificationPrefs{PendingEmail: "new@example.com"},
+			needingCode: []string{"email"},
+		},
+		{
+			name:  "pending address sent back keeps its code",
+			saved: notificationPrefs{PendingEmail: "me@example.com"},
+			put:   notificationPrefs{PendingEmail: "me@example.com"},
+			want:  notificationPrefs{PendingEmail: "me@example.com"},
+		},
+		{
+			name:  "pending address asked for again keeps its code",
+			saved: notificationPrefs{PendingEmail: "me@example.com"},
+			put:   notificationPrefs{Email: "me@example.com"},
+			want:  notificationPrefs{PendingEmail: "me@example.com"},
+		},
+		{
+			name:  "client can't mark its own address pending or confirmed",
+			saved: notificationPrefs{PendingEmail: "me@example.com"},
+			put:   notificationPrefs{PendingEmail: "stranger@example.com", PendingSlackUserID: "U012AB3CD"},
+			want:  notificationPrefs{},
+		},
+		{
+			name:  "removing an address",
+			saved: notificationPrefs{Email: "me@example.com", PendingSlackUserID: "U012AB3CD"},
+			put:   notificationPrefs{},
+			want:  notificationPrefs{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.put
+			p.requestAddresses(tt.saved)
+			var needingCode []string
+			for _, a := range p.holdUnconfirmed(tt.saved) {
+				needingCode = append(needingCode, a.channel+"="+*a.pending)
+			}
+			if p.Email != tt.want.Email || p.PendingEmail != tt.want.PendingEmail || p.SlackUserID != tt.want.SlackUserID || p.PendingSlackUserID != tt.want.PendingSlackUserID {
+				t.Errorf("got %+v, want %+v", p, tt.want)
+			}
+			if len(needingCode) != len(tt.needingCode) {
+				t.Fatalf("codes needed for %q, want %q", needingCode, tt.needingCode)
+			}
+			for i, channel := range tt.needingCode {
+				a := p.confirmable()[0]
+				if channel == "slack" {
+					a = p.confirmable()[1]
+				}
+				if needingCode[i] != channel+"="+*a.pending {
+					t.Errorf("code needed for %q, want %s=%s", needingCode[i], channel, *a.pending)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package jobfit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOllamaURL     = "http://localhost:11434"
+	defaultOllamaTimeout = 2 * time.Minute
+)
+
+// ollamaTiers are the default local models for each of modelTiers.
+// OLLAMA_MODEL and OLLAMA_MODEL_LITE override them.
+var ollamaTiers = map[string]string{
+	"standard": "llama3",
+	"lite":     "llama3",
+}
+
+// ollamaModel calls a model served by Ollama, usually on the same machine or
+// network, so resumes never leave the deployment.
+type ollamaModel struct {
+	client  *http.Client
+	baseURL string
+	models  map[string]string // by tier
+	wait    time.Duration
+}
+
+// newOllamaModel reads OLLAMA_URL, the models and OLLAMA_TIMEOUT. Local
+// models on modest hardware are far slower than hosted ones, so calls get
+// longer than modelTimeout.
+func newOllamaModel() (*ollamaModel, error) {
+	base := cmp.Or(os.Getenv("OLLAMA_URL"), defaultOllamaURL)
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OLLAMA_URL must be an http(s) URL, got %q", base)
+	}
+	wait := defaultOllamaTimeout
+	if v := os.Getenv("OLLAMA_TIMEOUT"); v != "" {
+		if wait, err = time.ParseDuration(v); err != nil || wait <= 0 {
+			return nil, fmt.Errorf("OLLAMA_TIMEOUT must be a positive duration such as 90s, got %q", v)
+		}
+	}
+	return &ollamaModel{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		models: map[string]string{
+			"standard": cmp.Or(os.Getenv("OLLAMA_MODEL"), ollamaTiers["standard"]),
+			"lite":     cmp.Or(os.Getenv("OLLAMA_MODEL_LITE"), os.Getenv("OLLAMA_MODEL"), ollamaTiers["lite"]),
+		},
+		wait: wait,
+	}, nil
+}
+
+// generate implements languageModel, with Ollama's JSON mode on since every
+// prompt asks for a JSON object.
+func (m *ollamaModel) generate(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": m.models[modelTier(ctx)],
+		"messages": []any{
+			map[string]string{"role": "user", "content": prompt},
+		},
+		"format": "json",
+		"stream": false,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("ollama: %s: %w", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ollama: %s: %s", res.Status, out.Error)
+	}
+	if strings.TrimSpace(out.Message.Content) == "" {
+		return "", errModelEmpty
+	}
+	return out.Message.Content, nil
+}
+
+func (m *ollamaModel) provider() string {
+	return "ollama/" + m.models[defaultModelTier]
+}
+
+func (m *ollamaModel) timeout() time.Duration {
+	return m.wait
+}
+
+func (m *ollamaModel) close() {
+	m.client.CloseIdleConnections()
+}
@@ -0,0 +1,139 @@
+package jobfit
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// openAITiers are the default OpenAI models for each of modelTiers.
+// OPENAI_MODEL and OPENAI_MODEL_LITE override them.
+var openAITiers = map[string]string{
+	"standard": "gpt-4o",
+	"lite":     "gpt-4o-mini",
+}
+
+// openAIModel calls OpenAI's chat completions API, or any API compatible with
+// it at OPENAI_BASE_URL.
+type openAIModel struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	models  map[string]string // by tier
+	// apiVersion is set for Azure OpenAI, where the models are deployments
+	// addressed by URL and the key goes in the api-key header.
+	apiVersion string
+}
+
+func newOpenAIModel() (*openAIModel, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("LLM_PROVIDER=openai needs OPENAI_API_KEY")
+	}
+	return &openAIModel{
+		client:  &http.Client{},
+		baseURL: strings.TrimSuffix(cmp.Or(os.Getenv("OPENAI_BASE_URL"), defaultOpenAIBaseURL), "/"),
+		apiKey:  apiKey,
+		models: map[string]string{
+			"standard": cmp.Or(os.Getenv("OPENAI_MODEL"), openAITiers["standard"]),
+			"lite":     cmp.Or(os.Getenv("OPENAI_MODEL_LITE"), openAITiers["lite"]),
+		},
+	}, nil
+}
+
+// openAIResponse is the part of a chat completion the app reads.
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+			Refusal string `json:"refusal"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// generate implements languageModel. Every prompt asks for a JSON object, so
+// JSON mode is always on.
+func (m *openAIModel) generate(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": m.models[modelTier(ctx)],
+		"messages": []any{
+			map[string]string{"role": "system", "content": "You are a careful career advisor. Reply with a single JSON object exactly as the user asks."},
+			map[string]string{"role": "user", "content": prompt},
+		},
+		"response_format": map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return "", err
+	}
+	endpoint := m.baseURL + "/chat/completions"
+	if m.apiVersion != "" {
+		endpoint = fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			m.baseURL, url.PathEscape(m.models[modelTier(ctx)]), url.QueryEscape(m.apiVersion))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiVersion != "" {
+		req.Header.Set("Api-Key", m.apiKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &e)
+		return "", fmt.Errorf("openai: %s: %s", res.Status, e.Error.Message)
+	}
+
+	var or openAIResponse
+	if err := json.Unmarshal(data, &or); err != nil {
+		return "", fmt.Errorf("openai: %w", err)
+	}
+	if len(or.Choices) == 0 {
+		return "", errModelEmpty
+	}
+	choice := or.Choices[0]
+	if choice.FinishReason == "content_filter" || choice.Message.Refusal != "" {
+		return "", errModelBlocked
+	}
+	if choice.Message.Content == "" {
+		return "", errModelEmpty
+	}
+	return choice.Message.Content, nil
+}
+
+func (m *openAIModel) provider() string {
+	if m.apiVersion != "" {
+		return "azure-openai/" + m.models[defaultModelTier]
+	}
+	return "openai/" + m.models[defaultModelTier]
+}
+
+func (m *openAIModel) close() {
+	m.client.CloseIdleConnections()
+}
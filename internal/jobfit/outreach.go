@@ -0,0 +1,174 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxOutreachInputSize = 50000
+
+// outreachLengths are the message variants and the most characters LinkedIn
+// allows for each: a connection request note, a direct message to a
+// connection and an InMail. The InMail subject is limited separately.
+var outreachLengths = []struct {
+	kind  string
+	limit int
+	brief string
+}{
+	{"connection_note", 300, "two sentences, for a connection request"},
+	{"message", 800, "a short message to someone already connected, three to five sentences"},
+	{"inmail", 1900, "an InMail of two short paragraphs"},
+}
+
+const maxInMailSubject = 200
+
+// outreachRecipients are who a message can be addressed to, with how to pitch
+// it to them.
+var outreachRecipients = map[string]string{
+	"recruiter":      "a recruiter: make it easy to see the candidate fits the role and ask for a quick call about it.",
+	"hiring_manager": "the hiring manager: speak to the team's work and the problems the role solves, and ask for a short conversation.",
+}
+
+type outreachRequest struct {
+	Resume         string `json:"resume"`
+	JobDescription string `json:"jobDescription"`
+	// Recipient is "recruiter" unless set to "hiring_manager".
+	Recipient     string `json:"recipient,omitempty"`
+	RecipientName string `json:"recipientName,omitempty"`
+}
+
+// outreachMessage is one length variant. Characters is its length as
+// LinkedIn counts it, always within Limit.
+type outreachMessage struct {
+	Kind       string `json:"kind"`
+	Subject    string `json:"subject,omitempty"`
+	Body       string `json:"body"`
+	Characters int    `json:"characters"`
+	Limit      int    `json:"limit"`
+}
+
+type outreachResponse struct {
+	ID         string            `json:"id"`
+	Highlights []string          `json:"highlights"`
+	Messages   []outreachMessage `json:"messages"`
+}
+
+// fitMessage shortens s to at most limit characters, ending at the last
+// sentence that fits, or failing that the last whole word.
+func fitMessage(s string, limit int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	cut := string([]rune(s)[:limit])
+	if i := strings.LastIndexAny(cut, ".!?"); i > len(cut)/2 {
+		return cut[:i+1]
+	}
+	if i := strings.LastIndexAny(cut, " \n"); i > 0 {
+		return strings.TrimRight(cut[:i], " ,;:-")
+	}
+	return cut
+}
+
+// outreachHandler writes short messages to a recruiter or hiring manager about
+// a job, each citing a few highlights from the resume, in lengths that fit
+// LinkedIn's limits. Messages the model writes too long are cut back to the
+// last sentence that fits rather than rejected.
+func (app *application) outreachHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req outreachRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		http.Error(w, "A resume and a job description are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resume) > maxOutreachInputSize || len(req.JobDescription) > maxOutreachInputSize {
+		http.Error(w, "Resume or job description is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Recipient = cmp.Or(req.Recipient, "recruiter")
+	pitch, ok := outreachRecipients[req.Recipient]
+	if !ok {
+		http.Error(w, `recipient must be "recruiter" or "hiring_manager"`, http.StatusBadRequest)
+		return
+	}
+	req.RecipientName = strings.TrimSpace(req.RecipientName)
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionOutreach); !ok {
+		return
+	}
+
+	res := outreachResponse{ID: newULID(time.Now())}
+	log := app.logger.With("outreachID", res.ID, "ip", ip)
+
+	var variants strings.Builder
+	for _, l := range outreachLengths {
+		fmt.Fprintf(&variants, "\t\t- %q: %s, at most %d characters.\n", l.kind, l.brief, l.limit)
+	}
+	prompt := fmt.Sprintf(`
+		Write short, personal outreach messages from the candidate to %s about the job below.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "highlights": a JSON array of two or three strings, the candidate's achievements from the resume most relevant to the job, each under 15 words.
+		- "subject": a string, the InMail subject line, at most %d characters, naming the role.
+		- "messages": a JSON object with one string per variant:
+%s
+		Each message names the role and company, mentions the highlights (as many as fit), and ends with one clear ask. Address the recipient as %s.
+		Do not invent experience that isn't on the resume, and do not use placeholders in square brackets.
+
+		**Resume:**
+		---
+		%s
+		---
+		**Job Description:**
+		---
+		%s
+		---
+	`, pitch, maxInMailSubject, variants.String(), cmp.Or(req.RecipientName, "\"Hi there\" since their name isn't known"), req.Resume, req.JobDescription)
+
+	var out struct {
+		Highlights []string          `json:"highlights"`
+		Subject    string            `json:"subject"`
+		Messages   map[string]string `json:"messages"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	res.Highlights = out.Highlights
+	if res.Highlights == nil {
+		res.Highlights = []string{}
+	}
+	res.Messages = []outreachMessage{}
+	for _, l := range outreachLengths {
+		body := fitMessage(out.Messages[l.kind], l.limit)
+		if body == "" {
+			continue
+		}
+		m := outreachMessage{Kind: l.kind, Body: body, Characters: utf8.RuneCountInString(body), Limit: l.limit}
+		if l.kind == "inmail" {
+			m.Subject = fitMessage(out.Subject, maxInMailSubject)
+		}
+		res.Messages = append(res.Messages, m)
+	}
+	log.Info("wrote outreach messages", "recipient", req.Recipient, "messages", len(res.Messages))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
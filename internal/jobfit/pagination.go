@@ -0,0 +1,67 @@
+package jobfit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor marks the last item a client has seen. It records the item's sort
+// key rather than an offset so pages stay stable while new items are added.
+type pageCursor struct {
+	ID    string `json:"id"`
+	Score int    `json:"score,omitempty"`
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// parsePageParams reads the "limit" and "cursor" query parameters shared by all
+// list endpoints.
+func parsePageParams(r *http.Request) (limit int, cursor *pageCursor, err error) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, nil, errors.New("limit must be a positive integer")
+		}
+		limit = min(limit, maxPageSize)
+	}
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return 0, nil, err
+		}
+		cursor = &c
+	}
+	return limit, cursor, nil
+}
+
+// page is the envelope returned by list endpoints.
+type page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,325 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	stripeAPI     = "https://api.stripe.com/v1"
+	stripeTimeout = 10 * time.Second
+	maxStripeBody = 64 << 10
+	// Fulfilled sessions are remembered for longer than Stripe keeps retrying
+	// a webhook, three days, so a retry never grants credits twice.
+	stripeSessionRetention = 7 * 24 * time.Hour
+)
+
+// A creditPack is a one-time purchase of bonus credits, sold at a Stripe
+// price. Bought credits are bonus credits like those from coupons and
+// referrals, spent once the daily allowance runs out.
+type creditPack struct {
+	Name    string `json:"name"`
+	Credits int64  `json:"credits"`
+	priceID string
+}
+
+// stripeConfig is set when STRIPE_SECRET_KEY is.
+type stripeConfig struct {
+	client        *http.Client
+	secretKey     string
+	webhookSecret string
+	packs         []creditPack
+}
+
+func stripeSessionKey(id string) string { return rkey("stripe", "session", id) }
+
+// loadStripeConfig reads STRIPE_SECRET_KEY, STRIPE_WEBHOOK_SECRET and
+// CREDIT_PACKS, a list of name=credits:price pairs such as
+// "starter=20:price_123".
+func loadStripeConfig() (*stripeConfig, error) {
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	if secretKey == "" {
+		return nil, nil
+	}
+	sc := &stripeConfig{
+		client:        &http.Client{Timeout: stripeTimeout},
+		secretKey:     secretKey,
+		webhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+	}
+	if sc.webhookSecret == "" {
+		return nil, errors.New("STRIPE_SECRET_KEY needs STRIPE_WEBHOOK_SECRET, or purchases are never fulfilled")
+	}
+	for _, pair := range strings.Split(os.Getenv("CREDIT_PACKS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(pair, "=")
+		credits, priceID, ok2 := strings.Cut(rest, ":")
+		name, priceID = strings.TrimSpace(name), strings.TrimSpace(priceID)
+		n, err := strconv.ParseInt(strings.TrimSpace(credits), 10, 64)
+		if !ok || !ok2 || err != nil || n < 1 || name == "" || priceID == "" {
+			return nil, fmt.Errorf("CREDIT_PACKS entry %q must look like name=credits:price_id", pair)
+		}
+		if _, dup := sc.pack(name); dup {
+			return nil, fmt.Errorf("CREDIT_PACKS names %q twice", name)
+		}
+		sc.packs = append(sc.packs, creditPack{Name: name, Credits: n, priceID: priceID})
+	}
+	if len(sc.packs) == 0 {
+		return nil, errors.New("STRIPE_SECRET_KEY needs CREDIT_PACKS")
+	}
+	return sc, nil
+}
+
+func (sc *stripeConfig) pack(name string) (creditPack, bool) {
+	for _, p := range sc.packs {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return creditPack{}, false
+}
+
+// createCheckoutSession starts a Stripe Checkout payment for pack and returns
+// the URL to send the buyer to. The bucket to credit travels in the
+// session's metadata and comes back in the webhook.
+func (sc *stripeConfig) createCheckoutSession(ctx context.Context, pack creditPack, bucket, successURL, cancelURL string) (string, error) {
+	form := url.Values{
+		"mode":                    {"payment"},
+		"line_items[0][price]":    {pack.priceID},
+		"line_items[0][quantity]": {"1"},
+		"success_url":             {successURL},
+		"cancel_url":              {cancelURL},
+		"metadata[bucket]":        {bucket},
+		"metadata[pack]":          {pack.Name},
+		"metadata[credits]":       {strconv.FormatInt(pack.Credits, 10)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stripeAPI+"/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(sc.secretKey, "")
+	res, err := sc.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxStripeBody))
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		URL   string `json:"url"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(data, &out)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("stripe: %s: %s", res.Status, out.Error.Message)
+	}
+	if out.URL == "" {
+		return "", errors.New("stripe: checkout session has no URL")
+	}
+	return out.URL, nil
+}
+
+// verifyStripeSignature checks a Stripe-Signature header, "t=<unix>,v1=<sig>",
+// where the signature is signPayload's over the timestamp and body.
+func verifyStripeSignature(header, secret string, body []byte, now time.Time) bool {
+	var timestamp string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return false
+	}
+	want := signPayload(secret, timestamp, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fulfillPurchaseScript grants a session's credits and records the purchase
+// unless it was already fulfilled. It returns the new bonus balance, or -1
+// for a repeat.
+var fulfillPurchaseScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], 1, 'NX', 'EX', ARGV[3]) then return -1 end
+local balance = redis.call('INCRBY', KEYS[2], ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+redis.call('LPUSH', KEYS[3], ARGV[4])
+redis.call('LTRIM', KEYS[3], 0, tonumber(ARGV[6]) - 1)
+redis.call('EXPIRE', KEYS[3], ARGV[5])
+return balance
+`)
+
+// creditPacksHandler lists the packs on sale.
+func (app *application) creditPacksHandler(w http.ResponseWriter, r *http.Request) {
+	if app.stripe == nil {
+		http.Error(w, "Credit packs are not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]creditPack{"packs": app.stripe.packs})
+}
+
+// checkoutCreditPackHandler starts the purchase of a credit pack for the
+// caller's client and returns the Stripe Checkout page to redirect to.
+// Credits are only sold to clients with a signed token (it's routed through
+// signedClientOnly), so they can't end up with whoever else shares or claims
+// the buyer's IP address.
+func (app *application) checkoutCreditPackHandler(w http.ResponseWriter, r *http.Request) {
+	if app.stripe == nil {
+		http.Error(w, "Credit packs are not available", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Pack string `json:"pack"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	pack, ok := app.stripe.pack(req.Pack)
+	if !ok {
+		http.Error(w, "Unknown credit pack", http.StatusNotFound)
+		return
+	}
+
+	bucket := "client:" + signedClientID(r.Context())
+	checkoutURL, err := app.stripe.createCheckoutSession(r.Context(), pack, bucket,
+		app.publicURL(r, "/?purchase=complete"), app.publicURL(r, "/?purchase=cancelled"))
+	if err != nil {
+		app.logger.Error("failed to create checkout session", "pack", pack.Name, "error", err)
+		http.Error(w, "Could not start checkout", http.StatusBadGateway)
+		return
+	}
+	app.logger.Info("checkout started", "pack", pack.Name, "bucket", bucket)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": checkoutURL})
+}
+
+// stripeWebhookHandler fulfills paid checkout sessions. Card payments arrive
+// as checkout.session.completed; delayed methods such as bank debits complete
+// unpaid and are fulfilled by checkout.session.async_payment_succeeded.
+// Stripe retries until it gets a 2xx, so only failures to record the grant
+// answer otherwise.
+func (app *application) stripeWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if app.stripe == nil {
+		http.Error(w, "Credit packs are not available", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStripeBody))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !verifyStripeSignature(r.Header.Get("Stripe-Signature"), app.stripe.webhookSecret, body, time.Now()) {
+		app.logger.Warn("stripe webhook with a bad signature", "ip", getIPAddress(r))
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID            string            `json:"id"`
+				PaymentStatus string            `json:"payment_status"`
+				Metadata      map[string]string `json:"metadata"`
+				AmountTotal   int64             `json:"amount_total"`
+				Currency      string            `json:"currency"`
+				PaymentIntent string            `json:"payment_intent"`
+				Created       int64             `json:"created"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	session := event.Data.Object
+	log := app.logger.With("eventID", event.ID, "type", event.Type, "sessionID", session.ID)
+	if (event.Type != "checkout.session.completed" && event.Type != "checkout.session.async_payment_succeeded") ||
+		session.PaymentStatus != "paid" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	bucket := session.Metadata["bucket"]
+	credits, err := strconv.ParseInt(session.Metadata["credits"], 10, 64)
+	if bucket == "" || err != nil || credits < 1 {
+		// Not one of ours, e.g. a payment link for something else.
+		log.Warn("paid checkout session without credit pack metadata")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	p := purchase{
+		ID:            session.ID,
+		Pack:          session.Metadata["pack"],
+		Credits:       credits,
+		Amount:        session.AmountTotal,
+		Currency:      session.Currency,
+		PaymentIntent: session.PaymentIntent,
+		CreatedAt:     time.Unix(session.Created, 0).UTC(),
+	}
+	if p.PaymentIntent != "" {
+		// Best effort: the billing page looks up any receipt missing here.
+		if p.ReceiptURL, err = app.stripe.receiptURL(r.Context(), p.PaymentIntent); err != nil {
+			log.Warn("failed to look up receipt", "error", err)
+		}
+	}
+	record, err := json.Marshal(p)
+	if err != nil {
+		log.Error("failed to encode purchase", "error", err)
+		http.Error(w, "Could not fulfill purchase", http.StatusInternalServerError)
+		return
+	}
+
+	keys := []string{stripeSessionKey(session.ID), bonusKey(bucket), purchasesKey(bucket)}
+	balance, err := fulfillPurchaseScript.Run(r.Context(), app.rdb, keys,
+		credits, int(bonusCreditRetention.Seconds()), int(stripeSessionRetention.Seconds()),
+		record, int(purchaseRetention.Seconds()), maxPurchases).Int64()
+	if err != nil {
+		log.Error("failed to grant purchased credits", "error", err)
+		http.Error(w, "Could not fulfill purchase", http.StatusInternalServerError)
+		return
+	}
+	if balance < 0 {
+		log.Info("checkout session already fulfilled")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	app.forgetQuota(bucket)
+	log.Info("credit pack fulfilled", "pack", session.Metadata["pack"], "credits", credits, "bucket", bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,68 @@
+package jobfit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":"evt_1","type":"checkout.session.completed"}`)
+	now := time.Unix(1_700_000_000, 0)
+	header := func(at time.Time, secret string, body []byte) string {
+		ts := strconv.FormatInt(at.Unix(), 10)
+		return "t=" + ts + ",v1=" + signPayload(secret, ts, body)
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"valid", header(now, secret, body), true},
+		{"at the edge of the tolerance", header(now.Add(-signatureMaxAge), secret, body), true},
+		{"clock slightly ahead", header(now.Add(time.Minute), secret, body), true},
+		{"too old", header(now.Add(-signatureMaxAge-time.Second), secret, body), false},
+		{"too far ahead", header(now.Add(signatureMaxAge+time.Second), secret, body), false},
+		{"wrong secret", header(now, "whsec_other", body), false},
+		{"different body", header(now, secret, []byte(`{"id":"evt_2"}`)), false},
+		{"one of several signatures matches", fmt.Sprintf("t=%d,v1=deadbeef,v1=%s,v0=ignored", now.Unix(), signPayload(secret, strconv.FormatInt(now.Unix(), 10), body)), true},
+		{"only a v0 signature", fmt.Sprintf("t=%d,v0=%s", now.Unix(), signPayload(secret, strconv.FormatInt(now.Unix(), 10), body)), false},
+		{"spaces around parts", fmt.Sprintf("t=%d, v1=%s", now.Unix(), signPayload(secret, strconv.FormatInt(now.Unix(), 10), body)), true},
+		{"signature for another timestamp", fmt.Sprintf("t=%d,v1=%s", now.Unix(), signPayload(secret, strconv.FormatInt(now.Unix()-1, 10), body)), false},
+		{"missing timestamp", "v1=" + signPayload(secret, "", body), false},
+		{"bad timestamp", "t=soon,v1=" + signPayload(secret, "soon", body), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyStripeSignature(tt.header, secret, body, now); got != tt.want {
+				t.Errorf("verifyStripeSignature(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckoutNeedsSignedClient(t *testing.T) {
+	app := &application{clientSecret: []byte("s"), stripe: &stripeConfig{}}
+	for name, cookie := range map[string]string{
+		"no cookie":      "",
+		"unsigned":       "0123456789abcdef0123456789abcdef",
+		"bad signature":  "0123456789abcdef0123456789abcdef.forged",
+		"someone else's": "0123456789abcdef0123456789abcdef." + app.signClientID("fedcba9876543210fedcba9876543210"),
+	} {
+		r := httptest.NewRequest("POST", "/credit-packs/checkout", nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: clientCookieName, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		app.signedClientOnly(http.HandlerFunc(app.checkoutCreditPackHandler)).ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", name, w.Code)
+		}
+	}
+}
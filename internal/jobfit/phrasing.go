@@ -0,0 +1,121 @@
+package jobfit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxPhrasingSuggestions caps how many weak phrases are reported, so the
+// list stays something a user will work through.
+const maxPhrasingSuggestions = 10
+
+// weakPhrase is a dictionary entry: a weak verb or cliché and what to write
+// instead.
+type weakPhrase struct {
+	phrase string
+	// kind is "weak_verb" for wording that hides what the candidate did, or
+	// "cliche" for claims every resume makes.
+	kind         string
+	alternatives []string
+}
+
+// weakPhrases is checked against every resume. Clichés are suggested
+// replacements that show the quality rather than claim it.
+var weakPhrases = []weakPhrase{
+	{"responsible for", "weak_verb", []string{"led", "owned", "managed", "ran"}},
+	{"duties included", "weak_verb", []string{"handled", "delivered", "oversaw"}},
+	{"tasked with", "weak_verb", []string{"delivered", "completed", "executed"}},
+	{"worked on", "weak_verb", []string{"built", "developed", "designed", "shipped"}},
+	{"helped", "weak_verb", []string{"contributed to", "enabled", "supported"}},
+	{"assisted with", "weak_verb", []string{"supported", "co-led", "contributed to"}},
+	{"involved in", "weak_verb", []string{"contributed to", "drove", "participated in"}},
+	{"participated in", "weak_verb", []string{"contributed to", "collaborated on", "co-led"}},
+	{"handled", "weak_verb", []string{"resolved", "managed", "processed"}},
+	{"was in charge of", "weak_verb", []string{"led", "directed", "headed"}},
+	{"team player", "cliche", []string{"a specific project you delivered with others"}},
+	{"hard worker", "cliche", []string{"an example of output or results"}},
+	{"hard-working", "cliche", []string{"an example of output or results"}},
+	{"detail-oriented", "cliche", []string{"an error rate, audit result or quality measure"}},
+	{"go-getter", "cliche", []string{"something you started without being asked"}},
+	{"self-starter", "cliche", []string{"something you started without being asked"}},
+	{"think outside the box", "cliche", []string{"a problem you solved in an unusual way"}},
+	{"excellent communication skills", "cliche", []string{"a presentation, document or audience you communicated with"}},
+	{"strong work ethic", "cliche", []string{"a deadline or target you met"}},
+	{"results-oriented", "cliche", []string{"a measurable result"}},
+	{"results-driven", "cliche", []string{"a measurable result"}},
+	{"proven track record", "cliche", []string{"the record itself, with numbers"}},
+	{"best of breed", "cliche", []string{"a concrete comparison or ranking"}},
+	{"go above and beyond", "cliche", []string{"what you did beyond the role"}},
+}
+
+// weakPhraseRegexes matches each entry of weakPhrases as whole words, in the
+// same order.
+var weakPhraseRegexes = func() []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(weakPhrases))
+	for i, p := range weakPhrases {
+		res[i] = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(p.phrase) + `\b`)
+	}
+	return res
+}()
+
+// phrasingSuggestion is a weak phrase found in the resume.
+type phrasingSuggestion struct {
+	Phrase string `json:"phrase"`
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	// Example is the first line it appears on.
+	Example      string   `json:"example"`
+	Alternatives []string `json:"alternatives"`
+}
+
+// suggestPhrasing finds the weak verbs and clichés in resume. It's a
+// dictionary lookup, so it costs no model calls. Suggestions are in the order
+// they first appear.
+func suggestPhrasing(resume string) []phrasingSuggestion {
+	type found struct {
+		phrasingSuggestion
+		at int
+	}
+	var all []found
+	for i, re := range weakPhraseRegexes {
+		locs := re.FindAllStringIndex(resume, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		start := strings.LastIndexByte(resume[:locs[0][0]], '\n') + 1
+		end := len(resume)
+		if n := strings.IndexByte(resume[locs[0][1]:], '\n'); n >= 0 {
+			end = locs[0][1] + n
+		}
+		p := weakPhrases[i]
+		all = append(all, found{phrasingSuggestion{
+			Phrase:       p.phrase,
+			Kind:         p.kind,
+			Count:        len(locs),
+			Example:      strings.Trim(strings.TrimSpace(resume[start:end]), "-•* "),
+			Alternatives: p.alternatives,
+		}, locs[0][0]})
+	}
+	slices.SortFunc(all, func(a, b found) int { return a.at - b.at })
+
+	suggestions := make([]phrasingSuggestion, 0, min(len(all), maxPhrasingSuggestions))
+	for _, f := range all[:min(len(all), maxPhrasingSuggestions)] {
+		suggestions = append(suggestions, f.phrasingSuggestion)
+	}
+	return suggestions
+}
+
+// phrasingImprovement summarizes suggestions as one improvement bullet.
+func phrasingImprovement(suggestions []phrasingSuggestion) string {
+	examples := make([]string, 0, 3)
+	for _, s := range suggestions[:min(len(suggestions), 3)] {
+		if s.Kind == "cliche" {
+			examples = append(examples, fmt.Sprintf("%q (show %s instead)", s.Phrase, s.Alternatives[0]))
+		} else {
+			examples = append(examples, fmt.Sprintf("%q (try %q)", s.Phrase, s.Alternatives[0]))
+		}
+	}
+	return "- **Wording:** Replace weak verbs and clichés such as " + strings.Join(examples, ", ") + "."
+}
@@ -0,0 +1,143 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	portfolioTimeout  = 8 * time.Second
+	maxPortfolioBytes = 2 << 20
+	// portfolioTokenBudget caps how much of the prompt the portfolio may use.
+	// Pages over budget are summarized by the model first; anything still
+	// over is cut off.
+	portfolioTokenBudget = 1500
+	// A rough but stable estimate for English prose.
+	charsPerToken = 4
+)
+
+var portfolioClient = newGuardedClient(portfolioTimeout)
+
+var errPortfolioNotHTML = errors.New("portfolio is not an HTML page")
+
+// parsePortfolioURL accepts an http(s) URL, adding https:// if the scheme was
+// left off.
+func parsePortfolioURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFetchURL(u); err != nil {
+		return nil, fmt.Errorf("unsupported portfolio URL %q: %w", raw, err)
+	}
+	return u, nil
+}
+
+func estimateTokens(s string) int {
+	return len(s) / charsPerToken
+}
+
+// fetchPortfolioText downloads a page and returns its visible text.
+func fetchPortfolioText(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "JobFit/1.0")
+	resp, err := portfolioClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("portfolio returned %s", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return "", errPortfolioNotHTML
+	}
+	return visibleText(io.LimitReader(resp.Body, maxPortfolioBytes)), nil
+}
+
+// visibleText extracts the text a visitor would read, one block per line.
+func visibleText(r io.Reader) string {
+	var lines []string
+	var cur strings.Builder
+	flush := func() {
+		if line := strings.Join(strings.Fields(cur.String()), " "); line != "" {
+			lines = append(lines, line)
+		}
+		cur.Reset()
+	}
+
+	z := html.NewTokenizer(r)
+	skip := 0
+	for {
+		switch tt := z.Next(); tt {
+		case html.ErrorToken:
+			flush()
+			return strings.Join(lines, "\n")
+		case html.StartTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript", "svg", "nav", "footer":
+				if tt == html.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			case "p", "div", "li", "h1", "h2", "h3", "h4", "h5", "h6", "section", "article", "br", "tr":
+				flush()
+			}
+		case html.TextToken:
+			if skip == 0 {
+				cur.Write(z.Text())
+				cur.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// portfolioContext fetches the portfolio and fits it into the token budget.
+func (app *application) portfolioContext(ctx context.Context, log *slog.Logger, u *url.URL) (string, error) {
+	text, err := fetchPortfolioText(ctx, u)
+	if err != nil {
+		return "", err
+	}
+	if estimateTokens(text) > portfolioTokenBudget {
+		var summary struct {
+			Summary string `json:"summary"`
+		}
+		prompt := fmt.Sprintf(`
+		Summarize the following portfolio website for a recruiter in at most %d words.
+		Focus on the projects, the candidate's role in each, tools and skills used, clients and measurable results.
+		Your response MUST be a valid JSON object with a single key "summary" holding the summary as a string.
+
+		**Portfolio:**
+		---
+		%s
+		---
+	`, portfolioTokenBudget*3/4, text)
+		if err := app.generateJSON(ctx, log, prompt, &summary); err != nil {
+			log.Warn("portfolio summary failed, truncating instead", "error", err)
+		} else {
+			text = summary.Summary
+		}
+	}
+	if maxChars := portfolioTokenBudget * charsPerToken; len(text) > maxChars {
+		text = strings.ToValidUTF8(text[:maxChars], "") + "…"
+	}
+	return text, nil
+}
@@ -0,0 +1,169 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AnalysisOptions shape how an analysis is written. Each can be sent with a
+// request, and any left out fall back to the defaults the client saved with
+// PUT /me/preferences.
+type AnalysisOptions struct {
+	// Tone is how the feedback is worded: encouraging, neutral or direct.
+	Tone string `json:"tone,omitempty"`
+	// DetailLevel is how much feedback to give: brief, standard or detailed.
+	DetailLevel string `json:"detailLevel,omitempty"`
+	// Language is the language the feedback is written in, such as "es" or
+	// "pt-br". English unless set.
+	Language string `json:"language,omitempty"`
+	// Profile is where the candidate is in their career, so the advice
+	// fits: graduate, career-changer, experienced or executive.
+	Profile string `json:"profile,omitempty"`
+	// ModelTier picks the model; see modelTiers.
+	ModelTier string `json:"modelTier,omitempty"`
+}
+
+// The accepted values of each option and what they add to the prompt. An
+// empty instruction leaves the prompt as it is.
+var (
+	analysisTones = map[string]string{
+		"encouraging": "Word the feedback warmly and encouragingly, starting from what the resume does well.",
+		"neutral":     "",
+		"direct":      "Be blunt and direct: skip praise and state each problem plainly.",
+	}
+	analysisDetailLevels = map[string]string{
+		"brief":    "Keep it short: at most three improvements and three next steps, one sentence each.",
+		"standard": "",
+		"detailed": "Be thorough: give every worthwhile improvement and next step, with an example rewrite for each improvement.",
+	}
+	analysisProfiles = map[string]string{
+		"graduate":       "The candidate is a recent graduate: weigh education, projects and internships, and suggest ways to make up for limited experience.",
+		"career-changer": "The candidate is changing careers: look for transferable skills and suggest how to frame past experience for this role.",
+		"experienced":    "",
+		"executive":      "The candidate is applying for a senior leadership role: focus on scope, strategy, leadership and business impact.",
+	}
+)
+
+func preferencesKey(owner string) string { return rkey("prefs", owner) }
+
+// checkOption returns a message if v isn't one of allowed's keys.
+func checkOption[V any](name, v string, allowed map[string]V) string {
+	if v == "" {
+		return ""
+	}
+	if _, ok := allowed[v]; !ok {
+		return fmt.Sprintf("%s must be one of %s", name, strings.Join(slices.Sorted(maps.Keys(allowed)), ", "))
+	}
+	return ""
+}
+
+// check returns why the options are invalid, or "".
+func (o AnalysisOptions) check() string {
+	if o.Language != "" && !localePattern.MatchString(o.Language) {
+		return "language must be a language code such as es or pt-br"
+	}
+	return cmp.Or(
+		checkOption("tone", o.Tone, analysisTones),
+		checkOption("detailLevel", o.DetailLevel, analysisDetailLevels),
+		checkOption("profile", o.Profile, analysisProfiles),
+		checkOption("modelTier", o.ModelTier, modelTiers),
+	)
+}
+
+// withDefaults fills in the options o leaves out from defaults.
+func (o AnalysisOptions) withDefaults(defaults AnalysisOptions) AnalysisOptions {
+	return AnalysisOptions{
+		Tone:        cmp.Or(o.Tone, defaults.Tone),
+		DetailLevel: cmp.Or(o.DetailLevel, defaults.DetailLevel),
+		Language:    cmp.Or(o.Language, defaults.Language),
+		Profile:     cmp.Or(o.Profile, defaults.Profile),
+		ModelTier:   cmp.Or(o.ModelTier, defaults.ModelTier),
+	}
+}
+
+// promptNotes are the analysis prompt's instructions for these options.
+func (o AnalysisOptions) promptNotes() string {
+	var notes []string
+	for _, n := range []string{analysisTones[o.Tone], analysisDetailLevels[o.DetailLevel], analysisProfiles[o.Profile]} {
+		if n != "" {
+			notes = append(notes, n)
+		}
+	}
+	if o.Language != "" && o.Language != "en" {
+		notes = append(notes, fmt.Sprintf("Write the improvements and next steps in the language with code %q, keeping the JSON keys in English.", o.Language))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "\n\t\t**How to write the feedback:**\n\t\t- " + strings.Join(notes, "\n\t\t- ") + "\n"
+}
+
+func (app *application) loadPreferences(ctx context.Context, owner string) (AnalysisOptions, error) {
+	var p AnalysisOptions
+	data, err := app.rdb.Get(ctx, preferencesKey(owner)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// applyPreferences fills in the options req leaves out from owner's saved
+// defaults. If they can't be read the request goes ahead without them.
+func (app *application) applyPreferences(ctx context.Context, owner string, req *AnalysisRequest) {
+	p, err := app.loadPreferences(ctx, owner)
+	if err != nil {
+		app.logger.Warn("failed to load preferences", "error", err)
+		return
+	}
+	req.AnalysisOptions = req.AnalysisOptions.withDefaults(p)
+}
+
+// preferencesHandler reads (GET) or replaces (PUT) the caller's default
+// analysis options.
+func (app *application) preferencesHandler(w http.ResponseWriter, r *http.Request) {
+	owner := app.clientID(w, r)
+
+	var p AnalysisOptions
+	if r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if msg := p.check(); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		data, err := json.Marshal(p)
+		if err == nil {
+			err = app.rdb.Set(r.Context(), preferencesKey(owner), data, 0).Err()
+		}
+		if err != nil {
+			app.logger.Error("failed to save preferences", "error", err)
+			http.Error(w, "Could not save preferences", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		var err error
+		if p, err = app.loadPreferences(r.Context(), owner); err != nil {
+			app.logger.Error("failed to load preferences", "error", err)
+			http.Error(w, "Could not load preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,52 @@
+package jobfit
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// configureOutboundProxy routes upstream HTTP calls (Gemini, GitHub, link and
+// portfolio fetches, webhooks and Slack) through OUTBOUND_PROXY when it's set,
+// still honoring NO_PROXY. Otherwise the usual HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY variables apply.
+//
+// Every client here uses the default transport, either directly or by cloning
+// it, so this has to run before any client is created.
+func configureOutboundProxy(logger *slog.Logger) error {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unexpected default transport %T", http.DefaultTransport)
+	}
+
+	v := os.Getenv("OUTBOUND_PROXY")
+	if v == "" {
+		cfg := httpproxy.FromEnvironment()
+		if cfg.HTTPSProxy != "" || cfg.HTTPProxy != "" {
+			logger.Info("using proxy from environment", "httpsProxy", redactProxy(cfg.HTTPSProxy), "httpProxy", redactProxy(cfg.HTTPProxy), "noProxy", cfg.NoProxy)
+		}
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+		return fmt.Errorf("OUTBOUND_PROXY must be an http, https or socks5 URL, got %q", redactProxy(v))
+	}
+
+	cfg := &httpproxy.Config{HTTPProxy: v, HTTPSProxy: v, NoProxy: httpproxy.FromEnvironment().NoProxy}
+	proxy := cfg.ProxyFunc()
+	transport.Proxy = func(r *http.Request) (*url.URL, error) { return proxy(r.URL) }
+	logger.Info("using outbound proxy", "proxy", u.Redacted(), "noProxy", cfg.NoProxy)
+	return nil
+}
+
+// redactProxy hides any password in a proxy URL for logging.
+func redactProxy(v string) string {
+	if u, err := url.Parse(v); err == nil && u.Host != "" {
+		return u.Redacted()
+	}
+	return v
+}
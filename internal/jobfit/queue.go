@@ -0,0 +1,417 @@
+package jobfit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Analyses posted to /analyses/async are queued on a Redis stream and run by
+// the worker, so analysis throughput scales with the number of workers rather
+// than API servers. The stream entry only names the job; the request, which
+// holds the resume, is stored sealed under analysisJobKey and dropped once
+// the job has run.
+const (
+	analysisQueueGroup = "analysis-workers"
+
+	analysisJobRetention = 24 * time.Hour
+	// A job a worker claimed but didn't finish in this long is assumed lost
+	// with its worker and run again. Analyses take well under a minute.
+	analysisJobClaimIdle = 5 * time.Minute
+	analysisQueueBlock   = 5 * time.Second
+
+	defaultWorkerConcurrency = 4
+	maxWorkerConcurrency     = 64
+)
+
+func analysisQueueKey() string { return rkey("queue", "analysis") }
+
+const (
+	jobDeferred = "deferred"
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+)
+
+func analysisJobKey(id string) string {
+	return rkey("analysis", "job", id)
+}
+
+// An analysisJob is an analysis waiting for, or run by, a worker. Its ID
+// becomes the analysis ID.
+type analysisJob struct {
+	ID      string           `json:"id"`
+	Status  string           `json:"status"`
+	Error   string           `json:"error,omitempty"`
+	Owner   string           `json:"owner"`
+	IP      string           `json:"ip"`
+	Request *AnalysisRequest `json:"request,omitempty"`
+	// Fields outlives Request so the result can be trimmed when polled.
+	Fields     []string  `json:"fields,omitempty"`
+	QuickScore *int      `json:"quickScore,omitempty"`
+	QueuedAt   time.Time `json:"queuedAt"`
+	// SignedClient is whether the request carried Owner's signed token,
+	// which paying out a referral needs once the job has run.
+	SignedClient bool `json:"signedClient,omitempty"`
+	// RunAfter is when a deferred job is queued for a worker.
+	RunAfter   *time.Time `json:"runAfter,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+var errAnalysisJobNotFound = errors.New("analysis job not found")
+
+func loadWorkerConcurrency() (int, error) {
+	v := os.Getenv("WORKER_CONCURRENCY")
+	if v == "" {
+		return defaultWorkerConcurrency, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxWorkerConcurrency {
+		return 0, fmt.Errorf("WORKER_CONCURRENCY must be between 1 and %d, got %q", maxWorkerConcurrency, v)
+	}
+	return n, nil
+}
+
+func (app *application) saveAnalysisJob(ctx context.Context, job *analysisJob) error {
+	data, err := app.marshalSealed(analysisJobKey(job.ID), job)
+	if err != nil {
+		return err
+	}
+	ttl := analysisJobRetention
+	if job.RunAfter != nil {
+		ttl += time.Until(*job.RunAfter)
+	}
+	return app.rdb.Set(ctx, analysisJobKey(job.ID), data, ttl).Err()
+}
+
+func (app *application) loadAnalysisJob(ctx context.Context, id string) (*analysisJob, error) {
+	data, err := app.rdb.Get(ctx, analysisJobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errAnalysisJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job analysisJob
+	if err := app.unmarshalSealed(analysisJobKey(id), data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// enqueueAnalysisHandler takes the same request as chatHandler but queues it
+// for a worker, replying straight away with where to poll for the result.
+// Clients with a signed token who are out of credits have it deferred until
+// their credits reset rather than refused.
+func (app *application) enqueueAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	ip := getIPAddress(r)
+	owner := app.clientID(w, r)
+	if !app.requireConsent(w, r, owner) {
+		return
+	}
+
+	// As in chatHandler, nothing is charged for a request that can't run.
+	var req AnalysisRequest
+	if msg, status := app.decodeAnalysisRequest(w, r, &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	req.readFields(r)
+	app.applyPreferences(r.Context(), owner, &req)
+	if msg, status := checkAnalysisRequest(&req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	if msg, status := resolveJobURL(r.Context(), &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	usage, ok := app.tryChargeQuota(w, r, actionAnalyze)
+	if !ok {
+		return
+	}
+	var runAfter time.Time
+	if usage.exceeded != nil {
+		if runAfter, ok = app.deferralTime(r, usage); !ok {
+			app.rejectOverQuota(w, r, actionAnalyze, usage)
+			return
+		}
+	} else {
+		app.checkQuotaAlerts(r, actionAnalyze, usage)
+	}
+
+	now := time.Now()
+	job := &analysisJob{ID: newULID(now), Status: jobQueued, Owner: owner, IP: ip, Request: &req, Fields: req.Fields, QueuedAt: now.UTC(), SignedClient: app.carriesSignedToken(r, owner)}
+	if !runAfter.IsZero() {
+		app.deferAnalysisJob(w, r, job, runAfter, usage)
+		return
+	}
+	app.queueAnalysisJob(w, r, job, usage)
+}
+
+// quickAnalysisHandler is the two-phase version of enqueueAnalysisHandler. It
+// scores the resume with a short score-only call, which takes a few seconds,
+// and replies with that score while the full analysis is queued; the detailed
+// report is then polled for as usual. The full analysis counts the quick
+// score as one of its samples, so the final score rarely moves far from it.
+func (app *application) quickAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	ip := getIPAddress(r)
+	owner := app.clientID(w, r)
+	if !app.requireConsent(w, r, owner) {
+		return
+	}
+
+	// As in chatHandler, nothing is charged for a request that can't run.
+	var req AnalysisRequest
+	if msg, status := app.decodeAnalysisRequest(w, r, &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	req.readFields(r)
+	app.applyPreferences(r.Context(), owner, &req)
+	if msg, status := checkAnalysisRequest(&req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	if msg, status := resolveJobURL(r.Context(), &req); msg != "" {
+		http.Error(w, msg, status)
+		return
+	}
+	usage, ok := app.chargeQuota(w, r, actionAnalyze)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	job := &analysisJob{ID: newULID(now), Status: jobQueued, Owner: owner, IP: ip, Request: &req, Fields: req.Fields, QueuedAt: now.UTC(), SignedClient: app.carriesSignedToken(r, owner)}
+	log := app.logger.With("analysisID", job.ID, "ip", ip)
+	if score, err := app.quickScore(withModelTier(r.Context(), req.ModelTier), log, req.Resume, req.JobDescription); err != nil {
+		// The full analysis may still succeed, so it's queued anyway and
+		// the client just waits longer for a score.
+		logModelError(log, err)
+	} else {
+		job.QuickScore = &score
+	}
+	app.queueAnalysisJob(w, r, job, usage)
+}
+
+// queueAnalysisJob stores job and queues it for a worker, then replies with
+// where to poll for the result.
+func (app *application) queueAnalysisJob(w http.ResponseWriter, r *http.Request, job *analysisJob, usage quotaUsage) {
+	ctx := r.Context()
+	region := app.geo.country(job.IP)
+	log := app.logger.With("analysisID", job.ID, "ip", job.IP, "region", region)
+	if err := app.submitAnalysisJob(ctx, job); err != nil {
+		log.Error("failed to queue analysis", "error", err)
+		http.Error(w, "Failed to queue analysis", http.StatusInternalServerError)
+		return
+	}
+	log.Info("queued analysis request", "usage", fmt.Sprintf("%d/%d", usage.used, usage.limit), "bonus", usage.bonus)
+
+	w.Header().Set("Location", "/analyses/async/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(analysisJobResponse{ID: job.ID, Status: job.Status, QueuedAt: job.QueuedAt, QuickScore: job.QuickScore})
+}
+
+// submitAnalysisJob stores job as queued and adds it to the queue.
+func (app *application) submitAnalysisJob(ctx context.Context, job *analysisJob) error {
+	job.Status = jobQueued
+	job.RunAfter = nil
+	if err := app.saveAnalysisJob(ctx, job); err != nil {
+		return err
+	}
+	if err := app.rdb.XAdd(ctx, &redis.XAddArgs{Stream: analysisQueueKey(), Values: []any{"id", job.ID}}).Err(); err != nil {
+		return err
+	}
+	app.publishEvent(ctx, eventAnalysisRequested, analysisEvent{AnalysisID: job.ID, Source: sourceWeb, ClientID: job.Owner, Region: app.geo.country(job.IP)})
+	return nil
+}
+
+type analysisJobResponse struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	QueuedAt   time.Time  `json:"queuedAt"`
+	RunAfter   *time.Time `json:"runAfter,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	// QuickScore is the first phase of a two-phase analysis, available
+	// before the result.
+	QuickScore *int `json:"quickScore,omitempty"`
+	// Result is the AnalysisResponse, trimmed to the fields the job asked for.
+	Result any `json:"result,omitempty"`
+}
+
+// analysisJobHandler reports a queued analysis's progress, including the
+// analysis once it's done.
+func (app *application) analysisJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	job, err := app.loadAnalysisJob(r.Context(), id)
+	if errors.Is(err, errAnalysisJobNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis job", "analysisID", id, "error", err)
+		http.Error(w, "Failed to load analysis", http.StatusInternalServerError)
+		return
+	}
+
+	resp := analysisJobResponse{ID: job.ID, Status: job.Status, Error: job.Error, QueuedAt: job.QueuedAt, RunAfter: job.RunAfter, FinishedAt: job.FinishedAt, QuickScore: job.QuickScore}
+	if job.Status == jobDone {
+		rec, err := app.loadAnalysis(r.Context(), id)
+		if err != nil {
+			app.logger.Error("failed to load queued analysis result", "analysisID", id, "error", err)
+			http.Error(w, "Failed to load analysis", http.StatusInternalServerError)
+			return
+		}
+		if resp.Result, err = selectFields(&rec.AnalysisResponse, job.Fields); err != nil {
+			app.logger.Error("failed to select response fields", "analysisID", id, "error", err)
+			http.Error(w, "Failed to load analysis", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// runAnalysisQueue runs queued analyses, concurrency at a time, until ctx is
+// cancelled. Workers share the queue as a consumer group, so each job is
+// normally run once; one whose worker died part way is run again.
+func (app *application) runAnalysisQueue(ctx context.Context, concurrency int) {
+	err := app.rdb.XGroupCreateMkStream(ctx, analysisQueueKey(), analysisQueueGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		app.logger.Error("failed to create analysis consumer group", "error", err)
+		return
+	}
+	host, _ := os.Hostname()
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app.consumeAnalysisJobs(ctx, fmt.Sprintf("%s-%d", cmp.Or(host, "worker"), i))
+		}()
+	}
+	wg.Wait()
+}
+
+func (app *application) consumeAnalysisJobs(ctx context.Context, consumer string) {
+	for ctx.Err() == nil {
+		msgs, err := app.nextAnalysisJobs(ctx, consumer)
+		if err != nil {
+			app.logger.Error("failed to read analysis queue", "consumer", consumer, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(analysisQueueBlock):
+			}
+			continue
+		}
+		for _, m := range msgs {
+			id, _ := m.Values["id"].(string)
+			// A job reclaimed from a worker that was only slow, not dead,
+			// must not run twice, so whoever runs it holds its lock.
+			err := app.withLock(ctx, "analysis-job:"+id, analysisJobClaimIdle, func() error {
+				return app.runAnalysisJob(ctx, id)
+			})
+			if errors.Is(err, errLockHeld) {
+				continue
+			}
+			if err != nil {
+				// Left unacknowledged, the job is picked up again once
+				// analysisJobClaimIdle has passed.
+				app.logger.Error("failed to run analysis job", "analysisID", id, "error", err)
+				continue
+			}
+			app.rdb.XAck(ctx, analysisQueueKey(), analysisQueueGroup, m.ID)
+			app.rdb.XDel(ctx, analysisQueueKey(), m.ID)
+		}
+	}
+}
+
+// nextAnalysisJobs returns a job abandoned by another worker if there is one,
+// otherwise waits for a new one.
+func (app *application) nextAnalysisJobs(ctx context.Context, consumer string) ([]redis.XMessage, error) {
+	claimed, _, err := app.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream: analysisQueueKey(), Group: analysisQueueGroup, Consumer: consumer,
+		MinIdle: analysisJobClaimIdle, Start: "0-0", Count: 1,
+	}).Result()
+	if err != nil || len(claimed) > 0 {
+		return claimed, err
+	}
+	streams, err := app.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: analysisQueueGroup, Consumer: consumer,
+		Streams: []string{analysisQueueKey(), ">"}, Count: 1, Block: analysisQueueBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return streams[0].Messages, nil
+}
+
+// runAnalysisJob runs one queued analysis and records how it went. It only
+// returns an error if the job should be tried again.
+func (app *application) runAnalysisJob(ctx context.Context, id string) error {
+	job, err := app.loadAnalysisJob(ctx, id)
+	if errors.Is(err, errAnalysisJobNotFound) {
+		app.logger.Warn("queued analysis expired before it ran", "analysisID", id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if job.Request == nil {
+		return nil // already finished by another worker
+	}
+	region := app.geo.country(job.IP)
+	log := app.logger.With("analysisID", job.ID, "ip", job.IP, "region", region)
+
+	job.Status = jobRunning
+	if err := app.saveAnalysisJob(ctx, job); err != nil {
+		return err
+	}
+	start := time.Now()
+	var known []int
+	if job.QuickScore != nil {
+		known = append(known, *job.QuickScore)
+	}
+	resp, err := app.analyze(ctx, log, job.ID, job.Owner, job.Request, known)
+	if err != nil {
+		logModelError(log, err)
+		job.Status = jobFailed
+		job.Error, _ = modelErrorMessage(err)
+		app.publishEvent(ctx, eventAnalysisFailed, analysisEvent{AnalysisID: job.ID, Source: sourceWeb, ClientID: job.Owner, Region: region, Reason: failureReason(err)})
+	} else {
+		job.Status = jobDone
+		completed := completedEvent(sourceWeb, job.Owner, resp, start)
+		completed.Region = region
+		app.publishEvent(ctx, eventAnalysisCompleted, completed)
+		// Only a finished analysis pays out a referral, checked against
+		// the request as it was when the job was queued.
+		if err := app.payReferral(ctx, job.Owner, job.IP, job.SignedClient); err != nil {
+			log.Error("failed to reward referral", "error", err)
+		}
+	}
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.Request = nil
+	return app.saveAnalysisJob(ctx, job)
+}
@@ -0,0 +1,104 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	maxQuotaAlertPercents = 5
+	quotaAlertTimeout     = 10 * time.Second
+)
+
+// defaultQuotaAlertPercents apply when a client subscribes to quota alerts
+// without choosing thresholds.
+var defaultQuotaAlertPercents = []int{80, 100}
+
+// alertPercents returns the client's thresholds, lowest first.
+func (p notificationPrefs) alertPercents() []int {
+	if len(p.QuotaAlertPercents) == 0 {
+		return defaultQuotaAlertPercents
+	}
+	return slices.Sorted(slices.Values(p.QuotaAlertPercents))
+}
+
+func validateQuotaAlertPercents(percents []int) error {
+	if len(percents) > maxQuotaAlertPercents {
+		return fmt.Errorf("at most %d quotaAlertPercents can be set", maxQuotaAlertPercents)
+	}
+	for _, pct := range percents {
+		if pct < 1 || pct > 100 {
+			return errors.New("quotaAlertPercents must be between 1 and 100")
+		}
+	}
+	return nil
+}
+
+// crossedPercent returns the highest threshold that going from before to
+// after credits used out of limit crossed, or 0. Usage only grows within a
+// day and each charge is atomic, so exactly one request crosses each
+// threshold and no alert is sent twice.
+func crossedPercent(percents []int, before, after, limit int64) int {
+	crossed := 0
+	for _, pct := range percents {
+		// The smallest whole number of credits that reaches pct.
+		threshold := (limit*int64(pct) + 99) / 100
+		if before < threshold && after >= threshold {
+			crossed = pct
+		}
+	}
+	return crossed
+}
+
+// checkQuotaAlerts tells the client, on the channels they chose for quota
+// alerts, when a charge takes their daily usage past one of their
+// thresholds. It runs after the response so it never slows a request down.
+func (app *application) checkQuotaAlerts(r *http.Request, action string, usage quotaUsage) {
+	owner, ok := app.existingClientID(r)
+	if !ok || usage.bonus || usage.limit <= 0 {
+		return
+	}
+	bucket := app.quotaBuckets(r)[0].key
+	before := usage.used - app.quota.costs[action]
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), quotaAlertTimeout)
+		defer cancel()
+		log := app.logger.With("clientID", owner, "bucket", bucket)
+
+		p, err := app.loadNotificationPrefs(ctx, owner)
+		if err != nil {
+			log.Error("failed to load notification preferences", "error", err)
+			return
+		}
+		if len(p.Events[eventQuotaAlert]) == 0 {
+			return
+		}
+		pct := crossedPercent(p.alertPercents(), before, usage.used, usage.limit)
+		if pct == 0 {
+			return
+		}
+
+		n := notification{Event: eventQuotaAlert}
+		if usage.used >= usage.limit {
+			n.Subject = "You've used all of today's credits"
+		} else {
+			n.Subject = fmt.Sprintf("You've used %d%% of today's credits", pct)
+		}
+		n.Body = fmt.Sprintf("You've used %d of your %d credits for today.", usage.used, usage.limit)
+		if ttl, err := app.rdb.TTL(ctx, quotaKey(bucket)).Result(); err == nil && ttl > 0 {
+			n.Body += fmt.Sprintf(" They reset at %s UTC.", time.Now().Add(ttl).UTC().Format("15:04"))
+		}
+		if bonus, err := app.rdb.Get(ctx, bonusKey(bucket)).Int64(); err == nil && bonus > 0 {
+			n.Body += fmt.Sprintf(" You also have %d bonus credits, which are spent once the daily ones run out.", bonus)
+		}
+		if err := app.notify(ctx, owner, n); err != nil {
+			log.Error("failed to send quota alert", "error", err)
+			return
+		}
+		log.Info("quota alert sent", "percent", pct, "used", usage.used, "limit", usage.limit)
+	}()
+}
@@ -0,0 +1,376 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultDailyCredits = 5
+	rateLimitDuration   = 24 * time.Hour
+
+	// When clients carry a signed token, their IP may still use this many
+	// times the per-client quota. That leaves room for a shared office or
+	// campus NAT while capping anyone who discards their token to reset it.
+	sharedIPQuotaFactor = 10
+
+	// An IPv6 user is usually given a whole /64 and can pick any address in
+	// it, so IPv6 addresses share a quota with the rest of their prefix.
+	defaultIPv6QuotaPrefix = 64
+
+	// Separately from credits, each IP may make this many API requests a
+	// minute, so a flood of cheap requests can't tie the server up.
+	defaultRequestsPerMinute = 300
+)
+
+// requestRateKey counts an IP's requests in one minute-long window.
+func requestRateKey(bucket string, window int64) string {
+	return rkey("rate", bucket, strconv.FormatInt(window, 10))
+}
+
+// Actions that draw on a client's daily credits.
+const (
+	actionAnalyze  = "analyze"
+	actionCompare  = "compare"
+	actionWhatIf   = "whatif"
+	actionJobPost  = "jobpost"
+	actionEmail    = "email"
+	actionDraft    = "draft"
+	actionStar     = "star"
+	actionTrim     = "trim"
+	actionHeadline = "headline"
+	actionLinkedIn = "linkedin"
+	actionOutreach = "outreach"
+	actionAnswers  = "answers"
+	actionBrief    = "brief"
+)
+
+// defaultQuotaCosts is the credit cost of each action unless QUOTA_COSTS
+// overrides it.
+var defaultQuotaCosts = map[string]int64{
+	actionAnalyze:  1,
+	actionCompare:  2,
+	actionWhatIf:   1,
+	actionJobPost:  1,
+	actionEmail:    1,
+	actionDraft:    1,
+	actionStar:     1,
+	actionTrim:     1,
+	actionHeadline: 1,
+	actionLinkedIn: 1,
+	actionOutreach: 1,
+	actionAnswers:  1,
+	actionBrief:    1,
+}
+
+// quotaConfig holds the daily credit budget and what each action costs.
+type quotaConfig struct {
+	dailyCredits int64
+	costs        map[string]int64
+	ipv6Prefix   int
+	// maxDeferred is how many analyses a client with a signed token may
+	// have waiting for their next day's credits; see deferral.go.
+	maxDeferred int64
+	// perMinute is how many API requests an IP may make a minute, or 0
+	// for no limit.
+	perMinute int64
+}
+
+// loadQuotaConfig reads DAILY_CREDITS, QUOTA_COSTS, IPV6_QUOTA_PREFIX,
+// DEFERRED_ANALYSES and RATE_LIMIT_PER_MINUTE.
+// QUOTA_COSTS is a comma-separated list of action=cost pairs, e.g.
+// "analyze=1".
+func loadQuotaConfig() (quotaConfig, error) {
+	qc := quotaConfig{dailyCredits: defaultDailyCredits, costs: maps.Clone(defaultQuotaCosts), ipv6Prefix: defaultIPv6QuotaPrefix, maxDeferred: defaultMaxDeferred, perMinute: defaultRequestsPerMinute}
+	if v := os.Getenv("IPV6_QUOTA_PREFIX"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 32 || n > 128 {
+			return qc, fmt.Errorf("IPV6_QUOTA_PREFIX must be between 32 and 128, got %q", v)
+		}
+		qc.ipv6Prefix = n
+	}
+	if v := os.Getenv("DAILY_CREDITS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return qc, fmt.Errorf("DAILY_CREDITS must be a positive integer, got %q", v)
+		}
+		qc.dailyCredits = n
+	}
+	if v := os.Getenv("DEFERRED_ANALYSES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 || n > maxDeferredLimit {
+			return qc, fmt.Errorf("DEFERRED_ANALYSES must be between 0 and %d, got %q", maxDeferredLimit, v)
+		}
+		qc.maxDeferred = n
+	}
+	if v := os.Getenv("RATE_LIMIT_PER_MINUTE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return qc, fmt.Errorf("RATE_LIMIT_PER_MINUTE must be a non-negative integer, got %q", v)
+		}
+		qc.perMinute = n
+	}
+	for _, pair := range strings.Split(os.Getenv("QUOTA_COSTS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		action, cost, ok := strings.Cut(pair, "=")
+		action = strings.TrimSpace(action)
+		n, err := strconv.ParseInt(strings.TrimSpace(cost), 10, 64)
+		if !ok || err != nil || n < 0 {
+			return qc, fmt.Errorf("QUOTA_COSTS entry %q must look like action=cost", pair)
+		}
+		if _, known := qc.costs[action]; !known {
+			return qc, fmt.Errorf("QUOTA_COSTS names unknown action %q", action)
+		}
+		qc.costs[action] = n
+	}
+	return qc, nil
+}
+
+// ipBucket is the quota identity of an IP address: IPv4 addresses stand for
+// themselves and IPv6 addresses for their ipv6Prefix network, such as
+// "2001:db8:1:2::/64".
+func (qc quotaConfig) ipBucket(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is6() || qc.ipv6Prefix >= 128 {
+		return ip
+	}
+	prefix, err := addr.Prefix(qc.ipv6Prefix)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// A quotaBucket is one counter a request is charged against. Its key is an IP
+// address, an IPv6 network or "client:" and a client ID; the counter itself
+// is at quotaKey.
+type quotaBucket struct {
+	key     string
+	limit   int64
+	message string
+}
+
+// quotaBuckets returns the counters that apply to r. Requests with a verified
+// client token are limited per client, with a looser ceiling per IP; all other
+// requests are limited per IP. Clients in a country with its own
+// GEO_DAILY_CREDITS get that many credits instead of DAILY_CREDITS.
+func (app *application) quotaBuckets(r *http.Request) []quotaBucket {
+	if id, verified := app.readClientCookie(r); verified {
+		return app.clientQuotaBuckets(id, getIPAddress(r), app.region(r))
+	}
+	credits := app.geo.dailyCredits(app.region(r), app.quota.dailyCredits)
+	return []quotaBucket{{key: app.quota.ipBucket(getIPAddress(r)), limit: credits, message: perClientMessage(credits)}}
+}
+
+// clientQuotaBuckets returns the counters for client id, with a verified
+// token, calling from ip in region.
+func (app *application) clientQuotaBuckets(id, ip, region string) []quotaBucket {
+	credits := app.geo.dailyCredits(region, app.quota.dailyCredits)
+	return []quotaBucket{
+		{key: "client:" + id, limit: credits, message: perClientMessage(credits)},
+		{key: app.quota.ipBucket(ip), limit: credits * sharedIPQuotaFactor, message: "Too many requests have come from your network today. Please try again tomorrow."},
+	}
+}
+
+func perClientMessage(credits int64) string {
+	return fmt.Sprintf("You have used up your %d credits for today.", credits)
+}
+
+// quotaUsage reports the result of charging a request against its buckets.
+type quotaUsage struct {
+	used, limit int64
+	// bonus is set when the request was paid for with bonus credits because
+	// the daily allowance was used up.
+	bonus    bool
+	exceeded *quotaBucket
+}
+
+// consumeQuotaScript charges a request against its buckets in one round trip.
+// KEYS are the bucket counters, most specific first, then the first bucket's
+// bonus balance; ARGV is the cost, the counters' lifetime in seconds, then
+// each bucket's limit. Every bucket is checked before any is charged, so
+// nothing needs refunding. When the first bucket is full its bonus balance
+// pays instead. A counter that somehow lost its expiry gets it back.
+//
+// It returns the 1-based index of the bucket that would go over its limit (0
+// if none), the first bucket's usage after the charge, and 1 if bonus credits
+// were spent.
+var consumeQuotaScript = redis.NewScript(`
+local cost = tonumber(ARGV[1])
+local n = #KEYS - 1
+local bonusKey = KEYS[#KEYS]
+local used = {}
+for i = 1, n do
+	used[i] = tonumber(redis.call('GET', KEYS[i]) or '0')
+end
+local bonus = 0
+for i = 1, n do
+	if used[i] + cost > tonumber(ARGV[i + 2]) then
+		if i == 1 and tonumber(redis.call('GET', bonusKey) or '0') >= cost then
+			bonus = 1
+		else
+			return {i, used[1], 0}
+		end
+	end
+end
+for i = 1, n do
+	if i > 1 or bonus == 0 then
+		used[i] = redis.call('INCRBY', KEYS[i], cost)
+	end
+	if redis.call('TTL', KEYS[i]) < 0 then
+		redis.call('EXPIRE', KEYS[i], ARGV[2])
+	end
+end
+if bonus == 1 then
+	redis.call('DECRBY', bonusKey, cost)
+end
+return {0, used[1], bonus}
+`)
+
+// consumeQuota charges the cost of action against every bucket that applies to
+// r. Once the client's own daily allowance is used up, bonus credits are spent
+// instead. If any bucket would still go over its limit nothing is charged. The
+// usage reported is that of the first (most specific) bucket.
+func (app *application) consumeQuota(ctx context.Context, r *http.Request, action string) (quotaUsage, error) {
+	return app.consumeBuckets(ctx, app.quotaBuckets(r), app.quota.costs[action])
+}
+
+// consumeBuckets charges cost against buckets as consumeQuota does.
+func (app *application) consumeBuckets(ctx context.Context, buckets []quotaBucket, cost int64) (quotaUsage, error) {
+	keys := make([]string, 0, len(buckets)+1)
+	args := []any{cost, int(rateLimitDuration.Seconds())}
+	for _, b := range buckets {
+		keys = append(keys, quotaKey(b.key))
+		args = append(args, b.limit)
+	}
+	keys = append(keys, bonusKey(buckets[0].key))
+
+	res, err := consumeQuotaScript.Run(ctx, app.rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return quotaUsage{}, err
+	}
+	for _, b := range buckets {
+		app.forgetQuota(b.key)
+	}
+	u := quotaUsage{used: res[1], limit: buckets[0].limit, bonus: res[2] == 1}
+	if res[0] > 0 {
+		u.exceeded = &buckets[res[0]-1]
+	}
+	return u, nil
+}
+
+// chargeQuota consumes the credits for action, writing the error response and
+// returning false when the request can't go ahead, including when it comes
+// from a blocked country.
+func (app *application) chargeQuota(w http.ResponseWriter, r *http.Request, action string) (quotaUsage, bool) {
+	usage, ok := app.tryChargeQuota(w, r, action)
+	if !ok {
+		return usage, false
+	}
+	if usage.exceeded != nil {
+		app.rejectOverQuota(w, r, action, usage)
+		return usage, false
+	}
+	app.checkQuotaAlerts(r, action, usage)
+	return usage, true
+}
+
+// tryChargeQuota is chargeQuota for handlers that can do something other
+// than refuse a request over quota: it leaves usage.exceeded for the caller
+// to handle, with nothing charged.
+func (app *application) tryChargeQuota(w http.ResponseWriter, r *http.Request, action string) (quotaUsage, bool) {
+	if !app.allowRegion(w, r) {
+		return quotaUsage{}, false
+	}
+	usage, err := app.consumeQuota(r.Context(), r, action)
+	if err != nil {
+		app.logger.Error("redis increment failed", "ip", getIPAddress(r), "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return usage, false
+	}
+	return usage, true
+}
+
+// rejectOverQuota reports a request that went over quota and refuses it
+// with a 429.
+func (app *application) rejectOverQuota(w http.ResponseWriter, r *http.Request, action string, usage quotaUsage) {
+	region := app.region(r)
+	app.logger.Warn("rate limit exceeded", "ip", getIPAddress(r), "region", region, "bucket", usage.exceeded.key, "count", usage.used)
+	clientID, _ := app.existingClientID(r)
+	app.publishEvent(r.Context(), eventQuotaExceeded, quotaEvent{
+		Action: action, Scope: quotaScope(usage.exceeded), Limit: usage.exceeded.limit, ClientID: clientID, Region: region,
+	})
+	http.Error(w, usage.exceeded.message, http.StatusTooManyRequests)
+}
+
+// quotaSnapshot is a bucket's usage as last read from Redis.
+type quotaSnapshot struct {
+	used, bonus int64
+	resetsAt    *time.Time
+}
+
+// forgetQuota drops cached usage for buckets whose credits just changed.
+func (app *application) forgetQuota(buckets ...string) {
+	for _, b := range buckets {
+		app.caches.quota.remove(b)
+	}
+}
+
+// quotaHandler reports the caller's remaining credits for today and what each
+// action costs.
+func (app *application) quotaHandler(w http.ResponseWriter, r *http.Request) {
+	b := app.quotaBuckets(r)[0]
+
+	snap, ok := app.caches.quota.get(b.key)
+	if !ok {
+		pipe := app.rdb.Pipeline()
+		usedCmd := pipe.Get(r.Context(), quotaKey(b.key))
+		bonusCmd := pipe.Get(r.Context(), bonusKey(b.key))
+		ttlCmd := pipe.TTL(r.Context(), quotaKey(b.key))
+		if _, err := pipe.Exec(r.Context()); err != nil && !errors.Is(err, redis.Nil) {
+			app.logger.Error("failed to read quota", "bucket", b.key, "error", err)
+			http.Error(w, "Could not load quota", http.StatusInternalServerError)
+			return
+		}
+		snap.used, _ = usedCmd.Int64()
+		snap.bonus, _ = bonusCmd.Int64()
+		if ttl := ttlCmd.Val(); ttl > 0 {
+			t := time.Now().Add(ttl).UTC().Truncate(time.Second)
+			snap.resetsAt = &t
+		}
+		app.caches.quota.set(b.key, snap)
+	}
+
+	resp := struct {
+		Limit     int64            `json:"limit"`
+		Used      int64            `json:"used"`
+		Remaining int64            `json:"remaining"`
+		Bonus     int64            `json:"bonusCredits"`
+		ResetsAt  *time.Time       `json:"resetsAt,omitempty"`
+		Costs     map[string]int64 `json:"costs"`
+	}{
+		Limit:     b.limit,
+		Used:      snap.used,
+		Remaining: max(0, b.limit-snap.used),
+		Bonus:     snap.bonus,
+		ResetsAt:  snap.resetsAt,
+		Costs:     app.quota.costs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,246 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Referrals pay both sides bonus credits, so both must hold a signed client
+// token: credits go to the token rather than to an IP address, which anyone
+// behind the same NAT would share, and tokens can't be made up. A referred
+// token younger than referralMinTokenAge is flagged rather than paid, since
+// clearing cookies is the cheapest way to refer yourself.
+const (
+	referralBonusCredits = 3
+	referralMinTokenAge  = 24 * time.Hour
+)
+
+func referralCodesKey() string               { return rkey("referral", "codes") }
+func referralCodeKey(code string) string     { return rkey("referral", "code", code) }
+func referralOwnerKey(owner string) string   { return rkey("referral", "owner", owner) }
+func referralPendingKey(owner string) string { return rkey("referral", "pending", owner) }
+func referralStatsKey(code string) string    { return rkey("referral", "stats", code) }
+
+// publicURL builds an absolute link to path, preferring PUBLIC_BASE_URL over
+// the host the request came in on.
+func (app *application) publicURL(r *http.Request, path string) string {
+	if app.baseURL != "" {
+		return strings.TrimSuffix(app.baseURL, "/") + path
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// myReferralHandler returns the caller's referral link, creating their code on
+// first use, along with how it has performed.
+func (app *application) myReferralHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, ok := app.verifiedClientID(w, r)
+	if !ok {
+		return
+	}
+
+	code, err := app.rdb.Get(ctx, referralOwnerKey(owner)).Result()
+	if errors.Is(err, redis.Nil) {
+		code = randomCouponCode()
+		pipe := app.rdb.TxPipeline()
+		pipe.HSet(ctx, referralCodeKey(code),
+			"owner", owner,
+			"bucket", "client:"+owner,
+			"ip", getIPAddress(r),
+		)
+		pipe.Set(ctx, referralOwnerKey(owner), code, 0)
+		pipe.SAdd(ctx, referralCodesKey(), code)
+		_, err = pipe.Exec(ctx)
+	}
+	if err != nil {
+		app.logger.Error("failed to load referral code", "error", err)
+		http.Error(w, "Could not load referral link", http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := app.referralStats(ctx, code)
+	if err != nil {
+		app.logger.Error("failed to load referral stats", "code", code, "error", err)
+		http.Error(w, "Could not load referral link", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"code":     code,
+		"link":     app.publicURL(r, "/?ref="+code),
+		"rewarded": stats.Rewarded,
+	})
+}
+
+// claimReferralHandler records that the caller arrived through a referral link.
+// The reward is paid out when they complete their first analysis.
+func (app *application) claimReferralHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	ctx := r.Context()
+	owner, ok := app.verifiedClientID(w, r)
+	if !ok {
+		return
+	}
+
+	referrer, err := app.rdb.HGet(ctx, referralCodeKey(code), "owner").Result()
+	if errors.Is(err, redis.Nil) {
+		http.Error(w, "Unknown referral code", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load referral code", "code", code, "error", err)
+		http.Error(w, "Could not claim referral", http.StatusInternalServerError)
+		return
+	}
+	if referrer == owner {
+		http.Error(w, "You can't use your own referral link", http.StatusBadRequest)
+		return
+	}
+
+	// Only brand-new visitors count as referred.
+	n, err := app.rdb.ZCard(ctx, historyKey(owner)).Result()
+	if err != nil {
+		app.logger.Error("failed to check history", "error", err)
+		http.Error(w, "Could not claim referral", http.StatusInternalServerError)
+		return
+	}
+	if n > 0 {
+		http.Error(w, "Referrals only apply before your first analysis", http.StatusConflict)
+		return
+	}
+	claimed, err := app.rdb.SetNX(ctx, referralPendingKey(owner), code, analysisRetention).Result()
+	if err != nil {
+		app.logger.Error("failed to store referral", "code", code, "error", err)
+		http.Error(w, "Could not claim referral", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, "You have already used a referral link", http.StatusConflict)
+		return
+	}
+	app.rdb.HIncrBy(ctx, referralStatsKey(code), "claimed", 1)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rewardReferral pays out a pending referral once the referred client has
+// completed an analysis, from the request that ran it.
+func (app *application) rewardReferral(ctx context.Context, r *http.Request, owner string) error {
+	return app.payReferral(ctx, owner, getIPAddress(r), app.carriesSignedToken(r, owner))
+}
+
+// payReferral is rewardReferral for an analysis that ran away from its
+// request, as queued ones do: ip and signed are what the request carried.
+// Both sides get bonus credits unless the two appear to be the same person,
+// or either lacks a signed token, in which case the referral is only
+// flagged.
+func (app *application) payReferral(ctx context.Context, owner, ip string, signed bool) error {
+	code, err := app.rdb.GetDel(ctx, referralPendingKey(owner)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ref, err := app.rdb.HGetAll(ctx, referralCodeKey(code)).Result()
+	if err != nil || len(ref) == 0 {
+		return err
+	}
+
+	flag := func(reason string) error {
+		app.logger.Warn("referral flagged", "code", code, "reason", reason)
+		return app.rdb.HIncrBy(ctx, referralStatsKey(code), "flagged", 1).Err()
+	}
+	// Codes handed out before referrals needed a signed token were paid to
+	// the referrer's IP address.
+	if ref["bucket"] != "client:"+ref["owner"] {
+		return flag("unsigned referrer")
+	}
+	if !signed {
+		return flag("unsigned client")
+	}
+	if app.quota.ipBucket(ref["ip"]) == app.quota.ipBucket(ip) {
+		return flag("same ip")
+	}
+	issued, err := app.clientIssuedAt(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if time.Since(issued) < referralMinTokenAge {
+		return flag("new client")
+	}
+
+	buckets := []string{ref["bucket"], "client:" + owner}
+	pipe := app.rdb.TxPipeline()
+	for _, bucket := range buckets {
+		pipe.IncrBy(ctx, bonusKey(bucket), referralBonusCredits)
+		pipe.Expire(ctx, bonusKey(bucket), bonusCreditRetention)
+	}
+	pipe.HIncrBy(ctx, referralStatsKey(code), "rewarded", 1)
+	_, err = pipe.Exec(ctx)
+	app.forgetQuota(buckets...)
+	if err == nil {
+		app.logger.Info("referral rewarded", "code", code)
+	}
+	return err
+}
+
+type referralStats struct {
+	Code     string `json:"code"`
+	Claimed  int64  `json:"claimed"`
+	Rewarded int64  `json:"rewarded"`
+	Flagged  int64  `json:"flagged"`
+}
+
+func (app *application) referralStats(ctx context.Context, code string) (referralStats, error) {
+	fields, err := app.rdb.HGetAll(ctx, referralStatsKey(code)).Result()
+	num := func(name string) int64 {
+		n, _ := strconv.ParseInt(fields[name], 10, 64)
+		return n
+	}
+	return referralStats{Code: code, Claimed: num("claimed"), Rewarded: num("rewarded"), Flagged: num("flagged")}, err
+}
+
+// adminReferralsHandler reports every referral code that has been claimed at
+// least once.
+func (app *application) adminReferralsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	codes, err := app.rdb.SMembers(ctx, referralCodesKey()).Result()
+	if err != nil {
+		app.logger.Error("failed to list referral codes", "error", err)
+		http.Error(w, "Could not load referrals", http.StatusInternalServerError)
+		return
+	}
+	report := make([]referralStats, 0, len(codes))
+	for _, code := range codes {
+		stats, err := app.referralStats(ctx, code)
+		if err != nil {
+			app.logger.Error("failed to load referral stats", "code", code, "error", err)
+			http.Error(w, "Could not load referrals", http.StatusInternalServerError)
+			return
+		}
+		if stats.Claimed > 0 {
+			report = append(report, stats)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
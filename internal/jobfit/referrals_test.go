@@ -0,0 +1,24 @@
+package jobfit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReferralsNeedSignedClient(t *testing.T) {
+	app := &application{clientSecret: []byte("s")}
+	for name, h := range map[string]http.HandlerFunc{
+		"my referral": app.myReferralHandler,
+		"claim":       app.claimReferralHandler,
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"code":"ABCDEFGH"}`))
+		r.AddCookie(&http.Cookie{Name: clientCookieName, Value: "0123456789abcdef0123456789abcdef"})
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s with an unsigned client: status %d, want 403", name, w.Code)
+		}
+	}
+}
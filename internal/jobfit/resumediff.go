@@ -0,0 +1,232 @@
+package jobfit
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Lines like these start a new resume section even when they aren't written in
+// capitals or followed by a colon.
+var knownSectionHeadings = map[string]bool{
+	"summary": true, "profile": true, "professional summary": true, "objective": true,
+	"experience": true, "work experience": true, "professional experience": true, "employment": true, "employment history": true,
+	"education": true, "skills": true, "technical skills": true, "core competencies": true,
+	"projects": true, "certifications": true, "certificates": true, "awards": true, "publications": true,
+	"volunteer": true, "volunteering": true, "languages": true, "interests": true, "contact": true,
+}
+
+// resumeSection is a heading and the non-empty lines under it. Text before the
+// first heading (usually the contact block) goes in a section named "header".
+type resumeSection struct {
+	Name  string
+	Lines []resumeLine
+}
+
+// resumeLine is a line with its bullet marker stripped.
+type resumeLine struct {
+	Text   string
+	Bullet bool
+}
+
+// isSectionHeading guesses whether a line is a section title.
+func isSectionHeading(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || len(line) > 40 {
+		return false
+	}
+	name := strings.ToLower(strings.TrimRight(line, ": "))
+	if knownSectionHeadings[name] {
+		return true
+	}
+	if strings.HasSuffix(line, ":") && len(strings.Fields(line)) <= 4 {
+		return true
+	}
+	hasLetter := false
+	for _, r := range line {
+		if unicode.IsLower(r) {
+			return false
+		}
+		hasLetter = hasLetter || unicode.IsLetter(r)
+	}
+	return hasLetter && len(strings.Fields(line)) <= 4
+}
+
+const bulletMarkers = "-•*·▪‣◦"
+
+// cleanLine strips bullet markers and surrounding whitespace, reporting whether
+// there was a marker.
+func cleanLine(line string) resumeLine {
+	line = strings.TrimSpace(line)
+	text := strings.TrimSpace(strings.TrimLeft(line, bulletMarkers))
+	return resumeLine{Text: text, Bullet: text != line}
+}
+
+// splitSections breaks a plain-text resume into sections.
+func splitSections(text string) []resumeSection {
+	sections := []resumeSection{{Name: "header"}}
+	for _, line := range strings.Split(text, "\n") {
+		if isSectionHeading(line) {
+			sections = append(sections, resumeSection{Name: strings.ToLower(strings.TrimRight(strings.TrimSpace(line), ": "))})
+			continue
+		}
+		if l := cleanLine(line); l.Text != "" {
+			cur := &sections[len(sections)-1]
+			cur.Lines = append(cur.Lines, l)
+		}
+	}
+	if len(sections[0].Lines) == 0 {
+		sections = sections[1:]
+	}
+	return sections
+}
+
+type changedLine struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type sectionDiff struct {
+	Section string        `json:"section"`
+	Added   []string      `json:"added,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+	Changed []changedLine `json:"changed,omitempty"`
+}
+
+type resumeDiff struct {
+	From            string        `json:"from"`
+	To              string        `json:"to"`
+	SectionsAdded   []string      `json:"sectionsAdded"`
+	SectionsRemoved []string      `json:"sectionsRemoved"`
+	Sections        []sectionDiff `json:"sections"`
+}
+
+// Two lines sharing at least this fraction of their words are reported as an
+// edit of one another rather than a removal plus an addition.
+const changedLineSimilarity = 0.5
+
+func wordSimilarity(a, b string) float64 {
+	wa, wb := searchTerms(a), searchTerms(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(wa))
+	for _, w := range wa {
+		set[w] = true
+	}
+	union := len(set)
+	shared := 0
+	seen := make(map[string]bool, len(wb))
+	for _, w := range wb {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	return float64(shared) / float64(union)
+}
+
+// diffLines returns the lines only in a and only in b, in order, using the
+// longest common subsequence of the two.
+func diffLines(a, b []string) (removed, added []string) {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	removed = append(removed, a[i:]...)
+	added = append(added, b[j:]...)
+	return removed, added
+}
+
+func diffSection(name string, a, b []string) sectionDiff {
+	d := sectionDiff{Section: name}
+	removed, added := diffLines(a, b)
+
+	// Pair each removed line with the most similar unclaimed added line.
+	claimed := make([]bool, len(added))
+	for _, r := range removed {
+		best, bestScore := -1, changedLineSimilarity
+		for j, ad := range added {
+			if s := wordSimilarity(r, ad); !claimed[j] && s >= bestScore {
+				best, bestScore = j, s
+			}
+		}
+		if best < 0 {
+			d.Removed = append(d.Removed, r)
+			continue
+		}
+		claimed[best] = true
+		d.Changed = append(d.Changed, changedLine{From: r, To: added[best]})
+	}
+	for j, ad := range added {
+		if !claimed[j] {
+			d.Added = append(d.Added, ad)
+		}
+	}
+	return d
+}
+
+// mergeSections combines sections that share a name, keeping first-seen order.
+func mergeSections(sections []resumeSection) (names []string, lines map[string][]string) {
+	lines = make(map[string][]string, len(sections))
+	for _, s := range sections {
+		if _, seen := lines[s.Name]; !seen {
+			names = append(names, s.Name)
+			lines[s.Name] = nil
+		}
+		for _, l := range s.Lines {
+			lines[s.Name] = append(lines[s.Name], l.Text)
+		}
+	}
+	return names, lines
+}
+
+// diffResumes compares two resume versions section by section.
+func diffResumes(from, to *resumeVersion) resumeDiff {
+	d := resumeDiff{From: from.ID, To: to.ID, SectionsAdded: []string{}, SectionsRemoved: []string{}, Sections: []sectionDiff{}}
+	aNames, aLines := mergeSections(splitSections(from.Text))
+	bNames, bLines := mergeSections(splitSections(to.Text))
+
+	for _, name := range aNames {
+		lines, ok := bLines[name]
+		if !ok {
+			d.SectionsRemoved = append(d.SectionsRemoved, name)
+			continue
+		}
+		if sd := diffSection(name, aLines[name], lines); len(sd.Added)+len(sd.Removed)+len(sd.Changed) > 0 {
+			d.Sections = append(d.Sections, sd)
+		}
+	}
+	for _, name := range bNames {
+		if _, ok := aLines[name]; !ok {
+			d.SectionsAdded = append(d.SectionsAdded, name)
+		}
+	}
+	return d
+}
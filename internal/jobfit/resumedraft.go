@@ -0,0 +1,186 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxDraftAnswersSize = 20000
+
+// draftRequest holds the guided builder's answers. Only the facts come from
+// the user; the model turns them into resume wording.
+type draftRequest struct {
+	Contact resumeContact `json:"contact"`
+	// TargetRole is the kind of job the resume is for, which the summary
+	// and the emphasis of each role are written towards.
+	TargetRole string            `json:"targetRole,omitempty"`
+	Roles      []draftRole       `json:"roles"`
+	Skills     []string          `json:"skills"`
+	Education  []resumeEducation `json:"education"`
+	// Notes is anything else the user wants included, in their own words.
+	Notes string `json:"notes,omitempty"`
+}
+
+// draftRole is one job, internship or volunteer role as the user described it.
+type draftRole struct {
+	Title     string `json:"title"`
+	Company   string `json:"company"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+	// Duties is what the role involved day to day.
+	Duties       string   `json:"duties,omitempty"`
+	Achievements []string `json:"achievements,omitempty"`
+}
+
+// draftResponse is the generated resume, as structured data and as plain
+// text ready to pass to /chat, with whatever the answers still left out.
+type draftResponse struct {
+	ID     string            `json:"id"`
+	Resume *structuredResume `json:"resume"`
+	Text   string            `json:"text"`
+	Gaps   []completenessGap `json:"gaps"`
+}
+
+// render writes the resume out as plain text in the layout
+// parseResumeRules reads back.
+func (sr *structuredResume) render() string {
+	var b strings.Builder
+	if sr.Contact.Name != "" {
+		b.WriteString(sr.Contact.Name + "\n")
+	}
+	var contact []string
+	for _, v := range []string{sr.Contact.Email, sr.Contact.Phone, sr.Contact.Location, sr.Contact.LinkedIn, sr.Contact.Website} {
+		if v != "" {
+			contact = append(contact, v)
+		}
+	}
+	if len(contact) > 0 {
+		b.WriteString(strings.Join(contact, " | ") + "\n")
+	}
+
+	if sr.Summary != "" {
+		b.WriteString("\nSummary\n" + sr.Summary + "\n")
+	}
+	if len(sr.Experience) > 0 {
+		b.WriteString("\nExperience\n")
+		for _, e := range sr.Experience {
+			b.WriteString(e.Title)
+			if e.Company != "" {
+				b.WriteString(" at " + e.Company)
+			}
+			if e.StartDate != "" {
+				fmt.Fprintf(&b, ", %s – %s", e.StartDate, e.EndDate)
+			}
+			b.WriteString("\n")
+			for _, bullet := range e.Bullets {
+				b.WriteString("- " + bullet + "\n")
+			}
+		}
+	}
+	if len(sr.Skills) > 0 {
+		b.WriteString("\nSkills\n" + strings.Join(sr.Skills, ", ") + "\n")
+	}
+	if len(sr.Education) > 0 {
+		b.WriteString("\nEducation\n")
+		for _, e := range sr.Education {
+			var line []string
+			for _, v := range []string{e.Degree, e.Institution} {
+				if v != "" {
+					line = append(line, v)
+				}
+			}
+			b.WriteString(strings.Join(line, ", "))
+			if e.StartDate != "" && e.EndDate != "" {
+				fmt.Fprintf(&b, ", %s – %s", e.StartDate, e.EndDate)
+			} else if e.EndDate != "" {
+				b.WriteString(", " + e.EndDate)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// draftResumeHandler writes a first-draft resume from the guided builder's
+// answers, for users who don't have a resume to start from.
+func (app *application) draftResumeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req draftRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDraftAnswersSize)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Roles) == 0 && len(req.Education) == 0 {
+		http.Error(w, "At least one role or education entry is required", http.StatusBadRequest)
+		return
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionDraft); !ok {
+		return
+	}
+
+	draftID := newULID(time.Now())
+	log := app.logger.With("draftID", draftID, "ip", ip)
+
+	// The contact details are copied over as given rather than sent to the
+	// model, which has no use for them.
+	answers, err := json.MarshalIndent(struct {
+		TargetRole string            `json:"targetRole,omitempty"`
+		Roles      []draftRole       `json:"roles"`
+		Skills     []string          `json:"skills"`
+		Education  []resumeEducation `json:"education"`
+		Notes      string            `json:"notes,omitempty"`
+	}{req.TargetRole, req.Roles, req.Skills, req.Education, req.Notes}, "\t\t", "  ")
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	prompt := fmt.Sprintf(`
+		Write a first-draft resume from the candidate's answers below.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "summary": a string of two or three sentences introducing the candidate, aimed at the target role if one is given.
+		- "experience": a JSON array with one object per role, in the order given, with keys "title", "company", "startDate", "endDate" (copied exactly as given) and "bullets" (a JSON array of three to five strings, each starting with a strong action verb).
+		- "skills": a JSON array of strings, one skill per entry.
+		- "education": a JSON array of objects with keys "institution", "degree", "startDate" and "endDate", copied from the answers.
+		Turn the duties and achievements into concise bullets, keeping every number the candidate gave. Only use facts from the answers: do not invent employers, dates, figures or skills. Do not use markdown.
+
+		**Answers:**
+		---
+		%s
+		---
+	`, answers)
+
+	var sr structuredResume
+	if err := app.generateJSON(ctx, log, prompt, &sr); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	sr.Contact = req.Contact
+	sr.Source = "model"
+	if sr.Skills == nil {
+		sr.Skills = []string{}
+	}
+	if sr.Experience == nil {
+		sr.Experience = []resumeExperience{}
+	}
+	if sr.Education == nil {
+		sr.Education = []resumeEducation{}
+	}
+	log.Info("drafted resume", "roles", len(sr.Experience))
+
+	resp := draftResponse{ID: draftID, Resume: &sr, Text: sr.render(), Gaps: checkCompleteness(&sr, time.Now()).Gaps}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
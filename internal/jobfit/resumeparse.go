@@ -0,0 +1,292 @@
+package jobfit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// structuredResume is a resume broken into fields. Dates are kept as written;
+// see normalizeResumeDate for turning them into something comparable.
+type structuredResume struct {
+	Contact    resumeContact      `json:"contact"`
+	Summary    string             `json:"summary,omitempty"`
+	Experience []resumeExperience `json:"experience"`
+	Skills     []string           `json:"skills"`
+	Education  []resumeEducation  `json:"education"`
+	// Source is "model" when the structure came from the LLM and "rules" when
+	// it came from parseResumeRules.
+	Source string `json:"source"`
+}
+
+type resumeContact struct {
+	Name     string `json:"name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	LinkedIn string `json:"linkedin,omitempty"`
+	Website  string `json:"website,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+type resumeExperience struct {
+	Title     string   `json:"title,omitempty"`
+	Company   string   `json:"company,omitempty"`
+	StartDate string   `json:"startDate,omitempty"`
+	EndDate   string   `json:"endDate,omitempty"`
+	Bullets   []string `json:"bullets"`
+}
+
+type resumeEducation struct {
+	Institution string `json:"institution,omitempty"`
+	Degree      string `json:"degree,omitempty"`
+	StartDate   string `json:"startDate,omitempty"`
+	EndDate     string `json:"endDate,omitempty"`
+}
+
+const resumeParserPrompt = `
+		Convert the following resume into structured data.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "contact": an object with string keys "name", "email", "phone", "linkedin", "website" and "location". Use an empty string for anything not present.
+		- "summary": a string with the summary or profile paragraph, or an empty string.
+		- "experience": a JSON array of objects with keys "title", "company", "startDate", "endDate" (dates copied exactly as written, "Present" for current roles) and "bullets" (a JSON array of strings).
+		- "skills": a JSON array of strings, one skill per entry.
+		- "education": a JSON array of objects with keys "institution", "degree", "startDate" and "endDate".
+		Copy text from the resume; do not invent or embellish anything.
+
+		**Resume:**
+		---
+		%s
+		---
+	`
+
+// parseResume structures text with the model when RESUME_PARSER is "model"
+// (the default), falling back to the rule-based parser if the model fails.
+func (app *application) parseResume(ctx context.Context, log *slog.Logger, text string) *structuredResume {
+	if os.Getenv("RESUME_PARSER") != "rules" {
+		var sr structuredResume
+		err := app.generateJSON(ctx, log, fmt.Sprintf(resumeParserPrompt, text), &sr)
+		if err == nil {
+			sr.Source = "model"
+			return &sr
+		}
+		log.Warn("model resume parsing failed, falling back to rules", "error", err)
+	}
+	return parseResumeRules(text)
+}
+
+var (
+	datePart       = `(?:(?:jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?,?\s+\d{4}|\d{1,2}/\d{4}|\d{4})`
+	dateRangeRegex = regexp.MustCompile(`(?i)(` + datePart + `)\s*(?:-|–|—|to|until)\s*(` + datePart + `|present|current|now|today)`)
+	yearRegex      = regexp.MustCompile(`\b(19|20)\d{2}\b`)
+
+	emailRegex    = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phoneRegex    = regexp.MustCompile(`\+?\(?\d[\d\s().-]{7,}\d`)
+	linkedInRegex = regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z]{2,3}\.)?linkedin\.com/in/[A-Za-z0-9_%-]+/?`)
+	urlRegex      = regexp.MustCompile(`(?i)\b(?:https?://)?(?:www\.)?[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|io|dev|net|org|me|app|co|ai|tech|site|page)(?:/[^\s|,;]*)?`)
+	locationRegex = regexp.MustCompile(`^[A-Z][A-Za-z .'-]+,\s*[A-Z][A-Za-z .]+$`)
+
+	institutionRegex = regexp.MustCompile(`(?i)\b(university|college|institute|school|academy|polytechnic)\b`)
+	degreeRegex      = regexp.MustCompile(`(?i)\b(bachelor|master|associate|doctor|diploma|certificate|b\.?sc?\.?|b\.?a\.?|m\.?sc?\.?|m\.?a\.?|mba|ph\.?d\.?|b\.?eng\.?|m\.?eng\.?)\b`)
+
+	headerSeparators = regexp.MustCompile(`\s*[|•·◆]\s*|\s{3,}`)
+	skillSeparators  = regexp.MustCompile(`\s*[,;|•·]\s*`)
+)
+
+// Section names grouped by the field they populate.
+var (
+	summarySections    = []string{"summary", "profile", "professional summary", "objective", "about"}
+	experienceSections = []string{"experience", "work experience", "professional experience", "employment", "employment history", "work history"}
+	skillSections      = []string{"skills", "technical skills", "core competencies", "technologies"}
+	educationSections  = []string{"education"}
+)
+
+// parseResumeRules structures a plain-text resume using layout heuristics. It
+// never fails, but fields it can't recognize are left empty.
+func parseResumeRules(text string) *structuredResume {
+	sr := &structuredResume{Source: "rules", Experience: []resumeExperience{}, Skills: []string{}, Education: []resumeEducation{}}
+	sections := splitSections(text)
+
+	for _, s := range sections {
+		switch {
+		case s.Name == "header" || s.Name == "contact":
+			parseContact(&sr.Contact, s.Lines)
+		case slices.Contains(summarySections, s.Name):
+			parts := make([]string, len(s.Lines))
+			for i, l := range s.Lines {
+				parts[i] = l.Text
+			}
+			sr.Summary = strings.Join(parts, " ")
+		case slices.Contains(experienceSections, s.Name):
+			sr.Experience = append(sr.Experience, parseExperience(s.Lines)...)
+		case slices.Contains(skillSections, s.Name):
+			sr.Skills = append(sr.Skills, parseSkills(s.Lines)...)
+		case slices.Contains(educationSections, s.Name):
+			sr.Education = append(sr.Education, parseEducation(s.Lines)...)
+		}
+	}
+
+	// Contact details are sometimes in a footer or sidebar rather than the header.
+	if sr.Contact.Email == "" {
+		sr.Contact.Email = emailRegex.FindString(text)
+	}
+	if sr.Contact.LinkedIn == "" {
+		sr.Contact.LinkedIn = linkedInRegex.FindString(text)
+	}
+	return sr
+}
+
+func parseContact(c *resumeContact, lines []resumeLine) {
+	for _, line := range lines {
+		for _, part := range headerSeparators.Split(line.Text, -1) {
+			part = strings.TrimSpace(part)
+			switch {
+			case part == "":
+			case c.Email == "" && emailRegex.MatchString(part):
+				c.Email = emailRegex.FindString(part)
+			case c.LinkedIn == "" && linkedInRegex.MatchString(part):
+				c.LinkedIn = linkedInRegex.FindString(part)
+			case c.Phone == "" && phoneRegex.MatchString(part) && !yearRegex.MatchString(strings.TrimSpace(phoneRegex.FindString(part))):
+				c.Phone = strings.TrimSpace(phoneRegex.FindString(part))
+			case c.Website == "" && urlRegex.MatchString(part):
+				c.Website = urlRegex.FindString(part)
+			case c.Location == "" && locationRegex.MatchString(part):
+				c.Location = part
+			case c.Name == "" && len(strings.Fields(part)) <= 5 && !strings.ContainsAny(part, "0123456789@/:"):
+				c.Name = part
+			}
+		}
+	}
+}
+
+// splitRole separates a job heading like "Senior Engineer at Acme" or
+// "Acme | Senior Engineer" into title and company. With no recognizable
+// separator the whole heading is treated as the title.
+func splitRole(heading string) (title, company string) {
+	heading = strings.Trim(heading, " ,|-–—")
+	if t, c, ok := strings.Cut(heading, " at "); ok {
+		return strings.TrimSpace(t), strings.TrimSpace(c)
+	}
+	for _, sep := range []string{" | ", " – ", " — ", " - ", ", "} {
+		if t, c, ok := strings.Cut(heading, sep); ok {
+			return strings.TrimSpace(t), strings.Trim(c, " ,|-–—")
+		}
+	}
+	return heading, ""
+}
+
+// parseExperience treats every line with a date range as the start of a role.
+// The rest of the dated line and any plain lines right before it name the
+// role; bullet lines after it describe it.
+func parseExperience(lines []resumeLine) []resumeExperience {
+	var roles []resumeExperience
+	var pending []string
+	for _, line := range lines {
+		if m := dateRangeRegex.FindStringSubmatchIndex(line.Text); m != nil {
+			role := resumeExperience{
+				StartDate: line.Text[m[2]:m[3]],
+				EndDate:   line.Text[m[4]:m[5]],
+				Bullets:   []string{},
+			}
+			rest := strings.Trim(line.Text[:m[0]]+" "+line.Text[m[1]:], " ,|-–—()")
+			role.Title, role.Company = splitRole(rest)
+			// A plain line above the dates is usually the company.
+			if above := strings.Join(pending, ", "); above != "" {
+				if role.Title == "" {
+					role.Title, role.Company = splitRole(above)
+				} else if role.Company == "" {
+					role.Company = above
+				}
+			}
+			roles = append(roles, role)
+			pending = nil
+			continue
+		}
+
+		if len(roles) == 0 {
+			pending = append(pending, line.Text)
+			continue
+		}
+		cur := &roles[len(roles)-1]
+		switch {
+		case line.Bullet:
+			cur.Bullets = append(cur.Bullets, line.Text)
+		case len(cur.Bullets) == 0 && cur.Company == "":
+			cur.Company = line.Text
+		default:
+			// Probably the heading of the next role; if no role follows it was
+			// a wrapped bullet after all.
+			pending = append(pending, line.Text)
+		}
+	}
+	if len(roles) > 0 {
+		cur := &roles[len(roles)-1]
+		cur.Bullets = append(cur.Bullets, pending...)
+	}
+	return roles
+}
+
+func parseSkills(lines []resumeLine) []string {
+	var skills []string
+	seen := map[string]bool{}
+	for _, line := range lines {
+		text := line.Text
+		// Drop category labels such as "Languages: Go, Python".
+		if label, rest, ok := strings.Cut(text, ":"); ok && len(strings.Fields(label)) <= 3 {
+			text = rest
+		}
+		for _, s := range skillSeparators.Split(text, -1) {
+			s = strings.TrimSpace(s)
+			if s == "" || len(s) > 40 || seen[strings.ToLower(s)] {
+				continue
+			}
+			seen[strings.ToLower(s)] = true
+			skills = append(skills, s)
+		}
+	}
+	return skills
+}
+
+// parseEducation starts a new entry at every line naming an institution.
+func parseEducation(lines []resumeLine) []resumeEducation {
+	var entries []resumeEducation
+	for _, line := range lines {
+		text := line.Text
+		var start, end string
+		if m := dateRangeRegex.FindStringSubmatchIndex(text); m != nil {
+			start, end = text[m[2]:m[3]], text[m[4]:m[5]]
+			text = strings.Trim(text[:m[0]]+" "+text[m[1]:], " ,|-–—()")
+		} else if years := yearRegex.FindAllString(text, -1); len(years) > 0 {
+			end = years[len(years)-1]
+			text = strings.Trim(yearRegex.ReplaceAllString(text, ""), " ,|-–—()")
+		}
+
+		hasInstitution := institutionRegex.MatchString(text)
+		if hasInstitution || len(entries) == 0 || entries[len(entries)-1].Institution != "" && entries[len(entries)-1].Degree != "" {
+			entries = append(entries, resumeEducation{})
+		}
+		cur := &entries[len(entries)-1]
+		for _, part := range headerSeparators.Split(strings.ReplaceAll(text, ", ", " | "), -1) {
+			switch {
+			case part == "":
+			case cur.Institution == "" && institutionRegex.MatchString(part):
+				cur.Institution = part
+			case cur.Degree == "" && degreeRegex.MatchString(part):
+				cur.Degree = part
+			case cur.Degree != "" && degreeRegex.MatchString(cur.Degree) && cur.Institution == "":
+				cur.Institution = part
+			}
+		}
+		if start != "" {
+			cur.StartDate = start
+		}
+		if end != "" {
+			cur.EndDate = end
+		}
+	}
+	return entries
+}
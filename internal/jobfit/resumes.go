@@ -0,0 +1,208 @@
+package jobfit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// A resumeVersion is one distinct resume text a client has analyzed.
+type resumeVersion struct {
+	ID         string            `json:"id"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Text       string            `json:"text"`
+	Structured *structuredResume `json:"structured,omitempty"`
+	// DeletedAt is set while the version is in its owner's trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+var errResumeNotFound = errors.New("resume not found")
+
+func resumeKey(id string) string          { return rkey("resume", id) }
+func resumesKey(owner string) string      { return rkey("resumes", owner) }
+func resumeHashesKey(owner string) string { return rkey("resumes", "hashes", owner) }
+
+// resumeHash identifies a resume's text for spotting repeats.
+func resumeHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// saveResumeVersion stores text as a new version for owner, or returns the ID of
+// the existing version if the owner has analyzed the exact same text before.
+// New versions are parsed into structured fields in the background.
+func (app *application) saveResumeVersion(ctx context.Context, owner, text string) (string, error) {
+	hash := resumeHash(text)
+
+	if id, err := app.rdb.HGet(ctx, resumeHashesKey(owner), hash).Result(); err == nil {
+		if n, err := app.rdb.Exists(ctx, resumeKey(id)).Result(); err == nil && n == 1 {
+			return id, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+
+	v := resumeVersion{CreatedAt: time.Now().UTC(), Text: text}
+	v.ID = newULID(v.CreatedAt)
+	data, err := app.marshalSealed(resumeKey(v.ID), &v)
+	if err != nil {
+		return "", err
+	}
+
+	pipe := app.rdb.TxPipeline()
+	pipe.Set(ctx, resumeKey(v.ID), data, analysisRetention)
+	pipe.ZAdd(ctx, resumesKey(owner), redis.Z{Score: float64(v.CreatedAt.UnixMilli()), Member: v.ID})
+	pipe.ZRemRangeByRank(ctx, resumesKey(owner), 0, -maxHistoryItems-1)
+	pipe.Expire(ctx, resumesKey(owner), analysisRetention)
+	pipe.HSet(ctx, resumeHashesKey(owner), hash, v.ID)
+	pipe.Expire(ctx, resumeHashesKey(owner), analysisRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	go app.structureResumeVersion(v)
+	return v.ID, nil
+}
+
+// structureResumeVersion parses a stored version and saves the result next to
+// its raw text.
+func (app *application) structureResumeVersion(v resumeVersion) {
+	ctx := context.Background()
+	log := app.logger.With("resumeID", v.ID)
+
+	v.Structured = app.parseResume(ctx, log, v.Text)
+	if err := app.saveResumeVersionData(ctx, &v); err != nil {
+		log.Error("failed to store structured resume", "error", err)
+	}
+}
+
+// saveResumeVersionData overwrites a stored version without extending its
+// retention.
+func (app *application) saveResumeVersionData(ctx context.Context, v *resumeVersion) error {
+	data, err := app.marshalSealed(resumeKey(v.ID), v)
+	if err != nil {
+		return err
+	}
+	return app.rdb.Set(ctx, resumeKey(v.ID), data, redis.KeepTTL).Err()
+}
+
+// loadResumeVersion returns one of owner's resume versions.
+func (app *application) loadResumeVersion(ctx context.Context, owner, id string) (*resumeVersion, error) {
+	if !isULID(id) {
+		return nil, errResumeNotFound
+	}
+	if err := app.rdb.ZScore(ctx, resumesKey(owner), id).Err(); errors.Is(err, redis.Nil) {
+		return nil, errResumeNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return app.readResumeVersion(ctx, id)
+}
+
+// readResumeVersion loads a resume version whether or not it's listed under
+// its owner, such as one in the trash.
+func (app *application) readResumeVersion(ctx context.Context, id string) (*resumeVersion, error) {
+	data, err := app.rdb.Get(ctx, resumeKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errResumeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v resumeVersion
+	if err := app.unmarshalSealed(resumeKey(id), data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// listResumesHandler lists the caller's stored resume versions, newest first,
+// without their text.
+func (app *application) listResumesHandler(w http.ResponseWriter, r *http.Request) {
+	type summary struct {
+		ID        string    `json:"id"`
+		CreatedAt time.Time `json:"createdAt"`
+		Preview   string    `json:"preview"`
+	}
+	list := []summary{}
+
+	if owner, ok := app.existingClientID(r); ok {
+		ids, err := app.rdb.ZRevRange(r.Context(), resumesKey(owner), 0, -1).Result()
+		if err != nil {
+			app.logger.Error("failed to list resumes", "error", err)
+			http.Error(w, "Could not list resumes", http.StatusInternalServerError)
+			return
+		}
+		for _, id := range ids {
+			v, err := app.loadResumeVersion(r.Context(), owner, id)
+			if errors.Is(err, errResumeNotFound) {
+				continue
+			}
+			if err != nil {
+				app.logger.Error("failed to load resume", "resumeID", id, "error", err)
+				http.Error(w, "Could not list resumes", http.StatusInternalServerError)
+				return
+			}
+			preview, _, _ := strings.Cut(strings.TrimSpace(v.Text), "\n")
+			list = append(list, summary{ID: v.ID, CreatedAt: v.CreatedAt, Preview: preview})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// getResumeHandler returns one of the caller's resume versions.
+func (app *application) getResumeHandler(w http.ResponseWriter, r *http.Request) {
+	owner, ok := app.existingClientID(r)
+	if !ok {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	v, err := app.loadResumeVersion(r.Context(), owner, r.PathValue("id"))
+	if errors.Is(err, errResumeNotFound) {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load resume", "error", err)
+		http.Error(w, "Could not load resume", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// diffResumesHandler compares two of the caller's resume versions section by
+// section.
+func (app *application) diffResumesHandler(w http.ResponseWriter, r *http.Request) {
+	owner, ok := app.existingClientID(r)
+	if !ok {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	var versions [2]*resumeVersion
+	for i, id := range []string{r.PathValue("id"), r.PathValue("otherId")} {
+		v, err := app.loadResumeVersion(r.Context(), owner, id)
+		if errors.Is(err, errResumeNotFound) {
+			http.Error(w, "Resume not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			app.logger.Error("failed to load resume", "resumeID", id, "error", err)
+			http.Error(w, "Could not load resume", http.StatusInternalServerError)
+			return
+		}
+		versions[i] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffResumes(versions[0], versions[1]))
+}
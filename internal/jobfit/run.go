@@ -0,0 +1,341 @@
+package jobfit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/cors"
+)
+
+// newApplication connects to Redis and the model and loads the configuration
+// shared by the API server and the worker. It exits the process if anything
+// required is missing or invalid.
+func newApplication(ctx context.Context, logger *slog.Logger) *application {
+	if err := godotenv.Load(); err != nil {
+		logger.Info("no .env file found, using environment variables")
+	}
+	if err := configureKeyNamespace(); err != nil {
+		logger.Error("invalid environment name", "error", err)
+		os.Exit(1)
+	}
+
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr, Password: os.Getenv("REDIS_PASSWORD"), DB: 0})
+	if _, err := rdb.Ping(ctx).Result(); err != nil {
+		logger.Error("redis connection failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("redis client connected")
+
+	if err := configureOutboundProxy(logger); err != nil {
+		logger.Error("invalid proxy configuration", "error", err)
+		os.Exit(1)
+	}
+
+	residency, err := loadResidency()
+	if err != nil {
+		logger.Error("invalid data residency configuration", "error", err)
+		os.Exit(1)
+	}
+
+	models, err := newLanguageModel(ctx, logger, residency)
+	if err != nil {
+		logger.Error("failed to create model client", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("model client initialized", "provider", models.provider(), "dataResidency", residency)
+
+	quota, err := loadQuotaConfig()
+	if err != nil {
+		logger.Error("invalid quota configuration", "error", err)
+		os.Exit(1)
+	}
+
+	geo, err := loadGeoPolicy()
+	if err != nil {
+		logger.Error("invalid geoip configuration", "error", err)
+		os.Exit(1)
+	}
+
+	proxies, err := loadTrustedProxies()
+	if err != nil {
+		logger.Error("invalid proxy configuration", "error", err)
+		os.Exit(1)
+	}
+
+	terms, err := loadTerms()
+	if err != nil {
+		logger.Error("invalid terms configuration", "error", err)
+		os.Exit(1)
+	}
+
+	grammar, err := newGrammarCheckerFromEnv()
+	if err != nil {
+		logger.Error("invalid grammar check configuration", "error", err)
+		os.Exit(1)
+	}
+
+	scoreSamples, err := loadScoreSamples()
+	if err != nil {
+		logger.Error("invalid score sampling configuration", "error", err)
+		os.Exit(1)
+	}
+
+	stripe, err := loadStripeConfig()
+	if err != nil {
+		logger.Error("invalid payment configuration", "error", err)
+		os.Exit(1)
+	}
+	if stripe != nil && os.Getenv("CLIENT_TOKEN_SECRET") == "" {
+		logger.Error("invalid payment configuration", "error", "credit packs need CLIENT_TOKEN_SECRET")
+		os.Exit(1)
+	}
+
+	keys, err := loadKeyring()
+	if err != nil {
+		logger.Error("invalid encryption configuration", "error", err)
+		os.Exit(1)
+	}
+	if keys == nil {
+		logger.Warn("ENCRYPTION_KEYS is not set; resumes and analyses are stored unencrypted")
+	}
+	webhooks := newWebhookDispatcher(rdb, logger, keys)
+
+	return &application{
+		logger:       logger,
+		models:       models,
+		rdb:          rdb,
+		channels:     newNotificationChannels(newMailerFromEnv(), webhooks),
+		adminToken:   os.Getenv("ADMIN_TOKEN"),
+		clientSecret: []byte(os.Getenv("CLIENT_TOKEN_SECRET")),
+		quota:        quota,
+		baseURL:      os.Getenv("PUBLIC_BASE_URL"),
+		scoreSamples: scoreSamples,
+		keys:         keys,
+		webhooks:     webhooks,
+		caches:       newHotCaches(),
+		geo:          geo,
+		proxies:      proxies,
+		grammar:      grammar,
+		terms:        terms,
+		residency:    residency,
+		stripe:       stripe,
+	}
+}
+
+// RunAPI serves the web app and HTTP API. Queued analyses, digests, webhook
+// deliveries and the Kafka sink are left to the worker.
+func RunAPI() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	app := newApplication(context.Background(), logger)
+	defer app.models.close()
+
+	frontend, err := loadFrontend()
+	if err != nil {
+		logger.Error("failed to load frontend", "error", err)
+		os.Exit(1)
+	}
+	app.frontend = frontend
+
+	// Every route gets a request ID, its client's address and panic recovery
+	// and is counted in the metrics. API routes are also logged, rate
+	// limited per IP and open to admin impersonation, unlike static files
+	// and the health check. Billing routes need a signed client token and
+	// admin routes the admin token.
+	mux := http.NewServeMux()
+	static := newRouteGroup(mux, withRequestID, app.withClientIP, app.countRequests, app.recoverPanic)
+	api := newRouteGroup(mux, withRequestID, app.withClientIP, app.logRequests, app.countRequests, app.recoverPanic,
+		app.limitRequestRate, app.withImpersonation)
+	billing := api.with(app.signedClientOnly)
+	admin := api.with(app.adminOnly)
+
+	fileServer := http.FileServerFS(staticFiles)
+	static.handle("GET /{$}", app.withClientID(http.HandlerFunc(app.indexHandler)))
+	static.handle("/", app.withClientID(http.StripPrefix("/", fileServer)))
+	api.handleFunc("/chat", app.chatHandler)
+	api.handleFunc("POST /compare-jobs", app.compareJobsHandler)
+	api.handleFunc("POST /what-if", app.whatIfHandler)
+	api.handleFunc("POST /anonymize", app.anonymizeHandler)
+	api.handleFunc("POST /resume-completeness", app.completenessHandler)
+	api.handleFunc("POST /resume-drafts", app.draftResumeHandler)
+	api.handleFunc("POST /achievements/star", app.starHandler)
+	api.handleFunc("POST /resume-trims", app.trimResumeHandler)
+	api.handleFunc("POST /headline", app.headlineHandler)
+	api.handleFunc("POST /linkedin-reviews", app.linkedinReviewHandler)
+	api.handleFunc("POST /outreach-messages", app.outreachHandler)
+	api.handleFunc("POST /answer-banks", app.answerBankHandler)
+	api.handleFunc("POST /company-brief", app.companyBriefHandler)
+	api.handleFunc("POST /job-posts", app.generateJobPostHandler)
+	api.handleFunc("GET /analyses/{id}", app.getAnalysisHandler)
+	api.handleFunc("POST /analyses/async", app.enqueueAnalysisHandler)
+	api.handleFunc("POST /analyses/quick", app.quickAnalysisHandler)
+	api.handleFunc("GET /analyses/async/{id}", app.analysisJobHandler)
+	api.handleFunc("POST /analyses/{id}/email", app.applicationEmailHandler)
+	api.handleFunc("GET /history", app.listHistoryHandler)
+	api.handleFunc("GET /history/search", app.searchHistoryHandler)
+	api.handleFunc("GET /history/export.csv", app.exportHistoryHandler)
+	api.handleFunc("GET /history/{id}/bundle.zip", app.bundleHandler)
+	api.handleFunc("PATCH /history/{id}", app.annotateHistoryHandler)
+	api.handleFunc("DELETE /history/{id}", app.deleteAnalysisHandler)
+	api.handleFunc("POST /history/{id}/restore", app.restoreAnalysisHandler)
+	api.handleFunc("GET /resumes", app.listResumesHandler)
+	api.handleFunc("GET /resumes/{id}", app.getResumeHandler)
+	api.handleFunc("GET /resumes/{id}/diff/{otherId}", app.diffResumesHandler)
+	api.handleFunc("DELETE /resumes/{id}", app.deleteResumeHandler)
+	api.handleFunc("POST /resumes/{id}/restore", app.restoreResumeHandler)
+	api.handleFunc("GET /trash", app.listTrashHandler)
+	api.handleFunc("GET /me/notifications", app.notificationPrefsHandler)
+	api.handleFunc("PUT /me/notifications", app.notificationPrefsHandler)
+	api.handleFunc("POST /me/notifications/confirm", app.confirmAddressHandler)
+	api.handleFunc("GET /unsubscribe/{token}", app.unsubscribeDigestHandler)
+	api.handleFunc("POST /unsubscribe/{token}", app.unsubscribeDigestHandler)
+	api.handleFunc("GET /me/preferences", app.preferencesHandler)
+	api.handleFunc("PUT /me/preferences", app.preferencesHandler)
+	api.handleFunc("GET /quota", app.quotaHandler)
+	api.handleFunc("GET /consent", app.consentHandler)
+	api.handleFunc("POST /consent", app.acceptConsentHandler)
+	api.handleFunc("POST /coupons/redeem", app.redeemCouponHandler)
+	api.handleFunc("GET /credit-packs", app.creditPacksHandler)
+	billing.handleFunc("POST /credit-packs/checkout", app.checkoutCreditPackHandler)
+	api.handleFunc("POST /stripe/webhook", app.stripeWebhookHandler)
+	billing.handleFunc("GET /billing/invoices", app.billingHandler)
+	api.handleFunc("GET /referrals/me", app.myReferralHandler)
+	api.handleFunc("POST /referrals/claim", app.claimReferralHandler)
+	api.handleFunc("GET /announcements", app.announcementsHandler)
+	api.handleFunc("GET /stats/public", app.publicStatsHandler)
+	api.handleFunc("GET /trends/skills", app.skillTrendsHandler)
+	api.handleFunc("GET /widget", app.widgetPageHandler)
+	api.handleFunc("POST /widget/analyze", app.widgetAnalyzeHandler)
+	api.handleFunc("POST /api/analyze", app.integrationAnalyzeHandler)
+
+	admin.handleFunc("GET /admin/announcements", app.adminListAnnouncementsHandler)
+	admin.handleFunc("POST /admin/announcements", app.adminCreateAnnouncementHandler)
+	admin.handleFunc("DELETE /admin/announcements/{id}", app.adminDeleteAnnouncementHandler)
+	admin.handleFunc("GET /admin/coupons", app.adminListCouponsHandler)
+	admin.handleFunc("POST /admin/coupons", app.adminCreateCouponHandler)
+	admin.handleFunc("DELETE /admin/coupons/{code}", app.adminDeleteCouponHandler)
+	admin.handleFunc("GET /admin/referrals", app.adminReferralsHandler)
+	admin.handleFunc("GET /admin/consent/{clientId}", app.adminConsentHandler)
+	admin.handleFunc("POST /admin/reencrypt", app.adminReencryptHandler)
+	admin.handleFunc("POST /admin/migrate-keys", app.adminMigrateKeysHandler)
+	admin.handleFunc("GET /admin/audit", app.adminAuditLogHandler)
+	admin.handleFunc("GET /admin/metrics", app.adminMetricsHandler)
+	admin.handleFunc("GET /admin/webhooks/log", app.adminWebhookLogHandler)
+	admin.handleFunc("GET /admin/webhooks/dead", app.adminDeadWebhooksHandler)
+	admin.handleFunc("POST /admin/webhooks/dead/{id}/retry", app.adminRetryWebhookHandler)
+	admin.handleFunc("DELETE /admin/webhooks/dead/{id}", app.adminDeleteDeadWebhookHandler)
+	admin.handleFunc("GET /admin/model-keys", app.adminModelKeysHandler)
+	admin.handleFunc("POST /admin/model-keys/rotate", app.adminRotateModelKeyHandler)
+	admin.handleFunc("GET /admin/widget-keys", app.adminListWidgetKeysHandler)
+	admin.handleFunc("POST /admin/widget-keys", app.adminCreateWidgetKeyHandler)
+	admin.handleFunc("DELETE /admin/widget-keys/{key}", app.adminDeleteWidgetKeyHandler)
+	admin.handleFunc("GET /admin/integrations", app.adminListIntegrationsHandler)
+	admin.handleFunc("POST /admin/integrations", app.adminCreateIntegrationHandler)
+	admin.handleFunc("DELETE /admin/integrations/{id}", app.adminDeleteIntegrationHandler)
+	static.handleFunc("/healthz", app.healthCheckHandler)
+
+	handler := cors.New(cors.Options{
+		AllowedOrigins: []string{"*"}, // TODO: Restrict in production
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type"},
+		// The web app follows Location to poll two-phase analyses, which
+		// it can only read across origins if it's exposed.
+		ExposedHeaders: []string{"Location", "X-Request-ID"},
+	}).Handler(mux)
+
+	listener, addr, err := apiListener()
+	if err != nil {
+		logger.Error("failed to listen", "addr", addr, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("starting server", "addr", addr)
+	if err := http.Serve(listener, handler); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// RunWorker runs queued analyses and the background jobs. Workers scale
+// independently of API servers and share the queue between them.
+func RunWorker() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("process", "worker")
+	ctx := context.Background()
+	app := newApplication(ctx, logger)
+	defer app.models.close()
+
+	concurrency, err := loadWorkerConcurrency()
+	if err != nil {
+		logger.Error("invalid worker configuration", "error", err)
+		os.Exit(1)
+	}
+	kafka, err := newKafkaSinkFromEnv(app.rdb, logger)
+	if err != nil {
+		logger.Error("invalid kafka configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if app.baseURL != "" {
+		go app.runAsLeader(ctx, "digests", app.runDigests)
+	} else {
+		logger.Warn("PUBLIC_BASE_URL is not set; weekly digests are off, since they must link back to unsubscribe")
+	}
+	go app.runAsLeader(ctx, "trash-purge", app.runTrashPurge)
+	go app.runAsLeader(ctx, "deferred-analyses", app.runDeferredAnalyses)
+	go app.webhooks.run(ctx)
+	if kafka != nil {
+		go kafka.run(ctx)
+	}
+
+	logger.Info("starting worker", "concurrency", concurrency)
+	app.runAnalysisQueue(ctx, concurrency)
+}
+
+// RunMigrate moves keys written before namespacing into APP_ENV's namespace
+// and prints what it did.
+func RunMigrate() {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil)).With("process", "migrate")
+	ctx := context.Background()
+	app := newApplication(ctx, logger)
+	defer app.models.close()
+
+	result, err := app.migrateLegacyKeys(ctx)
+	if err != nil {
+		logger.Error("key migration failed", "error", err)
+		os.Exit(1)
+	}
+	fmt.Printf("scanned %d keys: %d moved, %d skipped, %d failed\n", result.Scanned, result.Moved, result.Skipped, result.Failed)
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// RunCheck loads every setting the API server and worker use and connects to
+// Redis and the model, exiting non-zero if anything is wrong. It's meant for
+// deploy pipelines, before the new version takes traffic.
+func RunCheck() {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil)).With("process", "check")
+	app := newApplication(context.Background(), logger)
+	defer app.models.close()
+
+	if _, err := loadFrontend(); err != nil {
+		logger.Error("failed to load frontend", "error", err)
+		os.Exit(1)
+	}
+	if _, err := loadWorkerConcurrency(); err != nil {
+		logger.Error("invalid worker configuration", "error", err)
+		os.Exit(1)
+	}
+	if _, err := newKafkaSinkFromEnv(app.rdb, logger); err != nil {
+		logger.Error("invalid kafka configuration", "error", err)
+		os.Exit(1)
+	}
+	fmt.Println("configuration ok")
+}
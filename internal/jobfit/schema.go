@@ -0,0 +1,109 @@
+package jobfit
+
+import (
+	"context"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Gemini is always asked for JSON, and for the analysis prompt it is also
+// given the shape of the reply as a response schema, so its output decodes
+// into AnalysisResponse without relying on the model following the prompt's
+// description of the keys. Other providers still get that description and
+// their own JSON mode.
+
+type responseSchemaKey struct{}
+
+// withResponseSchema makes model calls made with ctx constrain Gemini's
+// reply to s.
+func withResponseSchema(ctx context.Context, s *genai.Schema) context.Context {
+	return context.WithValue(ctx, responseSchemaKey{}, s)
+}
+
+func responseSchema(ctx context.Context) *genai.Schema {
+	s, _ := ctx.Value(responseSchemaKey{}).(*genai.Schema)
+	return s
+}
+
+func stringArraySchema() *genai.Schema {
+	return &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}}
+}
+
+// analysisSchema describes the reply analysisPrompt asks for, including the
+// optional keys req wants.
+func analysisSchema(req *AnalysisRequest) *genai.Schema {
+	s := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"jobTitle":       {Type: genai.TypeString},
+			"company":        {Type: genai.TypeString},
+			"matchScore":     {Type: genai.TypeInteger},
+			"scoreMargin":    {Type: genai.TypeInteger},
+			"requiredSkills": stringArraySchema(),
+		},
+		Required: []string{"jobTitle", "company", "matchScore", "scoreMargin", "requiredSkills"},
+	}
+	for _, k := range analysisPromptOptional {
+		if req.wants(k.field) {
+			addRequired(s, k.field, stringArraySchema())
+		}
+	}
+	return s
+}
+
+// addRequired adds a required key to an object schema, for prompts that ask
+// for more than the base reply.
+func addRequired(s *genai.Schema, name string, prop *genai.Schema) {
+	s.Properties[name] = prop
+	s.Required = append(s.Required, name)
+}
+
+// withSchema returns gen set up to reply in the shape of s.
+func withSchema(gen contentGenerator, s *genai.Schema) contentGenerator {
+	switch m := gen.(type) {
+	case *genai.GenerativeModel:
+		c := *m
+		c.ResponseSchema = s
+		return &c
+	case *vertexModel:
+		c := *m
+		c.schema = s
+		return &c
+	}
+	return gen
+}
+
+// vertexSchemaTypes are the names Vertex AI's REST API gives schema types.
+var vertexSchemaTypes = map[genai.Type]string{
+	genai.TypeString:  "STRING",
+	genai.TypeNumber:  "NUMBER",
+	genai.TypeInteger: "INTEGER",
+	genai.TypeBoolean: "BOOLEAN",
+	genai.TypeArray:   "ARRAY",
+	genai.TypeObject:  "OBJECT",
+}
+
+// vertexSchema writes s as Vertex AI's REST API expects it.
+func vertexSchema(s *genai.Schema) map[string]any {
+	out := map[string]any{"type": vertexSchemaTypes[s.Type]}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = vertexSchema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]any, len(s.Properties))
+		for name, p := range s.Properties {
+			props[name] = vertexSchema(p)
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package jobfit
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Matches in the job title or company count for more than matches buried in the
+// feedback text.
+const (
+	headlineMatchWeight = 3
+	bodyMatchWeight     = 1
+)
+
+// searchTerms lowercases s and splits it into words.
+func searchTerms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// hasWordWithPrefix reports whether any word in words starts with term, so that
+// "stripe" finds "Stripe's" and "backend" finds "backend-focused".
+func hasWordWithPrefix(words []string, term string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// relevance scores rec against the query terms. Every term has to appear
+// somewhere; otherwise the record doesn't match and the score is zero.
+func relevance(rec *analysisRecord, terms []string) int {
+	headline := searchTerms(rec.JobTitle + " " + rec.Company)
+	body := searchTerms(strings.Join(rec.Improvements, " ") + " " + strings.Join(rec.NextSteps, " "))
+
+	score := 0
+	for _, t := range terms {
+		hit := false
+		if hasWordWithPrefix(headline, t) {
+			score += headlineMatchWeight
+			hit = true
+		}
+		if hasWordWithPrefix(body, t) {
+			score += bodyMatchWeight
+			hit = true
+		}
+		if !hit {
+			return 0
+		}
+	}
+	return score
+}
+
+// searchHistoryHandler finds analyses in the caller's history whose job title,
+// company or feedback mention every word of the "q" parameter, best match first.
+func (app *application) searchHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	terms := searchTerms(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit, _, err := parsePageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type hit struct {
+		rec   *analysisRecord
+		score int
+	}
+	var hits []hit
+	if owner, ok := app.existingClientID(r); ok {
+		recs, err := app.loadHistory(r.Context(), owner, time.Time{}, time.Time{})
+		if err != nil {
+			app.logger.Error("failed to load history", "error", err)
+			http.Error(w, "Could not search history", http.StatusInternalServerError)
+			return
+		}
+		for _, rec := range recs {
+			if s := relevance(rec, terms); s > 0 {
+				hits = append(hits, hit{rec, s})
+			}
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b hit) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return strings.Compare(b.rec.ID, a.rec.ID)
+	})
+
+	items := make([]*analysisRecord, 0, min(limit, len(hits)))
+	for _, h := range hits[:min(limit, len(hits))] {
+		items = append(items, h.rec)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page[*analysisRecord]{Items: items})
+}
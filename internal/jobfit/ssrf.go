@@ -0,0 +1,168 @@
+package jobfit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFetchRedirects bounds how many redirects a fetch of a user-supplied URL
+// follows; each hop is checked like the original URL.
+const maxFetchRedirects = 5
+
+var errBlockedAddress = errors.New("address is not publicly routable")
+
+// blockedPrefixes are ranges outside the ones netip already classifies that
+// must never be fetched on a user's behalf.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach private IPv4
+	netip.MustParsePrefix("2002::/16"),    // 6to4 likewise
+}
+
+// isPublicAddr reports whether addr is safe to connect to for a user-supplied
+// URL: not loopback, private, link-local (which includes cloud metadata
+// endpoints), multicast or otherwise reserved.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFetchURL rejects user-supplied URLs that can't be safe to fetch
+// whatever their host resolves to. Resolved addresses are checked when the
+// connection is made.
+func checkFetchURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("URLs with credentials are not allowed")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return errors.New("URL has no host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") || strings.HasSuffix(host, ".local") {
+		return errBlockedAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// proxyAddrs holds the proxies the guarded transport has been told to use.
+// Connections to them are allowed even on private addresses, since the proxy
+// makes the onward connection and the target was checked before it was
+// handed over.
+var proxyAddrs sync.Map
+
+// guardedProxy defers to the default transport's proxy settings, which are
+// configured at startup, after package-level clients are created.
+func guardedProxy(r *http.Request) (*url.URL, error) {
+	dt, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || dt.Proxy == nil {
+		return nil, nil
+	}
+	proxy, err := dt.Proxy(r)
+	if proxy == nil || err != nil {
+		return proxy, err
+	}
+	// The proxy resolves the target itself, so it's checked here instead of
+	// at dial time.
+	addrs, err := net.DefaultResolver.LookupNetIP(r.Context(), "ip", r.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if !isPublicAddr(a) {
+			return nil, fmt.Errorf("%s: %w", r.URL.Hostname(), errBlockedAddress)
+		}
+	}
+	port := proxy.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[proxy.Scheme]
+	}
+	proxyAddrs.Store(net.JoinHostPort(proxy.Hostname(), port), true)
+	return proxy, nil
+}
+
+// guardedDial resolves the host itself and connects only to public
+// addresses. Dialing the checked IP, rather than the name, means DNS can't
+// hand back a different answer between the check and the connection.
+func guardedDial(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if _, ok := proxyAddrs.Load(address); ok {
+			return dialer.DialContext(ctx, network, address)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if !isPublicAddr(a) {
+				return nil, fmt.Errorf("%s: %w", host, errBlockedAddress)
+			}
+		}
+		for _, a := range addrs {
+			var conn net.Conn
+			if conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(a.Unmap().String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		if err == nil {
+			err = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, err
+	}
+}
+
+// newGuardedClient returns a client for fetching user-supplied URLs, such as
+// portfolio pages, resume links and webhooks, that can't be pointed at this
+// server's internal network.
+func newGuardedClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                  guardedProxy,
+			DialContext:            guardedDial(dialer),
+			ForceAttemptHTTP2:      true,
+			MaxIdleConns:           20,
+			IdleConnTimeout:        90 * time.Second,
+			TLSHandshakeTimeout:    5 * time.Second,
+			ResponseHeaderTimeout:  timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkFetchURL(req.URL)
+		},
+	}
+}
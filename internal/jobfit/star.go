@@ -0,0 +1,152 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxAchievementSize = 500
+	maxStarAnswerSize  = 1000
+	// maxStarQuestions caps the follow-up questions, which are all asked in
+	// one round so the user answers them together.
+	maxStarQuestions = 2
+)
+
+// starRequest is an achievement to expand. The first call sends only the
+// achievement; if the reply asks questions, the second sends them back with
+// the user's answers.
+type starRequest struct {
+	Achievement string       `json:"achievement"`
+	Role        string       `json:"role,omitempty"`
+	Answers     []starAnswer `json:"answers,omitempty"`
+}
+
+type starAnswer struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// starBullet is an achievement told as situation, task, action and result.
+type starBullet struct {
+	Situation string `json:"situation"`
+	Task      string `json:"task"`
+	Action    string `json:"action"`
+	Result    string `json:"result"`
+	// Long is the full story in a short paragraph, for interviews and cover
+	// letters; Bullet is one line for the resume.
+	Long   string `json:"long"`
+	Bullet string `json:"bullet"`
+}
+
+// starResponse has either the follow-up questions to answer or the expanded
+// achievement.
+type starResponse struct {
+	ID        string      `json:"id"`
+	Questions []string    `json:"questions,omitempty"`
+	Star      *starBullet `json:"star,omitempty"`
+}
+
+// starHandler expands a terse achievement into a STAR-structured story and
+// resume bullet. When the achievement is too vague it first asks up to
+// maxStarQuestions follow-up questions; once they're answered it always
+// expands, so a client makes at most two calls per achievement. Each call
+// draws on the quota.
+func (app *application) starHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req starRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Achievement = strings.TrimSpace(req.Achievement)
+	if req.Achievement == "" {
+		http.Error(w, "An achievement is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Achievement) > maxAchievementSize || len(req.Role) > maxAchievementSize {
+		http.Error(w, "Achievement is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(req.Answers) > maxStarQuestions {
+		http.Error(w, fmt.Sprintf("At most %d answers can be given", maxStarQuestions), http.StatusBadRequest)
+		return
+	}
+	for _, a := range req.Answers {
+		if len(a.Question) > maxStarAnswerSize || len(a.Answer) > maxStarAnswerSize {
+			http.Error(w, "Answer is too long", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionStar); !ok {
+		return
+	}
+
+	starID := newULID(time.Now())
+	log := app.logger.With("starID", starID, "ip", ip)
+
+	var known strings.Builder
+	if req.Role != "" {
+		fmt.Fprintf(&known, "The achievement is from the candidate's role as %q.\n", req.Role)
+	}
+	for _, a := range req.Answers {
+		fmt.Fprintf(&known, "Q: %s\nA: %s\n", a.Question, a.Answer)
+	}
+	// Once the user has answered, the model must work with what it has.
+	questions := fmt.Sprintf(`- "questions": a JSON array of at most %d short follow-up questions asking for the missing context, what the candidate did themselves or a measurable result. Leave it empty if the achievement already says enough, and fill in the other keys anyway with what is known.`, maxStarQuestions)
+	if len(req.Answers) > 0 {
+		questions = `- "questions": an empty JSON array. Do not ask anything more.`
+	}
+
+	prompt := fmt.Sprintf(`
+		Expand the candidate's resume achievement using the STAR method.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		%s
+		- "situation": a string, one sentence on the context.
+		- "task": a string, one sentence on what the candidate had to achieve.
+		- "action": a string, one or two sentences on what the candidate did.
+		- "result": a string, one sentence on the outcome, with numbers where the candidate gave them.
+		- "long": a string, the four parts as a short first-person paragraph.
+		- "bullet": a string, a single resume bullet of at most 30 words that starts with a strong action verb and ends with the result.
+		Only use facts the candidate gave; do not invent numbers or details. Where something is unknown, leave it general rather than guessing. Do not use markdown.
+
+		**Achievement:**
+		---
+		%s
+		---
+		%s
+	`, questions, req.Achievement, known.String())
+
+	var out struct {
+		Questions []string `json:"questions"`
+		starBullet
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		modelError(w, log, err)
+		return
+	}
+
+	resp := starResponse{ID: starID}
+	if len(req.Answers) == 0 && len(out.Questions) > 0 {
+		resp.Questions = out.Questions[:min(len(out.Questions), maxStarQuestions)]
+	} else {
+		resp.Star = &out.starBullet
+	}
+	log.Info("expanded achievement", "questions", len(resp.Questions))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Monthly skill counters are kept a little over a year so trends can
+	// compare against the same month last year.
+	skillStatsRetention = 400 * 24 * time.Hour
+	// A skill has to be requested this many times in a month before it is
+	// shown publicly, so rare skills can't point back to one job posting.
+	minPublicSkillCount = 5
+	publicTopSkills     = 10
+	maxRecordedSkills   = 30
+)
+
+func statsTotalKey() string  { return rkey("stats", "analyses") }
+func statsScoresKey() string { return rkey("stats", "scores") }
+func statsScoreSum() string  { return rkey("stats", "scores", "sum") }
+
+func skillStatsKey(month string) string { return rkey("stats", "skills", month) }
+
+// normalizeSkill folds the spellings of a skill together for counting.
+func normalizeSkill(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// scoreBucket groups scores into tens; 100 goes in with the nineties.
+func scoreBucket(score int) int {
+	return min(score/10, 9) * 10
+}
+
+// recordStats adds an analysis to the aggregate counters. Only counts are
+// kept; nothing here can be traced back to a user or a resume.
+func (app *application) recordStats(ctx context.Context, resp *AnalysisResponse, at time.Time) error {
+	month := at.UTC().Format("2006-01")
+	family := roleFamily(resp.JobTitle)
+	pipe := app.rdb.TxPipeline()
+	pipe.Incr(ctx, statsTotalKey())
+	pipe.HIncrBy(ctx, monthlyAnalysesKey(month), familyAll, 1)
+	pipe.HIncrBy(ctx, monthlyAnalysesKey(month), family, 1)
+	pipe.HIncrBy(ctx, statsScoresKey(), strconv.Itoa(scoreBucket(resp.MatchScore)), 1)
+	pipe.IncrBy(ctx, statsScoreSum(), int64(resp.MatchScore))
+	seen := map[string]bool{}
+	for _, s := range resp.RequiredSkills[:min(len(resp.RequiredSkills), maxRecordedSkills)] {
+		if s = normalizeSkill(s); s != "" && !seen[s] {
+			seen[s] = true
+			pipe.ZIncrBy(ctx, familySkillStatsKey(month, familyAll), 1, s)
+			pipe.ZIncrBy(ctx, familySkillStatsKey(month, family), 1, s)
+		}
+	}
+	for _, key := range []string{monthlyAnalysesKey(month), familySkillStatsKey(month, familyAll), familySkillStatsKey(month, family)} {
+		pipe.Expire(ctx, key, skillStatsRetention)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+type scoreRange struct {
+	Range string `json:"range"`
+	Count int64  `json:"count"`
+}
+
+type skillCount struct {
+	Skill string `json:"skill"`
+	Count int64  `json:"count"`
+}
+
+type publicStats struct {
+	TotalAnalyses     int64        `json:"totalAnalyses"`
+	AverageScore      float64      `json:"averageScore"`
+	ScoreDistribution []scoreRange `json:"scoreDistribution"`
+	TopSkills         []skillCount `json:"topSkillsThisMonth"`
+}
+
+// publicStatsHandler serves aggregate usage numbers for the landing page.
+func (app *application) publicStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	month := skillStatsKey(time.Now().UTC().Format("2006-01"))
+
+	pipe := app.rdb.Pipeline()
+	total := pipe.Get(ctx, statsTotalKey())
+	buckets := pipe.HGetAll(ctx, statsScoresKey())
+	sum := pipe.Get(ctx, statsScoreSum())
+	skills := pipe.ZRevRangeByScoreWithScores(ctx, month, &redis.ZRangeBy{
+		Min: strconv.Itoa(minPublicSkillCount), Max: "+inf", Count: publicTopSkills,
+	})
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Error("failed to load public stats", "error", err)
+		http.Error(w, "Could not load stats", http.StatusInternalServerError)
+		return
+	}
+
+	stats := publicStats{ScoreDistribution: make([]scoreRange, 0, 10), TopSkills: []skillCount{}}
+	stats.TotalAnalyses, _ = total.Int64()
+	var scored int64
+	for low := 0; low <= 90; low += 10 {
+		n, _ := strconv.ParseInt(buckets.Val()[strconv.Itoa(low)], 10, 64)
+		high := low + 9
+		if low == 90 {
+			high = 100
+		}
+		stats.ScoreDistribution = append(stats.ScoreDistribution, scoreRange{Range: fmt.Sprintf("%d-%d", low, high), Count: n})
+		scored += n
+	}
+	if total, _ := sum.Int64(); scored > 0 {
+		stats.AverageScore = math.Round(float64(total)/float64(scored)*10) / 10
+	}
+	for _, z := range skills.Val() {
+		stats.TopSkills = append(stats.TopSkills, skillCount{Skill: z.Member.(string), Count: int64(z.Score)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(stats)
+}
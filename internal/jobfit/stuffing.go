@@ -0,0 +1,111 @@
+package jobfit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// A single job keyword making up more than this share of the resume, and
+	// appearing at least stuffedKeywordMinCount times, is an outlier.
+	stuffedKeywordShare    = 0.03
+	stuffedKeywordMinCount = 8
+	// Written English is roughly 40% function words. A long resume with
+	// almost none is a keyword dump rather than prose.
+	minFunctionWordShare = 0.08
+	coherenceMinWords    = 150
+	repeatedLineMinCount = 3
+)
+
+// functionWords are the common words that glue sentences together. They're
+// never job keywords, and their absence is a sign of a keyword dump.
+var functionWords = []string{
+	"a", "an", "and", "as", "at", "by", "for", "from", "in", "into", "is", "it", "of", "on", "or",
+	"our", "that", "the", "their", "this", "to", "was", "were", "which", "while", "with", "my", "i", "we",
+}
+
+// Invisible characters sometimes used to hide keywords from human readers
+// while leaving them for ATS parsers.
+var hiddenCharacters = []rune{'\u200b', '\u200c', '\u200d', '\u2060', '\ufeff', '\u00ad'}
+
+type stuffingSignal struct {
+	// Kind is one of "hidden_characters", "keyword_density",
+	// "repeated_lines" or "incoherent".
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// stuffingWarning flags a resume that looks written to game ATS keyword
+// matching. The checks are heuristics, so it's a warning rather than a verdict.
+type stuffingWarning struct {
+	Signals []stuffingSignal `json:"signals"`
+}
+
+// detectStuffing looks for keyword stuffing in resume relative to the job
+// description. It returns nil if nothing looks wrong.
+func detectStuffing(resume, jobDescription string) *stuffingWarning {
+	var signals []stuffingSignal
+
+	hidden := 0
+	for _, r := range resume {
+		if slices.Contains(hiddenCharacters, r) {
+			hidden++
+		}
+	}
+	if hidden > 0 {
+		signals = append(signals, stuffingSignal{"hidden_characters", fmt.Sprintf("The resume contains invisible characters (%d found).", hidden)})
+	}
+
+	words := searchTerms(resume)
+	jobWords := map[string]bool{}
+	for _, w := range searchTerms(jobDescription) {
+		if len(w) > 2 && !slices.Contains(functionWords, w) {
+			jobWords[w] = true
+		}
+	}
+	counts := map[string]int{}
+	functional := 0
+	for _, w := range words {
+		if slices.Contains(functionWords, w) {
+			functional++
+		} else if jobWords[w] {
+			counts[w]++
+		}
+	}
+	var stuffed []string
+	for w, n := range counts {
+		if n >= stuffedKeywordMinCount && float64(n) > stuffedKeywordShare*float64(len(words)) {
+			stuffed = append(stuffed, fmt.Sprintf("%q (%d times)", w, n))
+		}
+	}
+	if len(stuffed) > 0 {
+		slices.Sort(stuffed)
+		signals = append(signals, stuffingSignal{"keyword_density", "Job keywords are repeated far more than normal: " + strings.Join(stuffed, ", ") + "."})
+	}
+
+	lines := map[string]int{}
+	for _, line := range strings.Split(resume, "\n") {
+		if line = strings.ToLower(strings.Join(strings.Fields(line), " ")); len(strings.Fields(line)) >= 3 {
+			lines[line]++
+		}
+	}
+	repeated := 0
+	for _, n := range lines {
+		if n >= repeatedLineMinCount {
+			repeated++
+		}
+	}
+	if repeated > 0 {
+		signals = append(signals, stuffingSignal{"repeated_lines", fmt.Sprintf("Some lines are repeated %d or more times (%d distinct lines).", repeatedLineMinCount, repeated)})
+	}
+
+	if len(words) >= coherenceMinWords && float64(functional) < minFunctionWordShare*float64(len(words)) {
+		signals = append(signals, stuffingSignal{"incoherent", "The resume reads as a list of keywords rather than sentences."})
+	}
+
+	if len(signals) == 0 {
+		return nil
+	}
+	return &stuffingWarning{Signals: signals}
+}
@@ -0,0 +1,67 @@
+package jobfit
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// plannedChange is one resume edit with the model's estimate of how many
+// points it is worth.
+type plannedChange struct {
+	Change string `json:"change"`
+	Impact int    `json:"impact"`
+}
+
+// targetPlan is the smallest set of changes expected to lift the match score
+// to the target, biggest impact first.
+type targetPlan struct {
+	TargetScore    int `json:"targetScore"`
+	ProjectedScore int `json:"projectedScore"`
+	// Reachable is false when even every suggested change falls short.
+	Reachable bool            `json:"reachable"`
+	Changes   []plannedChange `json:"changes"`
+}
+
+func targetPlanPrompt(target int) string {
+	return fmt.Sprintf(`
+		The candidate wants to reach a match score of %d.
+		Also include the key "targetChanges": a JSON array of objects with "change" (a specific edit to the resume) and "impact" (an integer, the points that edit alone would add to matchScore).
+		List the fewest, highest-impact edits that would get the score to %d, and do not repeat the general improvements.
+	`, target, target)
+}
+
+// targetChangesSchema is the shape of the "targetChanges" targetPlanPrompt
+// asks for.
+var targetChangesSchema = &genai.Schema{
+	Type: genai.TypeArray,
+	Items: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"change": {Type: genai.TypeString},
+			"impact": {Type: genai.TypeInteger},
+		},
+		Required: []string{"change", "impact"},
+	},
+}
+
+// buildTargetPlan keeps only as many of the model's suggested changes as it
+// takes to reach target, so the user gets a short, prioritized list rather
+// than everything the model could think of.
+func buildTargetPlan(score, target int, suggested []plannedChange) *targetPlan {
+	changes := slices.DeleteFunc(slices.Clone(suggested), func(c plannedChange) bool { return c.Impact <= 0 || c.Change == "" })
+	slices.SortStableFunc(changes, func(a, b plannedChange) int { return cmp.Compare(b.Impact, a.Impact) })
+
+	plan := &targetPlan{TargetScore: target, ProjectedScore: score, Changes: []plannedChange{}}
+	for _, c := range changes {
+		if plan.ProjectedScore >= target {
+			break
+		}
+		plan.Changes = append(plan.Changes, c)
+		plan.ProjectedScore = min(plan.ProjectedScore+c.Impact, 100)
+	}
+	plan.Reachable = plan.ProjectedScore >= target
+	return plan
+}
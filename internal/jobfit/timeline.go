@@ -0,0 +1,168 @@
+package jobfit
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Breaks between roles shorter than this are normal job-change time and
+	// aren't reported.
+	minGapMonths = 3
+	// Roles overlapping by less than this are treated as a clean handover.
+	minOverlapMonths = 2
+)
+
+var (
+	monthYearRegex   = regexp.MustCompile(`(?i)^([a-z]{3,9})\.?,?\s+(\d{4})$`)
+	numericDateRegex = regexp.MustCompile(`^(\d{1,2})/(\d{4})$`)
+	yearOnlyRegex    = regexp.MustCompile(`^(\d{4})$`)
+
+	monthNames = map[string]time.Month{
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+)
+
+// yearMonth counts months since year 0, which makes month arithmetic trivial.
+type yearMonth int
+
+func newYearMonth(year int, m time.Month) yearMonth { return yearMonth(year*12 + int(m) - 1) }
+
+func (ym yearMonth) String() string {
+	return fmt.Sprintf("%04d-%02d", int(ym)/12, int(ym)%12+1)
+}
+
+func (ym yearMonth) MarshalText() ([]byte, error) { return []byte(ym.String()), nil }
+
+// normalizeResumeDate turns dates as people write them on resumes ("Jan 2020",
+// "01/2020", "2020", "Present") into a month. A bare year is read as January
+// when it starts a range and December when it ends one, so that vague dates
+// never produce a spurious gap.
+func normalizeResumeDate(s string, end bool, now time.Time) (yearMonth, bool) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "present", "current", "now", "today":
+		return newYearMonth(now.Year(), now.Month()), true
+	}
+	if m := monthYearRegex.FindStringSubmatch(s); m != nil {
+		month, ok := monthNames[strings.ToLower(m[1][:3])]
+		year, _ := strconv.Atoi(m[2])
+		return newYearMonth(year, month), ok
+	}
+	if m := numericDateRegex.FindStringSubmatch(s); m != nil {
+		month, _ := strconv.Atoi(m[1])
+		year, _ := strconv.Atoi(m[2])
+		return newYearMonth(year, time.Month(month)), month >= 1 && month <= 12
+	}
+	if m := yearOnlyRegex.FindStringSubmatch(s); m != nil {
+		year, _ := strconv.Atoi(m[1])
+		if end {
+			return newYearMonth(year, time.December), true
+		}
+		return newYearMonth(year, time.January), true
+	}
+	return 0, false
+}
+
+type timelineRole struct {
+	Title   string    `json:"title,omitempty"`
+	Company string    `json:"company,omitempty"`
+	Start   yearMonth `json:"start"`
+	End     yearMonth `json:"end"`
+}
+
+type employmentGap struct {
+	From   yearMonth `json:"from"`
+	To     yearMonth `json:"to"`
+	Months int       `json:"months"`
+}
+
+type roleOverlap struct {
+	First  string `json:"first"`
+	Second string `json:"second"`
+	Months int    `json:"months"`
+}
+
+// employmentTimeline is the dated work history of a resume, oldest role first.
+type employmentTimeline struct {
+	Roles []timelineRole `json:"roles"`
+	// Undated lists roles whose dates couldn't be read.
+	Undated  []string        `json:"undated,omitempty"`
+	Gaps     []employmentGap `json:"gaps"`
+	Overlaps []roleOverlap   `json:"overlaps"`
+}
+
+func describeRole(title, company string) string {
+	switch {
+	case title != "" && company != "":
+		return title + " at " + company
+	case title != "":
+		return title
+	case company != "":
+		return company
+	default:
+		return "untitled role"
+	}
+}
+
+// buildTimeline orders the resume's roles by date and finds the gaps and
+// overlaps between them.
+func buildTimeline(sr *structuredResume, now time.Time) *employmentTimeline {
+	tl := &employmentTimeline{Roles: []timelineRole{}, Gaps: []employmentGap{}, Overlaps: []roleOverlap{}}
+	for _, exp := range sr.Experience {
+		start, okStart := normalizeResumeDate(exp.StartDate, false, now)
+		end, okEnd := normalizeResumeDate(exp.EndDate, true, now)
+		if !okStart || !okEnd || end < start {
+			tl.Undated = append(tl.Undated, describeRole(exp.Title, exp.Company))
+			continue
+		}
+		tl.Roles = append(tl.Roles, timelineRole{Title: exp.Title, Company: exp.Company, Start: start, End: end})
+	}
+	slices.SortStableFunc(tl.Roles, func(a, b timelineRole) int { return int(a.Start - b.Start) })
+
+	// Walk the roles keeping track of the latest end date seen so far, so a
+	// long-running role covers any shorter ones inside it.
+	var coveredUntil yearMonth
+	var latest timelineRole
+	for i, role := range tl.Roles {
+		if i > 0 {
+			// A role ending in March and the next starting in April is continuous.
+			if gap := int(role.Start - coveredUntil - 1); gap >= minGapMonths {
+				tl.Gaps = append(tl.Gaps, employmentGap{From: coveredUntil + 1, To: role.Start - 1, Months: gap})
+			}
+			if overlap := int(min(coveredUntil, role.End) - role.Start + 1); overlap >= minOverlapMonths {
+				tl.Overlaps = append(tl.Overlaps, roleOverlap{
+					First:  describeRole(latest.Title, latest.Company),
+					Second: describeRole(role.Title, role.Company),
+					Months: overlap,
+				})
+			}
+		}
+		if i == 0 || role.End > coveredUntil {
+			coveredUntil, latest = role.End, role
+		}
+	}
+	return tl
+}
+
+// promptNotes summarizes the timeline for the model so it doesn't have to
+// work out dates itself.
+func (tl *employmentTimeline) promptNotes() string {
+	if len(tl.Gaps) == 0 && len(tl.Overlaps) == 0 {
+		return "No employment gaps or overlapping roles were found in the resume's work history."
+	}
+	var b strings.Builder
+	for _, g := range tl.Gaps {
+		fmt.Fprintf(&b, "- Employment gap of %d months from %s to %s.\n", g.Months, g.From, g.To)
+	}
+	for _, o := range tl.Overlaps {
+		fmt.Fprintf(&b, "- %s and %s overlap by %d months.\n", o.First, o.Second, o.Months)
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,359 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Deleting an analysis or a resume version moves it to its owner's trash,
+// where it can be restored for trashGracePeriod. After that the worker purges
+// it for good. While in the trash it's hidden everywhere else: history,
+// resume lists, share links and annotations.
+const (
+	trashGracePeriod    = 7 * 24 * time.Hour
+	trashPurgeInterval  = 10 * time.Minute
+	trashPurgeBatchSize = 500
+
+	trashAnalysis = "analysis"
+	trashResume   = "resume"
+)
+
+// trashKey holds an owner's trashed items as "kind:id", scored by when they
+// were deleted.
+func trashKey(owner string) string { return rkey("trash", owner) }
+
+// trashDueKey indexes every trashed item as "kind:id:owner", scored by when
+// it's due to be purged.
+func trashDueKey() string { return rkey("trash", "due") }
+
+// trashItemKeys are where each kind of trashable item is stored.
+var trashItemKeys = map[string]func(id string) string{
+	trashAnalysis: analysisKey,
+	trashResume:   resumeKey,
+}
+
+// A trashItem is one entry in an owner's trash.
+type trashItem struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+func newTrashItem(kind, id, title string, deletedAt time.Time) trashItem {
+	return trashItem{Kind: kind, ID: id, Title: title, DeletedAt: deletedAt, PurgeAt: deletedAt.Add(trashGracePeriod)}
+}
+
+// addToTrash queues the trashing of item on pipe.
+func addToTrash(ctx context.Context, pipe redis.Pipeliner, owner string, item trashItem) {
+	member := item.Kind + ":" + item.ID
+	pipe.ZAdd(ctx, trashKey(owner), redis.Z{Score: float64(item.DeletedAt.UnixMilli()), Member: member})
+	pipe.Expire(ctx, trashKey(owner), analysisRetention)
+	pipe.ZAdd(ctx, trashDueKey(), redis.Z{Score: float64(item.PurgeAt.UnixMilli()), Member: member + ":" + owner})
+}
+
+// removeFromTrash queues taking an item out of the trash on pipe.
+func removeFromTrash(ctx context.Context, pipe redis.Pipeliner, owner, kind, id string) {
+	pipe.ZRem(ctx, trashKey(owner), kind+":"+id)
+	pipe.ZRem(ctx, trashDueKey(), kind+":"+id+":"+owner)
+}
+
+// inTrash reports whether owner has the item in their trash.
+func (app *application) inTrash(ctx context.Context, owner, kind, id string) (bool, error) {
+	err := app.rdb.ZScore(ctx, trashKey(owner), kind+":"+id).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// writeTrashItem replies with item, which has just been deleted.
+func writeTrashItem(w http.ResponseWriter, item trashItem) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// deleteAnalysisHandler moves one of the caller's analyses to the trash.
+func (app *application) deleteAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err := app.rdb.ZScore(ctx, historyKey(owner), id).Err(); errors.Is(err, redis.Nil) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		app.logger.Error("failed to look up analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not delete analysis", http.StatusInternalServerError)
+		return
+	}
+	rec, err := app.loadAnalysis(ctx, id)
+	if errors.Is(err, errAnalysisNotFound) {
+		http.Error(w, "Analysis not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not delete analysis", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	rec.DeletedAt = &now
+	if err := app.updateAnalysis(ctx, rec); err != nil {
+		app.logger.Error("failed to mark analysis deleted", "analysisID", id, "error", err)
+		http.Error(w, "Could not delete analysis", http.StatusInternalServerError)
+		return
+	}
+	item := newTrashItem(trashAnalysis, id, rec.JobTitle, now)
+	pipe := app.rdb.TxPipeline()
+	pipe.ZRem(ctx, historyKey(owner), id)
+	addToTrash(ctx, pipe, owner, item)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to move analysis to trash", "analysisID", id, "error", err)
+		http.Error(w, "Could not delete analysis", http.StatusInternalServerError)
+		return
+	}
+	writeTrashItem(w, item)
+}
+
+// restoreAnalysisHandler puts an analysis back in the caller's history.
+func (app *application) restoreAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Analysis not found in trash", http.StatusNotFound)
+		return
+	}
+	trashed, err := app.inTrash(ctx, owner, trashAnalysis, id)
+	if err != nil {
+		app.logger.Error("failed to read trash", "error", err)
+		http.Error(w, "Could not restore analysis", http.StatusInternalServerError)
+		return
+	}
+	if !trashed {
+		http.Error(w, "Analysis not found in trash", http.StatusNotFound)
+		return
+	}
+	rec, err := app.readAnalysis(ctx, id)
+	if errors.Is(err, errAnalysisNotFound) {
+		// It outlived its retention while in the trash.
+		pipe := app.rdb.TxPipeline()
+		removeFromTrash(ctx, pipe, owner, trashAnalysis, id)
+		pipe.Exec(ctx)
+		http.Error(w, "Analysis not found in trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not restore analysis", http.StatusInternalServerError)
+		return
+	}
+
+	rec.DeletedAt = nil
+	if err := app.updateAnalysis(ctx, rec); err != nil {
+		app.logger.Error("failed to restore analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not restore analysis", http.StatusInternalServerError)
+		return
+	}
+	pipe := app.rdb.TxPipeline()
+	pipe.ZAdd(ctx, historyKey(owner), redis.Z{Score: float64(rec.CreatedAt.UnixMilli()), Member: id})
+	pipe.Expire(ctx, historyKey(owner), analysisRetention)
+	removeFromTrash(ctx, pipe, owner, trashAnalysis, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to restore analysis", "analysisID", id, "error", err)
+		http.Error(w, "Could not restore analysis", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+// deleteResumeHandler moves one of the caller's resume versions to the trash.
+// Analyses made with it are kept.
+func (app *application) deleteResumeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner, ok := app.existingClientID(r)
+	if !ok {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	v, err := app.loadResumeVersion(ctx, owner, r.PathValue("id"))
+	if errors.Is(err, errResumeNotFound) {
+		http.Error(w, "Resume not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load resume", "error", err)
+		http.Error(w, "Could not delete resume", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	v.DeletedAt = &now
+	if err := app.saveResumeVersionData(ctx, v); err != nil {
+		app.logger.Error("failed to mark resume deleted", "resumeID", v.ID, "error", err)
+		http.Error(w, "Could not delete resume", http.StatusInternalServerError)
+		return
+	}
+	preview, _, _ := strings.Cut(strings.TrimSpace(v.Text), "\n")
+	item := newTrashItem(trashResume, v.ID, preview, now)
+	pipe := app.rdb.TxPipeline()
+	pipe.ZRem(ctx, resumesKey(owner), v.ID)
+	// Analyzing the same text again makes a new version rather than
+	// reviving this one.
+	pipe.HDel(ctx, resumeHashesKey(owner), resumeHash(v.Text))
+	addToTrash(ctx, pipe, owner, item)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to move resume to trash", "resumeID", v.ID, "error", err)
+		http.Error(w, "Could not delete resume", http.StatusInternalServerError)
+		return
+	}
+	writeTrashItem(w, item)
+}
+
+// restoreResumeHandler puts a resume version back in the caller's list.
+func (app *application) restoreResumeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	owner, ok := app.existingClientID(r)
+	if !ok || !isULID(id) {
+		http.Error(w, "Resume not found in trash", http.StatusNotFound)
+		return
+	}
+	trashed, err := app.inTrash(ctx, owner, trashResume, id)
+	if err != nil {
+		app.logger.Error("failed to read trash", "error", err)
+		http.Error(w, "Could not restore resume", http.StatusInternalServerError)
+		return
+	}
+	if !trashed {
+		http.Error(w, "Resume not found in trash", http.StatusNotFound)
+		return
+	}
+	v, err := app.readResumeVersion(ctx, id)
+	if errors.Is(err, errResumeNotFound) {
+		pipe := app.rdb.TxPipeline()
+		removeFromTrash(ctx, pipe, owner, trashResume, id)
+		pipe.Exec(ctx)
+		http.Error(w, "Resume not found in trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.logger.Error("failed to load resume", "resumeID", id, "error", err)
+		http.Error(w, "Could not restore resume", http.StatusInternalServerError)
+		return
+	}
+
+	v.DeletedAt = nil
+	if err := app.saveResumeVersionData(ctx, v); err != nil {
+		app.logger.Error("failed to restore resume", "resumeID", id, "error", err)
+		http.Error(w, "Could not restore resume", http.StatusInternalServerError)
+		return
+	}
+	pipe := app.rdb.TxPipeline()
+	pipe.ZAdd(ctx, resumesKey(owner), redis.Z{Score: float64(v.CreatedAt.UnixMilli()), Member: id})
+	pipe.Expire(ctx, resumesKey(owner), analysisRetention)
+	pipe.HSetNX(ctx, resumeHashesKey(owner), resumeHash(v.Text), id)
+	removeFromTrash(ctx, pipe, owner, trashResume, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to restore resume", "resumeID", id, "error", err)
+		http.Error(w, "Could not restore resume", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// listTrashHandler lists what the caller has deleted and can still restore,
+// most recently deleted first.
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	items := []trashItem{}
+	if owner, ok := app.existingClientID(r); ok {
+		entries, err := app.rdb.ZRevRangeWithScores(ctx, trashKey(owner), 0, -1).Result()
+		if err != nil {
+			app.logger.Error("failed to read trash", "error", err)
+			http.Error(w, "Could not list trash", http.StatusInternalServerError)
+			return
+		}
+		for _, e := range entries {
+			kind, id, _ := strings.Cut(e.Member.(string), ":")
+			deletedAt := time.UnixMilli(int64(e.Score)).UTC()
+			var title string
+			switch kind {
+			case trashAnalysis:
+				rec, err := app.readAnalysis(ctx, id)
+				if err != nil {
+					continue
+				}
+				title = rec.JobTitle
+			case trashResume:
+				v, err := app.readResumeVersion(ctx, id)
+				if err != nil {
+					continue
+				}
+				title, _, _ = strings.Cut(strings.TrimSpace(v.Text), "\n")
+			}
+			items = append(items, newTrashItem(kind, id, title, deletedAt))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(items)
+}
+
+// runTrashPurge permanently deletes trashed items once their grace period is
+// over. It runs on the worker's leader.
+func (app *application) runTrashPurge(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.purgeDueTrash(ctx)
+		}
+	}
+}
+
+func (app *application) purgeDueTrash(ctx context.Context) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	due, err := app.rdb.ZRangeByScore(ctx, trashDueKey(), &redis.ZRangeBy{Min: "-inf", Max: now, Count: trashPurgeBatchSize}).Result()
+	if err != nil {
+		app.logger.Error("failed to list trash due for purging", "error", err)
+		return
+	}
+	purged := 0
+	for _, member := range due {
+		parts := strings.SplitN(member, ":", 3)
+		itemKey, known := trashItemKeys[parts[0]]
+		pipe := app.rdb.TxPipeline()
+		if len(parts) == 3 && known {
+			pipe.Del(ctx, itemKey(parts[1]))
+			pipe.ZRem(ctx, trashKey(parts[2]), parts[0]+":"+parts[1])
+		}
+		pipe.ZRem(ctx, trashDueKey(), member)
+		if _, err := pipe.Exec(ctx); err != nil {
+			app.logger.Error("failed to purge trashed item", "item", member, "error", err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		app.logger.Info("purged trash", "items", purged)
+	}
+}
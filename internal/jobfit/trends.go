@@ -0,0 +1,158 @@
+package jobfit
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultTrendMonths = 6
+	maxTrendMonths     = 12
+	trendSkills        = 15
+	familyAll          = "all"
+)
+
+type roleFamilyWords struct {
+	name  string
+	words []string
+}
+
+// roleFamilies maps a family to words that put a job title in it. Titles are
+// checked against the families in order, so "data engineer" lands in data
+// rather than engineering.
+var roleFamilies = []roleFamilyWords{
+	{"data", []string{"data", "analyst", "analytics", "machine learning", "ml", "scientist", "bi"}},
+	{"design", []string{"design", "designer", "ux", "ui", "creative"}},
+	{"product", []string{"product manager", "product owner", "product lead"}},
+	{"engineering", []string{"engineer", "developer", "programmer", "architect", "devops", "sre", "software"}},
+	{"marketing", []string{"marketing", "seo", "content", "growth", "brand"}},
+	{"sales", []string{"sales", "account executive", "account manager", "business development"}},
+	{"operations", []string{"operations", "project manager", "program manager", "coordinator", "administrator"}},
+}
+
+// roleFamily classifies a job title, or returns "other".
+func roleFamily(title string) string {
+	words := " " + strings.Join(searchTerms(title), " ") + " "
+	for _, f := range roleFamilies {
+		for _, w := range f.words {
+			if strings.Contains(words, " "+w+" ") {
+				return f.name
+			}
+		}
+	}
+	return "other"
+}
+
+func familySkillStatsKey(month, family string) string {
+	if family == familyAll {
+		return skillStatsKey(month)
+	}
+	return skillStatsKey(month) + ":" + family
+}
+
+// monthlyAnalysesKey is a hash of analysis counts for the month by family,
+// used to turn skill counts into shares of demand.
+func monthlyAnalysesKey(month string) string { return rkey("stats", "analyses", month) }
+
+type skillTrend struct {
+	Skill  string  `json:"skill"`
+	Counts []int64 `json:"counts"`
+	// Shares are the percentage of the month's analyses asking for the skill.
+	Shares []float64 `json:"shares"`
+	// Change is the difference in share between the first and last month,
+	// in percentage points.
+	Change float64 `json:"change"`
+}
+
+type skillTrends struct {
+	Family string       `json:"family"`
+	Months []string     `json:"months"`
+	Skills []skillTrend `json:"skills"`
+}
+
+// skillTrendsHandler shows how demand for the most requested skills changed
+// over recent months, optionally for one role family. It reads the same
+// count-only counters as the public stats.
+func (app *application) skillTrendsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	family := cmp.Or(r.URL.Query().Get("family"), familyAll)
+	if family != familyAll && family != "other" && !slices.ContainsFunc(roleFamilies, func(f roleFamilyWords) bool { return f.name == family }) {
+		http.Error(w, "Unknown role family", http.StatusBadRequest)
+		return
+	}
+	months := defaultTrendMonths
+	if v := r.URL.Query().Get("months"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > maxTrendMonths {
+			http.Error(w, "months must be between 2 and 12", http.StatusBadRequest)
+			return
+		}
+		months = n
+	}
+
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	trends := skillTrends{Family: family, Skills: []skillTrend{}}
+	for i := months - 1; i >= 0; i-- {
+		trends.Months = append(trends.Months, thisMonth.AddDate(0, -i, 0).Format("2006-01"))
+	}
+
+	// Sum each month's counters into one set to pick the skills to chart.
+	keys := make([]string, len(trends.Months))
+	for i, m := range trends.Months {
+		keys[i] = familySkillStatsKey(m, family)
+	}
+	top, err := app.rdb.ZUnionWithScores(ctx, redis.ZStore{Keys: keys}).Result()
+	if err != nil {
+		app.logger.Error("failed to load skill trends", "error", err)
+		http.Error(w, "Could not load trends", http.StatusInternalServerError)
+		return
+	}
+	top = slices.DeleteFunc(top, func(z redis.Z) bool { return z.Score < minPublicSkillCount })
+	slices.SortFunc(top, func(a, b redis.Z) int { return cmp.Compare(b.Score, a.Score) })
+	top = top[:min(len(top), trendSkills)]
+
+	pipe := app.rdb.Pipeline()
+	totals := make([]*redis.StringCmd, len(trends.Months))
+	for i, m := range trends.Months {
+		totals[i] = pipe.HGet(ctx, monthlyAnalysesKey(m), family)
+	}
+	scores := make([][]*redis.FloatCmd, len(top))
+	for s, z := range top {
+		scores[s] = make([]*redis.FloatCmd, len(keys))
+		for i, key := range keys {
+			scores[s][i] = pipe.ZScore(ctx, key, z.Member.(string))
+		}
+	}
+	// Months and skills with no counts come back as redis.Nil.
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		app.logger.Error("failed to load skill trends", "error", err)
+		http.Error(w, "Could not load trends", http.StatusInternalServerError)
+		return
+	}
+
+	for s, z := range top {
+		t := skillTrend{Skill: z.Member.(string), Counts: make([]int64, len(keys)), Shares: make([]float64, len(keys))}
+		for i := range keys {
+			t.Counts[i] = int64(scores[s][i].Val())
+			if total, _ := totals[i].Int64(); total > 0 {
+				t.Shares[i] = math.Round(float64(t.Counts[i])/float64(total)*1000) / 10
+			}
+		}
+		t.Change = math.Round((t.Shares[len(keys)-1]-t.Shares[0])*10) / 10
+		trends.Skills = append(trends.Skills, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(trends)
+}
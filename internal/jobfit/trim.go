@@ -0,0 +1,179 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// wordsPerPage is roughly how many words fit on a page in a typical
+	// resume layout.
+	wordsPerPage     = 500
+	maxTrimPages     = 3
+	maxTrimInputSize = 50000
+)
+
+type trimRequest struct {
+	Resume         string `json:"resume"`
+	JobDescription string `json:"jobDescription"`
+	// TargetPages is the length to trim to, one page unless set.
+	TargetPages int `json:"targetPages,omitempty"`
+}
+
+// trimCut is one step of a trimming plan. Line is 1-based in the resume as
+// sent.
+type trimCut struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	// Action is "remove" or "shorten", in which case Replacement is the
+	// shorter wording.
+	Action      string `json:"action"`
+	Replacement string `json:"replacement,omitempty"`
+	Reason      string `json:"reason"`
+	WordsSaved  int    `json:"wordsSaved"`
+}
+
+// trimPlan lists cuts least valuable first, so following it from the top
+// removes what matters least to this job. Cuts after the target is reached
+// are still listed in case the estimate runs short.
+type trimPlan struct {
+	ID            string    `json:"id"`
+	Words         int       `json:"words"`
+	Pages         float64   `json:"pages"`
+	TargetPages   int       `json:"targetPages"`
+	WordsToCut    int       `json:"wordsToCut"`
+	Cuts          []trimCut `json:"cuts"`
+	ReachesTarget bool      `json:"reachesTarget"`
+}
+
+// estimatePages is how many pages words take up, to one decimal place.
+func estimatePages(words int) float64 {
+	return math.Round(float64(words)/wordsPerPage*10) / 10
+}
+
+// trimResumeHandler plans how to cut a resume to a target length, choosing
+// what to drop by how little it matters to the job rather than by position.
+// A resume that already fits gets an empty plan without calling the model.
+func (app *application) trimResumeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req trimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		http.Error(w, "A resume and a job description are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Resume) > maxTrimInputSize || len(req.JobDescription) > maxTrimInputSize {
+		http.Error(w, "Resume or job description is too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if req.TargetPages == 0 {
+		req.TargetPages = 1
+	}
+	if req.TargetPages < 1 || req.TargetPages > maxTrimPages {
+		http.Error(w, fmt.Sprintf("targetPages must be between 1 and %d", maxTrimPages), http.StatusBadRequest)
+		return
+	}
+
+	lines := strings.Split(req.Resume, "\n")
+	words := wordCount(req.Resume)
+	plan := trimPlan{
+		ID:          newULID(time.Now()),
+		Words:       words,
+		Pages:       estimatePages(words),
+		TargetPages: req.TargetPages,
+		WordsToCut:  max(words-req.TargetPages*wordsPerPage, 0),
+		Cuts:        []trimCut{},
+	}
+	log := app.logger.With("trimID", plan.ID, "ip", ip)
+
+	if plan.WordsToCut > 0 {
+		if !app.requireConsent(w, r, app.clientID(w, r)) {
+			return
+		}
+		if _, ok := app.chargeQuota(w, r, actionTrim); !ok {
+			return
+		}
+		if err := app.planCuts(ctx, log, &plan, lines, req.JobDescription); err != nil {
+			modelError(w, log, err)
+			return
+		}
+	}
+	saved := 0
+	for _, c := range plan.Cuts {
+		saved += c.WordsSaved
+	}
+	plan.ReachesTarget = saved >= plan.WordsToCut
+	log.Info("planned resume trim", "wordsToCut", plan.WordsToCut, "wordsSaved", saved, "cuts", len(plan.Cuts))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
+
+// planCuts asks the model which of lines to cut for the job and adds them to
+// plan. Savings are counted here rather than trusted from the model, and cuts
+// naming lines that don't exist are dropped.
+func (app *application) planCuts(ctx context.Context, log *slog.Logger, plan *trimPlan, lines []string, jobDescription string) error {
+	var numbered strings.Builder
+	for i, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			fmt.Fprintf(&numbered, "%d: %s\n", i+1, l)
+		}
+	}
+	prompt := fmt.Sprintf(`
+		The resume below is about %d words too long. Plan what to cut, judged by how little each line matters for the job description, not by where it is.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "cuts": a JSON array of objects, least valuable first, with "line" (the line number as given), "action" ("remove" to drop the line, or "shorten"), "replacement" (the shorter wording for "shorten", otherwise an empty string) and "reason" (one sentence on why it matters little for this job).
+		Suggest cuts adding up to at least %d words. Never cut the candidate's name, contact details, job titles, employers or dates; shorten or remove bullets, summary sentences, old or unrelated roles, and skills the job doesn't need. Keep every number in shortened lines.
+
+		**Resume (numbered lines):**
+		---
+		%s
+		---
+		**Job Description:**
+		---
+		%s
+		---
+	`, plan.WordsToCut, plan.WordsToCut, numbered.String(), jobDescription)
+
+	var out struct {
+		Cuts []trimCut `json:"cuts"`
+	}
+	if err := app.generateJSON(ctx, log, prompt, &out); err != nil {
+		return err
+	}
+
+	seen := map[int]bool{}
+	for _, c := range out.Cuts {
+		if c.Line < 1 || c.Line > len(lines) || seen[c.Line] || strings.TrimSpace(lines[c.Line-1]) == "" {
+			continue
+		}
+		c.Text = strings.TrimSpace(lines[c.Line-1])
+		switch c.Action {
+		case "remove":
+			c.Replacement = ""
+			c.WordsSaved = wordCount(c.Text)
+		case "shorten":
+			c.WordsSaved = wordCount(c.Text) - wordCount(c.Replacement)
+		}
+		if c.WordsSaved <= 0 {
+			continue
+		}
+		seen[c.Line] = true
+		plan.Cuts = append(plan.Cuts, c)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package jobfit
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// Crockford's base32 alphabet, as used by the ULID spec.
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a 26-character ULID: a 48-bit millisecond timestamp followed by
+// 80 bits of randomness. IDs sort lexicographically by creation time, which lets
+// Redis keys and history listings order themselves without a separate timestamp.
+func newULID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	rand.Read(b[6:])
+
+	// Encode the 128 bits five at a time, most significant first. The first
+	// character only carries the top 3 bits since 26*5 = 130.
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = ulidAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// isULID reports whether s looks like an ID produced by newULID.
+func isULID(s string) bool {
+	if len(s) != 26 || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z') || c == 'I' || c == 'L' || c == 'O' || c == 'U' {
+			return false
+		}
+	}
+	return true
+}
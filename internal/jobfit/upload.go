@@ -0,0 +1,147 @@
+package jobfit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	maxUploadSize = 5 << 20
+	maxPDFPages   = 10
+	// A character starting further than this share of the font size past
+	// the end of the previous one begins a new word, for PDFs that position
+	// words instead of drawing spaces.
+	pdfWordGap = 0.15
+)
+
+// extractPDFText returns the text of a PDF a line at a time, so headings and
+// bullets stay on their own lines for the parsers that read them.
+func extractPDFText(data []byte) (text string, err error) {
+	// The reader panics on some malformed files.
+	defer func() {
+		if p := recover(); p != nil {
+			text, err = "", fmt.Errorf("read pdf: %v", p)
+		}
+	}()
+	doc, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	// The reader refuses most encrypted files, with an error that depends on
+	// how they were encrypted, and opens the rest; either way they are
+	// turned away.
+	if err != nil && bytes.Contains(data, []byte("/Encrypt")) || err == nil && !doc.Trailer().Key("Encrypt").IsNull() {
+		return "", errEncryptedPDF
+	}
+	if err != nil {
+		return "", fmt.Errorf("read pdf: %w", err)
+	}
+	if doc.NumPage() > maxPDFPages {
+		return "", documentError(fmt.Sprintf("The PDF has %d pages; resumes of at most %d pages can be read.", doc.NumPage(), maxPDFPages))
+	}
+
+	var b strings.Builder
+	for i := 1; i <= doc.NumPage(); i++ {
+		page := doc.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		// Content gives each character with its position; characters on the
+		// same baseline make a line.
+		rows := map[int][]pdf.Text{}
+		for _, t := range page.Content().Text {
+			y := int(math.Round(t.Y))
+			rows[y] = append(rows[y], t)
+		}
+		ys := slices.Sorted(maps.Keys(rows))
+		for _, y := range slices.Backward(ys) {
+			// Lines are drawn left to right, so characters are kept in stream
+			// order; sorting by X goes wrong for fonts without widths, whose
+			// characters all share one position.
+			var line strings.Builder
+			end := math.Inf(-1)
+			for _, t := range rows[y] {
+				if t.W > 0 && t.X-end > t.FontSize*pdfWordGap {
+					line.WriteByte(' ')
+				}
+				line.WriteString(t.S)
+				end = t.X + t.W
+			}
+			if l := strings.Join(strings.Fields(line.String()), " "); l != "" {
+				b.WriteString(l + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+	text = strings.TrimSpace(b.String())
+	if text == "" {
+		return "", documentError("The PDF has no text; it may be a scanned image.")
+	}
+	return text, nil
+}
+
+// decodeAnalysisRequest reads an analysis request from a JSON body or from a
+// multipart/form-data upload. An upload has the resume as a PDF, DOCX, ODT or
+// text file in the "resume" file field and the job description in "jobDescription"; any other
+// options can go in a "request" field as the usual JSON. It returns an error
+// message and status like checkAnalysisRequest.
+func (app *application) decodeAnalysisRequest(w http.ResponseWriter, r *http.Request, req *AnalysisRequest) (string, int) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return "Invalid request body", http.StatusBadRequest
+		}
+		return "", 0
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Sprintf("Uploads are limited to %d MB", maxUploadSize>>20), http.StatusRequestEntityTooLarge
+		}
+		return "Invalid form data", http.StatusBadRequest
+	}
+	if options := r.FormValue("request"); options != "" {
+		if err := json.Unmarshal([]byte(options), req); err != nil {
+			return "Invalid request field", http.StatusBadRequest
+		}
+	}
+	if jd := r.FormValue("jobDescription"); jd != "" {
+		req.JobDescription = jd
+	}
+	file, _, err := r.FormFile("resume")
+	if errors.Is(err, http.ErrMissingFile) {
+		req.Resume = r.FormValue("resume")
+		return "", 0
+	}
+	if err != nil {
+		return "Invalid resume upload", http.StatusBadRequest
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "Invalid resume upload", http.StatusBadRequest
+	}
+	text, err := extractResumeText(data)
+	var docErr documentError
+	switch {
+	case errors.Is(err, errUnsupportedDocument):
+		return "The resume must be a PDF, DOCX, ODT or plain text file", http.StatusUnsupportedMediaType
+	case errors.As(err, &docErr):
+		return "Could not read the resume. " + string(docErr), http.StatusUnprocessableEntity
+	case err != nil:
+		app.logger.Warn("failed to read uploaded resume", "ip", getIPAddress(r), "error", err)
+		return "Could not read the resume. Check that the file isn't damaged and try again.", http.StatusUnprocessableEntity
+	}
+	req.Resume = text
+	return "", 0
+}
@@ -0,0 +1,72 @@
+package jobfit
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testPDF builds a one-page PDF with no text, with extraTrailer added to its
+// trailer dictionary.
+func testPDF(extraTrailer string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, o := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, extraTrailer, xref)
+	return b.Bytes()
+}
+
+func TestDecodeUploadedResume(t *testing.T) {
+	hex32 := "<" + strings.Repeat("ab", 32) + ">"
+	tests := []struct {
+		name       string
+		file       []byte
+		wantStatus int
+		want       string
+	}{
+		{"pdf without text", testPDF(""), http.StatusUnprocessableEntity, "Could not read the resume. The PDF has no text; it may be a scanned image."},
+		{"password-protected pdf", testPDF("/Encrypt << /Filter /Standard /V 1 /R 2 /Length 40 /P -4 /O " + hex32 + " /U " + hex32 + " >> /ID [<0123456789abcdef0123456789abcdef> <0123456789abcdef0123456789abcdef>] "), http.StatusUnprocessableEntity, "Could not read the resume. " + string(errEncryptedPDF)},
+		{"pdf with unsupported encryption", testPDF("/Encrypt << /Filter /Adobe.PubSec /V 5 >> "), http.StatusUnprocessableEntity, "Could not read the resume. " + string(errEncryptedPDF)},
+		{"damaged pdf", []byte("%PDF-1.4\nthis is not the rest of a PDF\n"), http.StatusUnprocessableEntity, "Could not read the resume. Check that the file isn't damaged and try again."},
+		{"damaged docx", []byte("PK\x03\x04 truncated"), http.StatusUnprocessableEntity, "Could not read the resume. Check that the file isn't damaged and try again."},
+		{"image", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), http.StatusUnsupportedMediaType, "The resume must be a PDF, DOCX, ODT or plain text file"},
+	}
+	app := &application{logger: slog.New(slog.DiscardHandler)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("resume", "resume.pdf")
+			fw.Write(tt.file)
+			mw.WriteField("jobDescription", "Go engineer")
+			mw.Close()
+			r := httptest.NewRequest("POST", "/chat", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+
+			var req AnalysisRequest
+			msg, status := app.decodeAnalysisRequest(httptest.NewRecorder(), r, &req)
+			if status != tt.wantStatus || msg != tt.want {
+				t.Errorf("got %d %q, want %d %q", status, msg, tt.wantStatus, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// With DATA_RESIDENCY=eu, resumes are only sent to Gemini through Vertex AI
+// in an EU region, which keeps processing and any data at rest in that
+// region, and only stored in the Redis named by REDIS_ADDR. The Gemini API
+// (GEMINI_API_KEY) makes no such promise, so it is refused.
+const residencyEU = "eu"
+
+// euVertexLocations are the Vertex AI regions inside the EU. London and
+// Zurich are in Europe but not the EU, so they don't count.
+var euVertexLocations = []string{
+	"europe-central2", "europe-north1", "europe-southwest1", "europe-west1", "europe-west3",
+	"europe-west4", "europe-west8", "europe-west9", "europe-west10", "europe-west12",
+}
+
+const (
+	defaultVertexLocation   = "us-central1"
+	defaultEUVertexLocation = "europe-west4"
+	vertexScope             = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// loadResidency reads DATA_RESIDENCY, which is empty or "eu". In EU mode the
+// database has to be named explicitly rather than defaulting to localhost.
+func loadResidency() (string, error) {
+	switch mode := os.Getenv("DATA_RESIDENCY"); mode {
+	case "":
+		return "", nil
+	case residencyEU:
+		if os.Getenv("REDIS_ADDR") == "" {
+			return "", errors.New("DATA_RESIDENCY=eu needs REDIS_ADDR set to the EU database")
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("DATA_RESIDENCY must be empty or eu, got %q", mode)
+	}
+}
+
+// vertexModel calls Gemini through Vertex AI's regional endpoint, with the
+// service account from GOOGLE_APPLICATION_CREDENTIALS or the environment.
+type vertexModel struct {
+	client   *http.Client
+	endpoint string
+	schema   *genai.Schema // the reply's shape, if given
+}
+
+func newVertexModel(client *http.Client, project, location, model string) *vertexModel {
+	endpoint := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent",
+		location, url.PathEscape(project), location, model)
+	return &vertexModel{client: client, endpoint: endpoint}
+}
+
+// vertexResponse is the part of Vertex AI's generateContent reply the app
+// reads.
+type vertexResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+var vertexFinishReasons = map[string]genai.FinishReason{
+	"STOP":       genai.FinishReasonStop,
+	"MAX_TOKENS": genai.FinishReasonMaxTokens,
+	"SAFETY":     genai.FinishReasonSafety,
+	"RECITATION": genai.FinishReasonRecitation,
+}
+
+// GenerateContent sends text parts to the model and returns its reply in the
+// same form as the Gemini API client, so the rest of the app can't tell them
+// apart. Errors from Vertex AI come back as *googleapi.Error.
+func (m *vertexModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	type part struct {
+		Text string `json:"text"`
+	}
+	var texts []part
+	for _, p := range parts {
+		t, ok := p.(genai.Text)
+		if !ok {
+			return nil, fmt.Errorf("vertex: unsupported part %T", p)
+		}
+		texts = append(texts, part{string(t)})
+	}
+	config := map[string]any{"responseMimeType": "application/json"}
+	if m.schema != nil {
+		config["responseSchema"] = vertexSchema(m.schema)
+	}
+	body, err := json.Marshal(map[string]any{
+		"contents":         []any{map[string]any{"role": "user", "parts": texts}},
+		"generationConfig": config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &e)
+		return nil, &googleapi.Error{Code: res.StatusCode, Message: e.Error.Message, Body: string(data)}
+	}
+
+	var vr vertexResponse
+	if err := json.Unmarshal(data, &vr); err != nil {
+		return nil, fmt.Errorf("vertex: %w", err)
+	}
+	if vr.PromptFeedback.BlockReason != "" {
+		return nil, errModelBlocked
+	}
+	resp := &genai.GenerateContentResponse{}
+	for _, c := range vr.Candidates {
+		content := &genai.Content{Role: "model"}
+		for _, p := range c.Content.Parts {
+			content.Parts = append(content.Parts, genai.Text(p.Text))
+		}
+		reason, ok := vertexFinishReasons[c.FinishReason]
+		if !ok && c.FinishReason != "" {
+			reason = genai.FinishReasonOther
+		}
+		resp.Candidates = append(resp.Candidates, &genai.Candidate{Content: content, FinishReason: reason})
+	}
+	return resp, nil
+}
+
+// newVertexKey connects to Vertex AI in VERTEX_LOCATION, which has to be an
+// EU region in EU mode.
+func newVertexKey(ctx context.Context, residency, project string, tiers map[string]string) (*modelKey, error) {
+	location := os.Getenv("VERTEX_LOCATION")
+	if location == "" {
+		location = defaultVertexLocation
+		if residency == residencyEU {
+			location = defaultEUVertexLocation
+		}
+	}
+	if residency == residencyEU && !slices.Contains(euVertexLocations, location) {
+		return nil, fmt.Errorf("VERTEX_LOCATION %q is not an EU region", location)
+	}
+	client, _, err := htransport.NewClient(ctx, option.WithScopes(vertexScope))
+	if err != nil {
+		return nil, err
+	}
+	k := &modelKey{name: "vertex", hint: location, provider: "vertex-ai/" + location, models: map[string]contentGenerator{}}
+	for tier, model := range tiers {
+		k.models[tier] = newVertexModel(client, project, location, model)
+	}
+	return k, nil
+}
+
+// defaultVertexProject finds the project for LLM_PROVIDER=vertex when
+// VERTEX_PROJECT isn't set: GOOGLE_CLOUD_PROJECT, which Cloud Run and most
+// Google tooling set, or else the project of the application default
+// credentials, such as a service account key or a GCE metadata server.
+func defaultVertexProject(ctx context.Context) (string, error) {
+	if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
+		return project, nil
+	}
+	creds, err := google.FindDefaultCredentials(ctx, vertexScope)
+	if err != nil {
+		return "", fmt.Errorf("LLM_PROVIDER=vertex needs VERTEX_PROJECT or application default credentials: %w", err)
+	}
+	if creds.ProjectID == "" {
+		return "", errors.New("LLM_PROVIDER=vertex needs VERTEX_PROJECT: the application default credentials don't name a project")
+	}
+	return creds.ProjectID, nil
+}
@@ -0,0 +1,370 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	webhookPollInterval = 2 * time.Second
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	// A claimed delivery goes back on the queue if the instance that claimed
+	// it hasn't recorded the attempt after webhookClaimTimeout, e.g. because
+	// it crashed mid-send.
+	webhookClaimTimeout = time.Minute
+	// Retries back off exponentially from webhookRetryBase, so a delivery
+	// that never succeeds is given up on within about an hour.
+	webhookMaxAttempts   = 8
+	webhookRetryBase     = 30 * time.Second
+	webhookRetryMax      = time.Hour
+	webhookRetention     = 7 * 24 * time.Hour
+	webhookDeadRetention = 30 * 24 * time.Hour
+	webhookLogSize       = 1000
+)
+
+func webhookPendingKey() string { return rkey("webhook", "pending") }
+func webhookDeadKey() string    { return rkey("webhook", "dead") }
+func webhookLogKey() string     { return rkey("webhook", "log") }
+
+func webhookDeliveryKey(id string) string { return rkey("webhook", "delivery", id) }
+
+// A webhookDelivery is one payload on its way to one URL.
+type webhookDelivery struct {
+	ID      string          `json:"id"`
+	URL     string          `json:"url"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	// Secret is the subscription's signing secret at the time the
+	// delivery was queued.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Attempts  int       `json:"attempts"`
+	// LastStatus and LastError describe the most recent failed attempt.
+	LastStatus int       `json:"lastStatus,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	NextRetry  time.Time `json:"nextRetry,omitzero"`
+	DeadAt     time.Time `json:"deadAt,omitzero"`
+}
+
+// webhookAttempt is a delivery log entry.
+type webhookAttempt struct {
+	DeliveryID string    `json:"deliveryId"`
+	URL        string    `json:"url"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"durationMs"`
+	At         time.Time `json:"at"`
+}
+
+// A webhookDispatcher delivers webhooks from a queue in Redis, retrying
+// failures with exponential backoff. Deliveries that keep failing go to a
+// dead-letter queue that admins can inspect and retry.
+//
+// Each payload is signed with its subscription's secret, sent as
+// X-JobFit-Signature: t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+// Deliveries are stored sealed like resumes, since payloads describe the
+// client's analyses. A delivery is sent at least once; receivers can drop
+// repeats by X-JobFit-Delivery.
+type webhookDispatcher struct {
+	rdb    *redis.Client
+	client *http.Client
+	logger *slog.Logger
+	keys   *keyring
+}
+
+func newWebhookDispatcher(rdb *redis.Client, logger *slog.Logger, keys *keyring) *webhookDispatcher {
+	return &webhookDispatcher{
+		rdb:    rdb,
+		client: newGuardedClient(webhookTimeout),
+		logger: logger,
+		keys:   keys,
+	}
+}
+
+// newWebhookSecret returns a new random signing secret for a subscription.
+func newWebhookSecret() string {
+	return "whsec_" + randomHex(20)
+}
+
+// enqueue queues payload for delivery to url, signed with secret, and
+// returns the delivery ID.
+func (d *webhookDispatcher) enqueue(ctx context.Context, url, secret, event string, payload any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	del := webhookDelivery{ID: newULID(now), URL: url, Event: event, Payload: body, Secret: secret, CreatedAt: now}
+	data, err := json.Marshal(&del)
+	if err != nil {
+		return "", err
+	}
+	pipe := d.rdb.TxPipeline()
+	pipe.Set(ctx, webhookDeliveryKey(del.ID), d.keys.seal(webhookDeliveryKey(del.ID), data), webhookRetention)
+	pipe.ZAdd(ctx, webhookPendingKey(), redis.Z{Score: float64(now.UnixMilli()), Member: del.ID})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return del.ID, nil
+}
+
+// run delivers due webhooks until ctx is cancelled.
+func (d *webhookDispatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.deliverDue(ctx)
+		}
+	}
+}
+
+// claimWebhookScript claims a due delivery by pushing its score past the
+// claim timeout, so two instances never send the same attempt and one that
+// dies mid-attempt doesn't lose it.
+var claimWebhookScript = redis.NewScript(`
+local due = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not due or tonumber(due) > tonumber(ARGV[2]) then return 0 end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+return 1
+`)
+
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	now := time.Now()
+	ids, err := d.rdb.ZRangeByScore(ctx, webhookPendingKey(), &redis.ZRangeBy{
+		Min: "-inf", Max: strconv.FormatInt(now.UnixMilli(), 10), Count: webhookBatchSize,
+	}).Result()
+	if err != nil {
+		d.logger.Error("failed to list due webhooks", "error", err)
+		return
+	}
+	claimUntil := now.Add(webhookClaimTimeout).UnixMilli()
+	for _, id := range ids {
+		claimed, err := claimWebhookScript.Run(ctx, d.rdb, []string{webhookPendingKey()}, id, now.UnixMilli(), claimUntil).Int()
+		if err != nil || claimed == 0 {
+			continue
+		}
+		go d.attempt(ctx, id)
+	}
+}
+
+func (d *webhookDispatcher) loadDelivery(ctx context.Context, id string) (*webhookDelivery, error) {
+	data, err := d.rdb.Get(ctx, webhookDeliveryKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = d.keys.open(webhookDeliveryKey(id), data); err != nil {
+		return nil, err
+	}
+	var del webhookDelivery
+	if err := json.Unmarshal(data, &del); err != nil {
+		return nil, err
+	}
+	return &del, nil
+}
+
+// webhookBackoff is the wait before the given retry, with jitter so failed
+// deliveries to one endpoint don't all come back at once.
+func webhookBackoff(attempts int) time.Duration {
+	wait := min(webhookRetryBase<<(attempts-1), webhookRetryMax)
+	return wait/2 + rand.N(wait/2)
+}
+
+func (d *webhookDispatcher) attempt(ctx context.Context, id string) {
+	log := d.logger.With("deliveryID", id)
+	del, err := d.loadDelivery(ctx, id)
+	if errors.Is(err, redis.Nil) {
+		// It expired; there's nothing left to send.
+		d.rdb.ZRem(ctx, webhookPendingKey(), id)
+		return
+	}
+	if err != nil {
+		// The claim runs out and another attempt picks it up.
+		log.Error("failed to load webhook delivery", "error", err)
+		return
+	}
+	log = log.With("event", del.Event, "url", del.URL)
+
+	start := time.Now()
+	status, sendErr := d.send(ctx, del)
+	del.Attempts++
+	entry := webhookAttempt{
+		DeliveryID: del.ID, URL: del.URL, Event: del.Event, Attempt: del.Attempts,
+		Status: status, DurationMS: time.Since(start).Milliseconds(), At: start.UTC(),
+	}
+	if sendErr != nil {
+		entry.Error = sendErr.Error()
+	}
+
+	pipe := d.rdb.TxPipeline()
+	if logData, err := json.Marshal(&entry); err == nil {
+		pipe.LPush(ctx, webhookLogKey(), logData)
+		pipe.LTrim(ctx, webhookLogKey(), 0, webhookLogSize-1)
+	}
+	switch {
+	case sendErr == nil:
+		log.Info("webhook delivered", "attempts", del.Attempts, "status", status)
+		pipe.ZRem(ctx, webhookPendingKey(), del.ID)
+		pipe.Del(ctx, webhookDeliveryKey(del.ID))
+	case del.Attempts >= webhookMaxAttempts:
+		log.Error("webhook failed permanently", "attempts", del.Attempts, "error", sendErr)
+		del.LastStatus, del.LastError, del.NextRetry, del.DeadAt = status, sendErr.Error(), time.Time{}, time.Now().UTC()
+		d.store(ctx, pipe, del, webhookDeadRetention)
+		pipe.ZRem(ctx, webhookPendingKey(), del.ID)
+		pipe.ZAdd(ctx, webhookDeadKey(), redis.Z{Score: float64(del.DeadAt.UnixMilli()), Member: del.ID})
+	default:
+		del.LastStatus, del.LastError = status, sendErr.Error()
+		del.NextRetry = time.Now().Add(webhookBackoff(del.Attempts)).UTC()
+		log.Warn("webhook failed, will retry", "attempts", del.Attempts, "nextRetry", del.NextRetry, "error", sendErr)
+		d.store(ctx, pipe, del, webhookRetention)
+		pipe.ZAdd(ctx, webhookPendingKey(), redis.Z{Score: float64(del.NextRetry.UnixMilli()), Member: del.ID})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("failed to record webhook attempt", "error", err)
+	}
+}
+
+func (d *webhookDispatcher) store(ctx context.Context, pipe redis.Pipeliner, del *webhookDelivery, ttl time.Duration) {
+	data, err := json.Marshal(del)
+	if err != nil {
+		d.logger.Error("failed to encode webhook delivery", "deliveryID", del.ID, "error", err)
+		return
+	}
+	pipe.Set(ctx, webhookDeliveryKey(del.ID), d.keys.seal(webhookDeliveryKey(del.ID), data), ttl)
+}
+
+// send makes one delivery attempt. Any 2xx response counts as delivered.
+func (d *webhookDispatcher) send(ctx context.Context, del *webhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "JobFitWebhooks/1.0")
+	req.Header.Set("X-JobFit-Event", del.Event)
+	req.Header.Set("X-JobFit-Delivery", del.ID)
+	req.Header.Set("X-JobFit-Signature", "t="+ts+",v1="+signPayload(del.Secret, ts, del.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// adminWebhookLogHandler lists the most recent delivery attempts, newest
+// first.
+func (app *application) adminWebhookLogHandler(w http.ResponseWriter, r *http.Request) {
+	vals, err := app.rdb.LRange(r.Context(), webhookLogKey(), 0, 99).Result()
+	if err != nil {
+		app.logger.Error("failed to load webhook log", "error", err)
+		http.Error(w, "Could not load webhook log", http.StatusInternalServerError)
+		return
+	}
+	list := make([]webhookAttempt, 0, len(vals))
+	for _, v := range vals {
+		var a webhookAttempt
+		if err := json.Unmarshal([]byte(v), &a); err == nil {
+			list = append(list, a)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// adminDeadWebhooksHandler lists deliveries that ran out of retries, newest
+// first.
+func (app *application) adminDeadWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ids, err := app.rdb.ZRevRange(ctx, webhookDeadKey(), 0, 99).Result()
+	if err != nil {
+		app.logger.Error("failed to list dead webhooks", "error", err)
+		http.Error(w, "Could not list dead webhooks", http.StatusInternalServerError)
+		return
+	}
+	list := make([]*webhookDelivery, 0, len(ids))
+	for _, id := range ids {
+		del, err := app.webhooks.loadDelivery(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			app.rdb.ZRem(ctx, webhookDeadKey(), id)
+			continue
+		}
+		if err != nil {
+			app.logger.Error("failed to load dead webhook", "deliveryID", id, "error", err)
+			continue
+		}
+		del.Secret = ""
+		list = append(list, del)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// adminRetryWebhookHandler moves a dead delivery back onto the queue with a
+// fresh set of attempts.
+func (app *application) adminRetryWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	if n, err := app.rdb.ZRem(ctx, webhookDeadKey(), id).Result(); err != nil || n == 0 {
+		http.Error(w, "Dead webhook not found", http.StatusNotFound)
+		return
+	}
+	del, err := app.webhooks.loadDelivery(ctx, id)
+	if err != nil {
+		app.logger.Error("failed to load dead webhook", "deliveryID", id, "error", err)
+		http.Error(w, "Dead webhook not found", http.StatusNotFound)
+		return
+	}
+	del.Attempts, del.DeadAt, del.NextRetry = 0, time.Time{}, time.Time{}
+	pipe := app.rdb.TxPipeline()
+	app.webhooks.store(ctx, pipe, del, webhookRetention)
+	pipe.ZAdd(ctx, webhookPendingKey(), redis.Z{Score: float64(time.Now().UnixMilli()), Member: id})
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("failed to requeue webhook", "deliveryID", id, "error", err)
+		http.Error(w, "Could not retry webhook", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("dead webhook requeued", "deliveryID", id)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (app *application) adminDeleteDeadWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+	n, err := app.rdb.ZRem(ctx, webhookDeadKey(), id).Result()
+	if err != nil {
+		app.logger.Error("failed to delete dead webhook", "deliveryID", id, "error", err)
+		http.Error(w, "Could not delete dead webhook", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Dead webhook not found", http.StatusNotFound)
+		return
+	}
+	app.rdb.Del(ctx, webhookDeliveryKey(id))
+	w.WriteHeader(http.StatusNoContent)
+}
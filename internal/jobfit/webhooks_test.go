@@ -0,0 +1,108 @@
+package jobfit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyWebhook checks a delivery the way a receiver is expected to.
+func verifyWebhook(header, secret string, body []byte, now time.Time) bool {
+	var ts, sig string
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signPayload(secret, ts, body)))
+}
+
+func TestWebhookSendSignsWithSubscriptionSecret(t *testing.T) {
+	var got http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	d := &webhookDispatcher{client: srv.Client(), logger: slog.New(slog.DiscardHandler)}
+	del := &webhookDelivery{ID: "d1", URL: srv.URL, Event: eventWeeklyDigest, Payload: []byte(`{"a":1}`), Secret: newWebhookSecret()}
+	if status, err := d.send(context.Background(), del); err != nil || status != http.StatusOK {
+		t.Fatalf("send = %d, %v", status, err)
+	}
+	if !bytes.Equal(body, del.Payload) {
+		t.Errorf("body = %s, want %s", body, del.Payload)
+	}
+	if got.Get("X-JobFit-Delivery") != "d1" || got.Get("X-JobFit-Event") != eventWeeklyDigest {
+		t.Errorf("headers = %v", got)
+	}
+
+	sig := got.Get("X-JobFit-Signature")
+	if !verifyWebhook(sig, del.Secret, body, time.Now()) {
+		t.Errorf("signature %q doesn't verify with the subscription's secret", sig)
+	}
+	if verifyWebhook(sig, newWebhookSecret(), body, time.Now()) {
+		t.Error("signature verifies with another subscription's secret")
+	}
+	if verifyWebhook(sig, del.Secret, []byte(`{"a":2}`), time.Now()) {
+		t.Error("signature verifies a different body")
+	}
+	if verifyWebhook(sig, del.Secret, body, time.Now().Add(signatureMaxAge+time.Minute)) {
+		t.Error("signature verifies after it expired")
+	}
+}
+
+func TestNewWebhookSecret(t *testing.T) {
+	a, b := newWebhookSecret(), newWebhookSecret()
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+40 {
+		t.Errorf("secret %q isn't whsec_ and 40 hex digits", a)
+	}
+	if a == b {
+		t.Error("two subscriptions got the same secret")
+	}
+}
+
+func TestWebhookDeliveriesAreSealed(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEYS", "k1:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	keys, err := loadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	del := webhookDelivery{ID: "d1", URL: "https://example.com/hook", Payload: []byte(`{"name":"Ada"}`), Secret: newWebhookSecret()}
+	app := &application{keys: keys}
+	data, err := app.marshalSealed(webhookDeliveryKey(del.ID), del)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(del.Secret)) || bytes.Contains(data, []byte("Ada")) {
+		t.Fatalf("stored delivery isn't sealed: %s", data)
+	}
+	var back webhookDelivery
+	if err := app.unmarshalSealed(webhookDeliveryKey(del.ID), data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Secret != del.Secret || string(back.Payload) != string(del.Payload) {
+		t.Errorf("round trip = %+v", back)
+	}
+}
@@ -0,0 +1,126 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	maxWhatIfSkills    = 10
+	maxWhatIfSkillSize = 50
+)
+
+type whatIfRequest struct {
+	Resume         string   `json:"resume"`
+	JobDescription string   `json:"jobDescription"`
+	Skills         []string `json:"skills"`
+}
+
+type whatIfSkill struct {
+	Skill string `json:"skill"`
+	// Impact is how many points the skill alone is worth for this job.
+	Impact int    `json:"impact"`
+	Reason string `json:"reason"`
+}
+
+type whatIfResponse struct {
+	ID             string        `json:"id"`
+	JobTitle       string        `json:"jobTitle,omitempty"`
+	BaselineScore  int           `json:"baselineScore"`
+	SimulatedScore int           `json:"simulatedScore"`
+	Skills         []whatIfSkill `json:"skills"`
+	// RemainingGaps are what the job still needs even with the added skills.
+	RemainingGaps []string `json:"remainingGaps"`
+}
+
+// whatIfHandler re-scores a resume as if the candidate also had the given
+// skills, so users can see which ones are worth learning for a role.
+func (app *application) whatIfHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+
+	var req whatIfRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resume) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		http.Error(w, "A resume and a job description are required", http.StatusBadRequest)
+		return
+	}
+	var skills []string
+	for _, s := range req.Skills {
+		s = strings.Join(strings.Fields(s), " ")
+		if len(s) > maxWhatIfSkillSize {
+			http.Error(w, fmt.Sprintf("Skills must be at most %d characters", maxWhatIfSkillSize), http.StatusBadRequest)
+			return
+		}
+		if s != "" && !slices.ContainsFunc(skills, func(t string) bool { return strings.EqualFold(s, t) }) {
+			skills = append(skills, s)
+		}
+	}
+	if len(skills) == 0 || len(skills) > maxWhatIfSkills {
+		http.Error(w, fmt.Sprintf("Provide between 1 and %d skills", maxWhatIfSkills), http.StatusBadRequest)
+		return
+	}
+
+	if !app.requireConsent(w, r, app.clientID(w, r)) {
+		return
+	}
+	if _, ok := app.chargeQuota(w, r, actionWhatIf); !ok {
+		return
+	}
+
+	simulationID := newULID(time.Now())
+	log := app.logger.With("simulationID", simulationID, "ip", ip)
+	log.Info("received what-if request", "skills", len(skills))
+
+	// Both scores come from the same call so the difference reflects the
+	// skills rather than run-to-run variation in the model.
+	prompt := fmt.Sprintf(`
+		Score the following resume against the job description twice: once as written, and once assuming the candidate also has these skills at a solid working level: %s.
+		Your response MUST be a valid JSON object. Do not include any text or markdown formatting before or after the JSON object.
+		The JSON object must have the following keys and value types:
+		- "jobTitle": a string with the job title taken from the job description.
+		- "baselineScore": an integer between 0 and 100 for the resume as written.
+		- "simulatedScore": an integer between 0 and 100 for the resume with the added skills.
+		- "skills": a JSON array with one object per added skill, with "skill" (the skill as given), "impact" (an integer, the points that skill alone adds, 0 if the job doesn't need it) and "reason" (one sentence).
+		- "remainingGaps": a JSON array of strings naming what the job still needs that the resume would lack even with the added skills.
+
+		**Resume:**
+		---
+		%s
+		---
+		**Job Description:**
+		---
+		%s
+		---
+	`, strings.Join(skills, ", "), req.Resume, req.JobDescription)
+
+	var resp whatIfResponse
+	if err := app.generateJSON(ctx, log, prompt, &resp); err != nil {
+		modelError(w, log, err)
+		return
+	}
+	resp.ID = simulationID
+	resp.BaselineScore = min(max(resp.BaselineScore, 0), 100)
+	// Adding skills can't make a resume a worse fit.
+	resp.SimulatedScore = min(max(resp.SimulatedScore, resp.BaselineScore), 100)
+	if resp.Skills == nil {
+		resp.Skills = []whatIfSkill{}
+	}
+	if resp.RemainingGaps == nil {
+		resp.RemainingGaps = []string{}
+	}
+	log.Info("successfully simulated skills", "baselineScore", resp.BaselineScore, "simulatedScore", resp.SimulatedScore)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,308 @@
+package jobfit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultWidgetDailyLimit is how many analyses one widget key allows per
+	// day across all visitors unless the key says otherwise.
+	defaultWidgetDailyLimit = 200
+	// Each visitor to an embedding site gets this many analyses a day.
+	widgetVisitorDailyLimit = 3
+	maxWidgetInputSize      = 20000
+)
+
+func widgetKeysKey() string { return rkey("widget", "keys") }
+
+var widgetDomainPattern = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,}$`)
+
+var errWidgetKeyNotFound = errors.New("widget key not found")
+
+// A widgetKey is a publishable key that lets one site embed the matcher. It
+// isn't secret: it appears in the site's HTML, so it only works from the
+// domain it was issued for and has its own daily limit.
+type widgetKey struct {
+	Key        string    `json:"key"`
+	Domain     string    `json:"domain"`
+	DailyLimit int64     `json:"dailyLimit"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func widgetUsageKey(key, day string) string { return rkey("widget", "usage", key, day) }
+
+func widgetVisitorKey(key, day, ip string) string { return widgetUsageKey(key, day) + ":" + ip }
+
+// allowsHost reports whether a page on host may use the key: the key's
+// domain itself or any of its subdomains.
+func (k *widgetKey) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == k.Domain || strings.HasSuffix(host, "."+k.Domain)
+}
+
+func (k *widgetKey) frameAncestors() string {
+	return fmt.Sprintf("https://%s https://*.%s", k.Domain, k.Domain)
+}
+
+func (app *application) loadWidgetKey(ctx context.Context, key string) (*widgetKey, error) {
+	if k, ok := app.caches.widgetKeys.get(key); ok {
+		return &k, nil
+	}
+	data, err := app.rdb.HGet(ctx, widgetKeysKey(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, errWidgetKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var k widgetKey
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, err
+	}
+	app.caches.widgetKeys.set(key, k)
+	return &k, nil
+}
+
+// widgetKeyFromRequest loads the key named in the "key" query parameter,
+// writing the error response if it's missing or unknown.
+func (app *application) widgetKeyFromRequest(w http.ResponseWriter, r *http.Request) (*widgetKey, bool) {
+	k, err := app.loadWidgetKey(r.Context(), r.URL.Query().Get("key"))
+	if errors.Is(err, errWidgetKeyNotFound) {
+		http.Error(w, "Unknown widget key", http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil {
+		app.logger.Error("failed to load widget key", "error", err)
+		http.Error(w, "Could not load widget", http.StatusInternalServerError)
+		return nil, false
+	}
+	return k, true
+}
+
+// widgetPageHandler serves the page the embed script puts in an iframe. The
+// Content-Security-Policy stops any site but the key's from framing it.
+func (app *application) widgetPageHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := app.widgetKeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Security-Policy", "frame-ancestors "+k.frameAncestors())
+	http.ServeFileFS(w, r, staticFiles, "widget.html")
+}
+
+// widgetAnalyzeHandler is a minimal analyze API for embedded widgets. Calls
+// must come from the key's domain or from the widget iframe, and count
+// against the key's daily limit rather than the visitor's own quota.
+func (app *application) widgetAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	ip := getIPAddress(r)
+	k, ok := app.widgetKeyFromRequest(w, r)
+	if !ok || !app.allowRegion(w, r) {
+		return
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if origin != app.publicURL(r, "") && (err != nil || !k.allowsHost(u.Hostname())) {
+			http.Error(w, "This widget key is not valid for this site", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+	}
+
+	var req AnalysisRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.readFields(r)
+	if msg, status := checkBasicAnalysisRequest(&req); status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+
+	day := time.Now().UTC().Format("2006-01-02")
+	visitor := app.quota.ipBucket(ip)
+	pipe := app.rdb.TxPipeline()
+	keyCount := pipe.Incr(ctx, widgetUsageKey(k.Key, day))
+	visitorCount := pipe.Incr(ctx, widgetVisitorKey(k.Key, day, visitor))
+	pipe.Expire(ctx, widgetUsageKey(k.Key, day), rateLimitDuration)
+	pipe.Expire(ctx, widgetVisitorKey(k.Key, day, visitor), rateLimitDuration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		app.logger.Error("redis increment failed", "ip", ip, "widgetKey", k.Key, "error", err)
+		http.Error(w, "Could not process request", http.StatusInternalServerError)
+		return
+	}
+	if keyCount.Val() > k.DailyLimit {
+		app.logger.Warn("widget key limit exceeded", "widgetKey", k.Key, "count", keyCount.Val())
+		http.Error(w, "This site has reached its daily limit. Please try again tomorrow.", http.StatusTooManyRequests)
+		return
+	}
+	if visitorCount.Val() > widgetVisitorDailyLimit {
+		http.Error(w, "You have reached today's limit. Please try again tomorrow.", http.StatusTooManyRequests)
+		return
+	}
+
+	analysisID := newULID(time.Now())
+	log := app.logger.With("analysisID", analysisID, "ip", ip, "region", app.region(r), "widgetKey", k.Key)
+	resp, err := app.basicAnalysis(ctx, log, analysisID, sourceWidget, k.Key, &req)
+	if err != nil {
+		modelError(w, log, err)
+		return
+	}
+
+	out, err := selectFields(resp, req.Fields)
+	if err != nil {
+		log.Error("failed to select response fields", "error", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
+
+// checkBasicAnalysisRequest validates the input to basicAnalysis, returning
+// the message and status to reply with if it's unusable.
+func checkBasicAnalysisRequest(req *AnalysisRequest) (string, int) {
+	if strings.TrimSpace(req.Resume) == "" || strings.TrimSpace(req.JobDescription) == "" {
+		return "A resume and a job description are required", http.StatusBadRequest
+	}
+	if f, ok := req.checkFields(); !ok {
+		return fmt.Sprintf("Unknown field %q", f), http.StatusBadRequest
+	}
+	if msg := req.AnalysisOptions.check(); msg != "" {
+		return msg, http.StatusBadRequest
+	}
+	if len(req.Resume) > maxWidgetInputSize || len(req.JobDescription) > maxWidgetInputSize {
+		return "Resume or job description is too long", http.StatusRequestEntityTooLarge
+	}
+	return "", 0
+}
+
+// basicAnalysis scores a resume for callers outside the app, such as the
+// widget and integrations. Nothing is saved to a history, so only the
+// essentials of the analysis are returned. These callers score many resumes
+// against each posting, so the posting's cached requirements are sent rather
+// than the posting itself.
+func (app *application) basicAnalysis(ctx context.Context, log *slog.Logger, id, source, clientID string, req *AnalysisRequest) (*AnalysisResponse, error) {
+	ctx = withModelTier(ctx, req.ModelTier)
+	start := time.Now()
+	app.publishEvent(ctx, eventAnalysisRequested, analysisEvent{AnalysisID: id, Source: source, ClientID: clientID})
+	timeline := buildTimeline(parseResumeRules(req.Resume), start)
+	jobText := req.JobDescription
+	jr, err := app.loadJobRequirements(ctx, log, req.JobDescription)
+	if err != nil {
+		log.Warn("failed to load job requirements, sending the full job description", "error", err)
+	} else {
+		jobText = jr.promptText()
+	}
+	var resp AnalysisResponse
+	if err := app.generateJSON(withResponseSchema(ctx, analysisSchema(req)), log, buildAnalysisPrompt(req, jobText, timeline.promptNotes()), &resp); err != nil {
+		app.publishEvent(ctx, eventAnalysisFailed, analysisEvent{AnalysisID: id, Source: source, ClientID: clientID, Reason: failureReason(err)})
+		return nil, err
+	}
+	resp.ID = id
+	resp.MatchScore = min(max(resp.MatchScore, 0), 100)
+	if jr != nil {
+		resp.JobTitle, resp.Company, resp.RequiredSkills = jr.JobTitle, jr.Company, jr.RequiredSkills
+	}
+	log.Info("successfully parsed analysis", "matchScore", resp.MatchScore)
+	if err := app.recordStats(ctx, &resp, time.Now()); err != nil {
+		log.Error("failed to record stats", "error", err)
+	}
+	app.publishEvent(ctx, eventAnalysisCompleted, completedEvent(source, clientID, &resp, start))
+	return &AnalysisResponse{
+		ID:             resp.ID,
+		JobTitle:       resp.JobTitle,
+		Company:        resp.Company,
+		MatchScore:     resp.MatchScore,
+		Improvements:   resp.Improvements,
+		NextSteps:      resp.NextSteps,
+		RequiredSkills: resp.RequiredSkills,
+	}, nil
+}
+
+func (app *application) adminCreateWidgetKeyHandler(w http.ResponseWriter, r *http.Request) {
+	k := widgetKey{DailyLimit: defaultWidgetDailyLimit}
+	if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	k.Domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(k.Domain)), "www.")
+	if !widgetDomainPattern.MatchString(k.Domain) {
+		http.Error(w, "domain must be a hostname such as careers.example.com", http.StatusBadRequest)
+		return
+	}
+	if k.DailyLimit < 1 {
+		http.Error(w, "dailyLimit must be positive", http.StatusBadRequest)
+		return
+	}
+	k.Key = "pk_" + randomHex(12)
+	k.CreatedAt = time.Now().UTC()
+
+	data, err := json.Marshal(&k)
+	if err == nil {
+		err = app.rdb.HSet(r.Context(), widgetKeysKey(), k.Key, data).Err()
+	}
+	if err != nil {
+		app.logger.Error("failed to create widget key", "domain", k.Domain, "error", err)
+		http.Error(w, "Could not create widget key", http.StatusInternalServerError)
+		return
+	}
+	app.logger.Info("widget key created", "widgetKey", k.Key, "domain", k.Domain)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&k)
+}
+
+func (app *application) adminListWidgetKeysHandler(w http.ResponseWriter, r *http.Request) {
+	vals, err := app.rdb.HVals(r.Context(), widgetKeysKey()).Result()
+	if err != nil {
+		app.logger.Error("failed to list widget keys", "error", err)
+		http.Error(w, "Could not list widget keys", http.StatusInternalServerError)
+		return
+	}
+	list := make([]widgetKey, 0, len(vals))
+	for _, v := range vals {
+		var k widgetKey
+		if err := json.Unmarshal([]byte(v), &k); err != nil {
+			app.logger.Warn("skipping unreadable widget key", "error", err)
+			continue
+		}
+		list = append(list, k)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (app *application) adminDeleteWidgetKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	n, err := app.rdb.HDel(r.Context(), widgetKeysKey(), key).Result()
+	app.caches.widgetKeys.remove(key)
+	if err != nil {
+		app.logger.Error("failed to delete widget key", "widgetKey", key, "error", err)
+		http.Error(w, "Could not delete widget key", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Widget key not found", http.StatusNotFound)
+		return
+	}
+	app.logger.Info("widget key deleted", "widgetKey", key)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,4 +1,11 @@
 document.addEventListener("DOMContentLoaded", () => {
+    // --- CONFIG ---
+    // Rendered into the page by the server: where the API is, which
+    // features are on and the strings for the page's language.
+    const config = window.jobfitConfig || { apiBase: "", features: {}, messages: {} };
+    const t = (key) => config.messages[key] || key;
+    const apiUrl = (path) => config.apiBase + path;
+
     // --- STATE ---
     let storedResume = "";
     let history = []; // Holds the array of analysis results
@@ -29,12 +36,15 @@ document.addEventListener("DOMContentLoaded", () => {
     const nextStepsContent = document.getElementById("next-steps-content");
     const historyList = document.getElementById("history-list");
     const clearHistoryButton = document.getElementById("clear-history-button");
+    const announcementBanner = document.getElementById("announcement-banner");
+    const announcementText = document.getElementById("announcement-text");
+    const announcementDismiss = document.getElementById("announcement-dismiss");
 
     // --- HISTORY FUNCTIONS ---
     const renderHistory = () => {
         historyList.innerHTML = ''; // Clear current list
         if (history.length === 0) {
-            historyList.innerHTML = '<p style="text-align:center; color: #777; font-size: 0.9em; padding: 10px;">No history yet.</p>';
+            historyList.innerHTML = `<p style="text-align:center; color: #777; font-size: 0.9em; padding: 10px;">${t("noHistory")}</p>`;
             return;
         }
         history.forEach((item, index) => {
@@ -62,16 +72,105 @@ document.addEventListener("DOMContentLoaded", () => {
         renderHistory();
     };
     clearHistoryButton.addEventListener('click', () => {
-        if (confirm('Are you sure you want to clear all analysis history?')) {
+        if (confirm(t("confirmClearHistory"))) {
             history = [];
             localStorage.removeItem(HISTORY_KEY);
             renderHistory();
         }
     });
 
+    // --- ANNOUNCEMENTS ---
+    const DISMISSED_KEY = 'dismissedAnnouncements';
+    const ANNOUNCEMENT_POLL_MS = 5 * 60 * 1000;
+    let shownAnnouncementId = null;
+    const getDismissed = () => {
+        try { return JSON.parse(localStorage.getItem(DISMISSED_KEY)) || []; } catch (e) { return []; }
+    };
+    const checkAnnouncements = async () => {
+        try {
+            const response = await fetch(apiUrl("/announcements"));
+            if (!response.ok) return;
+            const announcements = await response.json();
+            const dismissed = getDismissed();
+            const current = announcements.reverse().find(a => !dismissed.includes(a.id));
+            if (!current) {
+                announcementBanner.classList.add("hidden");
+                return;
+            }
+            shownAnnouncementId = current.id;
+            announcementText.textContent = current.message;
+            announcementBanner.className = `level-${current.level}`;
+        } catch (e) { console.error("Could not load announcements", e); }
+    };
+    announcementDismiss.addEventListener("click", () => {
+        const dismissed = getDismissed();
+        dismissed.push(shownAnnouncementId);
+        localStorage.setItem(DISMISSED_KEY, JSON.stringify(dismissed.slice(-20)));
+        announcementBanner.classList.add("hidden");
+        checkAnnouncements();
+    });
+
+    // --- REFERRALS ---
+    // A "?ref=CODE" link is claimed once, then removed from the address bar.
+    const claimReferral = async () => {
+        const params = new URLSearchParams(window.location.search);
+        const code = params.get("ref");
+        if (!code) return;
+        params.delete("ref");
+        const query = params.toString();
+        window.history.replaceState(null, "", window.location.pathname + (query ? `?${query}` : ""));
+        try {
+            await fetch(apiUrl("/referrals/claim"), {
+                method: "POST",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ code }),
+            });
+        } catch (e) { console.error("Could not claim referral", e); }
+    };
+
+    // --- TERMS ---
+    // When the site requires it, an analysis answered with 428 asks the
+    // visitor to accept the terms, then runs again once they have.
+    const showTermsPrompt = () => {
+        dashboardPlaceholder.innerHTML = "";
+        const message = document.createElement("p");
+        message.textContent = t("termsPrompt");
+        dashboardPlaceholder.appendChild(message);
+        if (config.terms.url) {
+            const link = document.createElement("a");
+            link.href = config.terms.url;
+            link.target = "_blank";
+            link.rel = "noopener";
+            link.textContent = t("readTerms");
+            dashboardPlaceholder.appendChild(link);
+        }
+        const accept = document.createElement("button");
+        accept.type = "button";
+        accept.textContent = t("acceptTerms");
+        accept.addEventListener("click", async () => {
+            accept.disabled = true;
+            try {
+                const response = await fetch(apiUrl("/consent"), {
+                    method: "POST",
+                    headers: { "Content-Type": "application/json" },
+                    body: JSON.stringify({ version: config.terms.version }),
+                });
+                if (!response.ok) throw new Error(`HTTP error! status: ${response.status}`);
+                jdForm.requestSubmit();
+            } catch (e) {
+                console.error("Could not record consent", e);
+                accept.disabled = false;
+            }
+        });
+        dashboardPlaceholder.appendChild(accept);
+    };
+
     // --- INITIALIZATION ---
     resumeOverlay.classList.add("visible");
     loadHistory();
+    checkAnnouncements();
+    claimReferral();
+    setInterval(checkAnnouncements, ANNOUNCEMENT_POLL_MS);
 
     // --- CHARACTER COUNTERS ---
     const updateCounter = (textArea, currentEl, counterEl) => {
@@ -131,31 +230,38 @@ document.addEventListener("DOMContentLoaded", () => {
         if (isViewingHistory) return;
         const jobDescriptionText = jdInput.value.trim();
         if (storedResume.trim() === "" || jobDescriptionText === "") {
-            alert("Please provide both your resume and a job description.");
+            alert(t("missingInput"));
             return;
         }
         runButton.disabled = true;
-        runButtonText.textContent = "Analyzing...";
+        runButtonText.textContent = t("analyzing");
         resultsContent.classList.add("hidden");
         dashboardPlaceholder.classList.remove("hidden");
-        dashboardPlaceholder.innerHTML = "<p>Analyzing... this may take a moment.</p>";
+        dashboardPlaceholder.innerHTML = `<p>${t("analyzingPlaceholder")}</p>`;
         try {
-            const response = await fetch("/chat", {
+            const response = await fetch(apiUrl(config.features.quickAnalysis ? "/analyses/quick" : "/chat"), {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
                 body: JSON.stringify({ resume: storedResume, jobDescription: jobDescriptionText }),
             });
             if (!response.ok) {
+                if (response.status === 428 && config.terms) {
+                    showTermsPrompt();
+                    return;
+                }
                  if (response.status === 429) {
                     const errorText = await response.text();
                     dashboardPlaceholder.innerHTML = `<p style="color: #ff9800;">${errorText}</p>`;
-                    runButtonText.textContent = "Limit Reached";
+                    runButtonText.textContent = t("limitReached");
                     return;
                 }
                 const errorText = await response.text();
                 throw new Error(`HTTP error! status: ${response.status}, message: ${errorText}`);
             }
-            const data = await response.json();
+            let data = await response.json();
+            if (config.features.quickAnalysis) {
+                data = await awaitReport(data, response.headers.get("Location"));
+            }
             saveToHistory(data, jobDescriptionText);
             updateDashboard(data);
             document.querySelectorAll('.history-item').forEach(el => el.classList.remove('active'));
@@ -164,15 +270,34 @@ document.addEventListener("DOMContentLoaded", () => {
             }
         } catch (error) {
             console.error("Error fetching analysis:", error);
-            dashboardPlaceholder.innerHTML = `<p style="color: #ff5555;">An error occurred. Please check the console and try again.</p>`;
+            dashboardPlaceholder.classList.remove("hidden");
+            resultsContent.classList.add("hidden");
+            dashboardPlaceholder.innerHTML = `<p style="color: #ff5555;">${t("analysisError")}</p>`;
         } finally {
-            if (runButtonText.textContent !== "Limit Reached") {
+            if (runButtonText.textContent !== t("limitReached")) {
                 runButton.disabled = false;
-                runButtonText.textContent = "Analyze";
+                runButtonText.textContent = t("analyze");
             }
         }
     });
 
+    // awaitReport shows a two-phase analysis's quick score straight away,
+    // then polls until the full report is ready and returns it.
+    const REPORT_POLL_MS = 2000;
+    const awaitReport = async (job, location) => {
+        if (job.quickScore !== undefined) {
+            updateDashboard({ matchScore: job.quickScore, improvements: t("preparingReport"), nextSteps: "" });
+        }
+        for (;;) {
+            await new Promise(resolve => setTimeout(resolve, REPORT_POLL_MS));
+            const response = await fetch(apiUrl(location));
+            if (!response.ok) throw new Error(`HTTP error! status: ${response.status}`);
+            const status = await response.json();
+            if (status.status === "done") return status.result;
+            if (status.status === "failed") throw new Error(status.error);
+        }
+    };
+
     // --- HELPER FUNCTIONS ---
     function markdownToHtml(text) {
         if (!text) return "";
@@ -198,7 +323,9 @@ document.addEventListener("DOMContentLoaded", () => {
         dashboardPlaceholder.classList.add("hidden");
         resultsContent.classList.remove("hidden");
         progressBarFill.style.width = `${data.matchScore}%`;
-        scoreText.textContent = `${data.matchScore}%`;
+        scoreText.textContent = data.scoreConfidence
+            ? `${data.matchScore}% ±${data.scoreConfidence.margin}`
+            : `${data.matchScore}%`;
 
         const improvementsText = Array.isArray(data.improvements) ? data.improvements.join('\n') : data.improvements;
         const nextStepsText = Array.isArray(data.nextSteps) ? data.nextSteps.join('\n') : data.nextSteps;
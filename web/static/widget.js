@@ -0,0 +1,31 @@
+// Embeds the JobFit.ai matcher on another site. Add this script and an
+// element carrying the site's publishable key:
+//
+//   <div data-jobfit-key="pk_..."></div>
+//   <script src="https://jobfit.example/widget.js" async></script>
+(() => {
+    const script = document.currentScript;
+    const origin = new URL(script.src).origin;
+
+    const mount = () => {
+        document.querySelectorAll("[data-jobfit-key]").forEach((el) => {
+            if (el.dataset.jobfitMounted) {
+                return;
+            }
+            el.dataset.jobfitMounted = "true";
+            const frame = document.createElement("iframe");
+            frame.src = `${origin}/widget?key=${encodeURIComponent(el.dataset.jobfitKey)}`;
+            frame.title = "JobFit.ai resume matcher";
+            frame.style.width = "100%";
+            frame.style.height = el.dataset.jobfitHeight || "520px";
+            frame.style.border = "0";
+            el.appendChild(frame);
+        });
+    };
+
+    if (document.readyState === "loading") {
+        document.addEventListener("DOMContentLoaded", mount);
+    } else {
+        mount();
+    }
+})();
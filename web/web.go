@@ -0,0 +1,8 @@
+// Package web holds the web app's page template, translations and static
+// files. They're built into the binary, so a deployment is one file.
+package web
+
+import "embed"
+
+//go:embed static templates locales
+var Files embed.FS